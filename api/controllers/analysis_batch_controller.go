@@ -0,0 +1,97 @@
+// analysis_batch_controller.go
+
+package controllers
+
+import (
+	"errors"
+	"legally/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnalyzeBatch принимает несколько PDF (поле documents) или ZIP-архив и ставит
+// документы в очередь одним пакетом
+func AnalyzeBatch(c *gin.Context) {
+	if _, exists := c.Get("userId"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+			"code":  "AUTH_ERROR",
+		})
+		return
+	}
+
+	batch, serviceErr := services.AnalyzeBatch(c)
+	if serviceErr != nil {
+		c.JSON(serviceErr.Status, gin.H{
+			"error": serviceErr.Message,
+			"code":  serviceErr.CodeOr("BATCH_ERROR"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success":   true,
+		"batchId":   batch.ID.Hex(),
+		"documents": batch.Documents,
+		"timestamp": batch.CreatedAt,
+	})
+}
+
+// GetAnalysisBatch возвращает задачи пакета, общий статус и сводку по портфелю
+func GetAnalysisBatch(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	report, err := services.GetBatchReport(userID.(string), c.Param("id"))
+	if errors.Is(err, services.ErrBatchNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "BATCH_NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка получения пакета",
+			"code":   "BATCH_FETCH_ERROR",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"batch":     report.Batch,
+		"progress":  report.Progress,
+		"jobs":      report.Jobs,
+		"portfolio": report.Summary,
+	})
+}
+
+// CancelAnalysisBatch отменяет незавершённые задачи пакета
+func CancelAnalysisBatch(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	cancelled, err := services.CancelAnalysisBatch(userID.(string), c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrBatchNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "BATCH_NOT_FOUND"})
+		return
+	case errors.Is(err, services.ErrJobFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": "Все документы пакета уже обработаны", "code": "BATCH_FINISHED"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "code": "CANCEL_ERROR"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"cancelled": cancelled,
+	})
+}
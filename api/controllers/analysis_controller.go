@@ -3,12 +3,18 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"io"
+	"legally/models"
 	"legally/services"
 	"legally/utils"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func AnalyzeDocument(c *gin.Context) {
@@ -46,12 +52,12 @@ func AnalyzeDocument(c *gin.Context) {
 	newC := c.Copy()
 	newC.Set("userId", userID)
 
-	// Process the file using AnalyzePDFDocument
+	// Extract text and enqueue the analysis job
 	result, serviceErr := services.AnalyzeDocument(newC)
 	if serviceErr != nil {
 		c.JSON(serviceErr.Status, gin.H{
 			"error": serviceErr.Message,
-			"code":  "ANALYSIS_ERROR",
+			"code":  serviceErr.CodeOr("ANALYSIS_ERROR"),
 		})
 		return
 	}
@@ -66,13 +72,13 @@ func AnalyzeDocument(c *gin.Context) {
 		return
 	}
 
-	// Return successful response with data
-	c.JSON(http.StatusOK, gin.H{
-		"success":      true,
-		"analysis":     analysisResult["analysis"],
-		"documentType": analysisResult["document_type"],
-		"filename":     analysisResult["filename"],
-		"timestamp":    analysisResult["timestamp"],
+	// Return accepted job; the result is available via the job status endpoint
+	c.JSON(http.StatusAccepted, gin.H{
+		"success":   true,
+		"jobId":     analysisResult["job_id"],
+		"status":    analysisResult["status"],
+		"filename":  analysisResult["filename"],
+		"timestamp": analysisResult["timestamp"],
 	})
 }
 
@@ -93,7 +99,35 @@ func GetHistory(c *gin.Context) {
 
 	utils.LogInfo(fmt.Sprintf("Запрос истории для пользователя: %s", userID))
 
-	history, err := services.GetUserHistory(userID.(string))
+	filter := models.HistoryFilter{
+		Severity: models.Severity(c.Query("severity")),
+	}
+	if filter.Severity != "" && !filter.Severity.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Неверный уровень риска, ожидается high, medium или low",
+			"code":  "INVALID_SEVERITY",
+		})
+		return
+	}
+	if err := parseTermsFilter(c, &filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверный фильтр по условиям договора",
+			"code":   "INVALID_TERMS_FILTER",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	if err := parseRiskFilter(c, &filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверный фильтр по оценке риска",
+			"code":   "INVALID_RISK_FILTER",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	history, err := services.GetUserHistory(userID.(string), filter)
 	if err != nil {
 		utils.LogError(fmt.Sprintf("Ошибка получения истории: %v", err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения истории"})
@@ -104,6 +138,74 @@ func GetHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, history)
 }
 
+// parseTermsFilter читает фильтры истории по условиям договора: party,
+// governing_law, currency, min_amount, max_amount, effective_from и
+// effective_to (YYYY-MM-DD), auto_renewal
+func parseTermsFilter(c *gin.Context, filter *models.HistoryFilter) error {
+	filter.Party = strings.TrimSpace(c.Query("party"))
+	filter.GoverningLaw = strings.TrimSpace(c.Query("governing_law"))
+	filter.Currency = strings.ToUpper(strings.TrimSpace(c.Query("currency")))
+
+	for name, target := range map[string]**float64{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		if value := c.Query(name); value != "" {
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s: ожидается число", name)
+			}
+			*target = &amount
+		}
+	}
+
+	for name, target := range map[string]**time.Time{"effective_from": &filter.EffectiveFrom, "effective_to": &filter.EffectiveTo} {
+		if value := c.Query(name); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return fmt.Errorf("%s: ожидается дата YYYY-MM-DD", name)
+			}
+			*target = &date
+		}
+	}
+
+	if value := c.Query("auto_renewal"); value != "" {
+		renewal, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("auto_renewal: ожидается true или false")
+		}
+		filter.AutoRenewal = &renewal
+	}
+	return nil
+}
+
+// parseRiskFilter читает фильтры и порядок истории по оценке риска: min_risk,
+// max_risk (0–100), signing_blocked и sort (date, risk_desc, risk_asc)
+func parseRiskFilter(c *gin.Context, filter *models.HistoryFilter) error {
+	for name, target := range map[string]**int{"min_risk": &filter.MinRisk, "max_risk": &filter.MaxRisk} {
+		if value := c.Query(name); value != "" {
+			score, err := strconv.Atoi(value)
+			if err != nil || score < 0 || score > 100 {
+				return fmt.Errorf("%s: ожидается число от 0 до 100", name)
+			}
+			*target = &score
+		}
+	}
+
+	if value := c.Query("signing_blocked"); value != "" {
+		blocked, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("signing_blocked: ожидается true или false")
+		}
+		filter.SigningBlocked = &blocked
+	}
+
+	switch sort := c.DefaultQuery("sort", models.HistorySortDate); sort {
+	case models.HistorySortDate, models.HistorySortRiskDesc, models.HistorySortRiskAsc:
+		filter.Sort = sort
+	default:
+		return fmt.Errorf("sort: ожидается %s, %s или %s", models.HistorySortDate, models.HistorySortRiskDesc, models.HistorySortRiskAsc)
+	}
+	return nil
+}
+
 func CancelAnalysis(c *gin.Context) {
 	userID, exists := c.Get("userId")
 	if !exists {
@@ -124,6 +226,160 @@ func CancelAnalysis(c *gin.Context) {
 	})
 }
 
+const sseKeepAlive = 15 * time.Second
+
+// StreamAnalysisEvents отдаёт ход анализа как Server-Sent Events
+func StreamAnalysisEvents(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	job, err := services.GetAnalysisJob(userID.(string), c.Param("id"))
+	if errors.Is(err, services.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "JOB_NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "code": "JOB_FETCH_ERROR"})
+		return
+	}
+
+	events, unsubscribe := services.SubscribeAnalysisEvents(job)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	finished := false
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// Финальное событие могло быть пропущено, если клиент не успевал
+				// читать, — тогда результат берётся из сохранённой задачи
+				if !finished {
+					if current, err := services.GetAnalysisJob(userID.(string), job.ID.Hex()); err == nil && current.Status.IsFinal() {
+						final := services.FinalAnalysisEvent(current)
+						c.SSEvent(final.Type, final)
+					}
+				}
+				return false
+			}
+			finished = finished || event.IsFinal()
+			c.SSEvent(event.Type, event)
+			return true
+		case <-ticker.C:
+			// Задача могла завершиться в другом процессе, тогда события сюда не придут
+			if current, err := services.GetAnalysisJob(userID.(string), job.ID.Hex()); err == nil && current.Status.IsFinal() {
+				final := services.FinalAnalysisEvent(current)
+				c.SSEvent(final.Type, final)
+				return false
+			}
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func CancelAnalysisJob(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	err := services.CancelAnalysis(userID.(string), c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "JOB_NOT_FOUND"})
+		return
+	case errors.Is(err, services.ErrJobFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "JOB_FINISHED"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "code": "CANCEL_ERROR"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Анализ успешно отменен",
+	})
+}
+
+const docxContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// DownloadRedline отдаёт проанализированный документ в DOCX с предложенными
+// правками в режиме исправлений; ?refresh=true заново запрашивает правки у модели
+func DownloadRedline(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	refresh, _ := strconv.ParseBool(c.Query("refresh"))
+	data, filename, err := services.GetRedlineDocx(c.Request.Context(), userID.(string), c.Param("id"), refresh)
+	var quotaErr *services.QuotaError
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "ANALYSIS_NOT_FOUND"})
+		return
+	case errors.Is(err, services.ErrRedlineUnavailable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "code": "REDLINE_UNAVAILABLE"})
+		return
+	case errors.As(err, &quotaErr):
+		c.JSON(quotaErr.Status(), gin.H{"error": err.Error(), "code": "QUOTA_EXCEEDED"})
+		return
+	case err != nil:
+		utils.LogError(fmt.Sprintf("Ошибка построения DOCX с правками: %v", err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка построения документа с правками", "code": "REDLINE_ERROR", "detail": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Data(http.StatusOK, docxContentType, data)
+}
+
+// GetAnalysisRisk возвращает оценку риска анализа и матрицу «вероятность ×
+// последствия». signing_allowed — можно ли подписывать документ без
+// согласования по порогу организации.
+func GetAnalysisRisk(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	risk, err := services.GetAnalysisRisk(userID.(string), c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "ANALYSIS_NOT_FOUND"})
+		return
+	case errors.Is(err, services.ErrRiskUnavailable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "code": "RISK_UNAVAILABLE"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "code": "RISK_FETCH_ERROR"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"risk":            risk,
+		"signing_allowed": !risk.SigningBlocked,
+	})
+}
+
 func ClearFileCache(c *gin.Context) {
 	userID, exists := c.Get("userId")
 	if !exists {
@@ -139,6 +395,54 @@ func ClearFileCache(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Файл удален из кэша",
+		"message": "Ваши документы исключены из кэша анализов",
 	})
 }
+
+func GetAnalysisJob(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	job, err := services.GetAnalysisJob(userID.(string), c.Param("id"))
+	if errors.Is(err, services.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+			"code":  "JOB_NOT_FOUND",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка получения задачи",
+			"code":   "JOB_FETCH_ERROR",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	done, total := job.Progress()
+	response := gin.H{
+		"success":  true,
+		"job":      job,
+		"progress": gin.H{"done": done, "total": total},
+	}
+
+	analysis, err := services.GetJobAnalysis(job)
+	if err != nil {
+		utils.LogWarning(err.Error())
+	}
+	if analysis != nil {
+		response["analysis"] = analysis.Analysis
+		response["result"] = analysis.Result
+		response["documentType"] = analysis.Type
+		response["classification"] = analysis.Classification
+		response["cached"] = analysis.Cached
+		response["terms"] = analysis.Terms
+		response["playbook"] = analysis.Playbook
+	}
+
+	c.JSON(http.StatusOK, response)
+}
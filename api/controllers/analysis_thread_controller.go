@@ -0,0 +1,151 @@
+// analysis_thread_controller.go
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"legally/models"
+	"legally/services"
+	"legally/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type chatEvent struct {
+	name string
+	data interface{}
+}
+
+// AskAnalysisQuestion отвечает на вопрос по анализу. По умолчанию ответ
+// передаётся как Server-Sent Events: delta — фрагмент ответа, reset — отбросить
+// полученные фрагменты (поток перезапущен), done — сохранённый ответ и ветка,
+// error — ошибка. С ?stream=false ответ возвращается одним JSON.
+func AskAnalysisQuestion(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req models.AskQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные запроса", "code": "INVALID_REQUEST", "detail": err.Error()})
+		return
+	}
+
+	chat, err := services.PrepareAnalysisQuestion(userID.(string), c.Param("id"), req)
+	var quotaErr *services.QuotaError
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "ANALYSIS_NOT_FOUND"})
+		return
+	case errors.Is(err, services.ErrThreadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "THREAD_NOT_FOUND"})
+		return
+	case errors.Is(err, services.ErrEmptyQuestion), errors.Is(err, services.ErrQuestionTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_QUESTION"})
+		return
+	case errors.Is(err, services.ErrChatUnavailable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "code": "CHAT_UNAVAILABLE"})
+		return
+	case errors.As(err, &quotaErr):
+		c.JSON(quotaErr.Status(), gin.H{"error": err.Error(), "code": "QUOTA_EXCEEDED"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "code": "ANALYSIS_FETCH_ERROR"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if c.Query("stream") == "false" {
+		thread, answer, err := chat.Answer(ctx, nil, nil)
+		if err != nil {
+			utils.LogError(fmt.Sprintf("Ошибка ответа на вопрос: %v", err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка ответа на вопрос", "code": "CHAT_ERROR", "detail": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"success":   true,
+			"thread_id": thread.ID.Hex(),
+			"message":   answer,
+		})
+		return
+	}
+
+	// Ответ готовится в отдельной горутине, чтобы пока модель думает над
+	// первым фрагментом, соединение поддерживалось пингами
+	events := make(chan chatEvent, 64)
+	send := func(name string, data interface{}) {
+		select {
+		case events <- chatEvent{name: name, data: data}:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(events)
+		thread, answer, err := chat.Answer(ctx,
+			func(delta string) { send("delta", gin.H{"text": delta}) },
+			func() { send("reset", gin.H{}) },
+		)
+		if err != nil {
+			utils.LogError(fmt.Sprintf("Ошибка ответа на вопрос: %v", err))
+			send("error", gin.H{"error": "Ошибка ответа на вопрос", "code": "CHAT_ERROR", "detail": err.Error()})
+			return
+		}
+		send("done", gin.H{"thread_id": thread.ID.Hex(), "message": answer})
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.name, event.data)
+			return true
+		case <-ticker.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// GetAnalysisThreads возвращает ветки вопросов по анализу с сообщениями
+func GetAnalysisThreads(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	threads, err := services.GetAnalysisThreads(userID.(string), c.Param("id"))
+	if errors.Is(err, services.ErrAnalysisNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "ANALYSIS_NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения веток вопросов: %v", err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения веток вопросов", "code": "THREADS_FETCH_ERROR"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"threads": threads,
+	})
+}
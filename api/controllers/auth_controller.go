@@ -3,6 +3,7 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"legally/models"
 	"legally/services"
@@ -18,6 +19,10 @@ type RefreshRequest struct {
 	RefreshToken string `json:"refreshToken" binding:"required"`
 }
 
+type UpdateUserRequest struct {
+	Language string `json:"language" binding:"required"`
+}
+
 func Register(c *gin.Context) {
 	var req AuthRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -78,10 +83,42 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"email":     user.Email,
 		"role":      user.Role,
+		"language":  user.Language,
 		"createdAt": user.CreatedAt,
+	}
+	if !user.OrganizationID.IsZero() {
+		response["organizationId"] = user.OrganizationID.Hex()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateUser меняет настройки профиля: язык отчётов по умолчанию
+func UpdateUser(c *gin.Context) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := services.UpdateUserLanguage(c.GetString("userId"), req.Language)
+	switch {
+	case errors.Is(err, services.ErrUnsupportedLanguage):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "UNSUPPORTED_LANGUAGE",
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating user data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"language": req.Language,
 	})
 }
 func Refresh(c *gin.Context) {
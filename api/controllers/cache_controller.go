@@ -0,0 +1,77 @@
+// cache_controller.go
+
+package controllers
+
+import (
+	"fmt"
+	"legally/models"
+	"legally/services"
+	"legally/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAnalysisCacheStats возвращает число записей кэша и попаданий в него
+func GetAnalysisCacheStats(c *gin.Context) {
+	stats, err := services.GetAnalysisCacheStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка получения статистики кэша",
+			"code":   "CACHE_STATS_ERROR",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"stats":   stats,
+	})
+}
+
+// InvalidateAnalysisCache сбрасывает кэш анализов. Фильтр передаётся телом
+// запроса или параметрами kind, model, prompt_template_id, language; без
+// фильтра сбрасывается весь кэш.
+func InvalidateAnalysisCache(c *gin.Context) {
+	req := models.CacheInvalidation{
+		Kind:             models.CacheKind(c.Query("kind")),
+		Model:            c.Query("model"),
+		PromptTemplateID: c.Query("prompt_template_id"),
+		Language:         c.Query("language"),
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "Неверные данные запроса",
+				"code":   "INVALID_REQUEST",
+				"detail": err.Error(),
+			})
+			return
+		}
+	}
+	if req.Kind != "" && req.Kind != models.CacheDocument && req.Kind != models.CachePart {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверный вид записей кэша",
+			"code":   "INVALID_CACHE_KIND",
+			"detail": fmt.Sprintf("допустимые значения: %s, %s", models.CacheDocument, models.CachePart),
+		})
+		return
+	}
+
+	deleted, err := services.InvalidateAnalysisCache(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Ошибка сброса кэша",
+			"code":   "CACHE_INVALIDATE_ERROR",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	utils.LogInfo(fmt.Sprintf("Администратор сбросил кэш анализов: %d записей", deleted))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"deleted": deleted,
+	})
+}
@@ -0,0 +1,81 @@
+// compare_controller.go
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"legally/services"
+	"legally/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompareDocuments сравнивает две редакции документа: загруженные файлы
+// original и revised или анализы из истории original_id и revised_id
+func CompareDocuments(c *gin.Context) {
+	if _, exists := c.Get("userId"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+			"code":  "AUTH_ERROR",
+		})
+		return
+	}
+
+	comparison, serviceErr := services.CompareDocuments(c)
+	if serviceErr != nil {
+		c.JSON(serviceErr.Status, gin.H{
+			"error": serviceErr.Message,
+			"code":  serviceErr.CodeOr("COMPARE_ERROR"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":    true,
+		"comparison": comparison,
+	})
+}
+
+func GetComparison(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	comparison, err := services.GetComparison(userID.(string), c.Param("id"))
+	if errors.Is(err, services.ErrComparisonNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "COMPARISON_NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "code": "COMPARISON_FETCH_ERROR"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"comparison": comparison,
+	})
+}
+
+// GetComparisonHistory возвращает сводки сравнений пользователя; изменения по
+// пунктам отдаёт GetComparison
+func GetComparisonHistory(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	comparisons, err := services.GetUserComparisons(userID.(string))
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения истории сравнений: %v", err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения истории сравнений"})
+		return
+	}
+
+	c.JSON(http.StatusOK, comparisons)
+}
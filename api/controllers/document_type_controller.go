@@ -0,0 +1,124 @@
+// document_type_controller.go
+
+package controllers
+
+import (
+	"errors"
+	"legally/models"
+	"legally/services"
+	"legally/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDocumentTypes возвращает таксономию типов документов
+func GetDocumentTypes(c *gin.Context) {
+	types, err := services.ListDocumentTypes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка получения типов документов",
+			"code":   "DOCUMENT_TYPES_FETCH_ERROR",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"types":   types,
+	})
+}
+
+func CreateDocumentType(c *gin.Context) {
+	var req models.DocumentType
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверные данные запроса",
+			"code":   "INVALID_REQUEST",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	docType, err := services.CreateDocumentType(req)
+	if err != nil {
+		respondDocumentTypeError(c, err)
+		return
+	}
+
+	utils.LogSuccess("Добавлен тип документа: " + docType.Code)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"type":    docType,
+	})
+}
+
+func UpdateDocumentType(c *gin.Context) {
+	var req models.DocumentType
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверные данные запроса",
+			"code":   "INVALID_REQUEST",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	docType, err := services.UpdateDocumentType(c.Param("id"), req)
+	if err != nil {
+		respondDocumentTypeError(c, err)
+		return
+	}
+
+	utils.LogSuccess("Изменён тип документа: " + docType.Code)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"type":    docType,
+	})
+}
+
+func DeleteDocumentType(c *gin.Context) {
+	if err := services.DeleteDocumentType(c.Param("id")); err != nil {
+		respondDocumentTypeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Тип документа удалён",
+	})
+}
+
+func respondDocumentTypeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidDocumentType):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Некорректный тип документа",
+			"code":   "INVALID_DOCUMENT_TYPE",
+			"detail": err.Error(),
+		})
+	case errors.Is(err, services.ErrDocumentTypeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Тип документа не найден",
+			"code":  "DOCUMENT_TYPE_NOT_FOUND",
+		})
+	case errors.Is(err, models.ErrDocumentTypeExists):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Тип документа с таким кодом уже существует",
+			"code":  "DOCUMENT_TYPE_EXISTS",
+		})
+	case errors.Is(err, services.ErrDocumentTypeInUse):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Сначала удалите или перенесите подтипы",
+			"code":  "DOCUMENT_TYPE_IN_USE",
+		})
+	default:
+		utils.LogError("Ошибка сохранения типа документа: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка сохранения типа документа",
+			"code":   "DOCUMENT_TYPE_SAVE_ERROR",
+			"detail": err.Error(),
+		})
+	}
+}
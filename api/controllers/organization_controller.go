@@ -0,0 +1,166 @@
+// organization_controller.go
+
+package controllers
+
+import (
+	"errors"
+	"legally/models"
+	"legally/services"
+	"legally/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetOrganizations(c *gin.Context) {
+	organizations, err := services.ListOrganizations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка получения организаций",
+			"code":   "ORGANIZATIONS_FETCH_ERROR",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"organizations": organizations,
+	})
+}
+
+func GetOrganization(c *gin.Context) {
+	organization, err := services.GetOrganization(c.Param("id"))
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"organization": organization,
+	})
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req models.Organization
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверные данные запроса",
+			"code":   "INVALID_REQUEST",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	organization, err := services.CreateOrganization(req)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.LogSuccess("Добавлена организация: " + organization.Name)
+	c.JSON(http.StatusCreated, gin.H{
+		"success":      true,
+		"organization": organization,
+	})
+}
+
+// UpdateOrganization заменяет название и настройки оценки риска; при смене
+// настроек оценки анализов организации пересчитываются
+func UpdateOrganization(c *gin.Context) {
+	var req models.Organization
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверные данные запроса",
+			"code":   "INVALID_REQUEST",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	organization, rescored, err := services.UpdateOrganization(c.Param("id"), req)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.LogSuccess("Изменена организация: " + organization.Name)
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"organization": organization,
+		"rescored":     rescored,
+	})
+}
+
+// RescoreOrganization пересчитывает оценки риска анализов организации по её
+// текущим настройкам
+func RescoreOrganization(c *gin.Context) {
+	rescored, err := services.RescoreOrganizationAnalyses(c.Param("id"))
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"rescored": rescored,
+	})
+}
+
+// AssignUserOrganization привязывает пользователя к организации или отвязывает
+// его, если organization_id пуст
+func AssignUserOrganization(c *gin.Context) {
+	var req models.AssignOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверные данные запроса",
+			"code":   "INVALID_REQUEST",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	if err := services.AssignUserOrganization(c.Param("id"), req); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"organization_id": req.OrganizationID,
+	})
+}
+
+func respondOrganizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidOrganization), errors.Is(err, services.ErrInvalidRiskPolicy):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Некорректные данные организации",
+			"code":   "INVALID_ORGANIZATION",
+			"detail": err.Error(),
+		})
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Организация не найдена",
+			"code":  "ORGANIZATION_NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Пользователь не найден",
+			"code":  "USER_NOT_FOUND",
+		})
+	case errors.Is(err, models.ErrOrganizationExists):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Организация с таким названием уже существует",
+			"code":  "ORGANIZATION_EXISTS",
+		})
+	default:
+		utils.LogError("Ошибка сохранения организации: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка сохранения организации",
+			"code":   "ORGANIZATION_SAVE_ERROR",
+			"detail": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// playbook_controller.go
+
+package controllers
+
+import (
+	"errors"
+	"legally/models"
+	"legally/services"
+	"legally/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserPlaybooks возвращает действующие наборы правил организации пользователя
+func GetUserPlaybooks(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	playbooks, err := services.ListUserPlaybooks(userID.(string))
+	if err != nil {
+		respondPlaybookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"playbooks": playbooks,
+	})
+}
+
+func GetOrganizationPlaybooks(c *gin.Context) {
+	playbooks, err := services.ListOrganizationPlaybooks(c.Param("id"))
+	if err != nil {
+		respondPlaybookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"playbooks": playbooks,
+	})
+}
+
+func GetPlaybook(c *gin.Context) {
+	playbook, err := services.GetPlaybook(c.Param("id"))
+	if err != nil {
+		respondPlaybookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"playbook": playbook,
+	})
+}
+
+func CreatePlaybook(c *gin.Context) {
+	var req models.Playbook
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверные данные запроса",
+			"code":   "INVALID_REQUEST",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	playbook, err := services.CreatePlaybook(c.Param("id"), req)
+	if err != nil {
+		respondPlaybookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":  true,
+		"playbook": playbook,
+	})
+}
+
+// UpdatePlaybook заменяет набор правил целиком; правила применяются к следующим анализам
+func UpdatePlaybook(c *gin.Context) {
+	var req models.Playbook
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверные данные запроса",
+			"code":   "INVALID_REQUEST",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	playbook, err := services.UpdatePlaybook(c.Param("id"), req)
+	if err != nil {
+		respondPlaybookError(c, err)
+		return
+	}
+
+	utils.LogSuccess("Изменён набор правил: " + playbook.Name)
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"playbook": playbook,
+	})
+}
+
+func DeletePlaybook(c *gin.Context) {
+	if err := services.DeletePlaybook(c.Param("id")); err != nil {
+		respondPlaybookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Набор правил удалён",
+	})
+}
+
+func respondPlaybookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPlaybook):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Некорректный набор правил",
+			"code":   "INVALID_PLAYBOOK",
+			"detail": err.Error(),
+		})
+	case errors.Is(err, services.ErrPlaybookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Набор правил не найден",
+			"code":  "PLAYBOOK_NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Организация не найдена",
+			"code":  "ORGANIZATION_NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Пользователь не найден",
+			"code":  "USER_NOT_FOUND",
+		})
+	default:
+		utils.LogError("Ошибка работы с набором правил: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка сохранения набора правил",
+			"code":   "PLAYBOOK_SAVE_ERROR",
+			"detail": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// prompt_template_controller.go
+
+package controllers
+
+import (
+	"errors"
+	"legally/models"
+	"legally/services"
+	"legally/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPromptTemplates возвращает версии шаблонов промптов. Параметры document_type
+// и language (в том числе пустые — шаблон «для любого») сужают выборку, purpose
+// (analysis или reduce) — назначение шаблона.
+func GetPromptTemplates(c *gin.Context) {
+	var purpose, documentType, language *string
+	if v, ok := c.GetQuery("purpose"); ok {
+		purpose = &v
+	}
+	if v, ok := c.GetQuery("document_type"); ok {
+		documentType = &v
+	}
+	if v, ok := c.GetQuery("language"); ok {
+		language = &v
+	}
+
+	templates, err := services.ListPromptTemplates(purpose, documentType, language)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка получения шаблонов промптов",
+			"code":   "PROMPT_TEMPLATES_FETCH_ERROR",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"templates": templates,
+	})
+}
+
+func GetPromptTemplate(c *gin.Context) {
+	tmpl, err := services.GetPromptTemplate(c.Param("id"))
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"template": tmpl,
+	})
+}
+
+// CreatePromptTemplate сохраняет новую версию шаблона; изменить существующую версию нельзя
+func CreatePromptTemplate(c *gin.Context) {
+	var req models.PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверные данные запроса",
+			"code":   "INVALID_REQUEST",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	tmpl, err := services.CreatePromptTemplate(req, c.GetString("userId"))
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":  true,
+		"template": tmpl,
+	})
+}
+
+func ActivatePromptTemplate(c *gin.Context) {
+	tmpl, err := services.ActivatePromptTemplate(c.Param("id"))
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	utils.LogSuccess("Активирован шаблон промпта " + tmpl.ID.Hex())
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"template": tmpl,
+	})
+}
+
+// RollbackPromptTemplate возвращает предыдущую версию шаблона для типа документа и языка
+func RollbackPromptTemplate(c *gin.Context) {
+	var req models.PromptTemplateKey
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверные данные запроса",
+			"code":   "INVALID_REQUEST",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	tmpl, err := services.RollbackPromptTemplate(req)
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"template": tmpl,
+	})
+}
+
+func DeletePromptTemplate(c *gin.Context) {
+	if err := services.DeletePromptTemplate(c.Param("id")); err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Версия шаблона удалена",
+	})
+}
+
+func respondPromptTemplateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPromptTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Некорректный шаблон промпта",
+			"code":   "INVALID_PROMPT_TEMPLATE",
+			"detail": err.Error(),
+		})
+	case errors.Is(err, services.ErrPromptTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Шаблон промпта не найден",
+			"code":  "PROMPT_TEMPLATE_NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrPromptTemplateActive):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Активную версию шаблона нельзя удалить",
+			"code":  "PROMPT_TEMPLATE_ACTIVE",
+		})
+	case errors.Is(err, services.ErrNoPreviousPrompt):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Нет предыдущей версии шаблона для отката",
+			"code":  "NO_PREVIOUS_PROMPT_TEMPLATE",
+		})
+	default:
+		utils.LogError("Ошибка сохранения шаблона промпта: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка сохранения шаблона промпта",
+			"code":   "PROMPT_TEMPLATE_SAVE_ERROR",
+			"detail": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// usage_controller.go
+
+package controllers
+
+import (
+	"errors"
+	"legally/services"
+	"legally/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUsage возвращает расход и остаток лимитов текущего пользователя за месяц
+// (?month=2024-03, по умолчанию текущий)
+func GetUsage(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+			"code":  "AUTH_ERROR",
+		})
+		return
+	}
+
+	usage, err := services.GetUserUsage(userID.(string), c.Query("month"))
+	if err != nil {
+		respondUsageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"usage":   usage,
+	})
+}
+
+// GetUserUsage — расход пользователя для администратора
+func GetUserUsage(c *gin.Context) {
+	usage, err := services.GetUserUsage(c.Param("id"), c.Query("month"))
+	if err != nil {
+		respondUsageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"usage":   usage,
+	})
+}
+
+// GetOrganizationUsage — расход организации с разбивкой по пользователям
+func GetOrganizationUsage(c *gin.Context) {
+	usage, err := services.GetOrganizationUsage(c.Param("id"), c.Query("month"))
+	if err != nil {
+		respondUsageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"usage":   usage,
+	})
+}
+
+// GetUsageOverview — расход всего сервиса по пользователям, организациям и моделям
+func GetUsageOverview(c *gin.Context) {
+	usage, err := services.GetUsageOverview(c.Query("month"))
+	if err != nil {
+		respondUsageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"usage":   usage,
+	})
+}
+
+func respondUsageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidUsagePeriod):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Неверный месяц",
+			"code":   "INVALID_PERIOD",
+			"detail": err.Error(),
+		})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Пользователь не найден",
+			"code":  "USER_NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Организация не найдена",
+			"code":  "ORGANIZATION_NOT_FOUND",
+		})
+	default:
+		utils.LogError("Ошибка подсчёта расхода: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "Ошибка подсчёта расхода",
+			"code":   "USAGE_FETCH_ERROR",
+			"detail": err.Error(),
+		})
+	}
+}
@@ -43,10 +43,26 @@ func SetupRoutes(router *gin.Engine) {
 	private.Use(middleware.AuthRequired(models.RoleUser))
 	{
 		private.POST("/analyze", controllers.AnalyzeDocument)
+		private.POST("/analyze/batch", controllers.AnalyzeBatch)
 		private.GET("/history", controllers.GetHistory)
+		private.GET("/history/comparisons", controllers.GetComparisonHistory)
+		private.POST("/compare", controllers.CompareDocuments)
+		private.GET("/compare/:id", controllers.GetComparison)
 		private.POST("/logout", controllers.Logout)
 		private.GET("/user", controllers.GetUser)
+		private.PATCH("/user", controllers.UpdateUser)
+		private.GET("/analysis/jobs/:id", controllers.GetAnalysisJob)
+		private.GET("/analysis/batches/:id", controllers.GetAnalysisBatch)
+		private.POST("/analysis/batches/:id/cancel", controllers.CancelAnalysisBatch)
+		private.POST("/analysis/jobs/:id/cancel", controllers.CancelAnalysisJob)
+		private.GET("/analysis/:id/events", controllers.StreamAnalysisEvents)
+		private.GET("/analysis/:id/redline", controllers.DownloadRedline)
+		private.GET("/analysis/:id/risk", controllers.GetAnalysisRisk)
+		private.GET("/analysis/:id/messages", controllers.GetAnalysisThreads)
+		private.POST("/analysis/:id/messages", controllers.AskAnalysisQuestion)
 		private.POST("/analysis/cancel", controllers.CancelAnalysis)
+		private.GET("/playbooks", controllers.GetUserPlaybooks)
+		private.GET("/usage", controllers.GetUsage)
 		private.POST("/cache/clear", controllers.ClearFileCache)
 	}
 
@@ -54,6 +70,36 @@ func SetupRoutes(router *gin.Engine) {
 	admin := router.Group("/api/admin")
 	admin.Use(middleware.AuthRequired(models.RoleAdmin))
 	{
-		// TODO: admin endpoints
+		admin.GET("/document-types", controllers.GetDocumentTypes)
+		admin.POST("/document-types", controllers.CreateDocumentType)
+		admin.PUT("/document-types/:id", controllers.UpdateDocumentType)
+		admin.DELETE("/document-types/:id", controllers.DeleteDocumentType)
+
+		admin.GET("/prompt-templates", controllers.GetPromptTemplates)
+		admin.POST("/prompt-templates", controllers.CreatePromptTemplate)
+		admin.POST("/prompt-templates/rollback", controllers.RollbackPromptTemplate)
+		admin.GET("/prompt-templates/:id", controllers.GetPromptTemplate)
+		admin.POST("/prompt-templates/:id/activate", controllers.ActivatePromptTemplate)
+		admin.DELETE("/prompt-templates/:id", controllers.DeletePromptTemplate)
+
+		admin.GET("/cache/stats", controllers.GetAnalysisCacheStats)
+		admin.DELETE("/cache", controllers.InvalidateAnalysisCache)
+
+		admin.GET("/organizations", controllers.GetOrganizations)
+		admin.POST("/organizations", controllers.CreateOrganization)
+		admin.GET("/organizations/:id", controllers.GetOrganization)
+		admin.PUT("/organizations/:id", controllers.UpdateOrganization)
+		admin.POST("/organizations/:id/rescore", controllers.RescoreOrganization)
+		admin.PUT("/users/:id/organization", controllers.AssignUserOrganization)
+
+		admin.GET("/usage", controllers.GetUsageOverview)
+		admin.GET("/users/:id/usage", controllers.GetUserUsage)
+		admin.GET("/organizations/:id/usage", controllers.GetOrganizationUsage)
+
+		admin.GET("/organizations/:id/playbooks", controllers.GetOrganizationPlaybooks)
+		admin.POST("/organizations/:id/playbooks", controllers.CreatePlaybook)
+		admin.GET("/playbooks/:id", controllers.GetPlaybook)
+		admin.PUT("/playbooks/:id", controllers.UpdatePlaybook)
+		admin.DELETE("/playbooks/:id", controllers.DeletePlaybook)
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/joho/godotenv"
 	"legally/api"
 	"legally/db"
+	"legally/services"
 	"log"
 	"net/http"
 	"os"
@@ -20,11 +21,24 @@ func main() {
 	_ = godotenv.Load()
 	checkEnvVars()
 	db.InitMongo()
+	if err := services.InitLLM(); err != nil {
+		log.Fatal("❌ ERROR: Не удалось настроить LLM-провайдер: ", err)
+	}
+	services.InitAnalysisCache()
+	services.InitAnalysisThreads()
+	services.InitAnalysisIndexes()
+	services.InitAnalysisBatches()
+	services.InitPlaybooks()
+	services.InitPromptTemplates()
+	services.InitUsage()
 
 	if err := os.MkdirAll("./temp", os.ModePerm); err != nil {
 		log.Fatal("❌ ERROR: Не удалось создать временную папку:", err)
 	}
 
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	workers := services.StartAnalysisWorkers(workersCtx)
+
 	router := gin.Default()
 	api.SetupRoutes(router)
 	
@@ -61,11 +75,24 @@ func main() {
 		log.Fatal("❌ Принудительное завершение работы:", err)
 	}
 
+	stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Println("⚠️ Воркеры анализа не успели завершиться, задачи будут продолжены после перезапуска")
+	}
+
 	log.Println("✅ Сервер успешно остановлен")
 }
 
 func checkEnvVars() {
-	required := []string{"MONGO_URI", "OPENROUTER_API_KEY"}
+	// Ключи LLM-провайдера проверяет services.InitLLM: для mock и локальных моделей они не нужны
+	required := []string{"MONGO_URI"}
 	for _, env := range required {
 		if os.Getenv(env) == "" {
 			log.Fatalf("❌ ERROR: Необходимо установить переменную окружения %s", env)
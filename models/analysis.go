@@ -2,12 +2,217 @@
 
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type FindingKind string
+
+const (
+	FindingRisk      FindingKind = "risk"
+	FindingAmbiguity FindingKind = "ambiguity"
+	FindingViolation FindingKind = "violation"
+)
+
+type Severity string
+
+const (
+	SeverityHigh   Severity = "high"
+	SeverityMedium Severity = "medium"
+	SeverityLow    Severity = "low"
+)
+
+func (s Severity) IsValid() bool {
+	return s == SeverityHigh || s == SeverityMedium || s == SeverityLow
+}
 
 type Analysis struct {
-	Filename  string    `bson:"filename" json:"filename"`
-	Type      string    `bson:"type" json:"type"`
-	Analysis  string    `bson:"analysis" json:"analysis"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"-"`
+	Filename string             `bson:"filename" json:"filename"`
+	Type     string             `bson:"type" json:"type"`
+	// Language — язык отчёта (ru, kk, en)
+	Language string `bson:"language,omitempty" json:"language,omitempty"`
+	// Classification — основной тип, подтипы и язык документа; Type дублирует
+	// название основного типа для старых клиентов
+	Classification *DocumentClassification `bson:"classification,omitempty" json:"classification,omitempty"`
+	// PromptTemplate — версия шаблона, по которой построены промпты частей
+	PromptTemplate *PromptTemplateRef `bson:"prompt_template,omitempty" json:"prompt_template,omitempty"`
+	Result         *AnalysisResult    `bson:"result,omitempty" json:"result,omitempty"`
+	// FailedParts — номера частей (с 1), которые не удалось проанализировать
+	FailedParts []int `bson:"failed_parts,omitempty" json:"failed_parts,omitempty"`
+	// Parts — границы частей и модели, которые их анализировали; MergeModel — какая сводила
+	// отчёт (пусто, если части объединены без LLM)
+	Parts      []AnalysisPart `bson:"parts,omitempty" json:"parts,omitempty"`
+	MergeModel string         `bson:"merge_model,omitempty" json:"merge_model,omitempty"`
+	// MergePrompt — версия шаблона, по которой сводился отчёт
+	MergePrompt *PromptTemplateRef `bson:"merge_prompt,omitempty" json:"merge_prompt,omitempty"`
+	// Cached — отчёт целиком взят из кэша, без обращения к модели
+	Cached bool `bson:"cached,omitempty" json:"cached"`
+	// Terms — ключевые условия договора; nil, если извлечь их не удалось
+	Terms *ContractTerms `bson:"terms,omitempty" json:"terms,omitempty"`
+	// Playbook — проверка по правилам организации; пусто, если применимых правил нет
+	Playbook []PlaybookCheck `bson:"playbook,omitempty" json:"playbook,omitempty"`
+	// Redline — предложенные правки для выгрузки в DOCX; строятся при первой выгрузке
+	Redline *Redline `bson:"redline,omitempty" json:"redline,omitempty"`
+	// Risk — числовая оценка риска; у анализов, сделанных до её появления, отсутствует
+	Risk *RiskScore `bson:"risk,omitempty" json:"risk,omitempty"`
+	// OrganizationID — организация пользователя на момент анализа: по её весам
+	// считается Risk
+	OrganizationID primitive.ObjectID `bson:"organization_id,omitempty" json:"-"`
+	// Threads — ветки вопросов по анализу; в БД хранятся отдельно и
+	// подставляются при выдаче истории
+	Threads []ThreadSummary `bson:"-" json:"threads,omitempty"`
+	// Usage — расход токенов и стоимость анализа документа; вопросы и правки по
+	// анализу учитываются отдельно
+	Usage *TokenUsage `bson:"usage,omitempty" json:"usage,omitempty"`
+	// TextRedacted — в Text персональные данные заменены звёздочками по настройкам
+	// организации; длина текста сохранена, поэтому смещения остаются верными
+	TextRedacted bool `bson:"text_redacted,omitempty" json:"text_redacted,omitempty"`
+	// Analysis — markdown-представление результата. Для новых анализов не хранится
+	// и строится из Result при выдаче; в старых записях это исходный ответ модели.
+	Analysis  string    `bson:"analysis,omitempty" json:"analysis"`
 	Text      string    `bson:"text" json:"text"`
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 }
+
+// AnalysisPart — сведения об анализе одной части документа
+type AnalysisPart struct {
+	Index   int    `bson:"index" json:"index"`
+	Heading string `bson:"heading,omitempty" json:"heading,omitempty"`
+	Start   int    `bson:"start" json:"start"`
+	End     int    `bson:"end" json:"end"`
+	Model   string `bson:"model,omitempty" json:"model,omitempty"`
+	Cached  bool   `bson:"cached,omitempty" json:"cached,omitempty"`
+}
+
+// AnalysisResult — структурированный результат анализа документа
+type AnalysisResult struct {
+	Findings        []Finding     `bson:"findings" json:"findings"`
+	Recommendations []string      `bson:"recommendations" json:"recommendations"`
+	DefinedTerms    []DefinedTerm `bson:"defined_terms,omitempty" json:"defined_terms,omitempty"`
+	Conclusion      Conclusion    `bson:"conclusion" json:"conclusion"`
+	// CitationCheck — итог проверки ссылок на нормы по базе законов
+	CitationCheck *CitationCheck `bson:"citation_check,omitempty" json:"citation_check,omitempty"`
+}
+
+type Finding struct {
+	Kind           FindingKind `bson:"kind" json:"kind"`
+	Title          string      `bson:"title" json:"title"`
+	Description    string      `bson:"description" json:"description"`
+	LegalBasis     string      `bson:"legal_basis,omitempty" json:"legal_basis,omitempty"`
+	Severity       Severity    `bson:"severity" json:"severity"`
+	Recommendation string      `bson:"recommendation,omitempty" json:"recommendation,omitempty"`
+	// Likelihood — вероятность того, что риск реализуется; Severity — его последствия
+	Likelihood Severity `bson:"likelihood,omitempty" json:"likelihood,omitempty"`
+	// Parts — номера частей документа (с 1), из которых получен вывод
+	Parts []int `bson:"parts,omitempty" json:"parts,omitempty"`
+	// Citations — фрагменты законов из RAG-базы, на которые опирается вывод
+	Citations []Citation `bson:"citations,omitempty" json:"citations,omitempty"`
+	// References — ссылки на статьи из LegalBasis с результатом их проверки
+	References []LegalReference `bson:"references,omitempty" json:"references,omitempty"`
+	// Source — кто сделал вывод: пусто — модель, FindingSourceRule — правило
+	// детерминированной проверки с ID Rule
+	Source string `bson:"source,omitempty" json:"source,omitempty"`
+	Rule   string `bson:"rule,omitempty" json:"rule,omitempty"`
+	// Span — фрагмент документа, к которому относится вывод правила
+	Span *SourceSpan `bson:"span,omitempty" json:"span,omitempty"`
+}
+
+// FindingSourceRule — вывод сделан детерминированным правилом, а не моделью
+const FindingSourceRule = "rule"
+
+type ReferenceStatus string
+
+const (
+	ReferenceVerified   ReferenceStatus = "verified"
+	ReferenceNotFound   ReferenceStatus = "not_found"
+	ReferenceMismatched ReferenceStatus = "mismatched"
+)
+
+// LegalReference — ссылка на статью (и пункт) нормативного акта, найденная в выводе
+type LegalReference struct {
+	// Text — ссылка в том виде, в каком её написала модель
+	Text      string          `bson:"text" json:"text"`
+	Act       string          `bson:"act" json:"act"`
+	Article   string          `bson:"article" json:"article"`
+	Paragraph string          `bson:"paragraph,omitempty" json:"paragraph,omitempty"`
+	Status    ReferenceStatus `bson:"status" json:"status"`
+	// DocumentID и ResolvedText — документ базы и текст статьи (пункта), по которым
+	// проверялась ссылка
+	DocumentID   *primitive.ObjectID `bson:"document_id,omitempty" json:"document_id,omitempty"`
+	ResolvedText string              `bson:"resolved_text,omitempty" json:"resolved_text,omitempty"`
+	Note         string              `bson:"note,omitempty" json:"note,omitempty"`
+}
+
+// CitationCheck — сводка проверки ссылок; NeedsReview означает, что юристу
+// нужно перепроверить ненайденные или не соответствующие выводу статьи
+type CitationCheck struct {
+	Verified    int  `bson:"verified" json:"verified"`
+	NotFound    int  `bson:"not_found" json:"not_found"`
+	Mismatched  int  `bson:"mismatched" json:"mismatched"`
+	NeedsReview bool `bson:"needs_review" json:"needs_review"`
+}
+
+// Citation — ссылка вывода на фрагмент RAG-документа. ID стабилен для фрагмента
+// и передаётся модели в промпте.
+type Citation struct {
+	ID         string             `bson:"id" json:"id"`
+	DocumentID primitive.ObjectID `bson:"document_id" json:"document_id"`
+	ChunkID    primitive.ObjectID `bson:"chunk_id" json:"chunk_id"`
+	Title      string             `bson:"title" json:"title"`
+	Source     string             `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+// UnmarshalJSON принимает и объект, и строку: модель возвращает только ID цитаты
+func (c *Citation) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &c.ID)
+	}
+	type plain Citation
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// DefinedTerm — термин, определённый в документе; нужен, чтобы понять ссылки из других частей
+type DefinedTerm struct {
+	Term       string `bson:"term" json:"term"`
+	Definition string `bson:"definition" json:"definition"`
+	Part       int    `bson:"part,omitempty" json:"part,omitempty"`
+}
+
+type Conclusion struct {
+	Summary     string   `bson:"summary" json:"summary"`
+	OverallRisk Severity `bson:"overall_risk,omitempty" json:"overall_risk,omitempty"`
+}
+
+// HistoryFilter — условия выборки истории анализов. Поля после Severity
+// отбирают договоры по извлечённым условиям (ContractTerms).
+type HistoryFilter struct {
+	Severity Severity
+	// Party — часть наименования стороны либо её БИН или ИИН
+	Party         string
+	GoverningLaw  string
+	Currency      string
+	MinAmount     *float64
+	MaxAmount     *float64
+	EffectiveFrom *time.Time
+	EffectiveTo   *time.Time
+	AutoRenewal   *bool
+
+	// MinRisk и MaxRisk ограничивают оценку риска (0–100), SigningBlocked отбирает
+	// документы, оценка которых достигла порога подписания
+	MinRisk        *int
+	MaxRisk        *int
+	SigningBlocked *bool
+	// Sort — порядок выдачи: HistorySortDate (по умолчанию), HistorySortRiskDesc
+	// или HistorySortRiskAsc
+	Sort string
+}
+
+const (
+	HistorySortDate     = "date"
+	HistorySortRiskDesc = "risk_desc"
+	HistorySortRiskAsc  = "risk_asc"
+)
@@ -0,0 +1,96 @@
+// analysis_batch.go
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// BatchStatus — общий статус пакета, выводится из статусов его задач
+type BatchStatus string
+
+const (
+	BatchQueued    BatchStatus = "queued"
+	BatchRunning   BatchStatus = "running"
+	BatchCompleted BatchStatus = "completed"
+	// BatchPartial — все задачи завершены, но часть документов не проанализирована
+	BatchPartial BatchStatus = "partial"
+	BatchFailed  BatchStatus = "failed"
+)
+
+// AnalysisBatch — пакет документов, загруженных одним запросом. Каждый документ
+// анализируется отдельной задачей с BatchID пакета.
+type AnalysisBatch struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"-"`
+	Language string             `bson:"language" json:"language"`
+	// Documents — все файлы пакета в порядке загрузки, включая отклонённые
+	Documents []BatchDocument `bson:"documents" json:"documents"`
+	CreatedAt time.Time       `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time       `bson:"updated_at" json:"updated_at"`
+}
+
+// BatchDocument — файл пакета: задача анализа или причина, по которой он отклонён
+type BatchDocument struct {
+	Filename string              `bson:"filename" json:"filename"`
+	JobID    *primitive.ObjectID `bson:"job_id,omitempty" json:"job_id,omitempty"`
+	Error    string              `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// BatchProgress — сводный статус пакета и число документов в каждом статусе
+type BatchProgress struct {
+	Status    BatchStatus `json:"status"`
+	Total     int         `json:"total"`
+	Queued    int         `json:"queued"`
+	Running   int         `json:"running"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+	Cancelled int         `json:"cancelled"`
+	// Rejected — файлы, отклонённые ещё при загрузке
+	Rejected int `json:"rejected"`
+}
+
+// PortfolioSummary — сводка по проанализированным документам пакета
+type PortfolioSummary struct {
+	Documents int `json:"documents"`
+	// ByType — число документов каждого типа по убыванию
+	ByType []PortfolioCount `json:"by_type"`
+	// ByRisk — число документов каждого уровня риска (high, medium, low)
+	ByRisk       []PortfolioCount `json:"by_risk"`
+	AverageRisk  int              `json:"average_risk"`
+	MaxRisk      int              `json:"max_risk"`
+	RiskiestDocs []PortfolioDoc   `json:"riskiest_documents"`
+	// TopFindings — выводы, которые чаще всего встречаются в разных документах
+	TopFindings []PortfolioFinding `json:"top_findings"`
+}
+
+type PortfolioCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type PortfolioDoc struct {
+	AnalysisID primitive.ObjectID `json:"analysis_id"`
+	Filename   string             `json:"filename"`
+	Type       string             `json:"type"`
+	Risk       int                `json:"risk"`
+	Level      Severity           `json:"level"`
+}
+
+type PortfolioFinding struct {
+	Title    string      `json:"title"`
+	Kind     FindingKind `json:"kind"`
+	Severity Severity    `json:"severity"`
+	// Documents — в скольких документах встретился вывод
+	Documents int `json:"documents"`
+}
+
+// BatchReport — состояние пакета для клиента: задачи, общий статус и сводка
+// по уже проанализированным документам
+type BatchReport struct {
+	Batch    *AnalysisBatch    `json:"batch"`
+	Progress BatchProgress     `json:"progress"`
+	Jobs     []AnalysisJob     `json:"jobs"`
+	Summary  *PortfolioSummary `json:"summary"`
+}
@@ -0,0 +1,60 @@
+// analysis_cache.go
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type CacheKind string
+
+const (
+	// CacheDocument — сведённый отчёт по документу целиком
+	CacheDocument CacheKind = "document"
+	// CachePart — результат анализа одной части
+	CachePart CacheKind = "part"
+)
+
+// AnalysisCacheEntry — сохранённый результат анализа. Key — SHA-256 от
+// нормализованного текста и всего, что влияет на ответ модели: модели, версии
+// шаблона промпта, юрисдикции, языка отчёта и типа документа.
+type AnalysisCacheEntry struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key            string             `bson:"key" json:"key"`
+	Kind           CacheKind          `bson:"kind" json:"kind"`
+	TextHash       string             `bson:"text_hash" json:"text_hash"`
+	Model          string             `bson:"model" json:"model"`
+	PromptTemplate *PromptTemplateRef `bson:"prompt_template,omitempty" json:"prompt_template,omitempty"`
+	Jurisdiction   string             `bson:"jurisdiction" json:"jurisdiction"`
+	Language       string             `bson:"language" json:"language"`
+	Result         *AnalysisResult    `bson:"result" json:"result"`
+	// ResultModel — модель, которая фактически дала ответ (с учётом запасных);
+	// для отчёта по документу — модель сведения
+	ResultModel string `bson:"result_model,omitempty" json:"result_model,omitempty"`
+	// Parts — части документа, из которых сведён отчёт, и Terms — условия договора
+	// (только для CacheDocument)
+	Parts []AnalysisPart `bson:"parts,omitempty" json:"parts,omitempty"`
+	Terms *ContractTerms `bson:"terms,omitempty" json:"terms,omitempty"`
+	// UserIDs — пользователи, загружавшие документ: по ним пользователь может
+	// очистить кэш своих документов
+	UserIDs   []primitive.ObjectID `bson:"user_ids" json:"-"`
+	Hits      int                  `bson:"hits" json:"hits"`
+	CreatedAt time.Time            `bson:"created_at" json:"created_at"`
+	ExpiresAt time.Time            `bson:"expires_at" json:"expires_at"`
+}
+
+// CacheInvalidation — фильтр сброса кэша администратором; пустой сбрасывает всё
+type CacheInvalidation struct {
+	Kind             CacheKind `json:"kind"`
+	Model            string    `json:"model"`
+	PromptTemplateID string    `json:"prompt_template_id"`
+	Language         string    `json:"language"`
+}
+
+// CacheStats — сводка по кэшу для администратора
+type CacheStats struct {
+	Documents int64 `json:"documents"`
+	Parts     int64 `json:"parts"`
+	Hits      int64 `json:"hits"`
+}
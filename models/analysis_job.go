@@ -0,0 +1,81 @@
+// analysis_job.go
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// IsFinal сообщает, что задача больше не будет выполняться
+func (s JobStatus) IsFinal() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// AnalysisJob — задача на анализ документа, которую выполняет пул воркеров
+type AnalysisJob struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Filename     string             `bson:"filename" json:"filename"`
+	Text         string             `bson:"text" json:"-"`
+	Status       JobStatus          `bson:"status" json:"status"`
+	DocumentType string             `bson:"document_type,omitempty" json:"document_type,omitempty"`
+	Language     string             `bson:"language" json:"language"`
+	// OrganizationID — организация пользователя на момент постановки в очередь:
+	// на её лимиты засчитывается анализ
+	OrganizationID primitive.ObjectID `bson:"organization_id,omitempty" json:"-"`
+	// BatchID — пакет, в составе которого загружен документ
+	BatchID *primitive.ObjectID `bson:"batch_id,omitempty" json:"batch_id,omitempty"`
+	// Classification определяется до анализа частей и переживает перезапуск задачи
+	Classification *DocumentClassification `bson:"classification,omitempty" json:"classification,omitempty"`
+	// Prompt — версия шаблона промпта, которой анализируются части
+	Prompt          *PromptTemplateRef  `bson:"prompt_template,omitempty" json:"prompt_template,omitempty"`
+	Parts           []JobPart           `bson:"parts" json:"parts"`
+	Error           string              `bson:"error,omitempty" json:"error,omitempty"`
+	AnalysisID      *primitive.ObjectID `bson:"analysis_id,omitempty" json:"analysis_id,omitempty"`
+	Attempts        int                 `bson:"attempts" json:"attempts"`
+	CancelRequested bool                `bson:"cancel_requested,omitempty" json:"cancel_requested,omitempty"`
+	CreatedAt       time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time           `bson:"updated_at" json:"updated_at"`
+	StartedAt       *time.Time          `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt      *time.Time          `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// JobPart — состояние анализа одной части документа
+type JobPart struct {
+	Index  int       `bson:"index" json:"index"`
+	Status JobStatus `bson:"status" json:"status"`
+	// Heading, Start, End — заголовок, с которого начинается часть, и её границы
+	// в символах исходного текста
+	Heading string          `bson:"heading,omitempty" json:"heading,omitempty"`
+	Start   int             `bson:"start" json:"start"`
+	End     int             `bson:"end" json:"end"`
+	Result  *AnalysisResult `bson:"result,omitempty" json:"-"`
+	Error   string          `bson:"error,omitempty" json:"error,omitempty"`
+	// Model — модель, которая дала ответ по части (с учётом запасных)
+	Model string `bson:"model,omitempty" json:"model,omitempty"`
+	// Cached — результат части взят из кэша
+	Cached     bool       `bson:"cached,omitempty" json:"cached,omitempty"`
+	FinishedAt *time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// Progress возвращает количество завершённых частей и общее их число
+func (j *AnalysisJob) Progress() (int, int) {
+	done := 0
+	for _, p := range j.Parts {
+		if p.Status == JobSucceeded {
+			done++
+		}
+	}
+	return done, len(j.Parts)
+}
@@ -0,0 +1,53 @@
+// analysis_thread.go
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type ThreadRole string
+
+const (
+	ThreadRoleUser      ThreadRole = "user"
+	ThreadRoleAssistant ThreadRole = "assistant"
+)
+
+// AnalysisThread — переписка пользователя по одному анализу: вопросы по
+// документу и ответы модели. У анализа может быть несколько веток.
+type AnalysisThread struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AnalysisID primitive.ObjectID `bson:"analysis_id" json:"analysis_id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	// Title — первый вопрос ветки, сокращённый для списка
+	Title     string          `bson:"title" json:"title"`
+	Messages  []ThreadMessage `bson:"messages" json:"messages"`
+	CreatedAt time.Time       `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time       `bson:"updated_at" json:"updated_at"`
+}
+
+type ThreadMessage struct {
+	ID      primitive.ObjectID `bson:"_id" json:"id"`
+	Role    ThreadRole         `bson:"role" json:"role"`
+	Content string             `bson:"content" json:"content"`
+	// Citations — фрагменты законов из RAG-базы, на которые сослался ответ
+	Citations []Citation `bson:"citations,omitempty" json:"citations,omitempty"`
+	// Model — модель, которая сгенерировала ответ
+	Model     string    `bson:"model,omitempty" json:"model,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// ThreadSummary — ветка без сообщений для списка в истории анализов
+type ThreadSummary struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Title     string             `bson:"title" json:"title"`
+	Messages  int                `bson:"messages" json:"messages"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// AskQuestionRequest — вопрос по анализу; без ThreadID начинается новая ветка
+type AskQuestionRequest struct {
+	ThreadID string `json:"thread_id"`
+	Content  string `json:"content" binding:"required"`
+}
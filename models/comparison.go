@@ -0,0 +1,107 @@
+// comparison.go
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type ClauseChangeType string
+
+const (
+	ClauseAdded    ClauseChangeType = "added"
+	ClauseRemoved  ClauseChangeType = "removed"
+	ClauseModified ClauseChangeType = "modified"
+)
+
+type RiskChange string
+
+const (
+	RiskIncreased RiskChange = "increased"
+	RiskDecreased RiskChange = "decreased"
+	RiskUnchanged RiskChange = "unchanged"
+)
+
+func (r RiskChange) IsValid() bool {
+	return r == RiskIncreased || r == RiskDecreased || r == RiskUnchanged
+}
+
+// Comparison — сравнение двух редакций документа по пунктам
+type Comparison struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"-"`
+	Original ComparedDocument   `bson:"original" json:"original"`
+	Revised  ComparedDocument   `bson:"revised" json:"revised"`
+	// Language — язык пояснений к изменению риска
+	Language string `bson:"language" json:"language"`
+	// Changes — добавленные, удалённые и изменённые пункты в порядке новой редакции;
+	// неизменённые пункты не хранятся, их число есть в Summary
+	Changes   []ClauseChange    `bson:"changes" json:"changes"`
+	Summary   ComparisonSummary `bson:"summary" json:"summary"`
+	CreatedAt time.Time         `bson:"created_at" json:"created_at"`
+}
+
+// ComparedDocument — одна из сравниваемых редакций: загруженный файл или
+// документ из ранее выполненного анализа
+type ComparedDocument struct {
+	Filename   string              `bson:"filename" json:"filename"`
+	AnalysisID *primitive.ObjectID `bson:"analysis_id,omitempty" json:"analysis_id,omitempty"`
+	Clauses    int                 `bson:"clauses" json:"clauses"`
+}
+
+type ClauseChange struct {
+	Type     ClauseChangeType `bson:"type" json:"type"`
+	Original *ClauseVersion   `bson:"original,omitempty" json:"original,omitempty"`
+	Revised  *ClauseVersion   `bson:"revised,omitempty" json:"revised,omitempty"`
+	// Similarity — сходство редакций изменённого пункта по словам, от 0 до 1
+	Similarity float64 `bson:"similarity,omitempty" json:"similarity,omitempty"`
+	// Diff — пословные различия изменённого пункта
+	Diff []DiffSegment `bson:"diff,omitempty" json:"diff,omitempty"`
+	// Risk — оценка моделью, как изменение повлияло на риск; nil, если оценки нет
+	Risk *ClauseRisk `bson:"risk,omitempty" json:"risk,omitempty"`
+}
+
+// ClauseVersion — пункт в одной из редакций; смещения в символах текста редакции
+type ClauseVersion struct {
+	Number  string `bson:"number,omitempty" json:"number,omitempty"`
+	Heading string `bson:"heading,omitempty" json:"heading,omitempty"`
+	Start   int    `bson:"start" json:"start"`
+	End     int    `bson:"end" json:"end"`
+	Text    string `bson:"text" json:"text"`
+}
+
+// DiffSegment — фрагмент пословного сравнения: op равен equal, insert или delete
+type DiffSegment struct {
+	Op   string `bson:"op" json:"op"`
+	Text string `bson:"text" json:"text"`
+}
+
+type ClauseRisk struct {
+	Change RiskChange `bson:"change" json:"change"`
+	// Severity — насколько существенно изменение риска
+	Severity    Severity `bson:"severity,omitempty" json:"severity,omitempty"`
+	Explanation string   `bson:"explanation" json:"explanation"`
+	Model       string   `bson:"model,omitempty" json:"model,omitempty"`
+}
+
+type ComparisonSummary struct {
+	Added     int `bson:"added" json:"added"`
+	Removed   int `bson:"removed" json:"removed"`
+	Modified  int `bson:"modified" json:"modified"`
+	Unchanged int `bson:"unchanged" json:"unchanged"`
+	// RiskIncreased и RiskDecreased — число изменённых пунктов, в которых риск
+	// вырос или снизился; Unassessed — оставшихся без оценки
+	RiskIncreased int `bson:"risk_increased" json:"risk_increased"`
+	RiskDecreased int `bson:"risk_decreased" json:"risk_decreased"`
+	Unassessed    int `bson:"unassessed" json:"unassessed"`
+	// RiskChange — итоговое изменение риска с учётом существенности изменений
+	RiskChange RiskChange `bson:"risk_change" json:"risk_change"`
+}
+
+// CompareRequest — редакции, заданные ID анализов из истории
+type CompareRequest struct {
+	OriginalID string `json:"original_id" form:"original_id"`
+	RevisedID  string `json:"revised_id" form:"revised_id"`
+	Language   string `json:"language" form:"language"`
+}
@@ -0,0 +1,76 @@
+// contract_terms.go
+
+package models
+
+import "time"
+
+// ContractTerms — коммерческие условия договора, извлечённые из текста. У каждого
+// поля есть Source — место в тексте документа, откуда оно взято; Source пуст,
+// если цитату модели не удалось найти в тексте.
+type ContractTerms struct {
+	Parties            []ContractParty `bson:"parties,omitempty" json:"parties,omitempty"`
+	EffectiveDate      *DateTerm       `bson:"effective_date,omitempty" json:"effective_date,omitempty"`
+	Term               *ContractTerm   `bson:"term,omitempty" json:"term,omitempty"`
+	Price              *PriceTerm      `bson:"price,omitempty" json:"price,omitempty"`
+	PaymentTerms       *PaymentTerm    `bson:"payment_terms,omitempty" json:"payment_terms,omitempty"`
+	Penalties          []TextTerm      `bson:"penalties,omitempty" json:"penalties,omitempty"`
+	TerminationGrounds []TextTerm      `bson:"termination_grounds,omitempty" json:"termination_grounds,omitempty"`
+	GoverningLaw       *TextTerm       `bson:"governing_law,omitempty" json:"governing_law,omitempty"`
+	DisputeForum       *TextTerm       `bson:"dispute_forum,omitempty" json:"dispute_forum,omitempty"`
+	// Model — модель, извлёкшая условия
+	Model string `bson:"model,omitempty" json:"model,omitempty"`
+}
+
+// SourceSpan — фрагмент текста документа; смещения в символах, как у частей анализа
+type SourceSpan struct {
+	Start int    `bson:"start" json:"start"`
+	End   int    `bson:"end" json:"end"`
+	Quote string `bson:"quote" json:"quote"`
+}
+
+// ContractParty — сторона договора. BIN — для юридических лиц, IIN — для
+// физических лиц и ИП; AuthorityBasis — основание полномочий подписанта
+// (устав, доверенность и т. п.).
+type ContractParty struct {
+	Name           string      `bson:"name" json:"name"`
+	Role           string      `bson:"role,omitempty" json:"role,omitempty"`
+	BIN            string      `bson:"bin,omitempty" json:"bin,omitempty"`
+	IIN            string      `bson:"iin,omitempty" json:"iin,omitempty"`
+	Signatory      string      `bson:"signatory,omitempty" json:"signatory,omitempty"`
+	AuthorityBasis string      `bson:"authority_basis,omitempty" json:"authority_basis,omitempty"`
+	Source         *SourceSpan `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+type DateTerm struct {
+	Date   time.Time   `bson:"date" json:"date"`
+	Source *SourceSpan `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+// ContractTerm — срок действия договора и условия его продления
+type ContractTerm struct {
+	Value       string      `bson:"value" json:"value"`
+	EndDate     *time.Time  `bson:"end_date,omitempty" json:"end_date,omitempty"`
+	AutoRenewal bool        `bson:"auto_renewal" json:"auto_renewal"`
+	Renewal     string      `bson:"renewal,omitempty" json:"renewal,omitempty"`
+	Source      *SourceSpan `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+// PriceTerm — цена договора; Currency — код ISO 4217 (KZT, USD, ...)
+type PriceTerm struct {
+	Amount   float64     `bson:"amount,omitempty" json:"amount,omitempty"`
+	Currency string      `bson:"currency,omitempty" json:"currency,omitempty"`
+	Value    string      `bson:"value" json:"value"`
+	Source   *SourceSpan `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+// PaymentTerm — порядок оплаты; Days — срок оплаты в днях, если он указан
+type PaymentTerm struct {
+	Value  string      `bson:"value" json:"value"`
+	Days   int         `bson:"days,omitempty" json:"days,omitempty"`
+	Source *SourceSpan `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+type TextTerm struct {
+	Value  string      `bson:"value" json:"value"`
+	Source *SourceSpan `bson:"source,omitempty" json:"source,omitempty"`
+}
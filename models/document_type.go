@@ -0,0 +1,53 @@
+// document_type.go
+
+package models
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+var ErrDocumentTypeExists = errors.New("тип документа с таким кодом уже существует")
+
+// DocumentType — элемент таксономии документов. Типы верхнего уровня (договор,
+// доверенность, устав) не имеют Parent; подтипы (трудовой договор, NDA) ссылаются
+// на код основного типа.
+type DocumentType struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code   string             `bson:"code" json:"code"`
+	Name   string             `bson:"name" json:"name" binding:"required"`
+	Parent string             `bson:"parent,omitempty" json:"parent,omitempty"`
+	// TitlePatterns — фразы, по которым тип узнаётся в заголовке документа
+	TitlePatterns []string `bson:"title_patterns" json:"title_patterns"`
+	// Keywords — характерные для типа слова и их вес
+	Keywords  []WeightedTerm `bson:"keywords" json:"keywords"`
+	Active    bool           `bson:"active" json:"active"`
+	CreatedAt time.Time      `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time      `bson:"updated_at" json:"updated_at"`
+}
+
+type WeightedTerm struct {
+	Term   string  `bson:"term" json:"term"`
+	Weight float64 `bson:"weight" json:"weight"`
+}
+
+// DocumentClassification — результат классификации документа
+type DocumentClassification struct {
+	Code       string          `bson:"code" json:"code"`
+	Type       string          `bson:"type" json:"type"`
+	Confidence float64         `bson:"confidence" json:"confidence"`
+	Subtypes   []DocumentLabel `bson:"subtypes,omitempty" json:"subtypes,omitempty"`
+	// Language — основной язык текста, Languages — все заметные языки
+	// (у двуязычного документа их два)
+	Language  string   `bson:"language" json:"language"`
+	Languages []string `bson:"languages,omitempty" json:"languages,omitempty"`
+	// Method — heuristic или llm
+	Method string `bson:"method" json:"method"`
+}
+
+type DocumentLabel struct {
+	Code       string  `bson:"code" json:"code"`
+	Name       string  `bson:"name" json:"name"`
+	Confidence float64 `bson:"confidence" json:"confidence"`
+}
@@ -0,0 +1,38 @@
+// organization.go
+
+package models
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+var ErrOrganizationExists = errors.New("организация с таким названием уже существует")
+
+// Organization — компания, к которой относятся пользователи. Её настройки
+// применяются ко всем анализам её пользователей.
+type Organization struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name string             `bson:"name" json:"name" binding:"required"`
+	// RiskPolicy — веса оценки риска и порог подписания; nil — настройки по умолчанию
+	RiskPolicy *RiskPolicy `bson:"risk_policy,omitempty" json:"risk_policy,omitempty"`
+	// PrivacyPolicy — обращение с персональными данными; nil — по настройкам сервера
+	PrivacyPolicy *PrivacyPolicy `bson:"privacy_policy,omitempty" json:"privacy_policy,omitempty"`
+	// Plan — тарифный план с месячными лимитами; nil — действуют лимиты ролей
+	Plan      *UsagePlan `bson:"plan,omitempty" json:"plan,omitempty"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+// AssignOrganizationRequest — привязка пользователя к организации; пустой ID отвязывает
+type AssignOrganizationRequest struct {
+	OrganizationID string `json:"organization_id"`
+}
+
+// PrivacyPolicy — настройки хранения персональных данных организации. В запросах
+// к LLM они скрываются всегда; StoreRedactedText решает, скрывать ли их и в
+// сохранённом тексте документа.
+type PrivacyPolicy struct {
+	StoreRedactedText bool `bson:"store_redacted_text" json:"store_redacted_text"`
+}
@@ -0,0 +1,74 @@
+// playbook.go
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Playbook — набор внутренних правил организации, по которым проверяется
+// каждый анализ её пользователей: «ответственность ограничена», «споры — в суде
+// МФЦА» и т. п.
+type Playbook struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrganizationID primitive.ObjectID `bson:"organization_id" json:"organization_id"`
+	Name           string             `bson:"name" json:"name" binding:"required"`
+	Description    string             `bson:"description,omitempty" json:"description,omitempty"`
+	// DocumentTypes — коды или названия типов документов, к которым применяется
+	// набор; пусто — ко всем
+	DocumentTypes []string `bson:"document_types,omitempty" json:"document_types,omitempty"`
+	// Disabled — набор сохранён, но при анализе не применяется
+	Disabled  bool           `bson:"disabled,omitempty" json:"disabled"`
+	Rules     []PlaybookRule `bson:"rules" json:"rules"`
+	CreatedAt time.Time      `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time      `bson:"updated_at" json:"updated_at"`
+}
+
+// PlaybookRule — правило на естественном языке. Expected — какую позицию
+// организация считает приемлемой, Fallback — формулировка, которую юристы
+// предлагают вместо условия, нарушающего правило.
+type PlaybookRule struct {
+	// ID — ключ правила внутри набора; если не задан, назначается один раз при
+	// сохранении и дальше не меняется
+	ID       string   `bson:"id" json:"id"`
+	Title    string   `bson:"title" json:"title"`
+	Rule     string   `bson:"rule" json:"rule"`
+	Expected string   `bson:"expected,omitempty" json:"expected,omitempty"`
+	Severity Severity `bson:"severity" json:"severity"`
+	Fallback string   `bson:"fallback,omitempty" json:"fallback,omitempty"`
+	// DocumentTypes сужает применение правила внутри набора; пусто — как у набора
+	DocumentTypes []string `bson:"document_types,omitempty" json:"document_types,omitempty"`
+}
+
+// RuleStatus — итог проверки документа по правилу
+type RuleStatus string
+
+const (
+	RuleMet RuleStatus = "met"
+	// RuleNotMet — условие документа противоречит правилу
+	RuleNotMet RuleStatus = "not_met"
+	// RuleNotAddressed — в документе нет условия, к которому относится правило
+	RuleNotAddressed RuleStatus = "not_addressed"
+	// RuleNotChecked — правило не удалось проверить: запрос к модели завершился
+	// ошибкой или модель не вернула по нему результат
+	RuleNotChecked RuleStatus = "not_checked"
+)
+
+// PlaybookCheck — результат проверки документа по одному правилу набора.
+// Evidence — цитаты, найденные в тексте документа; цитаты модели, которых в
+// тексте нет, отбрасываются.
+type PlaybookCheck struct {
+	PlaybookID  primitive.ObjectID `bson:"playbook_id" json:"playbook_id"`
+	Playbook    string             `bson:"playbook" json:"playbook"`
+	RuleID      string             `bson:"rule_id" json:"rule_id"`
+	Title       string             `bson:"title" json:"title"`
+	Severity    Severity           `bson:"severity" json:"severity"`
+	Status      RuleStatus         `bson:"status" json:"status"`
+	Explanation string             `bson:"explanation,omitempty" json:"explanation,omitempty"`
+	Evidence    []SourceSpan       `bson:"evidence,omitempty" json:"evidence,omitempty"`
+	// Fallback — формулировка из правила; заполняется, только если правило не выполнено
+	Fallback string `bson:"fallback,omitempty" json:"fallback,omitempty"`
+	// Error — почему правило не проверено (для RuleNotChecked)
+	Error string `bson:"error,omitempty" json:"error,omitempty"`
+}
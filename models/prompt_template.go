@@ -0,0 +1,71 @@
+// prompt_template.go
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// PromptPurposeReduce — шаблон сведения результатов частей в единый отчёт.
+// Пустое назначение — шаблон анализа части документа.
+const PromptPurposeReduce = "reduce"
+
+// PromptTemplate — версия промпта анализа части документа или сведения частей
+// (Purpose). Шаблоны пишутся на text/template и выбираются по типу документа и
+// языку; пустые DocumentType и Language означают «для любого». Версии не меняются
+// после создания: правка — это новая версия, откат — активация предыдущей.
+type PromptTemplate struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Purpose      string             `bson:"purpose,omitempty" json:"purpose,omitempty"`
+	DocumentType string             `bson:"document_type" json:"document_type"`
+	Language     string             `bson:"language" json:"language"`
+	Version      int                `bson:"version" json:"version"`
+	System       string             `bson:"system" json:"system"`
+	Body         string             `bson:"body" json:"body"`
+	Comment      string             `bson:"comment,omitempty" json:"comment,omitempty"`
+	Active       bool               `bson:"active" json:"active"`
+	CreatedBy    primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	ActivatedAt  *time.Time         `bson:"activated_at,omitempty" json:"activated_at,omitempty"`
+}
+
+// Ref возвращает ссылку на версию шаблона для сохранения в анализе
+func (t *PromptTemplate) Ref() *PromptTemplateRef {
+	return &PromptTemplateRef{
+		ID:           t.ID,
+		Purpose:      t.Purpose,
+		DocumentType: t.DocumentType,
+		Language:     t.Language,
+		Version:      t.Version,
+	}
+}
+
+// PromptTemplateRef — какая версия шаблона построила промпт. Пустой ID и версия 0
+// означают встроенный шаблон.
+type PromptTemplateRef struct {
+	ID           primitive.ObjectID `bson:"id,omitempty" json:"id,omitempty"`
+	Purpose      string             `bson:"purpose,omitempty" json:"purpose,omitempty"`
+	DocumentType string             `bson:"document_type,omitempty" json:"document_type,omitempty"`
+	Language     string             `bson:"language,omitempty" json:"language,omitempty"`
+	Version      int                `bson:"version" json:"version"`
+}
+
+// PromptTemplateRequest — тело запроса на создание версии шаблона
+type PromptTemplateRequest struct {
+	Purpose      string `json:"purpose"`
+	DocumentType string `json:"document_type"`
+	Language     string `json:"language"`
+	System       string `json:"system" binding:"required"`
+	Body         string `json:"body" binding:"required"`
+	Comment      string `json:"comment"`
+	// Activate сразу делает новую версию активной
+	Activate bool `json:"activate"`
+}
+
+// PromptTemplateKey указывает набор версий шаблона для отката
+type PromptTemplateKey struct {
+	Purpose      string `json:"purpose"`
+	DocumentType string `json:"document_type"`
+	Language     string `json:"language"`
+}
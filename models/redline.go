@@ -0,0 +1,25 @@
+// redline.go
+
+package models
+
+import "time"
+
+// Redline — правки документа, предложенные по выводам анализа. Хранится в
+// анализе, чтобы повторная выгрузка DOCX не требовала обращения к модели.
+type Redline struct {
+	Edits     []RedlineEdit `bson:"edits" json:"edits"`
+	Model     string        `bson:"model,omitempty" json:"model,omitempty"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+}
+
+// RedlineEdit — замена фрагмента текста по одному выводу. Пустой Replacement
+// означает удаление; если Source не найден, вывод попадает в документ только
+// комментарием к части, из которой он получен.
+type RedlineEdit struct {
+	// Finding — номер вывода в Result.Findings (с 0)
+	Finding     int         `bson:"finding" json:"finding"`
+	Original    string      `bson:"original" json:"original"`
+	Replacement string      `bson:"replacement" json:"replacement"`
+	Comment     string      `bson:"comment" json:"comment"`
+	Source      *SourceSpan `bson:"source,omitempty" json:"source,omitempty"`
+}
@@ -0,0 +1,56 @@
+// risk.go
+
+package models
+
+import "time"
+
+// CitationStatus — итог проверки ссылок вывода на нормы
+type CitationStatus string
+
+const (
+	// CitationVerified — все ссылки вывода найдены в базе законов
+	CitationVerified CitationStatus = "verified"
+	// CitationUnverified — хотя бы одна ссылка не найдена или не соответствует норме
+	CitationUnverified CitationStatus = "unverified"
+	// CitationNone — вывод не ссылается на нормы
+	CitationNone CitationStatus = "none"
+)
+
+// RiskWeights — веса оценки риска. Вклад вывода — произведение весов его
+// последствий (Severity), вероятности, вида и статуса ссылок; Saturation — какую
+// долю шкалы занимает вывод с вкладом 1.
+type RiskWeights struct {
+	Severity   map[Severity]float64       `bson:"severity" json:"severity"`
+	Likelihood map[Severity]float64       `bson:"likelihood" json:"likelihood"`
+	Kind       map[FindingKind]float64    `bson:"kind" json:"kind"`
+	Citation   map[CitationStatus]float64 `bson:"citation" json:"citation"`
+	Saturation float64                    `bson:"saturation" json:"saturation"`
+}
+
+// RiskPolicy — настройки оценки риска организации. SigningThreshold — оценка, начиная
+// с которой документ нельзя подписывать без согласования; 0 — без ограничения.
+type RiskPolicy struct {
+	Weights          *RiskWeights `bson:"weights,omitempty" json:"weights,omitempty"`
+	SigningThreshold int          `bson:"signing_threshold" json:"signing_threshold"`
+}
+
+// RiskScore — итоговая оценка риска документа по шкале 0–100
+type RiskScore struct {
+	Score int      `bson:"score" json:"score"`
+	Level Severity `bson:"level" json:"level"`
+	// Matrix — число выводов в каждой клетке «вероятность × последствия»
+	Matrix []RiskMatrixCell `bson:"matrix" json:"matrix"`
+	// SigningThreshold — порог, действовавший при расчёте; SigningBlocked — оценка
+	// его достигла
+	SigningThreshold int       `bson:"signing_threshold,omitempty" json:"signing_threshold,omitempty"`
+	SigningBlocked   bool      `bson:"signing_blocked" json:"signing_blocked"`
+	ComputedAt       time.Time `bson:"computed_at" json:"computed_at"`
+}
+
+type RiskMatrixCell struct {
+	Likelihood Severity `bson:"likelihood" json:"likelihood"`
+	Impact     Severity `bson:"impact" json:"impact"`
+	Count      int      `bson:"count" json:"count"`
+	// Findings — номера выводов (с 0) в результате анализа
+	Findings []int `bson:"findings,omitempty" json:"findings,omitempty"`
+}
@@ -0,0 +1,125 @@
+// usage.go
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// TokenUsage — расход токенов и стоимость запросов к LLM. Cost — в USD по
+// данным провайдера; провайдеры, которые стоимость не сообщают, дают ноль.
+type TokenUsage struct {
+	PromptTokens     int64   `bson:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int64   `bson:"completion_tokens" json:"completion_tokens"`
+	Cost             float64 `bson:"cost" json:"cost"`
+	// Calls — число запросов к модели
+	Calls int64 `bson:"calls" json:"calls"`
+}
+
+func (u TokenUsage) TotalTokens() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// LLMCallStatus — итог попытки запроса к модели
+type LLMCallStatus string
+
+const (
+	LLMCallSucceeded LLMCallStatus = "succeeded"
+	// LLMCallFailed — попытка не удалась и могла быть повторена; расход по ней
+	// тоже учитывается, если провайдер успел что-то вернуть
+	LLMCallFailed LLMCallStatus = "failed"
+)
+
+// LLMCall — запись об одной попытке запроса к модели. JobID и AnalysisID связывают
+// запрос с анализом: первый задаётся при анализе документа, второй — при
+// вопросах и правках по готовому анализу.
+type LLMCall struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID  `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	OrganizationID primitive.ObjectID  `bson:"organization_id,omitempty" json:"organization_id,omitempty"`
+	JobID          *primitive.ObjectID `bson:"job_id,omitempty" json:"job_id,omitempty"`
+	AnalysisID     *primitive.ObjectID `bson:"analysis_id,omitempty" json:"analysis_id,omitempty"`
+	// Operation — этап, для которого сделан запрос: analysis, merge, chat и т. п.
+	Operation string `bson:"operation" json:"operation"`
+	Provider  string `bson:"provider" json:"provider"`
+	Model     string `bson:"model" json:"model"`
+	// Status — чем закончилась попытка; StatusCode — HTTP-статус ошибки провайдера
+	// (0 — сетевая ошибка или обрыв потока)
+	Status     LLMCallStatus `bson:"status" json:"status"`
+	StatusCode int           `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	// Estimated — провайдер не сообщил расход, токены посчитаны приблизительно
+	Estimated  bool `bson:"estimated,omitempty" json:"estimated,omitempty"`
+	TokenUsage `bson:",inline"`
+	CreatedAt  time.Time `bson:"created_at" json:"created_at"`
+}
+
+// UsageQuota — месячные лимиты; нулевое значение поля означает «без ограничения»
+type UsageQuota struct {
+	Analyses int64   `bson:"analyses,omitempty" json:"analyses,omitempty"`
+	Tokens   int64   `bson:"tokens,omitempty" json:"tokens,omitempty"`
+	Cost     float64 `bson:"cost,omitempty" json:"cost,omitempty"`
+}
+
+// IsZero сообщает, что лимитов нет
+func (q UsageQuota) IsZero() bool {
+	return q.Analyses == 0 && q.Tokens == 0 && q.Cost == 0
+}
+
+// UsagePlan — тарифный план организации. Quota ограничивает расход всей
+// организации, MemberQuota — каждого её пользователя вместо лимитов его роли.
+type UsagePlan struct {
+	Name        string      `bson:"name,omitempty" json:"name,omitempty"`
+	Quota       UsageQuota  `bson:"quota" json:"quota"`
+	MemberQuota *UsageQuota `bson:"member_quota,omitempty" json:"member_quota,omitempty"`
+}
+
+// UsagePeriod — календарный месяц в UTC, за который считается расход
+type UsagePeriod struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// UsageSummary — расход пользователя или организации за период и его лимиты.
+// Remaining заполняется только для заданных лимитов.
+type UsageSummary struct {
+	Analyses  int64       `json:"analyses"`
+	Usage     TokenUsage  `json:"usage"`
+	Quota     *UsageQuota `json:"quota,omitempty"`
+	Remaining *UsageQuota `json:"remaining,omitempty"`
+}
+
+// UsageGroup — расход, сгруппированный по ключу: операции, модели,
+// пользователю или организации
+type UsageGroup struct {
+	Key        string `bson:"_id" json:"key"`
+	TokenUsage `bson:",inline"`
+}
+
+// UsageReport — расход пользователя за месяц
+type UsageReport struct {
+	Period       UsagePeriod   `json:"period"`
+	User         UsageSummary  `json:"user"`
+	Organization *UsageSummary `json:"organization,omitempty"`
+	ByOperation  []UsageGroup  `json:"by_operation"`
+	ByModel      []UsageGroup  `json:"by_model"`
+}
+
+// OrganizationUsageReport — расход организации за месяц с разбивкой по пользователям
+type OrganizationUsageReport struct {
+	Period       UsagePeriod  `json:"period"`
+	Organization UsageSummary `json:"organization"`
+	ByUser       []UsageGroup `json:"by_user"`
+	ByOperation  []UsageGroup `json:"by_operation"`
+	ByModel      []UsageGroup `json:"by_model"`
+}
+
+// AdminUsageReport — расход всего сервиса за месяц
+type AdminUsageReport struct {
+	Period         UsagePeriod  `json:"period"`
+	Total          TokenUsage   `json:"total"`
+	ByUser         []UsageGroup `json:"by_user"`
+	ByOrganization []UsageGroup `json:"by_organization"`
+	ByModel        []UsageGroup `json:"by_model"`
+	ByOperation    []UsageGroup `json:"by_operation"`
+}
@@ -28,4 +28,7 @@ type User struct {
 	Role      UserRole           `bson:"role"`
 	CreatedAt time.Time          `bson:"createdAt"`
 	UpdatedAt time.Time          `bson:"updatedAt"`
+	Language  string             `bson:"language,omitempty"`
+	// OrganizationID — организация пользователя; пусто, если он работает сам по себе
+	OrganizationID primitive.ObjectID `bson:"organization_id,omitempty"`
 }
@@ -8,6 +8,7 @@ document.addEventListener('DOMContentLoaded', function() {
     });
 
     document.getElementById('documentInput').addEventListener('change', handleFileUpload);
+    document.getElementById('questionForm').addEventListener('submit', askQuestion);
 
     // Set up tab switching
     document.querySelectorAll('.tab').forEach(tab => {
@@ -74,6 +75,8 @@ function formatFileSize(bytes) {
 async function uploadDocument(file) {
     const formData = new FormData();
     formData.append('document', file);
+    const language = document.getElementById('reportLanguage').value;
+    if (language) formData.append('language', language);
 
     try {
         const response = await fetch('/api/analyze', {
@@ -86,7 +89,8 @@ async function uploadDocument(file) {
             throw new Error(error || `Server returned ${response.status}`);
         }
 
-        const data = await response.json();
+        const accepted = await response.json();
+        const data = await waitForJob(accepted.jobId);
 
         // Hide loading state
         document.getElementById('loadingSection').style.display = 'none';
@@ -105,13 +109,98 @@ async function uploadDocument(file) {
     }
 }
 
+function waitForJob(jobId) {
+    const status = document.getElementById('loadingStatus');
+    const partial = document.getElementById('partialOutput');
+    status.textContent = '';
+    partial.textContent = '';
+
+    return new Promise((resolve, reject) => {
+        const source = new EventSource(`/api/analysis/${jobId}/events`);
+        const parse = e => JSON.parse(e.data);
+
+        source.addEventListener('extracted', e => {
+            status.textContent = `Текст извлечён (${parse(e).data.chars} символов)`;
+        });
+        source.addEventListener('classified', e => {
+            status.textContent = `Тип документа: ${formatDocumentType(parse(e).data)}`;
+        });
+        source.addEventListener('part_started', e => {
+            const event = parse(e);
+            const heading = event.data && event.data.heading ? ` (${event.data.heading})` : '';
+            status.textContent = `Анализ части ${event.part} из ${event.total}${heading}...`;
+            partial.textContent = '';
+            partial.style.display = 'block';
+        });
+        source.addEventListener('part_delta', e => {
+            partial.textContent += parse(e).content;
+        });
+        source.addEventListener('part_reset', e => {
+            const event = parse(e);
+            status.textContent = `Повторный запрос для части ${event.part} из ${event.total}...`;
+            partial.textContent = '';
+        });
+        source.addEventListener('part_completed', e => {
+            const event = parse(e);
+            status.textContent = `Часть ${event.part} из ${event.total} готова`;
+        });
+        source.addEventListener('merging', () => {
+            status.textContent = 'Сводим результаты частей в единый отчёт...';
+            partial.style.display = 'none';
+        });
+        source.addEventListener('extracting_terms', () => {
+            status.textContent = 'Извлекаем ключевые условия договора...';
+            partial.style.display = 'none';
+        });
+        source.addEventListener('checking_playbook', () => {
+            status.textContent = 'Проверяем документ по правилам организации...';
+            partial.style.display = 'none';
+        });
+        source.addEventListener('completed', e => {
+            const event = parse(e);
+            source.close();
+            partial.style.display = 'none';
+            resolve({
+                analysis: event.content,
+                document_type: event.data.document_type,
+                classification: event.data.classification,
+                analysisId: event.data.analysis_id,
+                cached: event.data.cached,
+                risk: event.data.risk,
+                terms: event.data.terms,
+                playbook: event.data.playbook
+            });
+        });
+        ['failed', 'cancelled'].forEach(type => source.addEventListener(type, e => {
+            source.close();
+            partial.style.display = 'none';
+            reject(new Error(parse(e).data || type));
+        }));
+        source.onerror = () => {
+            if (source.readyState === EventSource.CLOSED) {
+                reject(new Error('Соединение с сервером потеряно'));
+            }
+        };
+    });
+}
+
+function formatDocumentType(classification) {
+    if (!classification) return 'Неизвестно';
+    const subtypes = (classification.subtypes || []).map(s => s.name).join(', ');
+    const confidence = Math.round((classification.confidence || 0) * 100);
+    return `${classification.type}${subtypes ? ` (${subtypes})` : ''}, уверенность ${confidence}%`;
+}
+
 function displayResults(data) {
     // Show result section
     const resultSection = document.getElementById('resultSection');
     resultSection.style.display = 'block';
 
     // Set document type
-    document.getElementById('documentType').textContent = `Тип документа: ${data.document_type || 'Неизвестно'}`;
+    const documentType = data.classification ? formatDocumentType(data.classification) : (data.document_type || 'Неизвестно');
+    const cachedNote = data.cached ? ' (отчёт из кэша)' : '';
+    const riskNote = data.risk ? `. Оценка риска: ${data.risk.score}/100${data.risk.signing_blocked ? ' — требуется согласование перед подписанием' : ''}` : '';
+    document.getElementById('documentType').textContent = `Тип документа: ${documentType}${cachedNote}${riskNote}`;
 
     // Clear previous content
     const fullContainer = document.getElementById('fullContainer');
@@ -123,6 +212,9 @@ function displayResults(data) {
     risksContainer.innerHTML = '';
     recommendationsContainer.innerHTML = '';
     summaryContainer.innerHTML = '';
+    renderTerms(data.terms);
+    renderPlaybook(data.playbook);
+    resetQuestions(data.analysisId);
 
     // Convert markdown to HTML
     if (data.analysis) {
@@ -146,6 +238,149 @@ function displayResults(data) {
     }
 }
 
+const ruleStatusLabels = {
+    met: 'Соблюдено',
+    not_met: 'Не соблюдено',
+    not_addressed: 'Не урегулировано',
+    not_checked: 'Не проверено'
+};
+
+function renderPlaybook(checks) {
+    const container = document.getElementById('playbookContainer');
+    container.innerHTML = '';
+    if (!checks || checks.length === 0) {
+        container.innerHTML = '<p>Правила организации к документу не применялись.</p>';
+        return;
+    }
+
+    const table = document.createElement('table');
+    checks.forEach(check => {
+        const tr = table.insertRow();
+        tr.insertCell().textContent = `${check.playbook}: ${check.title}`;
+        tr.insertCell().textContent = ruleStatusLabels[check.status] || check.status;
+
+        const details = [check.explanation];
+        (check.evidence || []).forEach(e => details.push(`«${e.quote}»`));
+        if (check.fallback) details.push(`Предлагаемая формулировка: ${check.fallback}`);
+        tr.insertCell().textContent = details.filter(Boolean).join('\n');
+    });
+    container.appendChild(table);
+}
+
+function renderTerms(terms) {
+    const container = document.getElementById('termsContainer');
+    container.innerHTML = '';
+    if (!terms) {
+        container.innerHTML = '<p>Условия договора не извлечены.</p>';
+        return;
+    }
+
+    const rows = [];
+    const add = (label, value) => {
+        if (value) rows.push([label, value]);
+    };
+    (terms.parties || []).forEach(p => {
+        const ids = [p.bin && `БИН ${p.bin}`, p.iin && `ИИН ${p.iin}`].filter(Boolean).join(', ');
+        const signatory = p.signatory ? `, подписант: ${p.signatory}${p.authority_basis ? ` (${p.authority_basis})` : ''}` : '';
+        add(p.role || 'Сторона', `${p.name}${ids ? ` (${ids})` : ''}${signatory}`);
+    });
+    add('Дата вступления в силу', terms.effective_date && terms.effective_date.date.slice(0, 10));
+    add('Срок действия', terms.term && `${terms.term.value}${terms.term.auto_renewal ? ' (автопролонгация)' : ''}`);
+    add('Цена', terms.price && (terms.price.amount
+        ? `${terms.price.amount.toLocaleString('ru-RU')} ${terms.price.currency || ''}`.trim()
+        : terms.price.value));
+    add('Порядок оплаты', terms.payment_terms && terms.payment_terms.value);
+    (terms.penalties || []).forEach(t => add('Неустойка', t.value));
+    (terms.termination_grounds || []).forEach(t => add('Основание расторжения', t.value));
+    add('Применимое право', terms.governing_law && terms.governing_law.value);
+    add('Разрешение споров', terms.dispute_forum && terms.dispute_forum.value);
+
+    if (rows.length === 0) {
+        container.innerHTML = '<p>Условия договора не найдены.</p>';
+        return;
+    }
+    const table = document.createElement('table');
+    rows.forEach(([label, value]) => {
+        const tr = table.insertRow();
+        tr.insertCell().textContent = label;
+        tr.insertCell().textContent = value;
+    });
+    container.appendChild(table);
+}
+
+// Вопросы по анализу: ветка начинается с первого вопроса, следующие вопросы
+// продолжают её, чтобы модель видела предыдущие ответы
+let questionThread = { analysisId: null, threadId: null };
+
+function resetQuestions(analysisId) {
+    questionThread = { analysisId: analysisId, threadId: null };
+    document.getElementById('questionsContainer').innerHTML = analysisId
+        ? '<p>Задайте вопрос по документу: ответ учитывает текст, выводы анализа и нормы законодательства.</p>'
+        : '<p>Для этого анализа вопросы недоступны.</p>';
+}
+
+async function askQuestion(e) {
+    e.preventDefault();
+    const input = document.getElementById('questionInput');
+    const question = input.value.trim();
+    if (!question || !questionThread.analysisId) return;
+    input.value = '';
+
+    const container = document.getElementById('questionsContainer');
+    const questionEl = document.createElement('p');
+    questionEl.className = 'question';
+    questionEl.textContent = question;
+    const answerEl = document.createElement('div');
+    answerEl.className = 'answer';
+    container.append(questionEl, answerEl);
+
+    try {
+        const response = await fetch(`/api/analysis/${questionThread.analysisId}/messages`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ content: question, thread_id: questionThread.threadId || '' })
+        });
+        if (!response.ok) {
+            const error = await response.json().catch(() => ({}));
+            throw new Error(error.error || `Server returned ${response.status}`);
+        }
+
+        // EventSource не умеет POST, поэтому события разбираются вручную
+        const reader = response.body.getReader();
+        const decoder = new TextDecoder();
+        let buffer = '';
+        let text = '';
+        for (;;) {
+            const { done, value } = await reader.read();
+            if (done) break;
+            buffer += decoder.decode(value, { stream: true });
+            const blocks = buffer.split('\n\n');
+            buffer = blocks.pop();
+            for (const block of blocks) {
+                const event = ((block.match(/^event:(.*)$/m) || [])[1] || '').trim();
+                const data = block.split('\n').filter(l => l.startsWith('data:')).map(l => l.slice(5)).join('\n');
+                if (!event || !data) continue;
+                const payload = JSON.parse(data);
+                if (event === 'delta') {
+                    text += payload.text;
+                    answerEl.textContent = text;
+                } else if (event === 'reset') {
+                    text = '';
+                    answerEl.textContent = '';
+                } else if (event === 'done') {
+                    questionThread.threadId = payload.thread_id;
+                    answerEl.innerHTML = marked.parse(payload.message.content);
+                } else if (event === 'error') {
+                    throw new Error(payload.error);
+                }
+            }
+        }
+    } catch (error) {
+        console.error('Question error:', error);
+        answerEl.textContent = `Не удалось получить ответ: ${error.message}`;
+    }
+}
+
 function splitAnalysisIntoSections(htmlContent) {
     const sections = {
         risks: '',
@@ -0,0 +1,101 @@
+// analysis_batch_repository.go
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"time"
+)
+
+const analysisBatchesCollection = "analysis_batches"
+
+// EnsureAnalysisBatchIndexes создаёт индекс задач по пакету: по нему собирается статус пакета
+func EnsureAnalysisBatchIndexes() error {
+	_, err := db.GetCollection(analysisJobsCollection).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "batch_id", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	return err
+}
+
+func CreateAnalysisBatch(batch *models.AnalysisBatch) error {
+	utils.LogAction(fmt.Sprintf("Создание пакета анализа: %d документов", len(batch.Documents)))
+
+	if batch.ID.IsZero() {
+		batch.ID = primitive.NewObjectID()
+	}
+	batch.CreatedAt = time.Now()
+	batch.UpdatedAt = batch.CreatedAt
+
+	if _, err := db.GetCollection(analysisBatchesCollection).InsertOne(context.TODO(), batch); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка создания пакета: %v", err))
+		return err
+	}
+	return nil
+}
+
+func GetAnalysisBatch(id primitive.ObjectID) (*models.AnalysisBatch, error) {
+	var batch models.AnalysisBatch
+	err := db.GetCollection(analysisBatchesCollection).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&batch)
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func SetAnalysisBatchDocuments(id primitive.ObjectID, documents []models.BatchDocument) error {
+	_, err := db.GetCollection(analysisBatchesCollection).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"documents": documents, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка обновления пакета %s: %v", id.Hex(), err))
+	}
+	return err
+}
+
+// GetBatchJobs возвращает задачи пакета без текста документов и частей
+func GetBatchJobs(batchID primitive.ObjectID) ([]models.AnalysisJob, error) {
+	opts := options.Find().SetProjection(bson.M{"text": 0, "parts": 0})
+	cursor, err := db.GetCollection(analysisJobsCollection).Find(context.TODO(), bson.M{"batch_id": batchID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	jobs := []models.AnalysisJob{}
+	if err := cursor.All(context.TODO(), &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// GetAnalysesByIDs возвращает анализы без текста документов: для сводки по
+// пакету достаточно результатов и оценок риска
+func GetAnalysesByIDs(ids []primitive.ObjectID) ([]models.Analysis, error) {
+	analyses := []models.Analysis{}
+	if len(ids) == 0 {
+		return analyses, nil
+	}
+
+	opts := options.Find().SetProjection(bson.M{"text": 0, "analysis": 0, "redline": 0})
+	cursor, err := db.GetCollection("analyses").Find(context.TODO(), bson.M{"_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	if err := cursor.All(context.TODO(), &analyses); err != nil {
+		return nil, err
+	}
+	return analyses, nil
+}
@@ -0,0 +1,137 @@
+// analysis_cache_repository.go
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"time"
+)
+
+const analysisCacheCollection = "analysis_cache"
+
+// EnsureAnalysisCacheIndexes создаёт уникальный индекс по ключу и TTL-индекс,
+// которым MongoDB сама удаляет просроченные записи
+func EnsureAnalysisCacheIndexes() error {
+	_, err := db.GetCollection(analysisCacheCollection).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "user_ids", Value: 1}}},
+	})
+	return err
+}
+
+// GetAnalysisCacheEntry возвращает непросроченную запись: TTL-индекс удаляет
+// записи с задержкой, поэтому срок проверяется и здесь
+func GetAnalysisCacheEntry(key string) (*models.AnalysisCacheEntry, error) {
+	var entry models.AnalysisCacheEntry
+	err := db.GetCollection(analysisCacheCollection).FindOne(
+		context.TODO(),
+		bson.M{"key": key, "expires_at": bson.M{"$gt": time.Now()}},
+	).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// TouchAnalysisCacheEntry учитывает попадание в кэш и запоминает пользователя
+func TouchAnalysisCacheEntry(key string, userID primitive.ObjectID) error {
+	_, err := db.GetCollection(analysisCacheCollection).UpdateOne(
+		context.TODO(),
+		bson.M{"key": key},
+		bson.M{"$inc": bson.M{"hits": 1}, "$addToSet": bson.M{"user_ids": userID}},
+	)
+	return err
+}
+
+// SaveAnalysisCacheEntry записывает результат, заменяя прежний с тем же ключом
+func SaveAnalysisCacheEntry(entry *models.AnalysisCacheEntry) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+
+	_, err := db.GetCollection(analysisCacheCollection).ReplaceOne(
+		context.TODO(),
+		bson.M{"key": entry.Key},
+		entry,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка сохранения результата в кэш: %v", err))
+		return err
+	}
+	return nil
+}
+
+func DeleteAnalysisCacheEntries(filter bson.M) (int64, error) {
+	res, err := db.GetCollection(analysisCacheCollection).DeleteMany(context.TODO(), filter)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка очистки кэша анализов: %v", err))
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// ReleaseAnalysisCacheEntries убирает пользователя из записей кэша и удаляет
+// записи, у которых не осталось пользователей. Запись, которую между двумя
+// запросами снова кто-то использовал, не удаляется: её user_ids уже не пуст.
+func ReleaseAnalysisCacheEntries(userID primitive.ObjectID) (int64, error) {
+	collection := db.GetCollection(analysisCacheCollection)
+	_, err := collection.UpdateMany(
+		context.TODO(),
+		bson.M{"user_ids": userID},
+		bson.M{"$pull": bson.M{"user_ids": userID}},
+	)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка очистки кэша анализов: %v", err))
+		return 0, err
+	}
+	return DeleteAnalysisCacheEntries(bson.M{"user_ids": bson.M{"$size": 0}})
+}
+
+func GetAnalysisCacheStats() (*models.CacheStats, error) {
+	collection := db.GetCollection(analysisCacheCollection)
+	live := bson.M{"expires_at": bson.M{"$gt": time.Now()}}
+
+	cursor, err := collection.Aggregate(context.TODO(), mongo.Pipeline{
+		{{Key: "$match", Value: live}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$kind",
+			"count": bson.M{"$sum": 1},
+			"hits":  bson.M{"$sum": "$hits"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var groups []struct {
+		Kind  models.CacheKind `bson:"_id"`
+		Count int64            `bson:"count"`
+		Hits  int64            `bson:"hits"`
+	}
+	if err := cursor.All(context.TODO(), &groups); err != nil {
+		return nil, err
+	}
+
+	stats := &models.CacheStats{}
+	for _, g := range groups {
+		switch g.Kind {
+		case models.CacheDocument:
+			stats.Documents = g.Count
+		case models.CachePart:
+			stats.Parts = g.Count
+		}
+		stats.Hits += g.Hits
+	}
+	return stats, nil
+}
@@ -0,0 +1,222 @@
+// analysis_job_repository.go
+
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"time"
+)
+
+const analysisJobsCollection = "analysis_jobs"
+
+func CreateAnalysisJob(job *models.AnalysisJob) error {
+	utils.LogAction("Создание задачи на анализ")
+
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+	job.Status = models.JobQueued
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+
+	_, err := db.GetCollection(analysisJobsCollection).InsertOne(context.TODO(), job)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка создания задачи: %v", err))
+		return err
+	}
+
+	utils.LogSuccess(fmt.Sprintf("Задача %s поставлена в очередь", job.ID.Hex()))
+	return nil
+}
+
+func GetAnalysisJob(id primitive.ObjectID) (*models.AnalysisJob, error) {
+	var job models.AnalysisJob
+	err := db.GetCollection(analysisJobsCollection).FindOne(
+		context.TODO(),
+		bson.M{"_id": id},
+	).Decode(&job)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// ClaimNextAnalysisJob атомарно переводит самую старую задачу из очереди в статус running.
+// Возвращает nil, если очередь пуста.
+func ClaimNextAnalysisJob() (*models.AnalysisJob, error) {
+	now := time.Now()
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.AnalysisJob
+	err := db.GetCollection(analysisJobsCollection).FindOneAndUpdate(
+		context.TODO(),
+		bson.M{"status": models.JobQueued},
+		bson.M{
+			"$set": bson.M{"status": models.JobRunning, "started_at": now, "updated_at": now},
+			"$inc": bson.M{"attempts": 1},
+		},
+		opts,
+	).Decode(&job)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func UpdateAnalysisJob(id primitive.ObjectID, updates bson.M) error {
+	updates["updated_at"] = time.Now()
+
+	_, err := db.GetCollection(analysisJobsCollection).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id},
+		bson.M{"$set": updates},
+	)
+
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка обновления задачи %s: %v", id.Hex(), err))
+	}
+	return err
+}
+
+// UpdateAnalysisJobPart сохраняет состояние одной части, не трогая остальные
+func UpdateAnalysisJobPart(id primitive.ObjectID, part models.JobPart) error {
+	return UpdateAnalysisJob(id, bson.M{
+		fmt.Sprintf("parts.%d", part.Index): part,
+	})
+}
+
+// RequeueInterruptedJobs возвращает в очередь задачи, прерванные остановкой сервера
+func RequeueInterruptedJobs() (int64, error) {
+	res, err := db.GetCollection(analysisJobsCollection).UpdateMany(
+		context.TODO(),
+		bson.M{"status": models.JobRunning},
+		bson.M{"$set": bson.M{"status": models.JobQueued, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка возврата задач в очередь: %v", err))
+		return 0, err
+	}
+
+	return res.ModifiedCount, nil
+}
+
+// CancelQueuedAnalysisJob отменяет задачу, если воркер ещё не взял её в работу
+func CancelQueuedAnalysisJob(id primitive.ObjectID) (bool, error) {
+	now := time.Now()
+	res, err := db.GetCollection(analysisJobsCollection).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id, "status": models.JobQueued},
+		bson.M{"$set": bson.M{
+			"status":      models.JobCancelled,
+			"error":       "анализ отменён пользователем",
+			"finished_at": now,
+			"updated_at":  now,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+// RequestAnalysisJobCancel помечает выполняющуюся задачу для отмены
+func RequestAnalysisJobCancel(id primitive.ObjectID) (bool, error) {
+	res, err := db.GetCollection(analysisJobsCollection).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id, "status": models.JobRunning},
+		bson.M{"$set": bson.M{"cancel_requested": true, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+func IsAnalysisJobCancelRequested(id primitive.ObjectID) (bool, error) {
+	var job struct {
+		CancelRequested bool `bson:"cancel_requested"`
+	}
+	err := db.GetCollection(analysisJobsCollection).FindOne(
+		context.TODO(),
+		bson.M{"_id": id},
+		options.FindOne().SetProjection(bson.M{"cancel_requested": 1}),
+	).Decode(&job)
+
+	if err != nil {
+		return false, err
+	}
+
+	return job.CancelRequested, nil
+}
+
+// GetUnfinishedAnalysisJobIDs возвращает ID задач пользователя в статусах queued и running
+func GetUnfinishedAnalysisJobIDs(userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := db.GetCollection(analysisJobsCollection).Find(
+		context.TODO(),
+		bson.M{
+			"user_id": userID,
+			"status":  bson.M{"$in": []models.JobStatus{models.JobQueued, models.JobRunning}},
+		},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(context.TODO(), &docs); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.ID)
+	}
+	return ids, nil
+}
+
+// EnsureAnalysisJobIndexes создаёт индексы, по которым считаются анализы за месяц
+func EnsureAnalysisJobIndexes() error {
+	_, err := db.GetCollection(analysisJobsCollection).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "organization_id", Value: 1}, {Key: "created_at", Value: 1}}},
+	})
+	return err
+}
+
+// CountAnalysisJobsBetween считает задачи, поставленные в [from, to): owner
+// отбирает задачи пользователя или организации. Проваленные задачи не
+// считаются — анализ не состоялся не по вине пользователя.
+func CountAnalysisJobsBetween(owner bson.M, from, to time.Time) (int64, error) {
+	query := bson.M{
+		"created_at": bson.M{"$gte": from, "$lt": to},
+		"status":     bson.M{"$ne": models.JobFailed},
+	}
+	for k, v := range owner {
+		query[k] = v
+	}
+	return db.GetCollection(analysisJobsCollection).CountDocuments(context.TODO(), query)
+}
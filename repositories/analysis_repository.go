@@ -7,29 +7,28 @@ import (
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"legally/db"
+	"legally/models"
 	"legally/utils"
+	"regexp"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func SaveAnalysis(userID, filename, docType, analysis, text string) error {
+// taxpayerIDRe — БИН или ИИН: 12 цифр
+var taxpayerIDRe = regexp.MustCompile(`^\d{12}$`)
+
+func SaveAnalysis(analysis *models.Analysis) error {
 	utils.LogAction("Сохранение анализа в БД")
 
-	objID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		return fmt.Errorf("неверный ID пользователя")
+	if analysis.ID.IsZero() {
+		analysis.ID = primitive.NewObjectID()
 	}
+	analysis.CreatedAt = time.Now()
 
-	_, err = db.GetCollection("analyses").InsertOne(context.TODO(), bson.M{
-		"user_id":    objID,
-		"filename":   filename,
-		"type":       docType,
-		"analysis":   analysis,
-		"text":       text,
-		"created_at": time.Now(),
-	})
+	_, err := db.GetCollection("analyses").InsertOne(context.TODO(), analysis)
 
 	if err != nil {
 		utils.LogError(fmt.Sprintf("Ошибка сохранения анализа: %v", err))
@@ -40,7 +39,7 @@ func SaveAnalysis(userID, filename, docType, analysis, text string) error {
 	return err
 }
 
-func GetUserHistory(userID string) ([]map[string]interface{}, error) {
+func GetUserHistory(userID string, filter models.HistoryFilter) ([]models.Analysis, error) {
 	utils.LogAction(fmt.Sprintf("Получение истории анализов для пользователя %s", userID))
 
 	objID, err := primitive.ObjectIDFromHex(userID)
@@ -52,27 +51,165 @@ func GetUserHistory(userID string) ([]map[string]interface{}, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	sort := bson.D{{Key: "created_at", Value: -1}}
+	switch filter.Sort {
+	case models.HistorySortRiskDesc:
+		sort = bson.D{{Key: "risk.score", Value: -1}, {Key: "created_at", Value: -1}}
+	case models.HistorySortRiskAsc:
+		sort = bson.D{{Key: "risk.score", Value: 1}, {Key: "created_at", Value: -1}}
+	}
 	opts := options.Find().
-		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSort(sort).
 		SetLimit(50)
 
-	cursor, err := coll.Find(ctx, bson.M{"user_id": objID}, opts)
+	query := bson.M{"user_id": objID}
+	if filter.Severity != "" {
+		query["result.findings.severity"] = filter.Severity
+	}
+	addTermsFilter(query, filter)
+	addRiskFilter(query, filter)
+
+	cursor, err := coll.Find(ctx, query, opts)
 	if err != nil {
 		utils.LogError(fmt.Sprintf("Ошибка получения истории: %v", err))
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var results []map[string]interface{}
+	results := []models.Analysis{}
 	if err := cursor.All(ctx, &results); err != nil {
 		utils.LogError(fmt.Sprintf("Ошибка декодирования истории: %v", err))
 		return nil, err
 	}
 
-	for _, result := range results {
-		delete(result, "_id")
-	}
-
 	utils.LogSuccess(fmt.Sprintf("Получено %d записей истории", len(results)))
 	return results, nil
 }
+
+// addTermsFilter добавляет условия по извлечённым условиям договора
+func addTermsFilter(query bson.M, filter models.HistoryFilter) {
+	if filter.Party != "" {
+		if taxpayerIDRe.MatchString(filter.Party) {
+			query["$or"] = bson.A{
+				bson.M{"terms.parties.bin": filter.Party},
+				bson.M{"terms.parties.iin": filter.Party},
+			}
+		} else {
+			query["terms.parties.name"] = primitive.Regex{Pattern: regexp.QuoteMeta(filter.Party), Options: "i"}
+		}
+	}
+	if filter.GoverningLaw != "" {
+		query["terms.governing_law.value"] = primitive.Regex{Pattern: regexp.QuoteMeta(filter.GoverningLaw), Options: "i"}
+	}
+	if filter.Currency != "" {
+		query["terms.price.currency"] = filter.Currency
+	}
+	if filter.AutoRenewal != nil {
+		query["terms.term.auto_renewal"] = *filter.AutoRenewal
+	}
+
+	amount := bson.M{}
+	if filter.MinAmount != nil {
+		amount["$gte"] = *filter.MinAmount
+	}
+	if filter.MaxAmount != nil {
+		amount["$lte"] = *filter.MaxAmount
+	}
+	if len(amount) > 0 {
+		query["terms.price.amount"] = amount
+	}
+
+	effective := bson.M{}
+	if filter.EffectiveFrom != nil {
+		effective["$gte"] = *filter.EffectiveFrom
+	}
+	if filter.EffectiveTo != nil {
+		effective["$lte"] = *filter.EffectiveTo
+	}
+	if len(effective) > 0 {
+		query["terms.effective_date.date"] = effective
+	}
+}
+
+// addRiskFilter добавляет условия по оценке риска. Анализы без оценки под
+// диапазон не попадают.
+func addRiskFilter(query bson.M, filter models.HistoryFilter) {
+	score := bson.M{}
+	if filter.MinRisk != nil {
+		score["$gte"] = *filter.MinRisk
+	}
+	if filter.MaxRisk != nil {
+		score["$lte"] = *filter.MaxRisk
+	}
+	if len(score) > 0 {
+		query["risk.score"] = score
+	}
+	if filter.SigningBlocked != nil {
+		query["risk.signing_blocked"] = *filter.SigningBlocked
+	}
+}
+
+// EnsureAnalysisIndexes создаёт индексы истории: по дате и по оценке риска
+// пользователя, а также по организации для пересчёта оценок
+func EnsureAnalysisIndexes() error {
+	_, err := db.GetCollection("analyses").Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "risk.score", Value: -1}}},
+		{Keys: bson.D{{Key: "organization_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	return err
+}
+
+func SetAnalysisRisk(id primitive.ObjectID, risk *models.RiskScore) error {
+	_, err := db.GetCollection("analyses").UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"risk": risk}},
+	)
+	return err
+}
+
+// ForEachOrganizationAnalysis передаёт в fn анализы организации без текста
+// документа: для пересчёта оценки достаточно результата
+func ForEachOrganizationAnalysis(organizationID primitive.ObjectID, fn func(*models.Analysis) error) error {
+	opts := options.Find().SetProjection(bson.M{"result": 1, "organization_id": 1})
+	cursor, err := db.GetCollection("analyses").Find(context.TODO(), bson.M{"organization_id": organizationID}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var analysis models.Analysis
+		if err := cursor.Decode(&analysis); err != nil {
+			return err
+		}
+		if err := fn(&analysis); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func SetAnalysisRedline(id primitive.ObjectID, redline *models.Redline) error {
+	_, err := db.GetCollection("analyses").UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"redline": redline}},
+	)
+	return err
+}
+
+func GetAnalysis(id primitive.ObjectID) (*models.Analysis, error) {
+	var analysis models.Analysis
+	err := db.GetCollection("analyses").FindOne(
+		context.TODO(),
+		bson.M{"_id": id},
+	).Decode(&analysis)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &analysis, nil
+}
@@ -0,0 +1,128 @@
+// analysis_thread_repository.go
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"time"
+)
+
+const analysisThreadsCollection = "analysis_threads"
+
+// EnsureAnalysisThreadIndexes создаёт индекс, по которому ветки ищутся для
+// анализа и для страницы истории
+func EnsureAnalysisThreadIndexes() error {
+	_, err := db.GetCollection(analysisThreadsCollection).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "analysis_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+	})
+	return err
+}
+
+func CreateAnalysisThread(thread *models.AnalysisThread) error {
+	if thread.ID.IsZero() {
+		thread.ID = primitive.NewObjectID()
+	}
+	now := time.Now()
+	thread.CreatedAt = now
+	thread.UpdatedAt = now
+
+	_, err := db.GetCollection(analysisThreadsCollection).InsertOne(context.TODO(), thread)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка сохранения ветки вопросов: %v", err))
+	}
+	return err
+}
+
+// GetAnalysisThread возвращает ветку, только если она относится к анализу и
+// принадлежит пользователю
+func GetAnalysisThread(id, analysisID, userID primitive.ObjectID) (*models.AnalysisThread, error) {
+	var thread models.AnalysisThread
+	err := db.GetCollection(analysisThreadsCollection).FindOne(
+		context.TODO(),
+		bson.M{"_id": id, "analysis_id": analysisID, "user_id": userID},
+	).Decode(&thread)
+	if err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// GetAnalysisThreads возвращает ветки анализа с сообщениями, новые первыми
+func GetAnalysisThreads(analysisID, userID primitive.ObjectID) ([]models.AnalysisThread, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}})
+	cursor, err := db.GetCollection(analysisThreadsCollection).Find(ctx, bson.M{"analysis_id": analysisID, "user_id": userID}, opts)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения веток вопросов: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	threads := []models.AnalysisThread{}
+	if err := cursor.All(ctx, &threads); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования веток вопросов: %v", err))
+		return nil, err
+	}
+	return threads, nil
+}
+
+// AppendThreadMessages добавляет сообщения в конец ветки
+func AppendThreadMessages(id primitive.ObjectID, messages ...models.ThreadMessage) error {
+	_, err := db.GetCollection(analysisThreadsCollection).UpdateByID(context.TODO(), id, bson.M{
+		"$push": bson.M{"messages": bson.M{"$each": messages}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка сохранения сообщений ветки %s: %v", id.Hex(), err))
+	}
+	return err
+}
+
+// GetThreadSummaries возвращает сводки веток пользователя по анализам без
+// текста сообщений
+func GetThreadSummaries(userID primitive.ObjectID, analysisIDs []primitive.ObjectID) (map[primitive.ObjectID][]models.ThreadSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID, "analysis_id": bson.M{"$in": analysisIDs}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "updated_at", Value: -1}}}},
+		{{Key: "$project", Value: bson.M{
+			"analysis_id": 1,
+			"title":       1,
+			"updated_at":  1,
+			"messages":    bson.M{"$size": "$messages"},
+		}}},
+	}
+	cursor, err := db.GetCollection(analysisThreadsCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения сводок веток: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		models.ThreadSummary `bson:",inline"`
+		AnalysisID           primitive.ObjectID `bson:"analysis_id"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования сводок веток: %v", err))
+		return nil, err
+	}
+
+	out := make(map[primitive.ObjectID][]models.ThreadSummary)
+	for _, row := range rows {
+		out[row.AnalysisID] = append(out[row.AnalysisID], row.ThreadSummary)
+	}
+	return out, nil
+}
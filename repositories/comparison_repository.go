@@ -0,0 +1,76 @@
+// comparison_repository.go
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"time"
+)
+
+const comparisonsCollection = "comparisons"
+
+func SaveComparison(comparison *models.Comparison) error {
+	utils.LogAction("Сохранение сравнения редакций в БД")
+
+	if comparison.ID.IsZero() {
+		comparison.ID = primitive.NewObjectID()
+	}
+	comparison.CreatedAt = time.Now()
+
+	_, err := db.GetCollection(comparisonsCollection).InsertOne(context.TODO(), comparison)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка сохранения сравнения: %v", err))
+		return err
+	}
+
+	utils.LogSuccess("Сравнение успешно сохранено в БД")
+	return nil
+}
+
+// GetComparison возвращает сравнение, только если оно принадлежит пользователю
+func GetComparison(id, userID primitive.ObjectID) (*models.Comparison, error) {
+	var comparison models.Comparison
+	err := db.GetCollection(comparisonsCollection).FindOne(
+		context.TODO(),
+		bson.M{"_id": id, "user_id": userID},
+	).Decode(&comparison)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &comparison, nil
+}
+
+// GetUserComparisons возвращает последние сравнения пользователя без текста
+// изменений: для списка в истории достаточно сводки
+func GetUserComparisons(userID primitive.ObjectID) ([]models.Comparison, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(50).
+		SetProjection(bson.M{"changes": 0})
+
+	cursor, err := db.GetCollection(comparisonsCollection).Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения сравнений: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.Comparison{}
+	if err := cursor.All(ctx, &results); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования сравнений: %v", err))
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,129 @@
+// document_type_repository.go
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"time"
+)
+
+const documentTypesCollection = "document_types"
+
+func GetDocumentTypes() ([]models.DocumentType, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "parent", Value: 1}, {Key: "code", Value: 1}})
+
+	cursor, err := db.GetCollection(documentTypesCollection).Find(context.TODO(), bson.M{}, opts)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения типов документов: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var types []models.DocumentType
+	if err := cursor.All(context.TODO(), &types); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования типов документов: %v", err))
+		return nil, err
+	}
+
+	return types, nil
+}
+
+func GetDocumentType(id primitive.ObjectID) (*models.DocumentType, error) {
+	var docType models.DocumentType
+	err := db.GetCollection(documentTypesCollection).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&docType)
+	if err != nil {
+		return nil, err
+	}
+	return &docType, nil
+}
+
+func DocumentTypeCodeExists(code string) (bool, error) {
+	count, err := db.GetCollection(documentTypesCollection).CountDocuments(context.TODO(), bson.M{"code": code})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func CreateDocumentType(docType *models.DocumentType) error {
+	utils.LogAction(fmt.Sprintf("Создание типа документа: %s", docType.Code))
+
+	exists, err := DocumentTypeCodeExists(docType.Code)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return models.ErrDocumentTypeExists
+	}
+
+	if docType.ID.IsZero() {
+		docType.ID = primitive.NewObjectID()
+	}
+	docType.CreatedAt = time.Now()
+	docType.UpdatedAt = docType.CreatedAt
+
+	if _, err := db.GetCollection(documentTypesCollection).InsertOne(context.TODO(), docType); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка сохранения типа документа: %v", err))
+		return err
+	}
+	return nil
+}
+
+// InsertDocumentTypes записывает начальную таксономию одним запросом
+func InsertDocumentTypes(types []models.DocumentType) error {
+	docs := make([]interface{}, len(types))
+	now := time.Now()
+	for i := range types {
+		types[i].ID = primitive.NewObjectID()
+		types[i].CreatedAt = now
+		types[i].UpdatedAt = now
+		docs[i] = types[i]
+	}
+
+	if _, err := db.GetCollection(documentTypesCollection).InsertMany(context.TODO(), docs); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка сохранения таксономии документов: %v", err))
+		return err
+	}
+	return nil
+}
+
+func UpdateDocumentType(id primitive.ObjectID, updates bson.M) error {
+	utils.LogAction(fmt.Sprintf("Обновление типа документа: %s", id.Hex()))
+
+	updates["updated_at"] = time.Now()
+	res, err := db.GetCollection(documentTypesCollection).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id},
+		bson.M{"$set": updates},
+	)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка обновления типа документа: %v", err))
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func DeleteDocumentType(id primitive.ObjectID) error {
+	utils.LogAction(fmt.Sprintf("Удаление типа документа: %s", id.Hex()))
+
+	res, err := db.GetCollection(documentTypesCollection).DeleteOne(context.TODO(), bson.M{"_id": id})
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка удаления типа документа: %v", err))
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
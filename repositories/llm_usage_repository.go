@@ -0,0 +1,90 @@
+// llm_usage_repository.go
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"time"
+)
+
+const llmCallsCollection = "llm_calls"
+
+// usageSums — суммы полей TokenUsage для $group
+var usageSums = bson.M{
+	"prompt_tokens":     bson.M{"$sum": "$prompt_tokens"},
+	"completion_tokens": bson.M{"$sum": "$completion_tokens"},
+	"cost":              bson.M{"$sum": "$cost"},
+	"calls":             bson.M{"$sum": "$calls"},
+}
+
+// EnsureLLMUsageIndexes создаёт индексы, по которым расход считается для лимитов и отчётов
+func EnsureLLMUsageIndexes() error {
+	_, err := db.GetCollection(llmCallsCollection).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "organization_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "job_id", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}},
+	})
+	return err
+}
+
+func SaveLLMCall(call *models.LLMCall) error {
+	if call.ID.IsZero() {
+		call.ID = primitive.NewObjectID()
+	}
+	call.CreatedAt = time.Now()
+
+	if _, err := db.GetCollection(llmCallsCollection).InsertOne(context.TODO(), call); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка записи расхода запроса к LLM: %v", err))
+		return err
+	}
+	return nil
+}
+
+// SumLLMUsage суммирует расход запросов, подходящих под query
+func SumLLMUsage(query bson.M) (models.TokenUsage, error) {
+	groups, err := aggregateLLMUsage(query, nil)
+	if err != nil || len(groups) == 0 {
+		return models.TokenUsage{}, err
+	}
+	return groups[0].TokenUsage, nil
+}
+
+// GroupLLMUsage суммирует расход запросов, подходящих под query, по полю field;
+// группы отсортированы по убыванию стоимости, затем входных токенов
+func GroupLLMUsage(query bson.M, field string) ([]models.UsageGroup, error) {
+	return aggregateLLMUsage(query, bson.M{"$toString": "$" + field})
+}
+
+func aggregateLLMUsage(query bson.M, key interface{}) ([]models.UsageGroup, error) {
+	group := bson.M{"_id": key}
+	for k, v := range usageSums {
+		group[k] = v
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: query}},
+		{{Key: "$group", Value: group}},
+		{{Key: "$sort", Value: bson.D{{Key: "cost", Value: -1}, {Key: "prompt_tokens", Value: -1}}}},
+	}
+
+	cursor, err := db.GetCollection(llmCallsCollection).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка подсчёта расхода запросов к LLM: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	groups := []models.UsageGroup{}
+	if err := cursor.All(context.TODO(), &groups); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования расхода запросов к LLM: %v", err))
+		return nil, err
+	}
+	return groups, nil
+}
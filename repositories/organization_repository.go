@@ -0,0 +1,134 @@
+// organization_repository.go
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"regexp"
+	"time"
+)
+
+const organizationsCollection = "organizations"
+
+func GetOrganizations() ([]models.Organization, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := db.GetCollection(organizationsCollection).Find(context.TODO(), bson.M{}, opts)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения организаций: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	organizations := []models.Organization{}
+	if err := cursor.All(context.TODO(), &organizations); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования организаций: %v", err))
+		return nil, err
+	}
+	return organizations, nil
+}
+
+func GetOrganization(id primitive.ObjectID) (*models.Organization, error) {
+	var organization models.Organization
+	err := db.GetCollection(organizationsCollection).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&organization)
+	if err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// organizationNameExists проверяет название без учёта регистра; except — ID
+// организации, которая может носить это название (при переименовании)
+func organizationNameExists(name string, except primitive.ObjectID) (bool, error) {
+	query := bson.M{"name": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}}
+	if !except.IsZero() {
+		query["_id"] = bson.M{"$ne": except}
+	}
+	count, err := db.GetCollection(organizationsCollection).CountDocuments(context.TODO(), query)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func CreateOrganization(organization *models.Organization) error {
+	utils.LogAction(fmt.Sprintf("Создание организации: %s", organization.Name))
+
+	exists, err := organizationNameExists(organization.Name, primitive.NilObjectID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return models.ErrOrganizationExists
+	}
+
+	if organization.ID.IsZero() {
+		organization.ID = primitive.NewObjectID()
+	}
+	organization.CreatedAt = time.Now()
+	organization.UpdatedAt = organization.CreatedAt
+
+	if _, err := db.GetCollection(organizationsCollection).InsertOne(context.TODO(), organization); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка сохранения организации: %v", err))
+		return err
+	}
+	return nil
+}
+
+func UpdateOrganization(id primitive.ObjectID, updates bson.M) error {
+	utils.LogAction(fmt.Sprintf("Обновление организации: %s", id.Hex()))
+
+	if name, ok := updates["name"].(string); ok {
+		exists, err := organizationNameExists(name, id)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return models.ErrOrganizationExists
+		}
+	}
+
+	updates["updated_at"] = time.Now()
+	res, err := db.GetCollection(organizationsCollection).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id},
+		bson.M{"$set": updates},
+	)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка обновления организации: %v", err))
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SetUserOrganization привязывает пользователя к организации; нулевой ID отвязывает
+func SetUserOrganization(userID, organizationID primitive.ObjectID) error {
+	update := bson.M{"$set": bson.M{"organization_id": organizationID, "updatedAt": time.Now()}}
+	if organizationID.IsZero() {
+		update = bson.M{
+			"$unset": bson.M{"organization_id": ""},
+			"$set":   bson.M{"updatedAt": time.Now()},
+		}
+	}
+
+	res, err := db.GetCollection("users").UpdateOne(context.TODO(), bson.M{"_id": userID}, update)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка привязки пользователя к организации: %v", err))
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
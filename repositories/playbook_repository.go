@@ -0,0 +1,107 @@
+// playbook_repository.go
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"time"
+)
+
+const playbooksCollection = "playbooks"
+
+// EnsurePlaybookIndexes создаёт индекс, по которому наборы правил загружаются
+// для каждого анализа
+func EnsurePlaybookIndexes() error {
+	_, err := db.GetCollection(playbooksCollection).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "organization_id", Value: 1}, {Key: "name", Value: 1}},
+	})
+	return err
+}
+
+// GetOrganizationPlaybooks возвращает наборы правил организации; activeOnly
+// отбрасывает отключённые
+func GetOrganizationPlaybooks(organizationID primitive.ObjectID, activeOnly bool) ([]models.Playbook, error) {
+	query := bson.M{"organization_id": organizationID}
+	if activeOnly {
+		query["disabled"] = bson.M{"$ne": true}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := db.GetCollection(playbooksCollection).Find(context.TODO(), query, opts)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения наборов правил: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	playbooks := []models.Playbook{}
+	if err := cursor.All(context.TODO(), &playbooks); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования наборов правил: %v", err))
+		return nil, err
+	}
+	return playbooks, nil
+}
+
+func GetPlaybook(id primitive.ObjectID) (*models.Playbook, error) {
+	var playbook models.Playbook
+	err := db.GetCollection(playbooksCollection).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&playbook)
+	if err != nil {
+		return nil, err
+	}
+	return &playbook, nil
+}
+
+func CreatePlaybook(playbook *models.Playbook) error {
+	utils.LogAction(fmt.Sprintf("Создание набора правил: %s", playbook.Name))
+
+	if playbook.ID.IsZero() {
+		playbook.ID = primitive.NewObjectID()
+	}
+	playbook.CreatedAt = time.Now()
+	playbook.UpdatedAt = playbook.CreatedAt
+
+	if _, err := db.GetCollection(playbooksCollection).InsertOne(context.TODO(), playbook); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка сохранения набора правил: %v", err))
+		return err
+	}
+	return nil
+}
+
+func UpdatePlaybook(id primitive.ObjectID, updates bson.M) error {
+	utils.LogAction(fmt.Sprintf("Обновление набора правил: %s", id.Hex()))
+
+	updates["updated_at"] = time.Now()
+	res, err := db.GetCollection(playbooksCollection).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": id},
+		bson.M{"$set": updates},
+	)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка обновления набора правил: %v", err))
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func DeletePlaybook(id primitive.ObjectID) error {
+	res, err := db.GetCollection(playbooksCollection).DeleteOne(context.TODO(), bson.M{"_id": id})
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка удаления набора правил: %v", err))
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// prompt_template_repository.go
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"legally/db"
+	"legally/models"
+	"legally/utils"
+	"time"
+)
+
+const (
+	promptTemplatesCollection = "prompt_templates"
+	// promptTemplateCreateAttempts — сколько раз повторить выбор номера версии,
+	// если его одновременно занял другой запрос
+	promptTemplateCreateAttempts = 5
+)
+
+// EnsurePromptTemplateIndexes создаёт уникальные индексы: номер версии не
+// повторяется в пределах шаблона, и у шаблона не больше одной активной версии
+func EnsurePromptTemplateIndexes() error {
+	_, err := db.GetCollection(promptTemplatesCollection).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "purpose", Value: 1},
+				{Key: "document_type", Value: 1},
+				{Key: "language", Value: 1},
+				{Key: "version", Value: -1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "purpose", Value: 1},
+				{Key: "document_type", Value: 1},
+				{Key: "language", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"active": true}),
+		},
+	})
+	return err
+}
+
+// promptTemplateKeyFilter выбирает все версии шаблона с тем же назначением, типом
+// документа и языком. У шаблонов анализа назначение не хранится, поэтому пустое
+// значение ищется как отсутствующее поле.
+func promptTemplateKeyFilter(purpose, documentType, language string) bson.M {
+	filter := bson.M{"document_type": documentType, "language": language, "purpose": nil}
+	if purpose != "" {
+		filter["purpose"] = purpose
+	}
+	return filter
+}
+
+// GetPromptTemplates возвращает версии шаблонов по фильтру, новые версии первыми
+func GetPromptTemplates(filter bson.M) ([]models.PromptTemplate, error) {
+	opts := options.Find().SetSort(bson.D{
+		{Key: "purpose", Value: 1},
+		{Key: "document_type", Value: 1},
+		{Key: "language", Value: 1},
+		{Key: "version", Value: -1},
+	})
+
+	cursor, err := db.GetCollection(promptTemplatesCollection).Find(context.TODO(), filter, opts)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения шаблонов промптов: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var templates []models.PromptTemplate
+	if err := cursor.All(context.TODO(), &templates); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования шаблонов промптов: %v", err))
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func GetPromptTemplate(id primitive.ObjectID) (*models.PromptTemplate, error) {
+	var tmpl models.PromptTemplate
+	err := db.GetCollection(promptTemplatesCollection).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// CreatePromptTemplate сохраняет шаблон следующей версией для его назначения, типа
+// документа и языка. Если ту же версию одновременно занял другой запрос, уникальный
+// индекс отклоняет вставку и номер выбирается заново.
+func CreatePromptTemplate(tmpl *models.PromptTemplate) error {
+	utils.LogAction(fmt.Sprintf("Создание шаблона промпта: %q/%q", tmpl.DocumentType, tmpl.Language))
+
+	collection := db.GetCollection(promptTemplatesCollection)
+	var err error
+	for attempt := 0; attempt < promptTemplateCreateAttempts; attempt++ {
+		var last models.PromptTemplate
+		err = collection.FindOne(
+			context.TODO(),
+			promptTemplateKeyFilter(tmpl.Purpose, tmpl.DocumentType, tmpl.Language),
+			options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}),
+		).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		tmpl.ID = primitive.NewObjectID()
+		tmpl.Version = last.Version + 1
+		tmpl.Active = false
+		tmpl.CreatedAt = time.Now()
+
+		_, err = collection.InsertOne(context.TODO(), tmpl)
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+		utils.LogWarning(fmt.Sprintf("Версия %d шаблона промпта %q/%q уже занята, выбираем следующую", tmpl.Version, tmpl.DocumentType, tmpl.Language))
+	}
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка сохранения шаблона промпта: %v", err))
+		return err
+	}
+	return nil
+}
+
+// ActivatePromptTemplate делает версию активной, снимая активность с остальных
+// версий того же назначения, типа документа и языка. Транзакции не используются:
+// они доступны только в наборе реплик. Две активные версии не допускает
+// уникальный индекс; если параллельная активация успела включить другую версию
+// между двумя записями, они повторяются один раз.
+func ActivatePromptTemplate(tmpl *models.PromptTemplate) error {
+	utils.LogAction(fmt.Sprintf("Активация шаблона промпта %q/%q v%d", tmpl.DocumentType, tmpl.Language, tmpl.Version))
+
+	collection := db.GetCollection(promptTemplatesCollection)
+	others := promptTemplateKeyFilter(tmpl.Purpose, tmpl.DocumentType, tmpl.Language)
+	others["_id"] = bson.M{"$ne": tmpl.ID}
+	now := time.Now()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if _, err = collection.UpdateMany(context.TODO(), others, bson.M{"$set": bson.M{"active": false}}); err != nil {
+			break
+		}
+
+		var res *mongo.UpdateResult
+		res, err = collection.UpdateOne(
+			context.TODO(),
+			bson.M{"_id": tmpl.ID},
+			bson.M{"$set": bson.M{"active": true, "activated_at": now}},
+		)
+		if err == nil && res.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка активации шаблона промпта: %v", err))
+		return err
+	}
+
+	tmpl.Active = true
+	tmpl.ActivatedAt = &now
+	return nil
+}
+
+func DeletePromptTemplate(id primitive.ObjectID) error {
+	utils.LogAction(fmt.Sprintf("Удаление шаблона промпта: %s", id.Hex()))
+
+	res, err := db.GetCollection(promptTemplatesCollection).DeleteOne(context.TODO(), bson.M{"_id": id})
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка удаления шаблона промпта: %v", err))
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -68,6 +68,57 @@ func GetRAGDocument(id primitive.ObjectID) (*models.RAGDocument, error) {
 	return &doc, nil
 }
 
+// GetProcessedRAGChunks возвращает обработанные документы только с полями,
+// нужными для поиска по фрагментам
+func GetProcessedRAGChunks() ([]models.RAGDocument, error) {
+	opts := options.Find().SetProjection(bson.M{
+		"title":    1,
+		"category": 1,
+		"source":   1,
+		"chunks":   1,
+	})
+
+	cursor, err := db.GetCollection("rag_documents").Find(context.TODO(), bson.M{"status": "processed"}, opts)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения фрагментов RAG документов: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var documents []models.RAGDocument
+	if err := cursor.All(context.TODO(), &documents); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования RAG документов: %v", err))
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// GetProcessedRAGTexts возвращает полные тексты обработанных документов без
+// эмбеддингов и фрагментов
+func GetProcessedRAGTexts() ([]models.RAGDocument, error) {
+	opts := options.Find().SetProjection(bson.M{
+		"title":   1,
+		"source":  1,
+		"content": 1,
+	})
+
+	cursor, err := db.GetCollection("rag_documents").Find(context.TODO(), bson.M{"status": "processed"}, opts)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка получения текстов RAG документов: %v", err))
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var documents []models.RAGDocument
+	if err := cursor.All(context.TODO(), &documents); err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка декодирования RAG документов: %v", err))
+		return nil, err
+	}
+
+	return documents, nil
+}
+
 func GetAllRAGDocuments(limit, offset int) ([]models.RAGDocument, error) {
 	utils.LogAction("Получение всех RAG документов")
 
@@ -0,0 +1,284 @@
+// amounts.go
+
+package rules
+
+import (
+	"fmt"
+	"legally/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const amountDigits = `(\d{1,3}(?:[ \x{00a0}\x{202f}]\d{3})+|\d{1,15})(?:[.,]\d{1,2})?`
+
+var (
+	// amountThenWordsRe — «100 000 (сто тысяч)»
+	amountThenWordsRe = regexp.MustCompile(amountDigits + `\s*\(([^()\n]{2,200})\)`)
+	// wordsThenAmountRe — «сто тысяч (100 000)», в скобках допускается валюта или единица
+	wordsThenAmountRe = regexp.MustCompile(`\(\s*` + amountDigits + `(?:\s*[\p{L}.]+){0,2}\s*\)`)
+)
+
+// maxAmountWords — сколько слов перед скобками просматривается в поисках числа прописью
+const maxAmountWords = 12
+
+// numberWords — количественные числительные; для русских, кроме именительного
+// падежа, есть родительный: «в течение пяти (5) дней»
+var numberWords = func() map[string]int64 {
+	words := map[string]int64{
+		"ноль": 0, "один": 1, "одна": 1, "одно": 1, "одного": 1, "одной": 1,
+		"два": 2, "две": 2, "двух": 2, "три": 3, "трех": 3, "трёх": 3,
+		"четыре": 4, "четырех": 4, "четырёх": 4, "восьми": 8,
+		"сорок": 40, "сорока": 40, "девяносто": 90, "девяноста": 90,
+		"пятьдесят": 50, "пятидесяти": 50, "шестьдесят": 60, "шестидесяти": 60,
+		"семьдесят": 70, "семидесяти": 70, "восемьдесят": 80, "восьмидесяти": 80,
+		"сто": 100, "ста": 100, "двести": 200, "двухсот": 200, "триста": 300, "трехсот": 300, "трёхсот": 300,
+		"четыреста": 400, "четырехсот": 400, "четырёхсот": 400, "пятьсот": 500, "пятисот": 500,
+		"шестьсот": 600, "шестисот": 600, "семьсот": 700, "семисот": 700,
+		"восемьсот": 800, "восьмисот": 800, "девятьсот": 900, "девятисот": 900,
+
+		"нөл": 0, "бір": 1, "екі": 2, "үш": 3, "төрт": 4, "бес": 5, "алты": 6, "жеті": 7,
+		"сегіз": 8, "тоғыз": 9, "он": 10, "жиырма": 20, "отыз": 30, "қырық": 40, "елу": 50,
+		"алпыс": 60, "жетпіс": 70, "сексен": 80, "тоқсан": 90,
+
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7,
+		"eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13,
+		"fourteen": 14, "fifteen": 15, "sixteen": 16, "seventeen": 17, "eighteen": 18,
+		"nineteen": 19, "twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60,
+		"seventy": 70, "eighty": 80, "ninety": 90,
+	}
+	// Числительные на «-ь» склоняются одинаково: «пять» — «пяти»
+	for word, value := range map[string]int64{
+		"пять": 5, "шесть": 6, "семь": 7, "восемь": 8, "девять": 9, "десять": 10,
+		"одиннадцать": 11, "двенадцать": 12, "тринадцать": 13, "четырнадцать": 14,
+		"пятнадцать": 15, "шестнадцать": 16, "семнадцать": 17, "восемнадцать": 18,
+		"девятнадцать": 19, "двадцать": 20, "тридцать": 30,
+	} {
+		words[word] = value
+		if word != "восемь" {
+			words[strings.TrimSuffix(word, "ь")+"и"] = value
+		}
+	}
+	return words
+}()
+
+// hundredWords умножают предыдущее число на сто: «екі жүз», «two hundred»
+var hundredWords = map[string]bool{"жүз": true, "hundred": true}
+
+// scaleWords — основы разрядов; проверяются по началу слова, чтобы покрыть
+// все падежи: «тысяча», «тысячи», «тысяч»
+var scaleWords = []struct {
+	stem  string
+	value int64
+}{
+	{"тысяч", 1_000}, {"мың", 1_000}, {"thousand", 1_000},
+	{"миллиард", 1_000_000_000}, {"миллион", 1_000_000}, {"million", 1_000_000},
+	{"billion", 1_000_000_000},
+}
+
+// amountUnitStems — валюты и единицы, которыми заканчивается число прописью
+var amountUnitStems = []string{
+	"тенге", "теңге", "тг", "тиын", "руб", "коп", "доллар", "евро", "процент", "пайыз",
+	"дн", "день", "календар", "рабоч", "банковск", "месяц", "мес", "лет", "год", "недел",
+	"час", "минут", "штук", "экземпляр", "күн", "ай", "жыл", "апта", "сағат", "дана",
+	"tenge", "kzt", "usd", "eur", "rub", "dollar", "euro", "percent", "day", "business",
+	"working", "calendar", "week", "month", "year", "hour", "copies", "copy", "unit",
+}
+
+// AmountInWordsRule — число цифрами не совпадает с тем же числом прописью
+type AmountInWordsRule struct{}
+
+func (AmountInWordsRule) ID() string                { return "amount_in_words" }
+func (AmountInWordsRule) Kind() models.FindingKind  { return models.FindingAmbiguity }
+func (AmountInWordsRule) Severity() models.Severity { return models.SeverityHigh }
+
+func (AmountInWordsRule) Check(doc *Document) []Issue {
+	var issues []Issue
+	for _, m := range amountThenWordsRe.FindAllStringSubmatchIndex(doc.Text, -1) {
+		if !amountStartsNumber(doc.Text, m[0]) {
+			continue
+		}
+		digits, ok := parseAmountDigits(doc.Text[m[2]:m[3]])
+		if !ok {
+			continue
+		}
+		words, ok := parseNumberWords(wordTokens(doc.Text[m[4]:m[5]]), true)
+		if ok && words != digits {
+			issues = append(issues, amountIssue(doc, m[0], m[1], digits, words))
+		}
+	}
+
+	for _, m := range wordsThenAmountRe.FindAllStringSubmatchIndex(doc.Text, -1) {
+		digits, ok := parseAmountDigits(doc.Text[m[2]:m[3]])
+		if !ok {
+			continue
+		}
+		lineStart, _ := doc.lineAt(m[0])
+		start, words, ok := numberWordsBefore(doc.Text[lineStart:m[0]])
+		if ok && words != digits {
+			issues = append(issues, amountIssue(doc, lineStart+start, m[1], digits, words))
+		}
+	}
+	return issues
+}
+
+func amountIssue(doc *Document, start, end int, digits, words int64) Issue {
+	return Issue{
+		Title: doc.text("Число цифрами не совпадает с числом прописью", "Цифрмен және жазбаша көрсетілген сандар сәйкес емес", "Figures and words do not match"),
+		Description: doc.text(
+			fmt.Sprintf("Цифрами указано %s, прописью — %s. При споре такое расхождение толкуется неоднозначно, обычно в пользу суммы прописью.", formatAmount(digits), formatAmount(words)),
+			fmt.Sprintf("Цифрмен %s, жазбаша — %s көрсетілген. Дау туындаған жағдайда мұндай айырмашылық әртүрлі түсіндіріледі, әдетте жазбаша сома басым болады.", formatAmount(digits), formatAmount(words)),
+			fmt.Sprintf("The figure is %s while the words say %s. In a dispute such a discrepancy is open to interpretation, usually in favour of the amount in words.", formatAmount(digits), formatAmount(words))),
+		Recommendation: doc.text(
+			"Приведите число цифрами и прописью к одному значению.",
+			"Цифрмен және жазбаша көрсетілген санды бір мәнге келтіріңіз.",
+			"Make the figures and the words state the same value."),
+		Span: doc.span(start, end),
+	}
+}
+
+// amountStartsNumber отсекает совпадения внутри «3.1 (три…)» или «1/2 (одна…)»:
+// перед числом не должно быть цифры или разделителя
+func amountStartsNumber(text string, start int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:start])
+	return !unicode.IsDigit(r) && r != '.' && r != ',' && r != '/'
+}
+
+// parseAmountDigits берёт целую часть числа без пробелов между разрядами
+func parseAmountDigits(s string) (int64, bool) {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
+
+func wordTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) })
+}
+
+// parseNumberWords разбирает число прописью. strict требует, чтобы после
+// числительных шли только валюта или единица: «(две тысячи двадцать четвёртого)»
+// — порядковое числительное, а не число, и не разбирается.
+func parseNumberWords(tokens []string, strict bool) (int64, bool) {
+	var total, current int64
+	parsed := 0
+	for _, token := range tokens {
+		if token == "and" {
+			continue
+		}
+		if value, ok := numberWords[token]; ok {
+			current += value
+			parsed++
+			continue
+		}
+		if hundredWords[token] {
+			current = max(current, 1) * 100
+			parsed++
+			continue
+		}
+		if scale := scaleValue(token); scale > 0 {
+			total += max(current, 1) * scale
+			current = 0
+			parsed++
+			continue
+		}
+		if parsed > 0 && isAmountUnit(token) {
+			break
+		}
+		if strict || parsed == 0 {
+			return 0, false
+		}
+		break
+	}
+	if parsed == 0 {
+		return 0, false
+	}
+	return total + current, true
+}
+
+// numberWordsBefore находит число прописью в конце строки перед скобками;
+// валюта между числом и скобками пропускается: «сто тысяч тенге (100 000)».
+// Возвращает байтовое начало числа в строке.
+func numberWordsBefore(line string) (int, int64, bool) {
+	type token struct {
+		word  string
+		start int
+	}
+	var tokens []token
+	start := -1
+	for i, r := range line {
+		switch {
+		case unicode.IsLetter(r) && start < 0:
+			start = i
+		case !unicode.IsLetter(r) && start >= 0:
+			tokens = append(tokens, token{strings.ToLower(line[start:i]), start})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(line[start:]), start})
+	}
+
+	end := len(tokens)
+	for skipped := 0; end > 0 && skipped < 2 && isAmountUnit(tokens[end-1].word); skipped++ {
+		end--
+	}
+	first := end
+	for first > 0 && end-first < maxAmountWords && isNumberWord(tokens[first-1].word) {
+		first--
+	}
+	for first < end && tokens[first].word == "and" {
+		first++
+	}
+	if first == end {
+		return 0, 0, false
+	}
+	words := make([]string, 0, end-first)
+	for _, t := range tokens[first:end] {
+		words = append(words, t.word)
+	}
+	value, ok := parseNumberWords(words, false)
+	return tokens[first].start, value, ok
+}
+
+func isNumberWord(word string) bool {
+	_, ok := numberWords[word]
+	return ok || hundredWords[word] || scaleValue(word) > 0 || word == "and"
+}
+
+func scaleValue(word string) int64 {
+	for _, s := range scaleWords {
+		if strings.HasPrefix(word, s.stem) {
+			return s.value
+		}
+	}
+	return 0
+}
+
+func isAmountUnit(word string) bool {
+	for _, stem := range amountUnitStems {
+		if strings.HasPrefix(word, stem) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatAmount разбивает число на разряды пробелами: 1 500 000
+func formatAmount(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+// amounts_test.go
+
+package rules
+
+import "testing"
+
+func TestAmountInWordsRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		quote string
+	}{
+		{"совпадает", "Цена составляет 1 500 000 (один миллион пятьсот тысяч) тенге.", ""},
+		{"совпадает с тиынами", "Цена 250 000,50 (двести пятьдесят тысяч) тенге 50 тиын.", ""},
+		{"родительный падеж", "в течение 30 (тридцати) календарных дней", ""},
+		{"прописью перед цифрами", "в течение пяти (5) рабочих дней", ""},
+		{"казахский", "Бағасы 250 000 (екі жүз елу мың) теңге.", ""},
+		{"английский", "a fee of 1,250 is not grouped, but twenty-one (21) days is fine", ""},
+		{"не совпадает", "Цена составляет 100 000 (сто десять тысяч) тенге.", "100 000 (сто десять тысяч)"},
+		{"не совпадает перед цифрами", "штраф в размере пятидесяти тысяч тенге (5 000)", "пятидесяти тысяч тенге (5 000)"},
+		{"английский не совпадает", "within ten (15) business days", "ten (15)"},
+		{"порядковое числительное не проверяется", "2024 (две тысячи двадцать четвёртого) года", ""},
+		{"номер пункта не число", "согласно п. 3.1 (три) договора", ""},
+		{"в скобках не число", "Статья 15 (Ответственность сторон)", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := check(t, AmountInWordsRule{}, tt.text)
+			if tt.quote == "" {
+				if len(issues) > 0 {
+					t.Errorf("лишнее нарушение: %+v", issues)
+				}
+				return
+			}
+			if len(issues) != 1 {
+				t.Fatalf("нарушений %d, ожидалось 1", len(issues))
+			}
+			quote(t, tt.text, issues[0], tt.quote)
+		})
+	}
+}
+
+func TestParseNumberWords(t *testing.T) {
+	tests := map[string]int64{
+		"один миллион двести тысяч триста сорок пять": 1_200_345,
+		"две тысячи":                1_000 * 2,
+		"тысяча":                    1_000,
+		"бір жүз мың":               100_000,
+		"екі миллион үш жүз":        2_000_300,
+		"three hundred and twenty":  320,
+		"one million five thousand": 1_005_000,
+	}
+	for words, want := range tests {
+		if got, ok := parseNumberWords(wordTokens(words), true); !ok || got != want {
+			t.Errorf("%q: %d (%v), ожидалось %d", words, got, ok, want)
+		}
+	}
+}
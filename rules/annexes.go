@@ -0,0 +1,95 @@
+// annexes.go
+
+package rules
+
+import (
+	"fmt"
+	"legally/models"
+	"regexp"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxAnnexHeadingRunes — строка длиннее этого — текст договора, а не заголовок
+// приложения
+const maxAnnexHeadingRunes = 150
+
+// annexReferenceRe — «Приложении № 2», «Annex 3», «№ 1 қосымша», «2-қосымшада»
+var annexReferenceRe = regexp.MustCompile(`(?i)(?:^|\P{L})(?:приложени\p{L}*|annex|appendix|schedule|қосымша\p{L}*)\s*(?:№|no\.?)?\s*(\d{1,2})(?:$|\D)|(?:№\s*)?(\d{1,2})\s*-?\s*қосымша`)
+
+// annexHeadingRe — заголовок приложения в начале строки
+var annexHeadingRe = regexp.MustCompile(`(?im)^[ \t]*(?:(?:приложение|annex|appendix|schedule|қосымша)\s*(?:№|no\.?)?\s*(\d{1,2})(?:$|\D)|(?:№\s*)?(\d{1,2})\s*-?\s*қосымша)`)
+
+// MissingAnnexRule — в тексте есть ссылка на приложение, которого в документе нет.
+// Приложением считается строка, начинающаяся с его заголовка: приложения,
+// оформленные отдельными файлами, правило не видит, о чём говорит рекомендация.
+type MissingAnnexRule struct{}
+
+func (MissingAnnexRule) ID() string                { return "missing_annex" }
+func (MissingAnnexRule) Kind() models.FindingKind  { return models.FindingAmbiguity }
+func (MissingAnnexRule) Severity() models.Severity { return models.SeverityMedium }
+
+func (MissingAnnexRule) Check(doc *Document) []Issue {
+	present := make(map[int]bool)
+	for _, m := range annexHeadingRe.FindAllStringSubmatchIndex(doc.Text, -1) {
+		lineStart, lineEnd := doc.lineAt(m[0])
+		if utf8.RuneCountInString(doc.Text[lineStart:lineEnd]) > maxAnnexHeadingRunes {
+			continue
+		}
+		if n, ok := annexNumber(doc.Text, m); ok {
+			present[n] = true
+		}
+	}
+
+	var issues []Issue
+	reported := make(map[int]bool)
+	for _, m := range annexReferenceRe.FindAllStringSubmatchIndex(doc.Text, -1) {
+		n, ok := annexNumber(doc.Text, m)
+		if !ok || present[n] || reported[n] {
+			continue
+		}
+		reported[n] = true
+
+		start, end := m[0], m[1]
+		if r, size := utf8.DecodeRuneInString(doc.Text[start:]); !isAnnexRune(r) {
+			start += size
+		}
+		if r, size := utf8.DecodeLastRuneInString(doc.Text[:end]); !isAnnexRune(r) {
+			end -= size
+		}
+		issues = append(issues, Issue{
+			Title: doc.text(
+				fmt.Sprintf("Нет приложения № %d", n),
+				fmt.Sprintf("№ %d қосымша жоқ", n),
+				fmt.Sprintf("Annex %d is missing", n)),
+			Description: doc.text(
+				fmt.Sprintf("Документ ссылается на приложение № %d, но в тексте его нет. Условия, вынесенные в приложение, без него не согласованы.", n),
+				fmt.Sprintf("Құжат № %d қосымшаға сілтеме жасайды, бірақ мәтінде ол жоқ. Қосымшаға шығарылған талаптар онсыз келісілмеген болып саналады.", n),
+				fmt.Sprintf("The document refers to annex %d, but the text does not contain it. Terms placed in the annex are not agreed without it.", n)),
+			Recommendation: doc.text(
+				"Приложите приложение к документу или, если оно оформлено отдельным файлом, проверьте, что оно подписано вместе с документом; лишнюю ссылку удалите.",
+				"Қосымшаны құжатқа тіркеңіз немесе ол жеке файлмен ресімделсе, құжатпен бірге қол қойылғанын тексеріңіз; артық сілтемені алып тастаңыз.",
+				"Attach the annex or, if it is a separate file, make sure it is signed together with the document; otherwise remove the reference."),
+			Span: doc.span(start, end),
+		})
+	}
+	return issues
+}
+
+// annexNumber берёт номер приложения из той группы, что совпала
+func annexNumber(text string, m []int) (int, bool) {
+	for g := 2; g+1 < len(m); g += 2 {
+		if m[g] >= 0 {
+			n, err := strconv.Atoi(text[m[g]:m[g+1]])
+			return n, err == nil && n > 0
+		}
+	}
+	return 0, false
+}
+
+// isAnnexRune — символ, которым может начинаться или заканчиваться ссылка:
+// граничный символ, захваченный выражением, в цитату не входит
+func isAnnexRune(r rune) bool {
+	return r == '№' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
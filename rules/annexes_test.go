@@ -0,0 +1,50 @@
+// annexes_test.go
+
+package rules
+
+import "testing"
+
+func TestMissingAnnexRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		missing []string
+	}{
+		{
+			"приложение есть",
+			"Спецификация указана в Приложении № 1 к Договору.\n\nПриложение № 1\nк договору поставки\nСпецификация",
+			nil,
+		},
+		{
+			"одного приложения нет",
+			"Спецификация — в Приложении № 1, график платежей — в приложении №2.\n\nПриложение № 1\nСпецификация",
+			[]string{"приложении №2"},
+		},
+		{
+			"казахские ссылки",
+			"Ерекшелік 1-қосымшада, кесте № 2 қосымшада келтірілген.\n\n1-қосымша\nЕрекшелік",
+			[]string{"2 қосымша"},
+		},
+		{
+			"английские ссылки",
+			"The price list is set out in Annex 3.\n\nAnnex 1\nSpecification",
+			[]string{"Annex 3"},
+		},
+		{
+			"длинная строка — не заголовок",
+			"Приложение № 4 является неотъемлемой частью настоящего договора, и Стороны обязуются соблюдать его положения на протяжении всего срока действия договора и после его окончания.",
+			[]string{"Приложение № 4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := check(t, MissingAnnexRule{}, tt.text)
+			if len(issues) != len(tt.missing) {
+				t.Fatalf("нарушений %d, ожидалось %d: %+v", len(issues), len(tt.missing), issues)
+			}
+			for i, want := range tt.missing {
+				quote(t, tt.text, issues[i], want)
+			}
+		})
+	}
+}
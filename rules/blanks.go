@@ -0,0 +1,93 @@
+// blanks.go
+
+package rules
+
+import (
+	"fmt"
+	"legally/models"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxBlankExamples — сколько незаполненных мест приводится в описании
+	maxBlankExamples = 5
+	// blankExampleRunes — длина строки с пропуском в описании
+	blankExampleRunes = 120
+	// signatureAreaShare — доля конца документа, где строки из одних
+	// подчёркиваний считаются местом для подписи
+	signatureAreaShare = 5
+)
+
+// blankRe — пропуск для заполнения: «_____», «.........», «……»
+var blankRe = regexp.MustCompile(`_{2,}|\.{5,}|…{3,}`)
+
+// signatureLineRe — строка с местом для подписи: «____ / Иванов И.И. /», «М.П.»
+var signatureLineRe = regexp.MustCompile(`(?i)/|м\.\s*п\.|(?:^|\P{L})(?:подпись|қолы|мөр|печать|signature)(?:$|\P{L})|(?:^|\P{L})\p{Lu}\.\s?\p{Lu}\.`)
+
+// BlankFieldsRule — в документе остались незаполненные поля шаблона. Места для
+// подписей незаполненными полями не считаются: документ проверяют до подписания.
+type BlankFieldsRule struct{}
+
+func (BlankFieldsRule) ID() string                { return "blank_fields" }
+func (BlankFieldsRule) Kind() models.FindingKind  { return models.FindingAmbiguity }
+func (BlankFieldsRule) Severity() models.Severity { return models.SeverityMedium }
+
+func (BlankFieldsRule) Check(doc *Document) []Issue {
+	signatureArea := len(doc.Text) - len(doc.Text)/signatureAreaShare
+
+	var examples []string
+	var first *models.SourceSpan
+	count := 0
+	lastLine := -1
+	for _, m := range blankRe.FindAllStringIndex(doc.Text, -1) {
+		lineStart, lineEnd := doc.lineAt(m[0])
+		line := doc.Text[lineStart:lineEnd]
+		if signatureLineRe.MatchString(line) {
+			continue
+		}
+		if lineStart >= signatureArea && onlyBlanks(line) {
+			continue
+		}
+		count++
+		if first == nil {
+			first = doc.span(m[0], m[1])
+		}
+		if lineStart != lastLine && len(examples) < maxBlankExamples {
+			examples = append(examples, "«"+truncate(strings.TrimSpace(line), blankExampleRunes)+"»")
+		}
+		lastLine = lineStart
+	}
+	if count == 0 {
+		return nil
+	}
+
+	return []Issue{{
+		Title: doc.text("Незаполненные поля", "Толтырылмаған өрістер", "Unfilled blanks"),
+		Description: doc.text(
+			fmt.Sprintf("В документе осталось незаполненных мест: %d. Например: %s. Незаполненное условие считается несогласованным или может быть дописано одной из сторон.", count, strings.Join(examples, "; ")),
+			fmt.Sprintf("Құжатта толтырылмаған орындар қалды: %d. Мысалы: %s. Толтырылмаған талап келісілмеген болып саналады немесе оны тараптардың бірі толтыруы мүмкін.", count, strings.Join(examples, "; ")),
+			fmt.Sprintf("The document still has %d unfilled blanks, for example: %s. A blank term is not agreed or may be filled in by one party alone.", count, strings.Join(examples, "; "))),
+		Recommendation: doc.text(
+			"Заполните пропуски до подписания или прочеркните неприменимые поля.",
+			"Қол қойылғанға дейін бос орындарды толтырыңыз немесе қолданылмайтын өрістерді сызып тастаңыз.",
+			"Fill in the blanks before signing or strike out the fields that do not apply."),
+		Span: first,
+	}}
+}
+
+// onlyBlanks сообщает, что в строке нет ничего, кроме пропусков
+func onlyBlanks(line string) bool {
+	return strings.IndexFunc(blankRe.ReplaceAllString(line, ""), func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) < 0
+}
+
+// truncate обрезает строку до n символов, отмечая обрезку многоточием
+func truncate(s string, n int) string {
+	if cut := prefixRunes(s, n); len(cut) < len(s) {
+		return cut + "…"
+	}
+	return s
+}
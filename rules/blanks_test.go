@@ -0,0 +1,49 @@
+// blanks_test.go
+
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBlankFieldsRule(t *testing.T) {
+	const filler = "\nСтороны исполняют обязательства надлежащим образом.\n"
+	tests := []struct {
+		name  string
+		text  string
+		count string
+		quote string
+	}{
+		{"нет пропусков", "Цена договора — 100 000 тенге." + filler, "", ""},
+		{"пропуск в условии", "Срок поставки — ____ дней с даты подписания." + filler, "1", "____"},
+		{"дата не заполнена", "г. Алматы «__» ________ 20__ г." + filler, "3", "__"},
+		{"многоточие", "Покупатель: ............................" + filler, "1", "......"},
+		{"подписи не в счёт", filler + strings.Repeat(filler, 5) + "Поставщик ____________ / Иванов И.И. /\nМ.П. ______\nДиректор Петров П.П. ________\n______________ (подпись)\n______________\n", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := check(t, BlankFieldsRule{}, tt.text)
+			if tt.count == "" {
+				if len(issues) > 0 {
+					t.Errorf("лишнее нарушение: %+v", issues)
+				}
+				return
+			}
+			if len(issues) != 1 {
+				t.Fatalf("нарушений %d, ожидалось 1", len(issues))
+			}
+			if !strings.Contains(issues[0].Description, ": "+tt.count+".") {
+				t.Errorf("описание %q, ожидалось пропусков: %s", issues[0].Description, tt.count)
+			}
+			quote(t, tt.text, issues[0], tt.quote)
+		})
+	}
+}
+
+func TestBlankFieldsRuleKeepsFillLinesOutsideSignatures(t *testing.T) {
+	text := "Особые условия:\n______________________\n" + strings.Repeat("Стороны исполняют обязательства. ", 20)
+	if issues := check(t, BlankFieldsRule{}, text); len(issues) != 1 {
+		t.Errorf("нарушений %d, ожидалось 1", len(issues))
+	}
+}
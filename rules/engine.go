@@ -0,0 +1,144 @@
+// engine.go
+
+// Package rules — детерминированные проверки документа, которым не нужна
+// модель: реквизиты, контрольные разряды БИН/ИИН, суммы цифрами и прописью и
+// т. п. Правила работают по тексту документа и возвращают выводы в том же
+// формате, что и LLM-анализ.
+package rules
+
+import (
+	"legally/models"
+	"legally/utils"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rule — одна проверка документа
+type Rule interface {
+	// ID — постоянный ключ правила: по нему правило отключается и находятся его выводы
+	ID() string
+	Kind() models.FindingKind
+	Severity() models.Severity
+	Check(doc *Document) []Issue
+}
+
+// Issue — нарушение, найденное правилом. Span указывает на фрагмент текста;
+// у нарушений вида «нет даты», «нет реквизитов» фрагмента нет.
+type Issue struct {
+	Title          string
+	Description    string
+	Recommendation string
+	Span           *models.SourceSpan
+}
+
+// Document — разобранный документ, общий для всех правил
+type Document struct {
+	Text string
+	// Language — язык формулировок выводов (ru, kk, en)
+	Language string
+	Clauses  []utils.Clause
+}
+
+// Parse готовит документ к проверке
+func Parse(text, language string) *Document {
+	return &Document{
+		Text:     text,
+		Language: language,
+		Clauses:  utils.SplitClauses(text),
+	}
+}
+
+// span переводит байтовые границы фрагмента в символьные, как у цитат LLM-анализа
+func (d *Document) span(start, end int) *models.SourceSpan {
+	runeStart := utf8.RuneCountInString(d.Text[:start])
+	return &models.SourceSpan{
+		Start: runeStart,
+		End:   runeStart + utf8.RuneCountInString(d.Text[start:end]),
+		Quote: d.Text[start:end],
+	}
+}
+
+// lineAt возвращает границы строки, содержащей байт pos
+func (d *Document) lineAt(pos int) (int, int) {
+	start := strings.LastIndexByte(d.Text[:pos], '\n') + 1
+	end := strings.IndexByte(d.Text[pos:], '\n')
+	if end < 0 {
+		return start, len(d.Text)
+	}
+	return start, pos + end
+}
+
+// text выбирает формулировку на языке документа; по умолчанию — русскую
+func (d *Document) text(ru, kk, en string) string {
+	switch d.Language {
+	case "kk":
+		return kk
+	case "en":
+		return en
+	}
+	return ru
+}
+
+// Engine применяет набор правил к документу
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Default — движок со всеми встроенными правилами
+func Default() *Engine {
+	return NewEngine(
+		SigningDetailsRule{},
+		RequisitesRule{},
+		TaxpayerNumberRule{},
+		AmountInWordsRule{},
+		MissingAnnexRule{},
+		BlankFieldsRule{},
+	)
+}
+
+// Rules возвращает правила движка
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// Without возвращает движок без правил с указанными ID
+func (e *Engine) Without(ids ...string) *Engine {
+	skip := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		skip[strings.TrimSpace(id)] = true
+	}
+	var rules []Rule
+	for _, rule := range e.rules {
+		if !skip[rule.ID()] {
+			rules = append(rules, rule)
+		}
+	}
+	return NewEngine(rules...)
+}
+
+// Run проверяет документ всеми правилами. Выводы помечаются источником
+// FindingSourceRule; вероятность у них высокая: нарушение не предполагается,
+// а найдено в тексте.
+func (e *Engine) Run(doc *Document) []models.Finding {
+	var findings []models.Finding
+	for _, rule := range e.rules {
+		for _, issue := range rule.Check(doc) {
+			findings = append(findings, models.Finding{
+				Kind:           rule.Kind(),
+				Title:          issue.Title,
+				Description:    issue.Description,
+				Severity:       rule.Severity(),
+				Recommendation: issue.Recommendation,
+				Likelihood:     models.SeverityHigh,
+				Source:         models.FindingSourceRule,
+				Rule:           rule.ID(),
+				Span:           issue.Span,
+			})
+		}
+	}
+	return findings
+}
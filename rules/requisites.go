@@ -0,0 +1,70 @@
+// requisites.go
+
+package rules
+
+import (
+	"legally/models"
+	"regexp"
+	"unicode/utf8"
+)
+
+// requisitesTailRunes — минимальный хвост документа, в котором ищется блок
+// реквизитов; у длинных документов берётся последняя треть
+const requisitesTailRunes = 3000
+
+var requisitesHeadingRe = regexp.MustCompile(`(?i)реквизит|деректемел|requisites|bank\s+details|details\s+of\s+the\s+parties`)
+
+// requisitesMarkers — составные части реквизитов: регистрационный номер,
+// банковский счёт и код банка. Блок считается заполненным, если есть хотя бы два.
+var requisitesMarkers = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:^|\P{L})(?:бин|иин|бсн|жсн)(?:$|\P{L})|\b(?:BIN|IIN)\b`),
+	regexp.MustCompile(`(?i)(?:^|\P{L})(?:иик|жск|р/с|расч[её]тный\s+сч[её]т)|\b(?:IBAN|IIK)\b|\bKZ\d{2}[0-9A-Z]{13}`),
+	regexp.MustCompile(`(?i)(?:^|\P{L})(?:бик|бск)(?:$|\P{L})|\b(?:BIC|SWIFT)\b`),
+}
+
+// RequisitesRule — в договоре нет блока реквизитов сторон или он неполон
+type RequisitesRule struct{}
+
+func (RequisitesRule) ID() string                { return "requisites" }
+func (RequisitesRule) Kind() models.FindingKind  { return models.FindingRisk }
+func (RequisitesRule) Severity() models.Severity { return models.SeverityMedium }
+
+func (RequisitesRule) Check(doc *Document) []Issue {
+	if !doc.isAgreement() {
+		return nil
+	}
+	tail := suffixRunes(doc.Text, max(requisitesTailRunes, utf8.RuneCountInString(doc.Text)/3))
+
+	markers := 0
+	for _, re := range requisitesMarkers {
+		if re.MatchString(tail) {
+			markers++
+		}
+	}
+	if markers >= 2 {
+		return nil
+	}
+
+	recommendation := doc.text(
+		"Добавьте в конец договора реквизиты каждой стороны: наименование, БИН/ИИН, юридический адрес, банковский счёт (IBAN), БИК банка и подпись уполномоченного лица.",
+		"Шарттың соңына әр тараптың деректемелерін қосыңыз: атауы, БСН/ЖСН, заңды мекенжайы, банк шоты (IBAN), банктің БСК және уәкілетті тұлғаның қолы.",
+		"Add each party's details at the end of the agreement: name, BIN/IIN, registered address, bank account (IBAN), bank BIC and the authorised signatory.")
+	if requisitesHeadingRe.MatchString(tail) {
+		return []Issue{{
+			Title: doc.text("Реквизиты сторон неполные", "Тараптардың деректемелері толық емес", "Party details are incomplete"),
+			Description: doc.text(
+				"Раздел реквизитов есть, но в нём не хватает регистрационных номеров или банковских данных сторон. По таким реквизитам сложно идентифицировать сторону и провести платёж.",
+				"Деректемелер бөлімі бар, бірақ онда тараптардың тіркеу нөмірлері немесе банк деректері жетіспейді. Мұндай деректемелер бойынша тарапты сәйкестендіру және төлем жүргізу қиын.",
+				"There is a details section, but it lacks the parties' registration numbers or bank data, which makes it hard to identify the parties and make payments."),
+			Recommendation: recommendation,
+		}}
+	}
+	return []Issue{{
+		Title: doc.text("Нет реквизитов сторон", "Тараптардың деректемелері жоқ", "Party details are missing"),
+		Description: doc.text(
+			"В конце документа не найден блок реквизитов сторон. Без БИН/ИИН, адресов и банковских данных сторону трудно идентифицировать, а исполнение денежных обязательств и направление уведомлений затруднены.",
+			"Құжаттың соңында тараптардың деректемелер блогы табылмады. БСН/ЖСН, мекенжайлар мен банк деректерінсіз тарапты сәйкестендіру, ақшалай міндеттемелерді орындау және хабарламалар жіберу қиын.",
+			"No party details block was found at the end of the document. Without BIN/IIN, addresses and bank data, the parties are hard to identify, and payments and notices are hampered."),
+		Recommendation: recommendation,
+	}}
+}
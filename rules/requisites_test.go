@@ -0,0 +1,43 @@
+// requisites_test.go
+
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequisitesRule(t *testing.T) {
+	const body = "ДОГОВОР № 5\nг. Алматы 15.03.2024\nСтороны договорились о следующем. Стороны несут ответственность.\n"
+	tests := []struct {
+		name  string
+		text  string
+		title string
+	}{
+		{"полные реквизиты", body + "8. Реквизиты сторон\nТОО «Альфа», БИН 990340001230, ИИК KZ86125KZT5004100100, БИК KCJBKZKX", ""},
+		{"казахские реквизиты", body + "8. Тараптардың деректемелері\nБСН 990340001230, ЖСК KZ86125KZT5004100100", ""},
+		{"раздел без банковских данных", body + "8. Реквизиты сторон\nТОО «Альфа», г. Алматы, ул. Абая, 1", "Реквизиты сторон неполные"},
+		{"нет реквизитов", body + "8. Заключительные положения\nДоговор составлен в двух экземплярах.", "Нет реквизитов сторон"},
+		{"не договор", "Справка о составе учредителей. Выдана по месту требования.", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := check(t, RequisitesRule{}, tt.text)
+			switch {
+			case tt.title == "" && len(issues) > 0:
+				t.Errorf("лишнее нарушение: %+v", issues)
+			case tt.title != "" && (len(issues) != 1 || !strings.Contains(issues[0].Title, tt.title)):
+				t.Errorf("нарушения %+v, ожидалось «%s»", issues, tt.title)
+			}
+		})
+	}
+}
+
+func TestRequisitesRuleIgnoresPreamble(t *testing.T) {
+	// БИН и банк в преамбуле длинного договора не заменяют блок реквизитов в конце
+	text := "ДОГОВОР\nТОО «Альфа» (БИН 990340001230, БИК KCJBKZKX), далее Сторона.\n" +
+		strings.Repeat("Стороны исполняют обязательства надлежащим образом. ", 200)
+	if issues := check(t, RequisitesRule{}, text); len(issues) != 1 {
+		t.Errorf("нарушений %d, ожидалось 1", len(issues))
+	}
+}
@@ -0,0 +1,63 @@
+// rules_test.go
+
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+// check прогоняет правило по тексту и возвращает найденные нарушения
+func check(t *testing.T, rule Rule, text string) []Issue {
+	t.Helper()
+	return rule.Check(Parse(text, "ru"))
+}
+
+// quote проверяет, что фрагмент нарушения указывает на нужное место текста
+func quote(t *testing.T, text string, issue Issue, want string) {
+	t.Helper()
+	if issue.Span == nil {
+		t.Fatalf("нет фрагмента, ожидался %q", want)
+	}
+	runes := []rune(text)
+	if got := string(runes[issue.Span.Start:issue.Span.End]); got != issue.Span.Quote || !strings.Contains(got, want) {
+		t.Errorf("фрагмент %q (цитата %q), ожидался %q", got, issue.Span.Quote, want)
+	}
+}
+
+func TestEngineRunTagsFindings(t *testing.T) {
+	text := "Договор. Стороны договорились. БИН 990340001231."
+	findings := NewEngine(TaxpayerNumberRule{}).Run(Parse(text, "ru"))
+	if len(findings) != 1 {
+		t.Fatalf("выводов %d, ожидался 1", len(findings))
+	}
+	f := findings[0]
+	if f.Source != "rule" || f.Rule != "taxpayer_number" || f.Severity != (TaxpayerNumberRule{}).Severity() || f.Kind != (TaxpayerNumberRule{}).Kind() {
+		t.Errorf("неверная разметка вывода: %+v", f)
+	}
+}
+
+func TestEngineWithout(t *testing.T) {
+	engine := Default().Without("blank_fields", " amount_in_words ")
+	if len(engine.Rules()) != len(Default().Rules())-2 {
+		t.Fatalf("правил %d", len(engine.Rules()))
+	}
+	for _, rule := range engine.Rules() {
+		if rule.ID() == "blank_fields" || rule.ID() == "amount_in_words" {
+			t.Errorf("правило %s не отключено", rule.ID())
+		}
+	}
+}
+
+func TestRuleIDsUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, rule := range Default().Rules() {
+		if seen[rule.ID()] {
+			t.Errorf("повторяется ID %s", rule.ID())
+		}
+		seen[rule.ID()] = true
+		if !rule.Severity().IsValid() {
+			t.Errorf("у правила %s неверная важность %q", rule.ID(), rule.Severity())
+		}
+	}
+}
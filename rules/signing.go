@@ -0,0 +1,136 @@
+// signing.go
+
+package rules
+
+import (
+	"legally/models"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// signingHeaderRunes — начало документа, где в преамбуле указываются место и
+	// дата заключения
+	signingHeaderRunes = 800
+	// signingFooterRunes — конец документа с подписями, где дату тоже ставят
+	signingFooterRunes = 2000
+)
+
+const monthNames = `января|февраля|марта|апреля|мая|июня|июля|августа|сентября|октября|ноября|декабря|` +
+	`қаңтар|ақпан|наурыз|сәуір|мамыр|маусым|шілде|тамыз|қыркүйек|қазан|қараша|желтоқсан|` +
+	`january|february|march|april|may|june|july|august|september|october|november|december`
+
+var signingDateRe = regexp.MustCompile(`(?i)` +
+	`\b\d{1,2}[./]\d{1,2}[./](?:19|20)\d{2}\b` +
+	`|\b(?:19|20)\d{2}-\d{2}-\d{2}\b` +
+	`|\d{1,2}\s*[»"”]?\s*(?:` + monthNames + `)\s*,?\s*(?:19|20)\d{2}` +
+	`|(?:` + monthNames + `)\s+\d{1,2},?\s+(?:19|20)\d{2}` +
+	`|(?:19|20)\d{2}\s*(?:жылғы|ж\.)\s*[«"]?\d{1,2}[»"]?\s*(?:` + monthNames + `)`)
+
+// signingCityRe — крупные города Казахстана: место заключения обычно указывают
+// без «г.», а в адресах сторон они встречаются только в конце документа.
+// \b в regexp понимает только латиницу, поэтому границы слов заданы через \P{L}.
+var signingCityRe = regexp.MustCompile(`(?i)(?:^|\P{L})(?:алматы|астана|нур-султан|нұр-сұлтан|шымкент|караганд[аы]|қарағанды|актобе|ақтөбе|атырау|павлодар|костанай|қостанай|усть-каменогорск|өскемен|семей|тараз|актау|ақтау|кызылорда|қызылорда|уральск|орал|петропавловск|туркестан|түркістан|кокшетау|көкшетау|талдыкорган|талдықорған|экибастуз|екібастұз|жезказган|жезқазған|almaty|astana|shymkent|karaganda|aktobe|atyrau|pavlodar|kostanay|taraz|aktau)(?:$|\P{L})`)
+
+// signingPlaceRe — «г. Конаев», «город …», «… қаласы», «city of …»; «г.» после
+// года означает «год» и отсекается отдельно
+var signingPlaceRe = regexp.MustCompile(`(?:^|\P{L})(?:город|гор\.|г\.|с\.|село|пос\.)\s*[А-ЯЁӘІҢҒҮҰҚӨҺ]|[А-ЯЁӘІҢҒҮҰҚӨҺ][\p{L}-]+\s+(?:қаласы|қ\.|ауылы)|(?i:\bcity\s+of\s+)[A-Z]`)
+
+// agreementRe — признак договора или иного документа сторон, у которого есть
+// место и дата заключения и реквизиты
+var agreementRe = regexp.MustCompile(`(?i)сторон|тарап|\bpart(?:y|ies)\b`)
+
+// isAgreement сообщает, что документ заключается сторонами: к законам, справкам
+// и письмам правила о подписании и реквизитах не применяются
+func (d *Document) isAgreement() bool {
+	return len(agreementRe.FindAllStringIndex(d.Text, 2)) == 2
+}
+
+// SigningDetailsRule — в документе не указаны дата или место заключения
+type SigningDetailsRule struct{}
+
+func (SigningDetailsRule) ID() string                { return "signing_details" }
+func (SigningDetailsRule) Kind() models.FindingKind  { return models.FindingRisk }
+func (SigningDetailsRule) Severity() models.Severity { return models.SeverityMedium }
+
+func (SigningDetailsRule) Check(doc *Document) []Issue {
+	if !doc.isAgreement() {
+		return nil
+	}
+	header := prefixRunes(doc.Text, signingHeaderRunes)
+	footer := suffixRunes(doc.Text, signingFooterRunes)
+
+	var issues []Issue
+	if !signingDateRe.MatchString(header) && !signingDateRe.MatchString(footer) {
+		issues = append(issues, Issue{
+			Title: doc.text("Не указана дата заключения", "Жасалған күні көрсетілмеген", "Signing date is missing"),
+			Description: doc.text(
+				"Ни в преамбуле, ни в блоке подписей нет даты заключения документа. Без неё сложно определить начало действия условий, сроки исполнения и исковой давности.",
+				"Кіріспеде де, қолтаңба блогында да құжаттың жасалған күні жоқ. Онсыз талаптардың күшіне енуін, орындау және талап қою мерзімдерін анықтау қиын.",
+				"Neither the preamble nor the signature block states the signing date. Without it, the effective date and the performance and limitation periods are hard to determine."),
+			Recommendation: doc.text(
+				"Укажите дату заключения в преамбуле или рядом с подписями сторон.",
+				"Жасалған күнін кіріспеде немесе тараптардың қолтаңбаларының жанында көрсетіңіз.",
+				"State the signing date in the preamble or next to the parties' signatures."),
+		})
+	}
+	if !hasSigningPlace(header) {
+		issues = append(issues, Issue{
+			Title: doc.text("Не указано место заключения", "Жасалған орны көрсетілмеген", "Place of signing is missing"),
+			Description: doc.text(
+				"В преамбуле не указан населённый пункт, в котором заключён документ. Место заключения может влиять на применимое право и подсудность споров.",
+				"Кіріспеде құжат жасалған елді мекен көрсетілмеген. Жасалған орны қолданылатын құқыққа және даулардың соттылығына әсер етуі мүмкін.",
+				"The preamble does not name the place where the document was signed. The place of signing may affect the governing law and jurisdiction."),
+			Recommendation: doc.text(
+				"Укажите в преамбуле город (населённый пункт) заключения.",
+				"Кіріспеде жасалған қаланы (елді мекенді) көрсетіңіз.",
+				"Name the city of signing in the preamble."),
+		})
+	}
+	return issues
+}
+
+func hasSigningPlace(header string) bool {
+	if signingCityRe.MatchString(header) {
+		return true
+	}
+	for _, loc := range signingPlaceRe.FindAllStringIndex(header, -1) {
+		// «2024 г. Настоящий договор…» — это год, а не город. Граничный символ
+		// перед «г.» входит в совпадение; с новой строки «г.» — всегда город.
+		start := loc[0]
+		if r, size := utf8.DecodeRuneInString(header[start:]); !unicode.IsLetter(r) {
+			if r == '\n' {
+				return true
+			}
+			start += size
+		}
+		before := strings.TrimRight(header[:start], " \t\u00a0")
+		if r, _ := utf8.DecodeLastRuneInString(before); !unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixRunes возвращает первые n символов строки
+func prefixRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// suffixRunes возвращает последние n символов строки
+func suffixRunes(s string, n int) string {
+	i := len(s)
+	for ; i > 0 && n > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+	}
+	return s[i:]
+}
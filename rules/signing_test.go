@@ -0,0 +1,30 @@
+// signing_test.go
+
+package rules
+
+import "testing"
+
+func TestSigningDetailsRule(t *testing.T) {
+	const body = "\n1. Предмет договора\nПоставщик обязуется передать товар, а Покупатель — принять его. Стороны несут ответственность по закону. Споры сторон разрешаются в суде.\n"
+	tests := []struct {
+		name   string
+		text   string
+		issues int
+	}{
+		{"дата и город в преамбуле", "ДОГОВОР ПОСТАВКИ № 5\nг. Алматы «15» марта 2024 г." + body, 0},
+		{"город без «г.» и дата цифрами", "ДОГОВОР № 5\nАстана 15.03.2024" + body, 0},
+		{"дата в блоке подписей", "ДОГОВОР № 5\nг. Конаев" + body + "\nПодписан 01.04.2024", 0},
+		{"казахская преамбула", "ШАРТ № 5\nАлматы қ. 2024 жылғы 15 наурыз" + body, 0},
+		{"нет даты", "ДОГОВОР № 5\nг. Алматы «___» ________ 20__ г." + body, 1},
+		{"«г.» после года — не город", "ДОГОВОР № 5\n15 марта 2024 г. ТОО «Альфа» и ТОО «Бета»" + body, 1},
+		{"нет ни даты, ни места", "ДОГОВОР № 5" + body, 2},
+		{"не договор", "Закон о поставках. Глава 1. Общие положения.", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if issues := check(t, SigningDetailsRule{}, tt.text); len(issues) != tt.issues {
+				t.Errorf("нарушений %d, ожидалось %d: %+v", len(issues), tt.issues, issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// taxpayer.go
+
+package rules
+
+import (
+	"fmt"
+	"legally/models"
+	"legally/utils"
+	"regexp"
+	"strings"
+)
+
+// taxpayerNumberRe — БИН или ИИН после своего обозначения: 12 цифр слитно или
+// группами («880101 300123», «8801 0130 0123», «880 101 300 123»), а также
+// сплошной ряд цифр другой длины, чтобы сообщить об опечатке. Произвольные
+// пробелы не допускаются: иначе к номеру прилипает следующее число
+// («ИИН 880101300123 1 экз.»).
+var taxpayerNumberRe = regexp.MustCompile(`(?i)(?:^|\P{L})(бин|иин|бсн|жсн|bin|iin)\s*(?:[:№-]\s*)?(\d{12}|\d{6} \d{6}|\d{4}(?: \d{4}){2}|\d{3}(?: \d{3}){3}|\d{10,18})(?:\D|$)`)
+
+// TaxpayerNumberRule — БИН или ИИН неверной длины либо с неверным контрольным разрядом
+type TaxpayerNumberRule struct{}
+
+func (TaxpayerNumberRule) ID() string                { return "taxpayer_number" }
+func (TaxpayerNumberRule) Kind() models.FindingKind  { return models.FindingViolation }
+func (TaxpayerNumberRule) Severity() models.Severity { return models.SeverityHigh }
+
+func (TaxpayerNumberRule) Check(doc *Document) []Issue {
+	var issues []Issue
+	seen := make(map[string]bool)
+	for _, m := range taxpayerNumberRe.FindAllStringSubmatchIndex(doc.Text, -1) {
+		label := strings.ToUpper(doc.Text[m[2]:m[3]])
+		number := strings.ReplaceAll(doc.Text[m[4]:m[5]], " ", "")
+		if seen[number] {
+			continue
+		}
+		seen[number] = true
+
+		var description string
+		switch {
+		case len(number) != 12:
+			description = doc.text(
+				fmt.Sprintf("%s %s состоит из %d цифр, а должен из 12.", label, number, len(number)),
+				fmt.Sprintf("%s %s %d цифрдан тұрады, ал 12 цифр болуы керек.", label, number, len(number)),
+				fmt.Sprintf("%s %s has %d digits instead of 12.", label, number, len(number)))
+		case !utils.ValidTaxpayerNumber(number):
+			description = doc.text(
+				fmt.Sprintf("У %s %s не сходится контрольный разряд: номер содержит опечатку или не существует.", label, number),
+				fmt.Sprintf("%s %s бақылау разряды сәйкес келмейді: нөмірде қате бар немесе ол жоқ.", label, number),
+				fmt.Sprintf("The check digit of %s %s does not match: the number has a typo or does not exist.", label, number))
+		default:
+			continue
+		}
+		issues = append(issues, Issue{
+			Title:       doc.text("Некорректный БИН/ИИН", "БСН/ЖСН қате", "Invalid BIN/IIN"),
+			Description: description,
+			Recommendation: doc.text(
+				"Сверьте номер с регистрационными документами стороны или данными КГД и исправьте его: ошибка в БИН/ИИН мешает идентифицировать сторону и провести платёж.",
+				"Нөмірді тараптың тіркеу құжаттарымен немесе МКК деректерімен салыстырып, түзетіңіз: БСН/ЖСН қатесі тарапты сәйкестендіруге және төлем жүргізуге кедергі келтіреді.",
+				"Check the number against the party's registration documents or the tax authority's records and correct it: a wrong BIN/IIN hampers identifying the party and making payments."),
+			Span: doc.span(m[4], m[5]),
+		})
+	}
+	return issues
+}
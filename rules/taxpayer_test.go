@@ -0,0 +1,47 @@
+// taxpayer_test.go
+
+package rules
+
+import "testing"
+
+func TestTaxpayerNumberRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		quote string
+	}{
+		{"верный БИН", "ТОО «Альфа», БИН 990340001230", ""},
+		{"верный ИИН с пробелами", "ИП Иванов, ИИН: 880101 300123", ""},
+		{"неверный контрольный разряд", "ТОО «Альфа», БИН 990340001231, адрес", "990340001231"},
+		{"неверная длина", "ИИН № 88010130012, паспорт", "88010130012"},
+		{"казахское обозначение", "ЖСН 880101300124", "880101300124"},
+		{"английское обозначение", "BIN: 990340001239", "990340001239"},
+		{"номер без обозначения не проверяется", "Счёт № 990340001231", ""},
+		{"за номером следует число", "ИИН 880101300123 1 экз.", ""},
+		{"за номером следует год", "БИН 990340001230 2024 г.", ""},
+		{"за номером группами следует число", "ИИН 880101 300123 2 экз.", ""},
+		{"неверный номер перед числом", "БИН 990340001231 2024 г.", "990340001231"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := check(t, TaxpayerNumberRule{}, tt.text)
+			if tt.quote == "" {
+				if len(issues) > 0 {
+					t.Errorf("лишнее нарушение: %+v", issues)
+				}
+				return
+			}
+			if len(issues) != 1 {
+				t.Fatalf("нарушений %d, ожидалось 1", len(issues))
+			}
+			quote(t, tt.text, issues[0], tt.quote)
+		})
+	}
+}
+
+func TestTaxpayerNumberRuleReportsOncePerNumber(t *testing.T) {
+	text := "БИН 990340001231 … подпись … БИН 990340001231"
+	if issues := check(t, TaxpayerNumberRule{}, text); len(issues) != 1 {
+		t.Errorf("нарушений %d, ожидалось 1", len(issues))
+	}
+}
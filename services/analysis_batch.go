@@ -0,0 +1,332 @@
+// analysis_batch.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// portfolioRiskiestDocs и portfolioTopFindings — длина списков в сводке пакета
+	portfolioRiskiestDocs = 5
+	portfolioTopFindings  = 10
+)
+
+var ErrBatchNotFound = errors.New("пакет не найден")
+
+// AnalyzeBatch принимает несколько PDF или ZIP-архив и ставит каждый документ в
+// очередь отдельной задачей пакета. Отклонённые файлы остаются в пакете с
+// причиной отказа; если не принят ни один, пакет не создаётся.
+func AnalyzeBatch(c *gin.Context) (*models.AnalysisBatch, *HttpError) {
+	utils.LogAction("Получен запрос на пакетный анализ")
+
+	documents, err := utils.ProcessBatchUpload(c)
+	if err != nil {
+		utils.LogError(err.Error())
+		return nil, &HttpError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	accepted := 0
+	var rejected []string
+	for _, doc := range documents {
+		if doc.Error == "" {
+			accepted++
+		} else {
+			rejected = append(rejected, doc.Filename+": "+doc.Error)
+		}
+	}
+	if accepted == 0 {
+		return nil, &HttpError{Status: http.StatusBadRequest, Message: "ни один файл не принят: " + strings.Join(rejected, "; ")}
+	}
+
+	userID, _ := c.Get("userId")
+	language, err := reportLanguage(c, userID.(string))
+	if err != nil {
+		return nil, &HttpError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID.(string))
+	if err != nil {
+		return nil, &HttpError{Status: http.StatusBadRequest, Message: "неверный ID пользователя"}
+	}
+	// Лимит проверяется на весь пакет сразу, чтобы не принять его частично, и
+	// держится, пока задачи пакета не созданы
+	release, err := ReserveUsageQuota(userID.(string), accepted)
+	if err != nil {
+		return nil, quotaHttpError(err)
+	}
+	defer release()
+
+	batch := &models.AnalysisBatch{
+		UserID:    userObjID,
+		Language:  language,
+		Documents: make([]models.BatchDocument, len(documents)),
+	}
+	for i, doc := range documents {
+		batch.Documents[i] = models.BatchDocument{Filename: doc.Filename, Error: doc.Error}
+	}
+	if err := repositories.CreateAnalysisBatch(batch); err != nil {
+		return nil, &HttpError{Status: http.StatusInternalServerError, Message: "ошибка создания пакета"}
+	}
+
+	// Задачи ставятся после сохранения пакета: к этому моменту у них уже есть BatchID
+	for i, doc := range documents {
+		if doc.Error != "" {
+			continue
+		}
+		job := &models.AnalysisJob{
+			UserID:   userObjID,
+			BatchID:  &batch.ID,
+			Filename: doc.Filename,
+			Text:     doc.Text,
+			Language: language,
+		}
+		if err := enqueueAnalysisJob(job); err != nil {
+			utils.LogError(err.Error())
+			batch.Documents[i].Error = "ошибка постановки в очередь"
+			continue
+		}
+		batch.Documents[i].JobID = &job.ID
+	}
+	if err := repositories.SetAnalysisBatchDocuments(batch.ID, batch.Documents); err != nil {
+		return nil, &HttpError{Status: http.StatusInternalServerError, Message: "ошибка сохранения пакета"}
+	}
+
+	utils.LogSuccess(fmt.Sprintf("Пакет %s: принято %d из %d документов", batch.ID.Hex(), accepted, len(documents)))
+	return batch, nil
+}
+
+// getUserBatch возвращает пакет, если он принадлежит пользователю
+func getUserBatch(userID, batchID string) (*models.AnalysisBatch, error) {
+	objID, err := primitive.ObjectIDFromHex(batchID)
+	if err != nil {
+		return nil, ErrBatchNotFound
+	}
+	batch, err := repositories.GetAnalysisBatch(objID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пакета: %w", err)
+	}
+	if batch.UserID.Hex() != userID {
+		return nil, ErrBatchNotFound
+	}
+	return batch, nil
+}
+
+// GetBatchReport возвращает задачи пакета, общий статус и сводку по документам,
+// анализ которых уже завершён
+func GetBatchReport(userID, batchID string) (*models.BatchReport, error) {
+	batch, err := getUserBatch(userID, batchID)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := repositories.GetBatchJobs(batch.ID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения задач пакета: %w", err)
+	}
+
+	var analysisIDs []primitive.ObjectID
+	for _, job := range jobs {
+		if job.Status == models.JobSucceeded && job.AnalysisID != nil {
+			analysisIDs = append(analysisIDs, *job.AnalysisID)
+		}
+	}
+	analyses, err := repositories.GetAnalysesByIDs(analysisIDs)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения анализов пакета: %w", err)
+	}
+
+	return &models.BatchReport{
+		Batch:    batch,
+		Progress: batchProgress(batch, jobs),
+		Jobs:     jobs,
+		Summary:  portfolioSummary(analyses),
+	}, nil
+}
+
+// CancelAnalysisBatch отменяет незавершённые задачи пакета и возвращает их число
+func CancelAnalysisBatch(userID, batchID string) (int, error) {
+	batch, err := getUserBatch(userID, batchID)
+	if err != nil {
+		return 0, err
+	}
+	jobs, err := repositories.GetBatchJobs(batch.ID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения задач пакета: %w", err)
+	}
+
+	cancelled := 0
+	for _, job := range jobs {
+		if job.Status.IsFinal() {
+			continue
+		}
+		if err := cancelJob(job.ID); err == nil {
+			cancelled++
+		}
+	}
+	if cancelled == 0 {
+		return 0, ErrJobFinished
+	}
+	return cancelled, nil
+}
+
+// batchProgress сводит статусы задач пакета. Пакет завершён, когда завершены
+// все задачи: completed — проанализированы все файлы пакета, partial — часть
+// (в том числе если какие-то файлы отклонены при загрузке), failed — ни одного.
+func batchProgress(batch *models.AnalysisBatch, jobs []models.AnalysisJob) models.BatchProgress {
+	progress := models.BatchProgress{Total: len(batch.Documents)}
+	for _, doc := range batch.Documents {
+		if doc.JobID == nil {
+			progress.Rejected++
+		}
+	}
+	for _, job := range jobs {
+		switch job.Status {
+		case models.JobQueued:
+			progress.Queued++
+		case models.JobRunning:
+			progress.Running++
+		case models.JobSucceeded:
+			progress.Succeeded++
+		case models.JobFailed:
+			progress.Failed++
+		case models.JobCancelled:
+			progress.Cancelled++
+		}
+	}
+
+	switch {
+	case progress.Running > 0 || (progress.Queued > 0 && progress.Succeeded+progress.Failed+progress.Cancelled > 0):
+		progress.Status = models.BatchRunning
+	case progress.Queued > 0:
+		progress.Status = models.BatchQueued
+	case progress.Succeeded == progress.Total:
+		progress.Status = models.BatchCompleted
+	case progress.Succeeded > 0:
+		progress.Status = models.BatchPartial
+	default:
+		progress.Status = models.BatchFailed
+	}
+	return progress
+}
+
+// portfolioSummary группирует документы пакета по типу и уровню риска и
+// находит выводы, повторяющиеся в разных документах. Выводы сравниваются по
+// заголовку без учёта регистра и пробелов.
+func portfolioSummary(analyses []models.Analysis) *models.PortfolioSummary {
+	summary := &models.PortfolioSummary{
+		Documents:    len(analyses),
+		ByType:       []models.PortfolioCount{},
+		ByRisk:       []models.PortfolioCount{},
+		RiskiestDocs: []models.PortfolioDoc{},
+		TopFindings:  []models.PortfolioFinding{},
+	}
+
+	byType := make(map[string]int)
+	byRisk := make(map[models.Severity]int)
+	findings := make(map[string]*models.PortfolioFinding)
+	riskTotal, scored := 0, 0
+
+	for _, analysis := range analyses {
+		byType[analysis.Type]++
+
+		if analysis.Risk != nil {
+			byRisk[analysis.Risk.Level]++
+			riskTotal += analysis.Risk.Score
+			scored++
+			summary.MaxRisk = max(summary.MaxRisk, analysis.Risk.Score)
+			summary.RiskiestDocs = append(summary.RiskiestDocs, models.PortfolioDoc{
+				AnalysisID: analysis.ID,
+				Filename:   analysis.Filename,
+				Type:       analysis.Type,
+				Risk:       analysis.Risk.Score,
+				Level:      analysis.Risk.Level,
+			})
+		}
+
+		if analysis.Result == nil {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, f := range analysis.Result.Findings {
+			key := strings.ToLower(strings.Join(strings.Fields(f.Title), " "))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			severity := normalizeSeverity(f.Severity)
+			entry, ok := findings[key]
+			if !ok {
+				entry = &models.PortfolioFinding{Title: f.Title, Kind: f.Kind, Severity: severity}
+				findings[key] = entry
+			}
+			entry.Documents++
+			if severityRank[severity] > severityRank[entry.Severity] {
+				entry.Severity = severity
+			}
+		}
+	}
+
+	for key, count := range byType {
+		summary.ByType = append(summary.ByType, models.PortfolioCount{Key: key, Count: count})
+	}
+	sort.Slice(summary.ByType, func(i, j int) bool {
+		if summary.ByType[i].Count != summary.ByType[j].Count {
+			return summary.ByType[i].Count > summary.ByType[j].Count
+		}
+		return summary.ByType[i].Key < summary.ByType[j].Key
+	})
+
+	for i := len(riskLevels) - 1; i >= 0; i-- {
+		level := riskLevels[i]
+		summary.ByRisk = append(summary.ByRisk, models.PortfolioCount{Key: string(level), Count: byRisk[level]})
+	}
+	if scored > 0 {
+		summary.AverageRisk = riskTotal / scored
+	}
+
+	sort.SliceStable(summary.RiskiestDocs, func(i, j int) bool {
+		return summary.RiskiestDocs[i].Risk > summary.RiskiestDocs[j].Risk
+	})
+	if len(summary.RiskiestDocs) > portfolioRiskiestDocs {
+		summary.RiskiestDocs = summary.RiskiestDocs[:portfolioRiskiestDocs]
+	}
+
+	for _, entry := range findings {
+		summary.TopFindings = append(summary.TopFindings, *entry)
+	}
+	sort.Slice(summary.TopFindings, func(i, j int) bool {
+		a, b := summary.TopFindings[i], summary.TopFindings[j]
+		if a.Documents != b.Documents {
+			return a.Documents > b.Documents
+		}
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] > severityRank[b.Severity]
+		}
+		return a.Title < b.Title
+	})
+	if len(summary.TopFindings) > portfolioTopFindings {
+		summary.TopFindings = summary.TopFindings[:portfolioTopFindings]
+	}
+	return summary
+}
+
+// InitAnalysisBatches создаёт индекс задач по пакету
+func InitAnalysisBatches() {
+	if err := repositories.EnsureAnalysisBatchIndexes(); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось создать индексы пакетов анализа: %v", err))
+	}
+}
@@ -0,0 +1,209 @@
+// analysis_cache.go
+
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultCacheTTL = 7 * 24 * time.Hour
+
+// InitAnalysisCache создаёт индексы кэша; без них кэш работает, но просроченные
+// записи не удаляются автоматически
+func InitAnalysisCache() {
+	if cacheTTL() == 0 {
+		utils.LogInfo("Кэш результатов анализа отключён (ANALYSIS_CACHE_TTL=0)")
+		return
+	}
+	if err := repositories.EnsureAnalysisCacheIndexes(); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось создать индексы кэша анализов: %v", err))
+	}
+}
+
+// cacheTTL — срок хранения результатов из ANALYSIS_CACHE_TTL (например, 72h);
+// 0 отключает кэш
+func cacheTTL() time.Duration {
+	value := strings.TrimSpace(os.Getenv("ANALYSIS_CACHE_TTL"))
+	if value == "" {
+		return defaultCacheTTL
+	}
+	if value == "0" {
+		return 0
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		utils.LogWarning(fmt.Sprintf("Некорректный ANALYSIS_CACHE_TTL %q, используется %s", value, defaultCacheTTL))
+		return defaultCacheTTL
+	}
+	return ttl
+}
+
+// textHash — SHA-256 текста без учёта пробелов и переносов: повторное извлечение
+// текста из того же PDF может отличаться только ими
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// analysisCache строит ключи кэша для одной задачи
+type analysisCache struct {
+	job          *models.AnalysisJob
+	template     *models.PromptTemplate
+	model        string
+	jurisdiction string
+	// private — организация владельца хранит тексты со скрытыми персональными
+	// данными: выводы содержат их в открытом виде, поэтому в кэш не пишутся
+	private bool
+}
+
+func newAnalysisCache(job *models.AnalysisJob, tmpl *models.PromptTemplate) *analysisCache {
+	return &analysisCache{
+		job:          job,
+		template:     tmpl,
+		model:        llm.Name() + "/" + llm.Model(),
+		jurisdiction: analysisJurisdiction(job.Language),
+		private:      storeRedactedText(userOrganization(job.UserID)),
+	}
+}
+
+// key хеширует всё, от чего зависит промпт: помимо ссылки на версию шаблона
+// учитывается и его текст, чтобы правка встроенного шаблона не отдавала старые ответы
+func (c *analysisCache) key(kind models.CacheKind, hash string) string {
+	fields := []string{
+		string(kind),
+		hash,
+		c.model,
+		c.template.ID.Hex(),
+		strconv.Itoa(c.template.Version),
+		c.template.System,
+		c.template.Body,
+		c.jurisdiction,
+		c.job.Language,
+	}
+	if cl := c.job.Classification; cl != nil {
+		fields = append(fields, cl.Code)
+		for _, s := range cl.Subtypes {
+			fields = append(fields, s.Code)
+		}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *analysisCache) documentKey() (string, string) {
+	hash := textHash(c.job.Text)
+	return c.key(models.CacheDocument, hash), hash
+}
+
+// partKey учитывает и перекрытие с предыдущей частью: оно входит в промпт
+func (c *analysisCache) partKey(chunk utils.TextChunk) (string, string) {
+	hash := textHash(chunk.Context + "\x00" + chunk.Text)
+	return c.key(models.CachePart, hash), hash
+}
+
+// lookup возвращает запись кэша или nil; ошибки кэша не мешают анализу
+func (c *analysisCache) lookup(key string) *models.AnalysisCacheEntry {
+	if cacheTTL() == 0 {
+		return nil
+	}
+	entry, err := repositories.GetAnalysisCacheEntry(key)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			utils.LogWarning(fmt.Sprintf("Ошибка чтения кэша анализов: %v", err))
+		}
+		return nil
+	}
+	if err := repositories.TouchAnalysisCacheEntry(key, c.job.UserID); err != nil {
+		utils.LogWarning(fmt.Sprintf("Ошибка обновления кэша анализов: %v", err))
+	}
+	return entry
+}
+
+func (c *analysisCache) store(kind models.CacheKind, key, hash string, result *models.AnalysisResult, model string, parts []models.AnalysisPart, terms *models.ContractTerms) {
+	ttl := cacheTTL()
+	if ttl == 0 || result == nil || c.private {
+		return
+	}
+
+	now := time.Now()
+	entry := &models.AnalysisCacheEntry{
+		Key:            key,
+		Kind:           kind,
+		TextHash:       hash,
+		Model:          c.model,
+		PromptTemplate: c.template.Ref(),
+		Jurisdiction:   c.jurisdiction,
+		Language:       c.job.Language,
+		Result:         result,
+		ResultModel:    model,
+		Parts:          parts,
+		Terms:          terms,
+		UserIDs:        []primitive.ObjectID{c.job.UserID},
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(ttl),
+	}
+	if err := repositories.SaveAnalysisCacheEntry(entry); err != nil {
+		utils.LogWarning(fmt.Sprintf("Ошибка записи в кэш анализов: %v", err))
+		return
+	}
+	utils.LogInfo(fmt.Sprintf("Результат (%s) сохранён в кэш до %s", kind, entry.ExpiresAt.Format(time.RFC3339)))
+}
+
+// InvalidateAnalysisCache удаляет записи кэша по фильтру администратора
+func InvalidateAnalysisCache(req models.CacheInvalidation) (int64, error) {
+	filter := bson.M{}
+	if req.Kind != "" {
+		filter["kind"] = req.Kind
+	}
+	if req.Model != "" {
+		filter["model"] = req.Model
+	}
+	if req.Language != "" {
+		filter["language"] = req.Language
+	}
+	if req.PromptTemplateID != "" {
+		id, err := primitive.ObjectIDFromHex(req.PromptTemplateID)
+		if err != nil {
+			return 0, fmt.Errorf("неверный ID шаблона: %w", err)
+		}
+		filter["prompt_template.id"] = id
+	}
+
+	deleted, err := repositories.DeleteAnalysisCacheEntries(filter)
+	if err != nil {
+		return 0, err
+	}
+	utils.LogSuccess(fmt.Sprintf("Из кэша анализов удалено записей: %d", deleted))
+	return deleted, nil
+}
+
+func GetAnalysisCacheStats() (*models.CacheStats, error) {
+	return repositories.GetAnalysisCacheStats()
+}
+
+// ClearUserCache отвязывает пользователя от результатов в кэше. Результат
+// удаляется, только если документ не загружал больше никто: записи общие, и
+// чужие результаты пользователь сбросить не может.
+func ClearUserCache(userID string) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	_, err = repositories.ReleaseAnalysisCacheEntries(id)
+	return err
+}
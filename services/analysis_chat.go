@@ -0,0 +1,396 @@
+// analysis_chat.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// chatPromptTokens — запас на системное сообщение и инструкцию вопроса
+	chatPromptTokens = 800
+	// chatHistoryMessages — сколько последних сообщений ветки видит модель
+	chatHistoryMessages = 10
+	// chatThreadTitleRunes — длина заголовка ветки, взятого из первого вопроса
+	chatThreadTitleRunes = 80
+	maxQuestionRunes     = 4000
+)
+
+var (
+	ErrThreadNotFound  = errors.New("ветка вопросов не найдена")
+	ErrEmptyQuestion   = errors.New("вопрос не может быть пустым")
+	ErrQuestionTooLong = fmt.Errorf("вопрос длиннее %d символов", maxQuestionRunes)
+	// ErrChatUnavailable — у старых анализов не сохранён текст документа
+	ErrChatUnavailable = errors.New("по этому анализу нельзя задавать вопросы: текст документа не сохранён")
+
+	// questionNumberRe — номера пунктов в вопросе: «7», «7.2», «12.3.1»
+	questionNumberRe = regexp.MustCompile(`\d+(?:\.\d+)*`)
+)
+
+// AnalysisChat — проверенный вопрос по анализу, готовый к отправке модели.
+// Разделение на подготовку и ответ позволяет вернуть ошибку запроса обычным
+// JSON до того, как начнётся потоковая передача.
+type AnalysisChat struct {
+	analysis *models.Analysis
+	// thread — nil, если вопрос начинает новую ветку
+	thread   *models.AnalysisThread
+	question string
+}
+
+// PrepareAnalysisQuestion проверяет вопрос, доступ к анализу и ветке
+func PrepareAnalysisQuestion(userID, analysisID string, req models.AskQuestionRequest) (*AnalysisChat, error) {
+	question := strings.TrimSpace(req.Content)
+	if question == "" {
+		return nil, ErrEmptyQuestion
+	}
+	if utf8.RuneCountInString(question) > maxQuestionRunes {
+		return nil, ErrQuestionTooLong
+	}
+
+	analysis, err := getUserAnalysis(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(analysis.Text) == "" {
+		return nil, ErrChatUnavailable
+	}
+	if err := CheckUsageQuota(userID, 0); err != nil {
+		return nil, err
+	}
+
+	chat := &AnalysisChat{analysis: analysis, question: question}
+	if req.ThreadID != "" {
+		id, err := primitive.ObjectIDFromHex(req.ThreadID)
+		if err != nil {
+			return nil, ErrThreadNotFound
+		}
+		chat.thread, err = repositories.GetAnalysisThread(id, analysis.ID, analysis.UserID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrThreadNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chat, nil
+}
+
+// Answer отвечает на вопрос и сохраняет вопрос с ответом в ветке. Модель получает
+// текст документа (целиком или наиболее подходящие пункты), выводы анализа,
+// фрагменты законов и последние сообщения ветки в пределах контекста модели.
+// Фрагменты ответа передаются в onDelta; onReset означает, что поток
+// перезапущен и полученные фрагменты нужно отбросить.
+func (chat *AnalysisChat) Answer(ctx context.Context, onDelta func(string), onReset func()) (*models.AnalysisThread, *models.ThreadMessage, error) {
+	utils.LogAction(fmt.Sprintf("Вопрос по анализу %s", chat.analysis.ID.Hex()))
+
+	budget := envInt(defaultContextTokens, "LLM_CONTEXT_TOKENS") - envInt(defaultLLMMaxTokens, "LLM_MAX_TOKENS") -
+		chatPromptTokens - lawContextTokens - llm.CountTokens(chat.question)
+	budget = max(budget, minPartTokens)
+
+	history := chat.history(budget / 5)
+	findings := chatFindings(chat.analysis, budget/5)
+	used := llm.CountTokens(findings)
+	for _, m := range history {
+		used += llm.CountTokens(m.Content)
+	}
+	excerpt := questionExcerpt(chat.analysis.Text, chat.question, max(budget-used, minPartTokens))
+
+	chunks, err := retrieveLawChunks(ctx, chat.question+"\n"+excerpt)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Ответ без фрагментов законов: %v", err))
+	}
+	for len(chunks) > 0 && llm.CountTokens(formatLawContext(chunks)) > lawContextTokens {
+		chunks = chunks[:len(chunks)-1]
+	}
+	laws := formatLawContext(chunks)
+	if laws == "" {
+		laws = "—"
+	}
+
+	system := systemPrompt + "\n" + answerLanguageInstruction(chat.analysis.Language)
+	prompt := fmt.Sprintf(`Пользователь задаёт вопрос по юридическому документу, который уже был проанализирован.
+Ответь на вопрос, опираясь на текст документа, выводы анализа и фрагменты законов ниже:
+- ссылаясь на пункт документа, указывай его номер;
+- ссылаясь на фрагмент закона, указывай его идентификатор в квадратных скобках, как он приведён ниже;
+- если ответа нет ни в документе, ни в приведённых законах, прямо скажи об этом и ничего не додумывай;
+- отвечай по существу вопроса, не пересказывай весь отчёт.
+
+Выводы анализа:
+%s
+
+Фрагменты законов:
+%s
+
+Текст документа (если приведены фрагменты, пропуски обозначены «…»):
+%s
+
+Вопрос: %s`, findings, laws, excerpt, chat.question)
+
+	req := newChatRequest(system, prompt)
+	req.Messages = append(append([]ChatMessage{req.Messages[0]}, history...), req.Messages[1])
+
+	resp, err := callLLM(withLLMOperation(withUsageScope(ctx, analysisUsageScope(chat.analysis)), llmOpChat), req,
+		&streamCallbacks{onDelta: onDelta, onReset: onReset})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	question := models.ThreadMessage{
+		ID:        primitive.NewObjectID(),
+		Role:      models.ThreadRoleUser,
+		Content:   chat.question,
+		CreatedAt: now,
+	}
+	content := strings.TrimSpace(thinkBlockRe.ReplaceAllString(resp.Content, ""))
+	answer := models.ThreadMessage{
+		ID:        primitive.NewObjectID(),
+		Role:      models.ThreadRoleAssistant,
+		Content:   content,
+		Citations: answerCitations(content, chunks),
+		Model:     resp.Model,
+		CreatedAt: now,
+	}
+
+	thread := chat.thread
+	if thread == nil {
+		thread = &models.AnalysisThread{
+			AnalysisID: chat.analysis.ID,
+			UserID:     chat.analysis.UserID,
+			Title:      threadTitle(chat.question),
+			Messages:   []models.ThreadMessage{question, answer},
+		}
+		err = repositories.CreateAnalysisThread(thread)
+	} else {
+		thread.Messages = append(thread.Messages, question, answer)
+		thread.UpdatedAt = now
+		err = repositories.AppendThreadMessages(thread.ID, question, answer)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("не удалось сохранить ответ: %w", err)
+	}
+
+	utils.LogSuccess(fmt.Sprintf("Ответ на вопрос по анализу %s (%s), ветка %s", chat.analysis.ID.Hex(), resp.Model, thread.ID.Hex()))
+	return thread, &answer, nil
+}
+
+// history возвращает последние сообщения ветки не больше budget токенов. История
+// начинается с вопроса пользователя, чтобы чередование ролей не нарушалось.
+func (chat *AnalysisChat) history(budget int) []ChatMessage {
+	if chat.thread == nil {
+		return nil
+	}
+	messages := chat.thread.Messages
+	start, used := len(messages), 0
+	for i := len(messages) - 1; i >= 0 && len(messages)-i <= chatHistoryMessages; i-- {
+		tokens := llm.CountTokens(messages[i].Content)
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		start = i
+	}
+	for start < len(messages) && messages[start].Role != models.ThreadRoleUser {
+		start++
+	}
+
+	out := make([]ChatMessage, 0, len(messages)-start)
+	for _, m := range messages[start:] {
+		out = append(out, ChatMessage{Role: string(m.Role), Content: m.Content})
+	}
+	return out
+}
+
+// chatFindings кратко перечисляет выводы анализа не больше budget токенов,
+// начиная с самых серьёзных
+func chatFindings(analysis *models.Analysis, budget int) string {
+	if analysis.Result == nil {
+		if analysis.Analysis == "" {
+			return "—"
+		}
+		return truncateTokens(analysis.Analysis, budget)
+	}
+
+	findings := append([]models.Finding(nil), analysis.Result.Findings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank[findings[i].Severity] > severityRank[findings[j].Severity]
+	})
+
+	var b strings.Builder
+	if summary := analysis.Result.Conclusion.Summary; summary != "" {
+		fmt.Fprintf(&b, "Заключение: %s\n", summary)
+	}
+	used := llm.CountTokens(b.String())
+	for _, f := range findings {
+		line := fmt.Sprintf("- [%s] %s: %s", f.Severity, f.Title, f.Description)
+		if f.Recommendation != "" {
+			line += " Рекомендация: " + f.Recommendation
+		}
+		if f.LegalBasis != "" {
+			line += " Основание: " + f.LegalBasis
+		}
+		tokens := llm.CountTokens(line)
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		return "—"
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// questionExcerpt возвращает документ целиком, если он помещается в budget
+// токенов, иначе — пункты, упомянутые в вопросе по номеру, и пункты с наибольшим
+// числом слов вопроса, в порядке следования
+func questionExcerpt(text, question string, budget int) string {
+	if llm.CountTokens(text) <= budget {
+		return text
+	}
+
+	clauses := utils.SplitClauses(text)
+	numbers := questionNumberRe.FindAllString(question, -1)
+	query := lawTerms(question)
+
+	scores := make([]int, len(clauses))
+	for i, clause := range clauses {
+		if referencedClause(clause.Number, numbers) {
+			// Пункт, названный в вопросе, важнее любого совпадения слов
+			scores[i] = len(query.counts) + 1
+			continue
+		}
+		terms := lawTerms(clause.Text)
+		for term := range query.counts {
+			if terms.counts[term] > 0 {
+				scores[i]++
+			}
+		}
+	}
+
+	order := make([]int, len(clauses))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	selected := make([]bool, len(clauses))
+	used := 0
+	for _, i := range order {
+		if tokens := llm.CountTokens(clauses[i].Text); used+tokens <= budget {
+			selected[i] = true
+			used += tokens
+		}
+	}
+
+	var b strings.Builder
+	for i, clause := range clauses {
+		if !selected[i] {
+			if !strings.HasSuffix(b.String(), "…\n") {
+				b.WriteString("…\n")
+			}
+			continue
+		}
+		b.WriteString(clause.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// referencedClause сообщает, что вопрос называет пункт или его подпункт:
+// для пункта «7» подходит и «7», и «7.2»
+func referencedClause(number string, mentioned []string) bool {
+	clause := strings.TrimSuffix(questionNumberRe.FindString(number), ".")
+	if clause == "" {
+		return false
+	}
+	for _, n := range mentioned {
+		if n == clause || strings.HasPrefix(n, clause+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// answerCitations — фрагменты законов, на идентификаторы которых сослался ответ
+func answerCitations(answer string, chunks []lawChunk) []models.Citation {
+	var out []models.Citation
+	for _, c := range chunks {
+		if strings.Contains(answer, c.citation.ID) {
+			out = append(out, c.citation)
+		}
+	}
+	return out
+}
+
+func threadTitle(question string) string {
+	return truncateRunes(strings.Join(strings.Fields(question), " "), chatThreadTitleRunes)
+}
+
+// truncateTokens обрезает текст до budget токенов
+func truncateTokens(text string, budget int) string {
+	runes := []rune(text)
+	end := len(runes)
+	for end > 0 && llm.CountTokens(string(runes[:end])) > budget {
+		end = end * 3 / 4
+	}
+	if end < len(runes) {
+		return string(runes[:end]) + "…"
+	}
+	return text
+}
+
+// GetAnalysisThreads возвращает ветки вопросов по анализу с сообщениями
+func GetAnalysisThreads(userID, analysisID string) ([]models.AnalysisThread, error) {
+	analysis, err := getUserAnalysis(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	return repositories.GetAnalysisThreads(analysis.ID, analysis.UserID)
+}
+
+// InitAnalysisThreads создаёт индексы веток вопросов
+func InitAnalysisThreads() {
+	if err := repositories.EnsureAnalysisThreadIndexes(); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось создать индексы веток вопросов: %v", err))
+	}
+}
+
+// attachThreadSummaries подставляет в историю сводки веток вопросов. Без них
+// история остаётся полезной, поэтому ошибка только записывается в лог.
+func attachThreadSummaries(userID string, history []models.Analysis) {
+	if len(history) == 0 {
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return
+	}
+	ids := make([]primitive.ObjectID, len(history))
+	for i, a := range history {
+		ids[i] = a.ID
+	}
+	summaries, err := repositories.GetThreadSummaries(objID, ids)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("История без веток вопросов: %v", err))
+		return
+	}
+	for i := range history {
+		history[i].Threads = summaries[history[i].ID]
+	}
+}
@@ -0,0 +1,232 @@
+// analysis_events.go
+
+package services
+
+import (
+	"fmt"
+	"legally/models"
+	"legally/utils"
+	"sync"
+	"time"
+)
+
+const (
+	EventStatus    = "status"
+	EventExtracted = "extracted"
+	// EventClassified — определены тип, подтипы и язык документа
+	EventClassified  = "classified"
+	EventPartStarted = "part_started"
+	EventPartDelta   = "part_delta"
+	// EventPartReset — поток части оборвался и будет запрошен заново,
+	// полученные фрагменты нужно отбросить
+	EventPartReset     = "part_reset"
+	EventPartCompleted = "part_completed"
+	EventPartFailed    = "part_failed"
+	EventMerging       = "merging"
+	EventCompleted     = "completed"
+	EventFailed        = "failed"
+	EventCancelled     = "cancelled"
+
+	// EventExtractingTerms — извлекаются ключевые условия договора
+	EventExtractingTerms = "extracting_terms"
+	// EventCheckingPlaybook — документ проверяется по правилам организации
+	EventCheckingPlaybook = "checking_playbook"
+
+	subscriberBuffer = 256
+	// streamRetention — сколько хранить историю событий после завершения задачи,
+	// чтобы подключившийся с опозданием клиент получил финальное событие
+	streamRetention = time.Minute
+)
+
+// ProgressEvent — событие хода анализа, отправляемое клиенту через SSE
+type ProgressEvent struct {
+	Type    string      `json:"type"`
+	Part    int         `json:"part,omitempty"`
+	Total   int         `json:"total,omitempty"`
+	Content string      `json:"content,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// IsFinal сообщает, что событие завершает поток задачи
+func (e ProgressEvent) IsFinal() bool {
+	return e.Type == EventCompleted || e.Type == EventFailed || e.Type == EventCancelled
+}
+
+// ProgressFunc получает события по мере выполнения анализа
+type ProgressFunc func(ProgressEvent)
+
+type eventStream struct {
+	history []ProgressEvent
+	subs    map[chan ProgressEvent]struct{}
+	closed  bool
+}
+
+var (
+	eventStreams = make(map[string]*eventStream)
+	eventsMutex  sync.Mutex
+)
+
+func getEventStream(jobID string) *eventStream {
+	stream, exists := eventStreams[jobID]
+	if !exists {
+		stream = &eventStream{subs: make(map[chan ProgressEvent]struct{})}
+		eventStreams[jobID] = stream
+	}
+	return stream
+}
+
+// PublishAnalysisEvent рассылает событие подписчикам задачи. Дельты текста не попадают
+// в историю: опоздавший клиент получит часть целиком в part_completed. Поток
+// заводится, только если на задачу подписались или она выполняется в этом
+// процессе: иначе удалить его было бы некому.
+func PublishAnalysisEvent(jobID string, event ProgressEvent) {
+	activeMutex.Lock()
+	_, running := activeAnalysis[jobID]
+	activeMutex.Unlock()
+
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+
+	stream, exists := eventStreams[jobID]
+	if !exists {
+		if !running {
+			return
+		}
+		stream = getEventStream(jobID)
+	}
+	if stream.closed {
+		return
+	}
+
+	if event.Type != EventPartDelta {
+		stream.history = append(stream.history, event)
+	}
+
+	for ch := range stream.subs {
+		select {
+		case ch <- event:
+		default:
+			// Финальное событие подписчик получит сам: после закрытия канала
+			// обработчик перечитывает задачу из базы
+			utils.LogWarning(fmt.Sprintf("Подписчик задачи %s не успевает, событие %s пропущено", jobID, event.Type))
+		}
+	}
+
+	if event.IsFinal() {
+		stream.closed = true
+		for ch := range stream.subs {
+			close(ch)
+		}
+		stream.subs = nil
+		time.AfterFunc(streamRetention, func() {
+			eventsMutex.Lock()
+			delete(eventStreams, jobID)
+			eventsMutex.Unlock()
+		})
+	}
+}
+
+// releaseEventStream удаляет поток задачи, у которой не осталось подписчиков и
+// которая не выполняется в этом процессе: финальное событие задачи из другого
+// процесса сюда не придёт, и поток иначе остался бы в памяти навсегда.
+// Вызывается под eventsMutex.
+func releaseEventStream(jobID string, stream *eventStream) {
+	if stream.closed || len(stream.subs) > 0 {
+		return
+	}
+	time.AfterFunc(streamRetention, func() {
+		activeMutex.Lock()
+		_, running := activeAnalysis[jobID]
+		activeMutex.Unlock()
+
+		eventsMutex.Lock()
+		defer eventsMutex.Unlock()
+		if !running && eventStreams[jobID] == stream && !stream.closed && len(stream.subs) == 0 {
+			delete(eventStreams, jobID)
+		}
+	})
+}
+
+// releaseAnalysisEvents вызывается, когда воркер отпускает задачу. Если задача не
+// дошла до финального события (например, её не удалось сохранить), поток без
+// подписчиков удаляется, как только задача перестанет выполняться.
+func releaseAnalysisEvents(jobID string) {
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+	if stream, exists := eventStreams[jobID]; exists {
+		releaseEventStream(jobID, stream)
+	}
+}
+
+// SubscribeAnalysisEvents возвращает канал событий задачи, начиная со снимка её состояния.
+// Канал закрывается после финального события; если подписчик не успевал
+// читать, само финальное событие в канал может не попасть.
+func SubscribeAnalysisEvents(job *models.AnalysisJob) (<-chan ProgressEvent, func()) {
+	jobID := job.ID.Hex()
+	ch := make(chan ProgressEvent, subscriberBuffer)
+
+	done, total := job.Progress()
+	ch <- ProgressEvent{Type: EventStatus, Part: done, Total: total, Data: job.Status}
+
+	eventsMutex.Lock()
+	stream, exists := eventStreams[jobID]
+	if job.Status.IsFinal() && (!exists || !stream.closed) {
+		eventsMutex.Unlock()
+		// Задача завершилась давно или в другом процессе — истории нет. Отчёт
+		// читается из базы уже без блокировки, чтобы не задерживать остальные задачи.
+		ch <- FinalAnalysisEvent(job)
+		close(ch)
+		return ch, func() {}
+	}
+	defer eventsMutex.Unlock()
+
+	stream = getEventStream(jobID)
+	for _, event := range stream.history {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+
+	if stream.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	stream.subs[ch] = struct{}{}
+	return ch, func() {
+		eventsMutex.Lock()
+		defer eventsMutex.Unlock()
+		if _, ok := stream.subs[ch]; ok {
+			delete(stream.subs, ch)
+			close(ch)
+			releaseEventStream(jobID, stream)
+		}
+	}
+}
+
+// FinalAnalysisEvent строит финальное событие по сохранённому состоянию задачи
+func FinalAnalysisEvent(job *models.AnalysisJob) ProgressEvent {
+	switch job.Status {
+	case models.JobSucceeded:
+		data := map[string]interface{}{
+			"analysis_id":    job.AnalysisID,
+			"document_type":  job.DocumentType,
+			"classification": job.Classification,
+		}
+		event := ProgressEvent{Type: EventCompleted, Data: data}
+		if analysis, err := GetJobAnalysis(job); err == nil && analysis != nil {
+			event.Content = analysis.Analysis
+			data["result"] = analysis.Result
+			data["cached"] = analysis.Cached
+			data["terms"] = analysis.Terms
+			data["risk"] = analysis.Risk
+			data["playbook"] = analysis.Playbook
+		}
+		return event
+	case models.JobCancelled:
+		return ProgressEvent{Type: EventCancelled, Data: job.Error}
+	default:
+		return ProgressEvent{Type: EventFailed, Data: job.Error}
+	}
+}
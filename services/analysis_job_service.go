@@ -0,0 +1,711 @@
+// analysis_job_service.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultAnalysisWorkers = 2
+	defaultPartConcurrency = 3
+	jobPollInterval        = 2 * time.Second
+)
+
+var (
+	ErrJobNotFound       = errors.New("задача не найдена")
+	ErrJobFinished       = errors.New("анализ уже завершён")
+	errAnalysisCancelled = errors.New("анализ отменён пользователем")
+)
+
+var (
+	// activeAnalysis хранит функции отмены задач, выполняющихся в этом процессе
+	activeAnalysis = make(map[string]context.CancelCauseFunc)
+	activeMutex    sync.Mutex
+	jobWakeup      = make(chan struct{}, 1)
+)
+
+// EnqueueAnalysis сохраняет задачу в очередь и будит свободного воркера;
+// language — язык отчёта
+func EnqueueAnalysis(userID, filename, text, language string) (*models.AnalysisJob, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("неверный ID пользователя: %w", err)
+	}
+
+	job := &models.AnalysisJob{
+		UserID:   userObjID,
+		Filename: filename,
+		Text:     text,
+		Language: language,
+	}
+	if err := enqueueAnalysisJob(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// enqueueAnalysisJob сохраняет подготовленную задачу в очередь и будит свободного воркера
+func enqueueAnalysisJob(job *models.AnalysisJob) error {
+	job.Parts = []models.JobPart{}
+	// Организация запоминается при постановке: по ней считаются анализы и расход в её лимитах
+	if organization := userOrganization(job.UserID); organization != nil {
+		job.OrganizationID = organization.ID
+	}
+	if err := repositories.CreateAnalysisJob(job); err != nil {
+		return fmt.Errorf("ошибка постановки задачи в очередь: %w", err)
+	}
+
+	PublishAnalysisEvent(job.ID.Hex(), ProgressEvent{Type: EventExtracted, Data: map[string]interface{}{
+		"filename": job.Filename,
+		"chars":    len([]rune(job.Text)),
+	}})
+
+	select {
+	case jobWakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// GetAnalysisJob возвращает задачу, если она принадлежит пользователю
+func GetAnalysisJob(userID, jobID string) (*models.AnalysisJob, error) {
+	objID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+
+	job, err := repositories.GetAnalysisJob(objID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения задачи: %w", err)
+	}
+
+	if job.UserID.Hex() != userID {
+		return nil, ErrJobNotFound
+	}
+
+	return job, nil
+}
+
+// StartAnalysisWorkers возвращает в очередь прерванные задачи и запускает пул воркеров.
+// Количество воркеров задаётся переменной ANALYSIS_WORKERS.
+func StartAnalysisWorkers(ctx context.Context) *sync.WaitGroup {
+	workers := envInt(defaultAnalysisWorkers, "ANALYSIS_WORKERS")
+
+	if n, err := repositories.RequeueInterruptedJobs(); err == nil && n > 0 {
+		utils.LogInfo(fmt.Sprintf("Возвращено в очередь %d незавершённых задач", n))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runAnalysisWorker(ctx)
+		}()
+	}
+
+	utils.LogSuccess(fmt.Sprintf("Запущено воркеров анализа: %d", workers))
+	return &wg
+}
+
+func runAnalysisWorker(ctx context.Context) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			job, err := repositories.ClaimNextAnalysisJob()
+			if err != nil {
+				utils.LogError(fmt.Sprintf("Ошибка получения задачи из очереди: %v", err))
+				break
+			}
+			if job == nil {
+				break
+			}
+			processAnalysisJob(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-jobWakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+func processAnalysisJob(parent context.Context, job *models.AnalysisJob) {
+	jobID := job.ID.Hex()
+	utils.LogAction(fmt.Sprintf("Воркер взял задачу %s (попытка %d)", jobID, job.Attempts))
+
+	ctx, cancel := context.WithCancelCause(parent)
+	activeMutex.Lock()
+	activeAnalysis[jobID] = cancel
+	activeMutex.Unlock()
+
+	defer func() {
+		activeMutex.Lock()
+		delete(activeAnalysis, jobID)
+		activeMutex.Unlock()
+		releaseAnalysisEvents(jobID)
+		cancel(nil)
+	}()
+
+	// Одно хранилище меток на задачу: одинаковые значения во всех частях
+	// документа и в сводке получают одинаковые метки
+	ctx = withPIIVault(ctx, newPIIVault())
+	ctx = withUsageScope(ctx, usageScope{userID: job.UserID, organizationID: job.OrganizationID, jobID: &job.ID})
+
+	if job.Language == "" {
+		job.Language = defaultLanguage
+	}
+	if job.Classification == nil {
+		job.Classification = ClassifyDocument(ctx, job.Text)
+		job.DocumentType = job.Classification.Type
+		err := repositories.UpdateAnalysisJob(job.ID, map[string]interface{}{
+			"classification": job.Classification,
+			"document_type":  job.DocumentType,
+		})
+		if err != nil {
+			finishJob(job, models.JobFailed, err.Error())
+			return
+		}
+	}
+	PublishAnalysisEvent(jobID, ProgressEvent{Type: EventClassified, Data: job.Classification})
+
+	tmpl, err := jobPromptTemplate(job)
+	if err != nil {
+		finishJob(job, models.JobFailed, err.Error())
+		return
+	}
+	prompt := analysisPrompt{template: tmpl, data: newPromptData(job.Classification, job.Language)}
+
+	chunks := utils.SplitDocument(job.Text, partSplitOptions())
+	if len(chunks) == 0 {
+		finishJob(job, models.JobFailed, "документ не содержит текста")
+		return
+	}
+	cache := newAnalysisCache(job, tmpl)
+	documentKey, documentHash := cache.documentKey()
+	if entry := cache.lookup(documentKey); entry != nil {
+		// Ключ не учитывает пробелы, поэтому границы частей берутся из разбиения
+		// этого текста; если частей получилось иначе, номера частей в выводах
+		// кэша к нему не подходят и документ анализируется заново
+		if parts, ok := cachedDocumentParts(entry.Parts, chunks); ok {
+			finishFromCache(ctx, job, entry, parts)
+			return
+		}
+		utils.LogInfo(fmt.Sprintf("Отчёт по задаче %s в кэше разбит на другие части, документ анализируется заново", job.ID.Hex()))
+	}
+
+	if !sameJobParts(job.Parts, chunks) {
+		job.Parts = make([]models.JobPart, len(chunks))
+		for i, chunk := range chunks {
+			job.Parts[i] = models.JobPart{
+				Index:   i,
+				Status:  models.JobQueued,
+				Heading: chunk.Heading,
+				Start:   chunk.Start,
+				End:     chunk.End,
+			}
+		}
+		if err := repositories.UpdateAnalysisJob(job.ID, map[string]interface{}{"parts": job.Parts}); err != nil {
+			finishJob(job, models.JobFailed, err.Error())
+			return
+		}
+	}
+
+	progress := func(event ProgressEvent) {
+		PublishAnalysisEvent(jobID, event)
+	}
+
+	go watchCancelRequest(ctx, cancel, job.ID)
+
+	results, failed := analyzeJobParts(ctx, job, chunks, prompt, cache, progress)
+	if ctx.Err() != nil {
+		interruptJob(ctx, job)
+		return
+	}
+	if len(failed) == len(chunks) {
+		finishJob(job, models.JobFailed, fmt.Sprintf("не удалось проанализировать ни одну часть: %s", job.Parts[0].Error))
+		return
+	}
+
+	if len(results) > 1 {
+		progress(ProgressEvent{Type: EventMerging, Total: len(results)})
+	}
+	reducePrompt := newReducePrompt(job.Classification, job.Language)
+	merged, mergeModel, err := reduceAnalysisResults(ctx, results, reducePrompt)
+	if ctx.Err() != nil {
+		interruptJob(ctx, job)
+		return
+	}
+	if err != nil {
+		finishJob(job, models.JobFailed, fmt.Sprintf("ошибка сведения отчёта: %v", err))
+		return
+	}
+	verifyCitations(merged, job.Language)
+
+	terms, ok := jobContractTerms(ctx, job, progress)
+	if !ok {
+		return
+	}
+	playbook, ok := jobPlaybookChecks(ctx, job, progress)
+	if !ok {
+		return
+	}
+
+	analysis := newJobAnalysis(job, merged)
+	analysis.FailedParts = failed
+	analysis.MergeModel = mergeModel
+	if mergeModel != "" {
+		analysis.MergePrompt = reducePrompt.template.Ref()
+	}
+	analysis.Terms = terms
+	analysis.Playbook = playbook
+	for _, part := range job.Parts {
+		if part.Status == models.JobSucceeded {
+			analysis.Parts = append(analysis.Parts, models.AnalysisPart{
+				Index:   part.Index,
+				Heading: part.Heading,
+				Start:   part.Start,
+				End:     part.End,
+				Model:   part.Model,
+				Cached:  part.Cached,
+			})
+		}
+	}
+
+	if !saveJobAnalysis(job, analysis) {
+		return
+	}
+	// Отчёт с неудавшимися частями неполон, его не кэшируем
+	if len(failed) == 0 {
+		cache.store(models.CacheDocument, documentKey, documentHash, merged, mergeModel, analysis.Parts, analysis.Terms)
+	}
+
+	message := ""
+	if len(failed) > 0 {
+		message = fmt.Sprintf("не удалось проанализировать части: %s", joinInts(failed))
+	}
+	finishJob(job, models.JobSucceeded, message)
+	utils.LogInfo(fmt.Sprintf("Тип документа: %s, выводов: %d", analysis.Type, len(analysis.Result.Findings)))
+}
+
+// cachedDocumentParts переносит модели частей из записи кэша на части текущего
+// текста; false, если число частей не совпадает
+func cachedDocumentParts(cached []models.AnalysisPart, chunks []utils.TextChunk) ([]models.AnalysisPart, bool) {
+	if len(cached) != len(chunks) {
+		return nil, false
+	}
+	parts := make([]models.AnalysisPart, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = models.AnalysisPart{
+			Index:   i,
+			Heading: chunk.Heading,
+			Start:   chunk.Start,
+			End:     chunk.End,
+			Model:   cached[i].Model,
+			Cached:  true,
+		}
+	}
+	return parts, true
+}
+
+// finishFromCache завершает задачу готовым отчётом из кэша; parts — части
+// текущего текста задачи, смещения в записи кэша относятся к другой загрузке
+func finishFromCache(ctx context.Context, job *models.AnalysisJob, entry *models.AnalysisCacheEntry, parts []models.AnalysisPart) {
+	utils.LogInfo(fmt.Sprintf("Отчёт по задаче %s взят из кэша (сохранён %s)", job.ID.Hex(), entry.CreatedAt.Format(time.RFC3339)))
+
+	job.Parts = make([]models.JobPart, len(parts))
+	for i, p := range parts {
+		job.Parts[i] = models.JobPart{
+			Index:   p.Index,
+			Status:  models.JobSucceeded,
+			Heading: p.Heading,
+			Start:   p.Start,
+			End:     p.End,
+			Model:   p.Model,
+			Cached:  true,
+		}
+	}
+	if err := repositories.UpdateAnalysisJob(job.ID, map[string]interface{}{"parts": job.Parts}); err != nil {
+		finishJob(job, models.JobFailed, err.Error())
+		return
+	}
+
+	progress := func(event ProgressEvent) {
+		PublishAnalysisEvent(job.ID.Hex(), event)
+	}
+
+	// Записи кэша, сохранённые до извлечения условий, их не содержат
+	terms := relocateContractTerms(job.Text, entry.Terms)
+	if terms == nil {
+		var ok bool
+		if terms, ok = jobContractTerms(ctx, job, progress); !ok {
+			return
+		}
+	}
+
+	// Правила организации в кэш не попадают: они меняются независимо от документа
+	playbook, ok := jobPlaybookChecks(ctx, job, progress)
+	if !ok {
+		return
+	}
+
+	analysis := newJobAnalysis(job, entry.Result)
+	analysis.MergeModel = entry.ResultModel
+	analysis.Parts = parts
+	analysis.Cached = true
+	analysis.Terms = terms
+	analysis.Playbook = playbook
+	if !saveJobAnalysis(job, analysis) {
+		return
+	}
+	finishJob(job, models.JobSucceeded, "")
+}
+
+// jobContractTerms извлекает условия договора. Ошибка извлечения не мешает
+// отчёту; false означает, что задача прервана и уже завершена.
+func jobContractTerms(ctx context.Context, job *models.AnalysisJob, progress ProgressFunc) (*models.ContractTerms, bool) {
+	progress(ProgressEvent{Type: EventExtractingTerms})
+	terms, err := extractContractTerms(ctx, job.Text, job.Language)
+	if ctx.Err() != nil {
+		interruptJob(ctx, job)
+		return nil, false
+	}
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось извлечь условия договора по задаче %s: %v", job.ID.Hex(), err))
+	}
+	return terms, true
+}
+
+// jobPlaybookChecks проверяет документ по правилам организации пользователя;
+// false означает, что задача прервана и уже завершена
+func jobPlaybookChecks(ctx context.Context, job *models.AnalysisJob, progress ProgressFunc) ([]models.PlaybookCheck, bool) {
+	organization := userOrganization(job.UserID)
+	if organization == nil {
+		return nil, true
+	}
+	progress(ProgressEvent{Type: EventCheckingPlaybook})
+	checks := checkPlaybooks(ctx, organization, job.Text, job.Classification, job.Language)
+	if ctx.Err() != nil {
+		interruptJob(ctx, job)
+		return nil, false
+	}
+	return checks, true
+}
+
+// newJobAnalysis собирает отчёт задачи из результата модели и выводов
+// детерминированных правил; result при этом не меняется и может идти в кэш
+func newJobAnalysis(job *models.AnalysisJob, result *models.AnalysisResult) *models.Analysis {
+	return &models.Analysis{
+		UserID:         job.UserID,
+		Filename:       job.Filename,
+		Type:           job.Classification.Type,
+		Language:       job.Language,
+		Classification: job.Classification,
+		PromptTemplate: job.Prompt,
+		Result:         withRuleFindings(result, job.Text, job.Language),
+		Text:           job.Text,
+	}
+}
+
+// saveJobAnalysis оценивает риск по настройкам организации пользователя и
+// сохраняет отчёт задачи, скрывая персональные данные в тексте, если так решила
+// организация; при ошибке задача завершается неудачей
+func saveJobAnalysis(job *models.AnalysisJob, analysis *models.Analysis) bool {
+	organization := userOrganization(job.UserID)
+	if organization != nil {
+		analysis.OrganizationID = organization.ID
+	}
+	analysis.Risk = scoreAnalysis(analysis.Result, riskPolicyOf(organization))
+	if storeRedactedText(organization) {
+		analysis.Text = utils.MaskPII(analysis.Text)
+		analysis.TextRedacted = true
+	}
+	analysis.Usage = jobUsage(job.ID)
+
+	if err := repositories.SaveAnalysis(analysis); err != nil {
+		finishJob(job, models.JobFailed, fmt.Sprintf("ошибка сохранения анализа: %v", err))
+		return false
+	}
+	job.AnalysisID = &analysis.ID
+	return true
+}
+
+// sameJobParts сообщает, что сохранённые части задачи совпадают с новым разбиением:
+// иначе (например, после смены настроек) готовые результаты частей не годятся
+func sameJobParts(parts []models.JobPart, chunks []utils.TextChunk) bool {
+	if len(parts) != len(chunks) {
+		return false
+	}
+	for i, chunk := range chunks {
+		if parts[i].Start != chunk.Start || parts[i].End != chunk.End {
+			return false
+		}
+	}
+	return true
+}
+
+// analyzeJobParts анализирует ещё не готовые части параллельно, не более
+// ANALYSIS_PART_CONCURRENCY одновременно на задачу; общий предел запросов к провайдеру
+// задаёт providerLimiter. Результаты возвращаются в исходном порядке, номера
+// неудавшихся частей (с 1) — отдельно: ошибка одной части не отменяет остальные.
+func analyzeJobParts(ctx context.Context, job *models.AnalysisJob, chunks []utils.TextChunk, prompt analysisPrompt, cache *analysisCache, progress ProgressFunc) ([]*models.AnalysisResult, []int) {
+	results := make([]*models.AnalysisResult, len(chunks))
+	sem := make(chan struct{}, envInt(defaultPartConcurrency, "ANALYSIS_PART_CONCURRENCY"))
+
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		part := &job.Parts[i]
+		if part.Status == models.JobSucceeded {
+			results[i] = part.Result
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			results[i] = analyzeJobPart(ctx, job, chunk, len(chunks), prompt, cache, progress)
+		}()
+	}
+	wg.Wait()
+
+	var failed []int
+	for i, result := range results {
+		if result == nil {
+			failed = append(failed, i+1)
+		}
+	}
+	return results, failed
+}
+
+// analyzeJobPart анализирует часть или берёт её результат из кэша: у документа
+// с одной изменённой страницей заново анализируется только эта часть
+func analyzeJobPart(ctx context.Context, job *models.AnalysisJob, chunk utils.TextChunk, total int, prompt analysisPrompt, cache *analysisCache, progress ProgressFunc) *models.AnalysisResult {
+	i := chunk.Index
+	part := &job.Parts[i]
+
+	utils.LogAction(fmt.Sprintf("Анализ части %d/%d задачи %s...", i+1, total, job.ID.Hex()))
+	part.Status = models.JobRunning
+	repositories.UpdateAnalysisJobPart(job.ID, *part)
+	progress(ProgressEvent{Type: EventPartStarted, Part: i + 1, Total: total, Data: chunk})
+
+	key, hash := cache.partKey(chunk)
+	var result *models.AnalysisResult
+	var model string
+	var err error
+	if entry := cache.lookup(key); entry != nil {
+		utils.LogInfo(fmt.Sprintf("Часть %d/%d задачи %s взята из кэша", i+1, total, job.ID.Hex()))
+		result, model = entry.Result, entry.ResultModel
+		part.Cached = true
+	} else {
+		result, model, err = analyzeDocumentPart(ctx, chunk, prompt, &streamCallbacks{
+			onDelta: func(delta string) {
+				progress(ProgressEvent{Type: EventPartDelta, Part: i + 1, Total: total, Content: delta})
+			},
+			onReset: func() {
+				progress(ProgressEvent{Type: EventPartReset, Part: i + 1, Total: total})
+			},
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			cache.store(models.CachePart, key, hash, result, model, nil, nil)
+		}
+	}
+
+	now := time.Now()
+	part.FinishedAt = &now
+	part.Model = model
+	if err != nil {
+		utils.LogError(fmt.Sprintf("При анализе части %d: %v", i+1, err))
+		part.Status = models.JobFailed
+		part.Error = err.Error()
+		repositories.UpdateAnalysisJobPart(job.ID, *part)
+		progress(ProgressEvent{Type: EventPartFailed, Part: i + 1, Total: total, Data: part.Error})
+		return nil
+	}
+
+	for j := range result.Findings {
+		result.Findings[j].Parts = []int{i + 1}
+	}
+	for j := range result.DefinedTerms {
+		result.DefinedTerms[j].Part = i + 1
+	}
+
+	utils.LogSuccess(fmt.Sprintf("Анализ части %d завершён, выводов: %d", i+1, len(result.Findings)))
+	part.Status = models.JobSucceeded
+	part.Result = result
+	part.Error = ""
+	repositories.UpdateAnalysisJobPart(job.ID, *part)
+	progress(ProgressEvent{Type: EventPartCompleted, Part: i + 1, Total: total, Data: result})
+	return result
+}
+
+// watchCancelRequest отменяет задачу, если отмену запросили из другого процесса
+// или до того, как задача попала в activeAnalysis
+func watchCancelRequest(ctx context.Context, cancel context.CancelCauseFunc, id primitive.ObjectID) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if requested, err := repositories.IsAnalysisJobCancelRequested(id); err == nil && requested {
+			cancel(errAnalysisCancelled)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// interruptJob различает отмену пользователем и остановку сервера:
+// во втором случае задача возвращается в очередь и будет продолжена после перезапуска.
+func interruptJob(ctx context.Context, job *models.AnalysisJob) {
+	if errors.Is(context.Cause(ctx), errAnalysisCancelled) {
+		finishJob(job, models.JobCancelled, errAnalysisCancelled.Error())
+		return
+	}
+
+	utils.LogWarning(fmt.Sprintf("Задача %s прервана остановкой сервера и возвращена в очередь", job.ID.Hex()))
+	repositories.UpdateAnalysisJob(job.ID, map[string]interface{}{"status": models.JobQueued})
+}
+
+func finishJob(job *models.AnalysisJob, status models.JobStatus, message string) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      status,
+		"error":       message,
+		"finished_at": now,
+	}
+	if job.AnalysisID != nil {
+		updates["analysis_id"] = job.AnalysisID
+		updates["document_type"] = job.DocumentType
+	}
+
+	if err := repositories.UpdateAnalysisJob(job.ID, updates); err != nil {
+		return
+	}
+
+	job.Status = status
+	job.Error = message
+	job.FinishedAt = &now
+	PublishAnalysisEvent(job.ID.Hex(), FinalAnalysisEvent(job))
+	maskStoredJobText(job)
+
+	if status == models.JobSucceeded {
+		utils.LogSuccess(fmt.Sprintf("Задача %s выполнена", job.ID.Hex()))
+	} else {
+		utils.LogWarning(fmt.Sprintf("Задача %s завершена со статусом %s: %s", job.ID.Hex(), status, message))
+	}
+}
+
+// CancelAnalysis отменяет одну задачу пользователя. Задача из очереди отменяется сразу,
+// у выполняющейся прерывается текущий запрос к LLM, и воркер помечает её как cancelled.
+func CancelAnalysis(userID, jobID string) error {
+	job, err := GetAnalysisJob(userID, jobID)
+	if err != nil {
+		return err
+	}
+	if job.Status.IsFinal() {
+		return ErrJobFinished
+	}
+
+	return cancelJob(job.ID)
+}
+
+// CancelUserAnalysis отменяет все незавершённые анализы пользователя
+func CancelUserAnalysis(userID string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("неверный ID пользователя: %w", err)
+	}
+
+	ids, err := repositories.GetUnfinishedAnalysisJobIDs(userObjID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения задач: %w", err)
+	}
+
+	cancelled := 0
+	for _, id := range ids {
+		if err := cancelJob(id); err == nil {
+			cancelled++
+		}
+	}
+
+	if cancelled == 0 {
+		return fmt.Errorf("анализ не найден или уже завершен")
+	}
+	return nil
+}
+
+func cancelJob(id primitive.ObjectID) error {
+	ok, err := repositories.CancelQueuedAnalysisJob(id)
+	if err != nil {
+		return fmt.Errorf("ошибка отмены задачи: %w", err)
+	}
+	if ok {
+		utils.LogInfo(fmt.Sprintf("Задача %s отменена до начала выполнения", id.Hex()))
+		return nil
+	}
+
+	ok, err = repositories.RequestAnalysisJobCancel(id)
+	if err != nil {
+		return fmt.Errorf("ошибка отмены задачи: %w", err)
+	}
+	if !ok {
+		return ErrJobFinished
+	}
+
+	activeMutex.Lock()
+	if cancel, exists := activeAnalysis[id.Hex()]; exists {
+		cancel(errAnalysisCancelled)
+	}
+	activeMutex.Unlock()
+
+	utils.LogInfo(fmt.Sprintf("Запрошена отмена задачи %s", id.Hex()))
+	return nil
+}
+
+// GetJobAnalysis возвращает сохранённый результат успешно выполненной задачи
+func GetJobAnalysis(job *models.AnalysisJob) (*models.Analysis, error) {
+	if job.Status != models.JobSucceeded || job.AnalysisID == nil {
+		return nil, nil
+	}
+
+	analysis, err := repositories.GetAnalysis(*job.AnalysisID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения результата анализа: %w", err)
+	}
+	fillAnalysisMarkdown(analysis)
+
+	return analysis, nil
+}
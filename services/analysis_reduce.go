@@ -0,0 +1,416 @@
+// analysis_reduce.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"legally/models"
+	"legally/utils"
+	"sort"
+	"strings"
+)
+
+const (
+	// reduceFindingRunes и reduceSummaryRunes — до скольких символов сокращаются
+	// описания выводов и сводки частей, если результаты не помещаются в контекст
+	reduceFindingRunes = 300
+	reduceSummaryRunes = 600
+)
+
+const reduceSchema = `{
+  "findings": [
+    {
+      "kind": "risk | ambiguity | violation",
+      "title": "краткое название",
+      "description": "подробное описание с учётом всего документа",
+      "legal_basis": "нормативный акт и статья",
+      "severity": "high | medium | low",
+      "likelihood": "high | medium | low",
+      "recommendation": "предложение по исправлению",
+      "sources": ["p1-f2", "p3-f1"]
+    }
+  ],
+  "recommendations": ["рекомендация по документу в целом"],
+  "conclusion": {"summary": "единое заключение по документу", "overall_risk": "high | medium | low"}
+}`
+
+// reduceSchemas — схема сведения с пояснениями на языке отчёта
+var reduceSchemas = map[string]string{
+	LanguageRussian: reduceSchema,
+	LanguageKazakh: `{
+  "findings": [
+    {
+      "kind": "risk | ambiguity | violation",
+      "title": "қысқаша атауы",
+      "description": "бүкіл құжатты ескергендегі толық сипаттамасы",
+      "legal_basis": "нормативтік акт және бап",
+      "severity": "high | medium | low",
+      "likelihood": "high | medium | low",
+      "recommendation": "түзету жөніндегі ұсыныс",
+      "sources": ["p1-f2", "p3-f1"]
+    }
+  ],
+  "recommendations": ["тұтас құжат бойынша ұсыныс"],
+  "conclusion": {"summary": "құжат бойынша бірыңғай қорытынды", "overall_risk": "high | medium | low"}
+}`,
+	LanguageEnglish: `{
+  "findings": [
+    {
+      "kind": "risk | ambiguity | violation",
+      "title": "short title",
+      "description": "detailed description taking the whole document into account",
+      "legal_basis": "legal act and article",
+      "severity": "high | medium | low",
+      "likelihood": "high | medium | low",
+      "recommendation": "suggested fix",
+      "sources": ["p1-f2", "p3-f1"]
+    }
+  ],
+  "recommendations": ["recommendation for the document as a whole"],
+  "conclusion": {"summary": "single conclusion on the document", "overall_risk": "high | medium | low"}
+}`,
+}
+
+func reduceSchemaFor(language string) string {
+	if schema, ok := reduceSchemas[language]; ok {
+		return schema
+	}
+	return reduceSchema
+}
+
+type reduceInputFinding struct {
+	ID string `json:"id"`
+	models.Finding
+}
+
+type reduceInputPart struct {
+	Part         int                  `json:"part"`
+	Findings     []reduceInputFinding `json:"findings"`
+	DefinedTerms []models.DefinedTerm `json:"defined_terms,omitempty"`
+	Summary      string               `json:"summary"`
+}
+
+type reduceOutput struct {
+	Findings []struct {
+		models.Finding
+		Sources []string `json:"sources"`
+	} `json:"findings"`
+	Recommendations []string          `json:"recommendations"`
+	Conclusion      models.Conclusion `json:"conclusion"`
+}
+
+// newReducePrompt выбирает шаблон сведения отчёта для типа документа и языка отчёта
+func newReducePrompt(classification *models.DocumentClassification, language string) analysisPrompt {
+	data := newPromptData(classification, language)
+	data.Schema = reduceSchemaFor(language)
+	return analysisPrompt{
+		template: selectPromptTemplate(models.PromptPurposeReduce, classification, language),
+		data:     data,
+	}
+}
+
+// reduceAnalysisResults сводит результаты частей в единый отчёт по документу.
+// Каждая часть анализируется без знания остальных, поэтому модель просят убрать дубли,
+// согласовать противоречия и учесть термины, определённые в других частях.
+// Если сведение через LLM не удалось, результаты объединяются детерминированно.
+// Вторым значением возвращается модель, сводившая отчёт, или пустая строка.
+func reduceAnalysisResults(ctx context.Context, parts []*models.AnalysisResult, prompt analysisPrompt) (*models.AnalysisResult, string, error) {
+	var available []*models.AnalysisResult
+	for _, part := range parts {
+		if part != nil {
+			available = append(available, part)
+		}
+	}
+	if len(available) == 0 {
+		return nil, "", fmt.Errorf("нет результатов для сведения")
+	}
+	if len(available) == 1 {
+		return available[0], "", nil
+	}
+
+	utils.LogAction(fmt.Sprintf("Сведение результатов %d частей в единый отчёт", len(available)))
+
+	result, model, err := reduceWithinBudget(ctx, parts, prompt)
+	if err == nil {
+		utils.LogSuccess(fmt.Sprintf("Отчёт сведён (%s): %d выводов", model, len(result.Findings)))
+		return result, model, nil
+	}
+	if ctx.Err() != nil {
+		return nil, "", ctx.Err()
+	}
+
+	utils.LogWarning(fmt.Sprintf("Не удалось свести части через AI, объединяем без него: %v", err))
+	return mergePartResults(parts), "", nil
+}
+
+// reduceInputBudget — сколько токенов остаётся на результаты частей: контекст
+// модели минус ответ и сам промпт без результатов
+func reduceInputBudget(prompt analysisPrompt) (int, error) {
+	data := prompt.data
+	data.Text = ""
+	system, body, err := renderPrompt(prompt.template, data)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка шаблона сведения: %w", err)
+	}
+	budget := envInt(defaultContextTokens, "LLM_CONTEXT_TOKENS") - envInt(defaultLLMMaxTokens, "LLM_MAX_TOKENS") -
+		llm.CountTokens(system) - llm.CountTokens(body)
+	return max(budget, minPartTokens), nil
+}
+
+// reduceWithinBudget сводит части за один запрос, если их результаты помещаются в
+// контекст модели, при необходимости сократив описания выводов. Иначе части
+// сводятся группами, а результаты групп — следующим уровнем тем же способом.
+func reduceWithinBudget(ctx context.Context, parts []*models.AnalysisResult, prompt analysisPrompt) (*models.AnalysisResult, string, error) {
+	budget, err := reduceInputBudget(prompt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input, sources := buildReduceInput(parts)
+	if reduceInputTokens(input) > budget {
+		trimReduceInput(input)
+	}
+	batches := batchReduceInput(input, budget)
+	if len(batches) == 1 {
+		return reduceWithLLM(ctx, input, sources, prompt)
+	}
+	if len(batches) == len(input) {
+		return nil, "", fmt.Errorf("результаты частей не помещаются в контекст модели даже попарно")
+	}
+
+	utils.LogInfo(fmt.Sprintf("Результаты %d частей не помещаются в контекст, сводим группами: %d", len(input), len(batches)))
+	level := make([]*models.AnalysisResult, len(batches))
+	for i, batch := range batches {
+		result, _, err := reduceWithLLM(ctx, batch, sources, prompt)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, "", ctx.Err()
+			}
+			utils.LogWarning(fmt.Sprintf("Не удалось свести группу частей %d через AI, объединяем без него: %v", i+1, err))
+			group := make([]*models.AnalysisResult, 0, len(batch))
+			for _, in := range batch {
+				group = append(group, parts[in.Part-1])
+			}
+			result = mergePartResults(group)
+		}
+		level[i] = result
+	}
+	return reduceWithinBudget(ctx, level, prompt)
+}
+
+func buildReduceInput(parts []*models.AnalysisResult) ([]reduceInputPart, map[string]models.Finding) {
+	input := make([]reduceInputPart, 0, len(parts))
+	sources := make(map[string]models.Finding)
+
+	for i, part := range parts {
+		if part == nil {
+			continue
+		}
+		in := reduceInputPart{
+			Part:         i + 1,
+			Findings:     []reduceInputFinding{},
+			DefinedTerms: append([]models.DefinedTerm(nil), part.DefinedTerms...),
+			Summary:      part.Conclusion.Summary,
+		}
+		for k := range in.DefinedTerms {
+			if in.DefinedTerms[k].Part == 0 {
+				in.DefinedTerms[k].Part = i + 1
+			}
+		}
+		for j, f := range part.Findings {
+			id := fmt.Sprintf("p%d-f%d", i+1, j+1)
+			sources[id] = f
+			// Ссылки на законы и фрагменты восстанавливаются по sources, модели они не нужны
+			f.Citations, f.References, f.Span = nil, nil, nil
+			in.Findings = append(in.Findings, reduceInputFinding{ID: id, Finding: f})
+		}
+		input = append(input, in)
+	}
+
+	return input, sources
+}
+
+func reduceInputTokens(input []reduceInputPart) int {
+	data, _ := json.Marshal(input)
+	return llm.CountTokens(string(data))
+}
+
+// trimReduceInput сокращает описания выводов, рекомендации и сводки частей:
+// для сведения достаточно сути. Определения терминов не сокращаются — они
+// переходят в отчёт как есть.
+func trimReduceInput(input []reduceInputPart) {
+	for i := range input {
+		for j := range input[i].Findings {
+			f := &input[i].Findings[j]
+			f.Description = truncateRunes(f.Description, reduceFindingRunes)
+			f.Recommendation = truncateRunes(f.Recommendation, reduceFindingRunes)
+		}
+		input[i].Summary = truncateRunes(input[i].Summary, reduceSummaryRunes)
+	}
+}
+
+// batchReduceInput делит результаты частей на группы подряд идущих частей, каждая
+// из которых помещается в budget токенов. Часть, которая не помещается и одна,
+// образует отдельную группу.
+func batchReduceInput(input []reduceInputPart, budget int) [][]reduceInputPart {
+	var batches [][]reduceInputPart
+	from := 0
+	for i := 1; i < len(input); i++ {
+		if reduceInputTokens(input[from:i+1]) > budget {
+			batches = append(batches, input[from:i])
+			from = i
+		}
+	}
+	batches = append(batches, input[from:])
+	return batches
+}
+
+func reduceWithLLM(ctx context.Context, input []reduceInputPart, sources map[string]models.Finding, prompt analysisPrompt) (*models.AnalysisResult, string, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка маршалинга результатов частей: %w", err)
+	}
+
+	promptData := prompt.data
+	promptData.Text = string(data)
+	system, body, err := renderPrompt(prompt.template, promptData)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка шаблона сведения: %w", err)
+	}
+
+	resp, err := callLLM(withLLMOperation(ctx, llmOpMerge), newChatRequest(system, body), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var out reduceOutput
+	if err := unmarshalModelJSON(resp.Content, &out); err != nil {
+		return nil, "", err
+	}
+
+	result := &models.AnalysisResult{
+		Findings:        []models.Finding{},
+		Recommendations: out.Recommendations,
+		Conclusion:      out.Conclusion,
+	}
+	if result.Recommendations == nil {
+		result.Recommendations = []string{}
+	}
+
+	for _, f := range out.Findings {
+		finding := f.Finding
+		if strings.TrimSpace(finding.Title) == "" && strings.TrimSpace(finding.Description) == "" {
+			continue
+		}
+		finding.Kind = normalizeFindingKind(finding.Kind)
+		finding.Severity = normalizeSeverity(finding.Severity)
+		finding.Likelihood = normalizeLikelihood(finding.Likelihood)
+
+		// Номера частей и ссылки на законы берём только из известных исходных выводов,
+		// а не из ответа модели
+		var partNums []int
+		finding.Citations = nil
+		for _, id := range f.Sources {
+			if src, ok := sources[strings.TrimSpace(id)]; ok {
+				partNums = append(partNums, src.Parts...)
+				finding.Citations = mergeCitations(finding.Citations, src.Citations)
+			}
+		}
+		finding.Parts = uniqueSortedInts(partNums)
+		result.Findings = append(result.Findings, finding)
+	}
+
+	// Термины сохраняют номер части документа, в которой определены, и при
+	// сведении групп частей
+	var found int
+	for _, part := range input {
+		result.DefinedTerms = append(result.DefinedTerms, part.DefinedTerms...)
+		found += len(part.Findings)
+	}
+
+	if len(result.Findings) == 0 && found > 0 {
+		return nil, "", fmt.Errorf("сведённый отчёт не содержит выводов")
+	}
+	if result.Conclusion.OverallRisk == "" {
+		result.Conclusion.OverallRisk = overallRisk(result.Findings)
+	} else {
+		result.Conclusion.OverallRisk = normalizeSeverity(result.Conclusion.OverallRisk)
+	}
+
+	return result, resp.Model, nil
+}
+
+// mergePartResults объединяет результаты частей без LLM: выводы с одинаковыми видом и названием
+// склеиваются, уровень риска берётся наибольший
+func mergePartResults(parts []*models.AnalysisResult) *models.AnalysisResult {
+	merged := &models.AnalysisResult{
+		Findings:        []models.Finding{},
+		Recommendations: []string{},
+	}
+
+	index := make(map[string]int)
+	seenRecommendations := make(map[string]bool)
+	var summaries []string
+
+	for i, part := range parts {
+		if part == nil {
+			continue
+		}
+
+		for _, f := range part.Findings {
+			key := string(f.Kind) + "|" + strings.ToLower(strings.TrimSpace(f.Title))
+			if at, ok := index[key]; ok {
+				existing := &merged.Findings[at]
+				existing.Parts = uniqueSortedInts(append(existing.Parts, f.Parts...))
+				existing.Citations = mergeCitations(existing.Citations, f.Citations)
+				if severityRank[f.Severity] > severityRank[existing.Severity] {
+					existing.Severity = f.Severity
+				}
+				if severityRank[f.Likelihood] > severityRank[existing.Likelihood] {
+					existing.Likelihood = f.Likelihood
+				}
+				continue
+			}
+			index[key] = len(merged.Findings)
+			merged.Findings = append(merged.Findings, f)
+		}
+
+		for _, r := range part.Recommendations {
+			key := strings.ToLower(strings.TrimSpace(r))
+			if !seenRecommendations[key] {
+				seenRecommendations[key] = true
+				merged.Recommendations = append(merged.Recommendations, r)
+			}
+		}
+
+		for _, t := range part.DefinedTerms {
+			if t.Part == 0 {
+				t.Part = i + 1
+			}
+			merged.DefinedTerms = append(merged.DefinedTerms, t)
+		}
+
+		if s := strings.TrimSpace(part.Conclusion.Summary); s != "" {
+			summaries = append(summaries, s)
+		}
+	}
+
+	merged.Conclusion.Summary = strings.Join(summaries, "\n\n")
+	merged.Conclusion.OverallRisk = overallRisk(merged.Findings)
+	return merged
+}
+
+func uniqueSortedInts(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	var out []int
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
@@ -0,0 +1,300 @@
+// analysis_result.go
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"legally/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// analysisSchema описывает JSON, который модель должна вернуть для каждой части документа
+const analysisSchema = `{
+  "findings": [
+    {
+      "kind": "risk | ambiguity | violation",
+      "title": "краткое название",
+      "description": "подробное описание; для нарушений — также возможные последствия",
+      "legal_basis": "нормативный акт и статья",
+      "severity": "high | medium | low",
+      "likelihood": "high | medium | low",
+      "recommendation": "предложение по исправлению",
+      "citations": ["идентификатор фрагмента закона из списка, например L-3f9a1c-12"]
+    }
+  ],
+  "recommendations": ["конкретная рекомендация по исправлению документа"],
+  "defined_terms": [{"term": "термин, определённый в тексте", "definition": "его определение"}],
+  "conclusion": {"summary": "общая сводка по документу с выводами", "overall_risk": "high | medium | low"}
+}`
+
+// analysisSchemas — та же схема с пояснениями на языке отчёта: русские пояснения
+// в английском промпте подталкивают модель отвечать по-русски
+var analysisSchemas = map[string]string{
+	LanguageRussian: analysisSchema,
+	LanguageKazakh: `{
+  "findings": [
+    {
+      "kind": "risk | ambiguity | violation",
+      "title": "қысқаша атауы",
+      "description": "толық сипаттамасы; бұзушылықтар үшін — ықтимал салдары да",
+      "legal_basis": "нормативтік акт және бап",
+      "severity": "high | medium | low",
+      "likelihood": "high | medium | low",
+      "recommendation": "түзету жөніндегі ұсыныс",
+      "citations": ["тізімдегі заң үзіндісінің идентификаторы, мысалы L-3f9a1c-12"]
+    }
+  ],
+  "recommendations": ["құжатты түзету жөніндегі нақты ұсыныс"],
+  "defined_terms": [{"term": "мәтінде анықталған термин", "definition": "оның анықтамасы"}],
+  "conclusion": {"summary": "құжат бойынша қорытындылары бар жалпы шолу", "overall_risk": "high | medium | low"}
+}`,
+	LanguageEnglish: `{
+  "findings": [
+    {
+      "kind": "risk | ambiguity | violation",
+      "title": "short title",
+      "description": "detailed description; for violations also the possible consequences",
+      "legal_basis": "legal act and article",
+      "severity": "high | medium | low",
+      "likelihood": "high | medium | low",
+      "recommendation": "suggested fix",
+      "citations": ["identifier of a law fragment from the list, e.g. L-3f9a1c-12"]
+    }
+  ],
+  "recommendations": ["specific recommendation for fixing the document"],
+  "defined_terms": [{"term": "term defined in the text", "definition": "its definition"}],
+  "conclusion": {"summary": "overall summary of the document with conclusions", "overall_risk": "high | medium | low"}
+}`,
+}
+
+func analysisSchemaFor(language string) string {
+	if schema, ok := analysisSchemas[language]; ok {
+		return schema
+	}
+	return analysisSchema
+}
+
+var (
+	thinkBlockRe = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+	findingKindOrder = []models.FindingKind{models.FindingRisk, models.FindingAmbiguity, models.FindingViolation}
+
+	severityRank = map[models.Severity]int{
+		models.SeverityLow:    1,
+		models.SeverityMedium: 2,
+		models.SeverityHigh:   3,
+	}
+)
+
+// parseAnalysisResult извлекает JSON из ответа модели и проверяет его по схеме
+func parseAnalysisResult(raw string) (*models.AnalysisResult, error) {
+	var result models.AnalysisResult
+	if err := unmarshalModelJSON(raw, &result); err != nil {
+		return nil, err
+	}
+
+	findings := result.Findings[:0]
+	for _, f := range result.Findings {
+		f.Title = strings.TrimSpace(f.Title)
+		f.Description = strings.TrimSpace(f.Description)
+		if f.Title == "" && f.Description == "" {
+			continue
+		}
+		f.Kind = normalizeFindingKind(f.Kind)
+		f.Severity = normalizeSeverity(f.Severity)
+		f.Likelihood = normalizeLikelihood(f.Likelihood)
+		// Источник и фрагмент ставят только правила, модель их не задаёт
+		f.Source, f.Rule, f.Span = "", "", nil
+		findings = append(findings, f)
+	}
+	result.Findings = findings
+
+	terms := result.DefinedTerms[:0]
+	for _, t := range result.DefinedTerms {
+		if strings.TrimSpace(t.Term) != "" {
+			terms = append(terms, t)
+		}
+	}
+	result.DefinedTerms = terms
+
+	if len(result.Findings) == 0 && strings.TrimSpace(result.Conclusion.Summary) == "" {
+		return nil, fmt.Errorf("ответ AI не содержит ни выводов, ни заключения")
+	}
+	if result.Recommendations == nil {
+		result.Recommendations = []string{}
+	}
+	if result.Conclusion.OverallRisk == "" {
+		result.Conclusion.OverallRisk = overallRisk(result.Findings)
+	} else {
+		result.Conclusion.OverallRisk = normalizeSeverity(result.Conclusion.OverallRisk)
+	}
+
+	return &result, nil
+}
+
+// unmarshalModelJSON вырезает JSON-объект из ответа модели (без блока рассуждений
+// и markdown-обёртки) и разбирает его в v
+func unmarshalModelJSON(raw string, v interface{}) error {
+	text := thinkBlockRe.ReplaceAllString(raw, "")
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start == -1 || end <= start {
+		return fmt.Errorf("ответ AI не содержит JSON")
+	}
+
+	if err := json.Unmarshal([]byte(text[start:end+1]), v); err != nil {
+		return fmt.Errorf("ответ AI не соответствует схеме: %w", err)
+	}
+	return nil
+}
+
+// overallRisk — наивысший уровень риска среди выводов
+func overallRisk(findings []models.Finding) models.Severity {
+	risk := models.SeverityLow
+	for _, f := range findings {
+		if severityRank[f.Severity] > severityRank[risk] {
+			risk = f.Severity
+		}
+	}
+	return risk
+}
+
+func normalizeFindingKind(kind models.FindingKind) models.FindingKind {
+	switch strings.ToLower(strings.TrimSpace(string(kind))) {
+	case "ambiguity", "неясная формулировка", "неясные формулировки":
+		return models.FindingAmbiguity
+	case "violation", "нарушение", "возможные нарушения":
+		return models.FindingViolation
+	default:
+		return models.FindingRisk
+	}
+}
+
+// normalizeSeverity приводит уровень к high/medium/low; модель иногда отвечает по-русски
+func normalizeSeverity(severity models.Severity) models.Severity {
+	switch strings.ToLower(strings.TrimSpace(string(severity))) {
+	case "high", "высокий", "критический":
+		return models.SeverityHigh
+	case "low", "низкий":
+		return models.SeverityLow
+	default:
+		return models.SeverityMedium
+	}
+}
+
+// normalizeLikelihood приводит вероятность к high/medium/low; пустая остаётся
+// пустой, и оценка риска выводит её из вида вывода
+func normalizeLikelihood(likelihood models.Severity) models.Severity {
+	if strings.TrimSpace(string(likelihood)) == "" {
+		return ""
+	}
+	return normalizeSeverity(likelihood)
+}
+
+// RenderAnalysisMarkdown строит markdown-отчёт из структурированного результата
+func RenderAnalysisMarkdown(result *models.AnalysisResult, language string) string {
+	if result == nil {
+		return ""
+	}
+	t := reportTextFor(language)
+
+	var b strings.Builder
+	for _, kind := range findingKindOrder {
+		var findings []models.Finding
+		for _, f := range result.Findings {
+			if f.Kind == kind {
+				findings = append(findings, f)
+			}
+		}
+		if len(findings) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "### %s\n\n", t.kinds[kind])
+		for i, f := range findings {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, f.Title)
+			if f.Description != "" {
+				fmt.Fprintf(&b, "   - %s: %s\n", t.description, f.Description)
+			}
+			if f.LegalBasis != "" {
+				fmt.Fprintf(&b, "   - %s: %s\n", t.legalBasis, f.LegalBasis)
+			}
+			if len(f.Citations) > 0 {
+				fmt.Fprintf(&b, "   - %s: %s\n", t.sources, formatCitations(f.Citations))
+			}
+			for _, ref := range f.References {
+				fmt.Fprintf(&b, "   - %s: %s\n", fmt.Sprintf(t.referenceCheck, ref.Text), referenceStatusText(ref, t))
+			}
+			fmt.Fprintf(&b, "   - %s: %s\n", t.riskLevel, t.severities[f.Severity])
+			if f.Recommendation != "" {
+				fmt.Fprintf(&b, "   - %s: %s\n", t.recommendation, f.Recommendation)
+			}
+			if len(f.Parts) > 0 {
+				fmt.Fprintf(&b, "   - %s: %s\n", t.parts, joinInts(f.Parts))
+			}
+			if f.Span != nil {
+				fmt.Fprintf(&b, "   - %s: «%s»\n", t.fragment, f.Span.Quote)
+			}
+			if f.Source == models.FindingSourceRule {
+				fmt.Fprintf(&b, "   - _%s_\n", t.ruleCheck)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(result.Recommendations) > 0 {
+		fmt.Fprintf(&b, "### %s\n\n", t.recommendations)
+		for _, r := range result.Recommendations {
+			fmt.Fprintf(&b, "- %s\n", r)
+		}
+		b.WriteString("\n")
+	}
+
+	if check := result.CitationCheck; check != nil && check.NeedsReview {
+		fmt.Fprintf(&b, "> %s\n\n", fmt.Sprintf(t.needsReview, check.NotFound, check.Mismatched))
+	}
+
+	if result.Conclusion.Summary != "" {
+		fmt.Fprintf(&b, "### %s\n\n%s\n", t.conclusion, result.Conclusion.Summary)
+		if result.Conclusion.OverallRisk != "" {
+			fmt.Fprintf(&b, "\n%s: %s\n", t.overallRisk, t.severities[result.Conclusion.OverallRisk])
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+func referenceStatusText(ref models.LegalReference, t reportText) string {
+	var status string
+	switch ref.Status {
+	case models.ReferenceVerified:
+		status = t.referenceVerified
+	case models.ReferenceMismatched:
+		status = t.referenceMismatched
+	default:
+		status = t.referenceNotFound
+	}
+	if ref.Note != "" {
+		status += " (" + ref.Note + ")"
+	}
+	return status
+}
+
+func formatCitations(citations []models.Citation) string {
+	parts := make([]string, len(citations))
+	for i, c := range citations {
+		parts[i] = fmt.Sprintf("[%s] %s", c.ID, c.Title)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -3,29 +3,66 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/net/context"
-	"io"
+	"legally/models"
 	"legally/repositories"
 	"legally/utils"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
 const (
-	model       = "deepseek/deepseek-r1-0528:free"
-	apiEndpoint = "https://openrouter.ai/api/v1/chat/completions"
+	defaultContextTokens     = 16000
+	defaultPartOverlapTokens = 200
+	// partPromptTokens — запас на инструкцию и схему ответа в промпте части
+	partPromptTokens = 1500
+	// maxPartTokens ограничивает часть и для моделей с большим контекстом:
+	// на длинном тексте модель пропускает больше рисков
+	maxPartTokens = 6000
+	minPartTokens = 500
 )
 
+// partSplitOptions рассчитывает бюджет части по контексту модели: LLM_CONTEXT_TOKENS
+// минус ответ (LLM_MAX_TOKENS), промпт, фрагменты законов и перекрытие. ANALYSIS_PART_TOKENS задаёт
+// бюджет явно, ANALYSIS_PART_OVERLAP_TOKENS — перекрытие (0 — без перекрытия).
+func partSplitOptions() utils.SplitOptions {
+	overlap := defaultPartOverlapTokens
+	if n, err := strconv.Atoi(os.Getenv("ANALYSIS_PART_OVERLAP_TOKENS")); err == nil && n >= 0 {
+		overlap = n
+	}
+
+	budget := envInt(0, "ANALYSIS_PART_TOKENS")
+	if budget == 0 {
+		budget = envInt(defaultContextTokens, "LLM_CONTEXT_TOKENS") -
+			envInt(defaultLLMMaxTokens, "LLM_MAX_TOKENS") - partPromptTokens - lawContextTokens - overlap
+		budget = max(min(budget, maxPartTokens), minPartTokens)
+	}
+
+	return utils.SplitOptions{
+		MaxTokens:     budget,
+		OverlapTokens: overlap,
+		CountTokens:   llm.CountTokens,
+	}
+}
+
 type HttpError struct {
 	Status  int
 	Message string
+	// Code — код ошибки для клиента; пустой, если подходит общий код обработчика
+	Code string
+}
+
+// CodeOr возвращает код ошибки или fallback, если он не задан
+func (e *HttpError) CodeOr(fallback string) string {
+	if e.Code != "" {
+		return e.Code
+	}
+	return fallback
 }
 
 func AnalyzeDocument(c *gin.Context) (interface{}, *HttpError) {
@@ -39,174 +76,90 @@ func AnalyzeDocument(c *gin.Context) (interface{}, *HttpError) {
 
 	utils.LogInfo(fmt.Sprintf("Извлечено %d символов из документа", len(text)))
 
-	analysis, docType, err := AnalyzeText(text)
+	userID, _ := c.Get("userId")
+	language, err := reportLanguage(c, userID.(string))
 	if err != nil {
-		utils.LogError(err.Error())
-		return nil, &HttpError{Status: http.StatusInternalServerError, Message: err.Error()}
+		return nil, &HttpError{Status: http.StatusBadRequest, Message: err.Error()}
 	}
 
-	userID, _ := c.Get("userId")
-	err = repositories.SaveAnalysis(userID.(string), filename, docType, analysis, text)
+	release, err := ReserveUsageQuota(userID.(string), 1)
 	if err != nil {
-		utils.LogWarning(fmt.Sprintf("Ошибка сохранения в MongoDB: %v", err))
+		return nil, quotaHttpError(err)
+	}
+	job, err := EnqueueAnalysis(userID.(string), filename, text, language)
+	release()
+	if err != nil {
+		utils.LogError(err.Error())
+		return nil, &HttpError{Status: http.StatusInternalServerError, Message: err.Error()}
 	}
-
-	utils.LogSuccess("Полный анализ готов, отправляем ответ клиенту")
-	utils.LogInfo(fmt.Sprintf("Тип документа: %s, длина анализа: %d символов", docType, len(analysis)))
 
 	return gin.H{
-		"analysis":      analysis,
-		"timestamp":     time.Now().Format(time.RFC3339),
-		"document_type": docType,
-		"filename":      filename,
+		"job_id":    job.ID.Hex(),
+		"status":    job.Status,
+		"timestamp": job.CreatedAt.Format(time.RFC3339),
+		"filename":  filename,
+		"language":  job.Language,
 	}, nil
 }
 
-func AnalyzeText(text string) (string, string, error) {
-	parts := utils.SplitText(text, 12000)
-	utils.LogInfo(fmt.Sprintf("Документ разбит на %d частей для анализа", len(parts)))
-
-	var analysisResults []string
-	for i, part := range parts {
-		partNum := i + 1
-		utils.LogAction(fmt.Sprintf("Анализ части %d/%d...", partNum, len(parts)))
-
-		result, err := analyzeDocumentPart(part)
-		if err != nil {
-			utils.LogError(fmt.Sprintf("При анализе части %d: %v", partNum, err))
-			return "", "", err
-		}
-
-		utils.LogSuccess(fmt.Sprintf("Анализ части %d завершён, результат длиной %d символов", partNum, len(result)))
-		analysisResults = append(analysisResults, result)
+// reportLanguage выбирает язык отчёта: параметр language запроса, затем язык
+// из профиля пользователя, затем Accept-Language, иначе русский
+func reportLanguage(c *gin.Context, userID string) (string, error) {
+	language := c.PostForm("language")
+	if language == "" {
+		language = c.Query("language")
 	}
-
-	fullAnalysis := strings.Join(analysisResults, "\n\n---\n\n")
-	docType := detectDocumentType(text)
-
-	return fullAnalysis, docType, nil
-}
-
-func analyzeDocumentPart(text string) (string, error) {
-	prompt := fmt.Sprintf(`Проанализируй следующий юридический документ на соответствие законодательству Казахстана. 
-
-В ответе придерживайся следующей структуры:
-
-### Правовые риски
-
-1. [Название риска]
-   - Описание: [подробное описание]
-   - Нормативный акт: [закон/статья]
-   - Уровень риска: [высокий/средний/низкий]
-   - Рекомендация: [предложение по исправлению]
-
-2. [Название риска]
-   ...
-
-### Неясные формулировки
-
-1. [Формулировка]
-   - Проблема: [в чем неясность]
-   - Рекомендация: [как переформулировать]
-   - Уровень важности: [высокий/средний/низкий]
-
-### Возможные нарушения
-
-1. [Описание нарушения]
-   - Нормативный акт: [закон/статья]
-   - Последствия: [возможные санкции]
-   - Рекомендация: [как избежать]
-
-### Рекомендации
-
-[Список конкретных рекомендаций по исправлению документа]
-
-### Заключение
-
-[Общая сводка по документу с выводами]
-
-Документ:
-%s`, text)
-
-	utils.LogInfo(fmt.Sprintf("Отправка запроса к AI с текстом длиной %d символов", len(text)))
-
-	result, err := queryOpenRouter(prompt)
-	if err != nil {
-		return "", err
+	if language = strings.ToLower(strings.TrimSpace(language)); language != "" {
+		if !IsSupportedLanguage(language) {
+			return "", ErrUnsupportedLanguage
+		}
+		return language, nil
 	}
 
-	utils.LogSuccess(fmt.Sprintf("Успешно получен ответ от AI длиной %d символов", len(result)))
-	return result, nil
-}
-
-func queryOpenRouter(prompt string) (string, error) {
-	apiKey := os.Getenv("OPENROUTER_API_KEY")
-	if apiKey == "" {
-		return "", fmt.Errorf("OPENROUTER_API_KEY не установлен")
+	if user, err := ValidateUser(userID); err == nil && IsSupportedLanguage(user.Language) {
+		return user.Language, nil
 	}
-
-	payload := map[string]interface{}{
-		"model": model,
-		"messages": []map[string]string{
-			{"role": "system", "content": "Ты — юридический эксперт по законодательству Казахстана. Анализируй документы и давай развернутые ответы с конкретными ссылками на законы."},
-			{"role": "user", "content": prompt},
-		},
-		"temperature": 0.3,
-		"max_tokens":  4000,
+	if language := preferredLanguage(c.GetHeader("Accept-Language")); language != "" {
+		return language, nil
 	}
+	return defaultLanguage, nil
+}
 
-	body, err := json.Marshal(payload)
+// analyzeDocumentPart анализирует одну часть документа по шаблону промпта задачи
+// и возвращает также модель, которая дала ответ. Если stream задан, ответ
+// запрашивается потоком и фрагменты передаются по мере поступления.
+func analyzeDocumentPart(ctx context.Context, chunk utils.TextChunk, prompt analysisPrompt, stream *streamCallbacks) (*models.AnalysisResult, string, error) {
+	laws, err := retrieveLawChunks(ctx, chunk.Text)
 	if err != nil {
-		return "", fmt.Errorf("ошибка маршалинга payload: %w", err)
+		utils.LogWarning(fmt.Sprintf("Не удалось подобрать нормы из базы законов: %v", err))
 	}
 
-	utils.LogRequest("out", apiEndpoint, len(body))
+	data := prompt.data
+	data.Text = chunk.Text
+	data.PreviousContext = chunk.Context
+	data.LawContext = formatLawContext(laws)
 
-	req, err := http.NewRequest("POST", apiEndpoint, bytes.NewBuffer(body))
+	system, text, err := renderPrompt(prompt.template, data)
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания запроса: %w", err)
+		return nil, "", fmt.Errorf("ошибка шаблона промпта v%d: %w", prompt.template.Version, err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("HTTP-Referer", "https://legally.kz")
-	req.Header.Set("X-Title", "Legally AI Risk Analyzer")
+	utils.LogInfo(fmt.Sprintf("Отправка запроса к AI с текстом длиной %d символов (~%d токенов), норм из базы: %d",
+		chunk.End-chunk.Start, chunk.Tokens, len(laws)))
 
-	client := &http.Client{Timeout: 120 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := queryLLMStream(withLLMOperation(ctx, llmOpAnalysis), system, text, stream)
 	if err != nil {
-		return "", fmt.Errorf("ошибка запроса к OpenRouter: %w", err)
+		return nil, "", err
 	}
-	defer resp.Body.Close()
 
-	resBody, err := io.ReadAll(resp.Body)
+	result, err := parseAnalysisResult(resp.Content)
 	if err != nil {
-		return "", fmt.Errorf("ошибка чтения ответа OpenRouter: %w", err)
+		return nil, resp.Model, err
 	}
+	resolveCitations(result, laws)
 
-	utils.LogRequest("in", fmt.Sprintf("OpenRouter (статус: %d)", resp.StatusCode), len(resBody))
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ошибка от OpenRouter: статус %d", resp.StatusCode)
-	}
-
-	var res struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.Unmarshal(resBody, &res); err != nil {
-		return "", fmt.Errorf("не удалось распарсить ответ AI: %w", err)
-	}
-
-	if len(res.Choices) == 0 || res.Choices[0].Message.Content == "" {
-		return "", fmt.Errorf("пустой ответ от OpenRouter")
-	}
-
-	return res.Choices[0].Message.Content, nil
+	utils.LogSuccess(fmt.Sprintf("Успешно получен ответ от AI (%s): %d выводов", resp.Model, len(result.Findings)))
+	return result, resp.Model, nil
 }
 
 func GetRelevantLaws() []map[string]string {
@@ -218,83 +171,23 @@ func GetRelevantLaws() []map[string]string {
 	}
 }
 
-func GetUserHistory(userID string) ([]map[string]interface{}, error) {
-	return repositories.GetUserHistory(userID)
-}
-
-func detectDocumentType(text string) string {
-	text = strings.ToLower(text)
-	switch {
-	case strings.Contains(text, "договор"):
-		return "Договор"
-	case strings.Contains(text, "приказ"):
-		return "Приказ"
-	case strings.Contains(text, "постановление"):
-		return "Постановление"
-	case strings.Contains(text, "закон"):
-		return "Закон"
-	case strings.Contains(text, "решение"):
-		return "Решение"
-	default:
-		return "Неизвестно"
+// GetUserHistory возвращает историю анализов пользователя с учётом фильтра
+func GetUserHistory(userID string, filter models.HistoryFilter) ([]models.Analysis, error) {
+	history, err := repositories.GetUserHistory(userID, filter)
+	if err != nil {
+		return nil, err
 	}
-}
-
-var (
-	userCache      = make(map[string]string)
-	activeAnalysis = make(map[string]context.CancelFunc)
-	cacheMutex     sync.Mutex
-)
 
-func CancelUserAnalysis(userID string) error {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-
-	if cancel, exists := activeAnalysis[userID]; exists {
-		cancel()
-		delete(activeAnalysis, userID)
-		delete(userCache, userID)
-		return nil
+	for i := range history {
+		fillAnalysisMarkdown(&history[i])
 	}
-	return fmt.Errorf("анализ не найден или уже завершен")
+	attachThreadSummaries(userID, history)
+	return history, nil
 }
 
-func ClearUserCache(userID string) error {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-
-	delete(userCache, userID)
-	return nil
-}
-
-func CacheUserFile(userID, content string) {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-
-	userCache[userID] = content
-}
-
-func GetCachedFile(userID string) (string, bool) {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-
-	content, exists := userCache[userID]
-	return content, exists
-}
-
-func StartAnalysis(ctx context.Context, userID string, fn func()) context.Context {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-
-	ctx, cancel := context.WithCancel(ctx)
-	activeAnalysis[userID] = cancel
-
-	go func() {
-		fn()
-		cacheMutex.Lock()
-		delete(activeAnalysis, userID)
-		cacheMutex.Unlock()
-	}()
-
-	return ctx
+// fillAnalysisMarkdown строит производное markdown-представление для анализов со структурированным результатом
+func fillAnalysisMarkdown(analysis *models.Analysis) {
+	if analysis.Result != nil {
+		analysis.Analysis = RenderAnalysisMarkdown(analysis.Result, analysis.Language)
+	}
 }
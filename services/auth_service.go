@@ -151,3 +151,28 @@ func ValidateUser(userID string) (*models.User, error) {
 
 	return &user, nil
 }
+
+// UpdateUserLanguage сохраняет язык отчётов по умолчанию в профиле пользователя
+func UpdateUserLanguage(userID, language string) error {
+	if !IsSupportedLanguage(language) {
+		return ErrUnsupportedLanguage
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	res, err := db.GetCollection("users").UpdateOne(
+		context.Background(),
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{"language": language, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
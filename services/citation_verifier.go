@@ -0,0 +1,448 @@
+// citation_verifier.go
+
+package services
+
+import (
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// resolvedTextChars — сколько текста статьи прикладывать к ссылке
+	resolvedTextChars = 2000
+	// minSupportTerms — сколько основ слов вывода должно встретиться в статье,
+	// чтобы считать, что статья говорит о том же
+	minSupportTerms = 2
+	minSupportRatio = 0.1
+)
+
+// legalAct — кодекс, ссылки на который распознаются в выводах. Название
+// распознаётся на русском, казахском и английском: legal_basis пишется на языке
+// отчёта. Процессуальные кодексы стоят раньше материальных, чтобы «Гражданский
+// процессуальный кодекс» не принимался за «Гражданский кодекс».
+type legalAct struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var legalActs = []legalAct{
+	newLegalAct("Гражданский процессуальный кодекс", "ГПК",
+		`гражданск\S*\s+процессуальн\S*\s+кодекс\S*`, `азаматтық\s+процест\S*\s+кодекс\S*`,
+		`civil\s+procedur\S*\s+code`, `code\s+of\s+civil\s+procedure`),
+	newLegalAct("Уголовно-процессуальный кодекс", "УПК",
+		`уголовно-процессуальн\S*\s+кодекс\S*`, `қылмыстық-процест\S*\s+кодекс\S*`,
+		`criminal\s+procedur\S*\s+code`, `code\s+of\s+criminal\s+procedure`),
+	newLegalAct("Административный процедурно-процессуальный кодекс", "АППК",
+		`административн\S*\s+процедурно-процессуальн\S*\s+кодекс\S*`, `әкімшілік\s+рәсімдік-процест\S*\s+кодекс\S*`,
+		`administrative\s+procedur\S*\s+(?:and\s+)?(?:procedural\s+|process\s+)?code`),
+	newLegalAct("Кодекс об административных правонарушениях", "КоАП",
+		`кодекс\S*\s+(?:республики\s+казахстан\s+)?об\s+административн\S*\s+правонарушени\S*`,
+		`әкімшілік\s+құқық\s+бұзушылық\S*\s+туралы\s+(?:қазақстан\s+республикасының\s+)?кодекс\S*`,
+		`code\s+(?:of\s+the\s+republic\s+of\s+kazakhstan\s+)?on\s+administrative\s+offen[cs]es`, `administrative\s+offen[cs]es\s+code`),
+	newLegalAct("Гражданский кодекс", "ГК",
+		`гражданск\S*\s+кодекс\S*`, `азаматтық\s+кодекс\S*`, `civil\s+code`),
+	newLegalAct("Трудовой кодекс", "ТК",
+		`трудов\S*\s+кодекс\S*`, `еңбек\s+кодекс\S*`, `labou?r\s+code`),
+	newLegalAct("Налоговый кодекс", "НК",
+		`налогов\S*\s+кодекс\S*`, `салық\s+кодекс\S*`, `tax\s+code`),
+	newLegalAct("Уголовный кодекс", "УК",
+		`уголовн\S*\s+кодекс\S*`, `қылмыстық\s+кодекс\S*`, `criminal\s+code`),
+	newLegalAct("Предпринимательский кодекс", "ПК",
+		`предпринимательск\S*\s+кодекс\S*`, `кәсіпкерлік\s+кодекс\S*`, `entrepreneurial\s+code`),
+	newLegalAct("Земельный кодекс", "ЗК",
+		`земельн\S*\s+кодекс\S*`, `жер\s+кодекс\S*`, `land\s+code`),
+	newLegalAct("Экологический кодекс", "ЭК",
+		`экологическ\S*\s+кодекс\S*`, `экологиялық\s+кодекс\S*`, `(?:environmental|ecological)\s+code`),
+	newLegalAct("Бюджетный кодекс", "БК",
+		`бюджетн\S*\s+кодекс\S*`, `бюджет\s+кодекс\S*`, `budget\s+code`),
+	newLegalAct("Кодекс о браке (супружестве) и семье", "",
+		`кодекс\S*\s+(?:республики\s+казахстан\s+)?о\s+браке`, `неке\s+\S*\s*(?:және\s+)?отбасы\s+туралы\s+(?:қазақстан\s+республикасының\s+)?кодекс\S*`,
+		`code\s+(?:of\s+the\s+republic\s+of\s+kazakhstan\s+)?on\s+marriage`),
+}
+
+func newLegalAct(name, abbr string, names ...string) legalAct {
+	pattern := strings.Join(names, "|")
+	if abbr != "" {
+		// \b в RE2 понимает только латиницу, поэтому границы аббревиатуры заданы явно
+		pattern += `|(?:^|[^\p{L}])` + abbr + `(?:[^\p{L}]|$)`
+	}
+	return legalAct{name: name, pattern: regexp.MustCompile(`(?i)` + pattern)}
+}
+
+// articleRefRes — ссылки на статьи с группами articles (номера статей) и
+// paragraph (пункт): «п. 2 ст. 401», «статья 15, 16 и 18», «paragraph 2 of
+// Article 401», «Articles 15 and 16», «401-бабының 2-тармағы»
+var articleRefRes = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:(?:п\.|пп\.|пункт\S*|ч\.|част\S*)\s*(?P<paragraph>\d+(?:\.\d+)?)\s*,?\s*)?(?:ст\.|ст\s|стать\S*)\s*(?P<articles>\d+(?:-\d+)?(?:\s*(?:,|и)\s*\d+(?:-\d+)?)*)`),
+	regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:(?:paragraph|para\.|clause|item|part|p\.)\s*(?P<paragraph>\d+(?:\.\d+)?)\s*(?:of\s+)?)?(?:articles?|art\.)\s*(?P<articles>\d+(?:-\d+)?(?:\s*(?:,|and)\s*\d+(?:-\d+)?)*)`),
+	regexp.MustCompile(`(?i)(?P<articles>\d+(?:-\d+)?(?:\s*(?:,|және)\s*\d+(?:-\d+)?)*)\s*-\s*ба[пб]\S*(?:\s+(?P<paragraph>\d+(?:\.\d+)?)\s*-\s*тарма[қғ]\S*)?`),
+}
+
+var (
+	articleNumRe = regexp.MustCompile(`\d+(?:-\d+)?`)
+	// Заголовок статьи в тексте кодекса
+	articleHeadingRe = regexp.MustCompile(`Статья\s+(\d+(?:-\d+)?)\.?\s`)
+	// Начало пункта внутри статьи: «2. Текст»
+	paragraphRe = regexp.MustCompile(`(?:^|[\s.;:])(\d+)\.\s+\p{Lu}`)
+)
+
+// statute — текст кодекса из RAG-базы, разобранный по статьям
+type statute struct {
+	documentID primitive.ObjectID
+	title      string
+	articles   map[string]string
+}
+
+type statuteIndex struct {
+	byAct   map[string][]statute
+	builtAt time.Time
+}
+
+var (
+	statuteCache *statuteIndex
+	// statuteGeneration растёт при каждом сбросе: индекс, который строился во
+	// время сброса, в кэш уже не попадает
+	statuteGeneration uint64
+	statuteMutex      sync.Mutex
+)
+
+// invalidateStatutes сбрасывает индекс кодексов; вызывается из InvalidateLawIndex,
+// когда RAG-документ обработан, отправлен на переобработку или удалён
+func invalidateStatutes() {
+	statuteMutex.Lock()
+	statuteCache = nil
+	statuteGeneration++
+	statuteMutex.Unlock()
+}
+
+// getStatutes возвращает индекс кодексов, перестраивая его по истечении
+// lawIndexTTL. Корпус читается и разбирается без блокировки, чтобы проверка
+// ссылок в других анализах не ждала перестройки.
+func getStatutes() (*statuteIndex, error) {
+	statuteMutex.Lock()
+	if statuteCache != nil && time.Since(statuteCache.builtAt) < lawIndexTTL {
+		index := statuteCache
+		statuteMutex.Unlock()
+		return index, nil
+	}
+	generation := statuteGeneration
+	statuteMutex.Unlock()
+
+	index, err := buildStatuteIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	statuteMutex.Lock()
+	if statuteGeneration == generation {
+		statuteCache = index
+	}
+	statuteMutex.Unlock()
+	return index, nil
+}
+
+func buildStatuteIndex() (*statuteIndex, error) {
+	docs, err := repositories.GetProcessedRAGTexts()
+	if err != nil {
+		return nil, err
+	}
+
+	index := &statuteIndex{byAct: make(map[string][]statute), builtAt: time.Now()}
+	for _, doc := range docs {
+		act := detectAct(doc.Title)
+		if act == "" {
+			continue
+		}
+		articles := splitArticles(doc.Content)
+		if len(articles) == 0 {
+			continue
+		}
+		index.byAct[act] = append(index.byAct[act], statute{documentID: doc.ID, title: doc.Title, articles: articles})
+	}
+
+	utils.LogInfo(fmt.Sprintf("Индекс кодексов для проверки ссылок: %d актов", len(index.byAct)))
+	return index, nil
+}
+
+// detectAct возвращает каноническое название кодекса, упомянутого в тексте
+func detectAct(text string) string {
+	for _, act := range legalActs {
+		if act.pattern.MatchString(text) {
+			return act.name
+		}
+	}
+	return ""
+}
+
+// splitArticles делит текст кодекса на статьи по заголовкам «Статья N.».
+// Если заголовок встречается несколько раз (оглавление), берётся самый длинный текст.
+func splitArticles(content string) map[string]string {
+	matches := articleHeadingRe.FindAllStringSubmatchIndex(content, -1)
+	articles := make(map[string]string)
+	for i, m := range matches {
+		end := len(content)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		number := content[m[2]:m[3]]
+		text := strings.TrimSpace(content[m[0]:end])
+		if len(text) > len(articles[number]) {
+			articles[number] = text
+		}
+	}
+	return articles
+}
+
+// findParagraph возвращает текст пункта статьи
+func findParagraph(article, number string) (string, bool) {
+	matches := paragraphRe.FindAllStringSubmatchIndex(article, -1)
+	for i, m := range matches {
+		if article[m[2]:m[3]] != number {
+			continue
+		}
+		end := len(article)
+		next := strconv.Itoa(atoiOr(number, 0) + 1)
+		for _, n := range matches[i+1:] {
+			if article[n[2]:n[3]] == next {
+				end = n[0]
+				break
+			}
+		}
+		return strings.TrimSpace(article[m[2]:end]), true
+	}
+	return "", false
+}
+
+func atoiOr(s string, fallback int) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return fallback
+}
+
+type actMention struct {
+	pos  int
+	name string
+}
+
+type articleRef struct {
+	start, end int
+	articles   string
+	paragraph  string
+}
+
+// articleRefMatches находит ссылки на статьи всеми шаблонами и упорядочивает их
+// по позиции; ссылки, пересекающиеся с уже найденными, отбрасываются
+func articleRefMatches(segment string) []articleRef {
+	var refs []articleRef
+	for _, re := range articleRefRes {
+		articles, paragraph := re.SubexpIndex("articles"), re.SubexpIndex("paragraph")
+		for _, m := range re.FindAllStringSubmatchIndex(segment, -1) {
+			ref := articleRef{start: m[0], end: m[1], articles: segment[m[2*articles]:m[2*articles+1]]}
+			if m[2*paragraph] >= 0 {
+				ref.paragraph = segment[m[2*paragraph]:m[2*paragraph+1]]
+			}
+			refs = append(refs, ref)
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].start < refs[j].start })
+
+	var out []articleRef
+	for _, ref := range refs {
+		if len(out) > 0 && ref.start < out[len(out)-1].end {
+			continue
+		}
+		out = append(out, ref)
+	}
+	return out
+}
+
+// parseLegalReferences находит ссылки на статьи кодексов в тексте legal_basis.
+// Кодекс ищется сразу после ссылки («ст. 401 ГК РК»), а если его там нет — перед
+// ней («Гражданский кодекс РК, ст. 401»).
+func parseLegalReferences(text string) []models.LegalReference {
+	var refs []models.LegalReference
+	seen := make(map[string]bool)
+
+	for _, segment := range strings.FieldsFunc(text, func(r rune) bool { return r == ';' || r == '\n' }) {
+		var acts []actMention
+		for _, act := range legalActs {
+			for _, m := range act.pattern.FindAllStringIndex(segment, -1) {
+				acts = append(acts, actMention{pos: m[0], name: act.name})
+			}
+		}
+		sort.Slice(acts, func(i, j int) bool { return acts[i].pos < acts[j].pos })
+
+		matches := articleRefMatches(segment)
+		for i, m := range matches {
+			limit := len(segment)
+			if i+1 < len(matches) {
+				limit = matches[i+1].start
+			}
+
+			act := ""
+			for _, a := range acts {
+				if a.pos >= m.end && a.pos < limit {
+					act = a.name
+					break
+				}
+			}
+			if act == "" {
+				for _, a := range acts {
+					if a.pos < m.start {
+						act = a.name
+					}
+				}
+			}
+			if act == "" {
+				continue
+			}
+
+			refText := strings.Trim(segment[m.start:m.end], " \t([,")
+			paragraph := m.paragraph
+			for _, article := range articleNumRe.FindAllString(m.articles, -1) {
+				key := act + "|" + article + "|" + paragraph
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				refs = append(refs, models.LegalReference{
+					Text:      refText,
+					Act:       act,
+					Article:   article,
+					Paragraph: paragraph,
+				})
+			}
+		}
+	}
+	return refs
+}
+
+// verifyCitations проверяет ссылки на статьи во всех выводах по текстам кодексов
+// из RAG-базы: статья (и пункт) должны существовать, а в их тексте должны
+// встречаться ключевые слова вывода. Ссылки на кодексы, которых нет в базе,
+// тоже считаются ненайденными — юрист должен проверить их вручную. Пояснения
+// к ссылкам пишутся на языке отчёта.
+func verifyCitations(result *models.AnalysisResult, language string) {
+	if result == nil {
+		return
+	}
+
+	statutes, err := getStatutes()
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Проверка ссылок на нормы пропущена: %v", err))
+		return
+	}
+
+	t := reportTextFor(language)
+	check := &models.CitationCheck{}
+	for i := range result.Findings {
+		f := &result.Findings[i]
+		f.References = parseLegalReferences(f.LegalBasis)
+		// Тексты кодексов в базе на русском: слова вывода на другом языке с ними
+		// не сравнить, поэтому для таких отчётов проверяется только наличие статьи
+		var claim *termCounts
+		if language == "" || language == LanguageRussian {
+			terms := lawTerms(f.Title + " " + f.Description)
+			claim = &terms
+		}
+
+		for j := range f.References {
+			ref := &f.References[j]
+			resolveReference(ref, statutes.byAct[ref.Act], claim, t)
+			switch ref.Status {
+			case models.ReferenceVerified:
+				check.Verified++
+			case models.ReferenceMismatched:
+				check.Mismatched++
+			default:
+				check.NotFound++
+			}
+		}
+	}
+
+	check.NeedsReview = check.NotFound+check.Mismatched > 0
+	if check.Verified+check.NotFound+check.Mismatched > 0 {
+		result.CitationCheck = check
+	}
+	utils.LogInfo(fmt.Sprintf("Проверка ссылок: подтверждено %d, не найдено %d, не соответствует %d",
+		check.Verified, check.NotFound, check.Mismatched))
+}
+
+// resolveReference ищет статью ссылки в текстах кодекса; claim = nil — соответствие
+// статьи выводу не проверяется
+func resolveReference(ref *models.LegalReference, candidates []statute, claim *termCounts, t reportText) {
+	ref.Status = models.ReferenceNotFound
+	if len(candidates) == 0 {
+		ref.Note = t.noteActMissing
+		return
+	}
+
+	for _, st := range candidates {
+		text, ok := st.articles[ref.Article]
+		if !ok {
+			continue
+		}
+
+		id := st.documentID
+		ref.DocumentID = &id
+		if ref.Paragraph != "" {
+			paragraph, found := findParagraph(text, ref.Paragraph)
+			if !found {
+				ref.ResolvedText = truncateRunes(text, resolvedTextChars)
+				ref.Note = fmt.Sprintf(t.noteParagraph, ref.Article, ref.Paragraph)
+				return
+			}
+			text = paragraph
+		}
+		ref.ResolvedText = truncateRunes(text, resolvedTextChars)
+
+		switch {
+		case claim == nil:
+			ref.Status = models.ReferenceVerified
+			ref.Note = t.noteNotCompared
+		case supportsClaim(text, *claim):
+			ref.Status = models.ReferenceVerified
+			ref.Note = ""
+		default:
+			ref.Status = models.ReferenceMismatched
+			ref.Note = t.noteMismatch
+		}
+		return
+	}
+
+	ref.Note = fmt.Sprintf(t.noteArticleMissing, ref.Article, candidates[0].title)
+}
+
+// supportsClaim — грубая лексическая проверка: достаточно ли слов вывода в тексте статьи
+func supportsClaim(text string, claim termCounts) bool {
+	if len(claim.counts) == 0 {
+		return true
+	}
+	article := lawTerms(text)
+	matched := 0
+	for term := range claim.counts {
+		if article.counts[term] > 0 {
+			matched++
+		}
+	}
+	return matched >= minSupportTerms && float64(matched)/float64(len(claim.counts)) >= minSupportRatio
+}
+
+func truncateRunes(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit]) + "…"
+}
@@ -0,0 +1,448 @@
+// compare_service.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// riskBatchSize — сколько изменённых пунктов оценивается одним запросом к модели
+	riskBatchSize = 8
+	// maxRiskAssessments ограничивает число оцениваемых пунктов в одном сравнении
+	maxRiskAssessments = 64
+	// maxRiskClauseRunes — до скольких символов сокращается редакция пункта в промпте
+	maxRiskClauseRunes = 3000
+	// defaultRiskAssessmentSeconds ограничивает оценку риска внутри HTTP-запроса
+	// (COMPARE_RISK_TIMEOUT, в секундах): пункты, не оценённые к этому сроку,
+	// остаются без оценки
+	defaultRiskAssessmentSeconds = 90
+)
+
+var ErrComparisonNotFound = errors.New("сравнение не найдено")
+
+// riskChangeSchemas — схема ответа с пояснениями на языке отчёта
+var riskChangeSchemas = map[string]string{
+	LanguageRussian: `{
+  "changes": [
+    {
+      "id": "c1",
+      "risk_change": "increased | decreased | unchanged",
+      "severity": "high | medium | low",
+      "explanation": "что изменилось по существу и почему риск вырос, снизился или не изменился"
+    }
+  ]
+}`,
+	LanguageKazakh: `{
+  "changes": [
+    {
+      "id": "c1",
+      "risk_change": "increased | decreased | unchanged",
+      "severity": "high | medium | low",
+      "explanation": "мәні бойынша не өзгерді және тәуекел неге өсті, азайды немесе өзгермеді"
+    }
+  ]
+}`,
+	LanguageEnglish: `{
+  "changes": [
+    {
+      "id": "c1",
+      "risk_change": "increased | decreased | unchanged",
+      "severity": "high | medium | low",
+      "explanation": "what changed in substance and why the risk increased, decreased or stayed the same"
+    }
+  ]
+}`,
+}
+
+// riskChangePrompts — промпт оценки изменения риска на языке отчёта; подставляются
+// юрисдикция, требование к языку ответа, схема и изменённые пункты
+var riskChangePrompts = map[string]string{
+	LanguageRussian: `Ниже — пункты юридического документа, изменённые между двумя редакциями (original — прежняя, revised — новая).
+Для каждого пункта оцени по законодательству %s, как изменение повлияло на правовой риск стороны, проверяющей документ:
+- increased — риск вырос (например, снято ограничение ответственности, увеличена неустойка, сокращены сроки);
+- decreased — риск снизился;
+- unchanged — изменение редакционное и на риск не влияет.
+В severity укажи, насколько существенно изменение риска, в explanation — кратко, что изменилось по существу.
+%s Значения risk_change и severity не переводи.
+
+Ответ верни строго в формате JSON по схеме ниже, без markdown и пояснений вне JSON:
+%s
+
+Изменённые пункты:
+%s`,
+	LanguageKazakh: `Төменде — заңдық құжаттың екі редакциясы арасында өзгерген тармақтары (original — бұрынғы, revised — жаңа).
+Әр тармақ бойынша %s заңнамасына сәйкес өзгеріс құжатты тексеретін тараптың құқықтық тәуекеліне қалай әсер еткенін бағала:
+- increased — тәуекел өсті (мысалы, жауапкершілік шегі алынып тасталды, тұрақсыздық айыбы ұлғайтылды, мерзімдер қысқартылды);
+- decreased — тәуекел азайды;
+- unchanged — өзгеріс редакциялық және тәуекелге әсер етпейді.
+severity өрісінде тәуекел өзгерісінің қаншалықты елеулі екенін, explanation өрісінде мәні бойынша не өзгергенін қысқаша көрсет.
+%s risk_change және severity мәндерін аударма.
+
+Жауапты markdown-сыз және JSON-нан тыс түсініктемесіз, төмендегі схема бойынша қатаң JSON форматында қайтар:
+%s
+
+Өзгерген тармақтар:
+%s`,
+	LanguageEnglish: `Below are the clauses of a legal document that changed between two versions (original — the previous one, revised — the new one).
+For each clause assess under the law of %s how the change affected the legal risk of the party reviewing the document:
+- increased — the risk increased (for example, a liability cap was removed, a penalty was raised, deadlines were shortened);
+- decreased — the risk decreased;
+- unchanged — the change is editorial and does not affect the risk.
+In severity state how significant the change in risk is, in explanation — briefly what changed in substance.
+%s Do not translate the values of risk_change and severity.
+
+Return the answer strictly as JSON following the schema below, without markdown or explanations outside the JSON:
+%s
+
+Changed clauses:
+%s`,
+}
+
+// CompareDocuments сравнивает две редакции документа по пунктам. Каждая редакция —
+// файл в поле original или revised формы либо ID анализа из истории в поле
+// original_id или revised_id.
+func CompareDocuments(c *gin.Context) (*models.Comparison, *HttpError) {
+	utils.LogAction("Получен запрос на сравнение редакций документа")
+
+	userID := c.GetString("userId")
+	var req models.CompareRequest
+	if c.ContentType() == gin.MIMEJSON {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, &HttpError{Status: http.StatusBadRequest, Message: "неверные данные запроса: " + err.Error()}
+		}
+	} else if err := utils.ParseUploadForm(c, utils.MaxCompareUploadSize); err == nil {
+		req.OriginalID = c.PostForm("original_id")
+		req.RevisedID = c.PostForm("revised_id")
+		req.Language = c.PostForm("language")
+	} else {
+		return nil, &HttpError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	original, originalText, httpErr := loadComparedDocument(c, userID, "original", req.OriginalID)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	revised, revisedText, httpErr := loadComparedDocument(c, userID, "revised", req.RevisedID)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	language := strings.ToLower(strings.TrimSpace(req.Language))
+	if language == "" {
+		var err error
+		if language, err = reportLanguage(c, userID); err != nil {
+			return nil, &HttpError{Status: http.StatusBadRequest, Message: err.Error()}
+		}
+	} else if !IsSupportedLanguage(language) {
+		return nil, &HttpError{Status: http.StatusBadRequest, Message: ErrUnsupportedLanguage.Error()}
+	}
+
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, &HttpError{Status: http.StatusUnauthorized, Message: "неверный ID пользователя"}
+	}
+	if err := CheckUsageQuota(userID, 0); err != nil {
+		return nil, quotaHttpError(err)
+	}
+
+	comparison := compareTexts(originalText, revisedText)
+	comparison.UserID = uid
+	comparison.Language = language
+	comparison.Original.Filename, comparison.Original.AnalysisID = original.Filename, original.AnalysisID
+	comparison.Revised.Filename, comparison.Revised.AnalysisID = revised.Filename, revised.AnalysisID
+
+	assessCtx, cancel := context.WithTimeout(c.Request.Context(),
+		time.Duration(envInt(defaultRiskAssessmentSeconds, "COMPARE_RISK_TIMEOUT"))*time.Second)
+	assessRiskChanges(withUsageScope(assessCtx, userUsageScope(uid)), comparison)
+	cancel()
+	if err := c.Request.Context().Err(); err != nil {
+		return nil, &HttpError{Status: http.StatusRequestTimeout, Message: "сравнение прервано: " + err.Error()}
+	}
+	summarizeComparison(comparison)
+
+	if err := repositories.SaveComparison(comparison); err != nil {
+		return nil, &HttpError{Status: http.StatusInternalServerError, Message: "ошибка сохранения сравнения"}
+	}
+
+	s := comparison.Summary
+	utils.LogSuccess(fmt.Sprintf("Сравнение готово: добавлено %d, удалено %d, изменено %d, риск %s",
+		s.Added, s.Removed, s.Modified, s.RiskChange))
+	return comparison, nil
+}
+
+// loadComparedDocument берёт текст редакции из анализа пользователя или из загруженного файла
+func loadComparedDocument(c *gin.Context, userID, field, analysisID string) (models.ComparedDocument, string, *HttpError) {
+	if analysisID = strings.TrimSpace(analysisID); analysisID == "" {
+		if c.ContentType() != gin.MIMEMultipartPOSTForm {
+			return models.ComparedDocument{}, "", &HttpError{
+				Status:  http.StatusBadRequest,
+				Message: fmt.Sprintf("нужен файл %s или %s_id анализа из истории", field, field),
+			}
+		}
+		text, filename, err := utils.ProcessUploadedField(c, field, utils.MaxCompareUploadSize)
+		if err != nil {
+			return models.ComparedDocument{}, "", &HttpError{Status: http.StatusBadRequest, Message: fmt.Sprintf("%s: %v", field, err)}
+		}
+		return models.ComparedDocument{Filename: filename}, text, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(analysisID)
+	if err != nil {
+		return models.ComparedDocument{}, "", &HttpError{Status: http.StatusBadRequest, Message: fmt.Sprintf("неверный %s_id", field)}
+	}
+	analysis, err := repositories.GetAnalysis(id)
+	if err != nil || analysis.UserID.Hex() != userID {
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return models.ComparedDocument{}, "", &HttpError{Status: http.StatusInternalServerError, Message: "ошибка получения анализа"}
+		}
+		return models.ComparedDocument{}, "", &HttpError{Status: http.StatusNotFound, Message: fmt.Sprintf("анализ %s не найден", analysisID)}
+	}
+	if strings.TrimSpace(analysis.Text) == "" {
+		return models.ComparedDocument{}, "", &HttpError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf("в анализе %s не сохранён текст документа", analysisID)}
+	}
+	return models.ComparedDocument{Filename: analysis.Filename, AnalysisID: &analysis.ID}, analysis.Text, nil
+}
+
+// compareTexts сопоставляет пункты редакций и строит пословные различия изменённых
+func compareTexts(originalText, revisedText string) *models.Comparison {
+	original := utils.SplitClauses(originalText)
+	revised := utils.SplitClauses(revisedText)
+
+	comparison := &models.Comparison{
+		Original: models.ComparedDocument{Clauses: len(original)},
+		Revised:  models.ComparedDocument{Clauses: len(revised)},
+		Changes:  []models.ClauseChange{},
+	}
+
+	for _, pair := range utils.AlignClauses(original, revised) {
+		switch {
+		case pair.Old < 0:
+			comparison.Changes = append(comparison.Changes, models.ClauseChange{
+				Type:    models.ClauseAdded,
+				Revised: clauseVersion(revised[pair.New]),
+			})
+		case pair.New < 0:
+			comparison.Changes = append(comparison.Changes, models.ClauseChange{
+				Type:     models.ClauseRemoved,
+				Original: clauseVersion(original[pair.Old]),
+			})
+		case utils.SameText(original[pair.Old].Text, revised[pair.New].Text):
+			comparison.Summary.Unchanged++
+		default:
+			change := models.ClauseChange{
+				Type:       models.ClauseModified,
+				Original:   clauseVersion(original[pair.Old]),
+				Revised:    clauseVersion(revised[pair.New]),
+				Similarity: pair.Similarity,
+			}
+			for _, segment := range utils.WordDiff(original[pair.Old].Text, revised[pair.New].Text) {
+				change.Diff = append(change.Diff, models.DiffSegment{Op: string(segment.Op), Text: segment.Text})
+			}
+			comparison.Changes = append(comparison.Changes, change)
+		}
+	}
+	return comparison
+}
+
+func clauseVersion(clause utils.Clause) *models.ClauseVersion {
+	return &models.ClauseVersion{
+		Number:  clause.Number,
+		Heading: clause.Heading,
+		Start:   clause.Start,
+		End:     clause.End,
+		Text:    clause.Text,
+	}
+}
+
+type riskChangeInput struct {
+	ID       string `json:"id"`
+	Original string `json:"original"`
+	Revised  string `json:"revised"`
+}
+
+type riskChangeOutput struct {
+	Changes []struct {
+		ID          string            `json:"id"`
+		RiskChange  models.RiskChange `json:"risk_change"`
+		Severity    models.Severity   `json:"severity"`
+		Explanation string            `json:"explanation"`
+	} `json:"changes"`
+}
+
+// assessRiskChanges просит модель оценить изменение риска в изменённых пунктах.
+// Пункты отправляются пачками параллельно (не больше ANALYSIS_PART_CONCURRENCY
+// одновременно); пачка, которую не удалось оценить до истечения ctx, остаётся
+// без оценки и не мешает сравнению.
+func assessRiskChanges(ctx context.Context, comparison *models.Comparison) {
+	var modified []int
+	for i, change := range comparison.Changes {
+		if change.Type == models.ClauseModified {
+			modified = append(modified, i)
+		}
+	}
+	if len(modified) > maxRiskAssessments {
+		utils.LogWarning(fmt.Sprintf("Изменённых пунктов %d, риск оценивается для первых %d", len(modified), maxRiskAssessments))
+		modified = modified[:maxRiskAssessments]
+	}
+
+	// Пачки пишут оценки в разные элементы comparison.Changes
+	sem := make(chan struct{}, envInt(defaultPartConcurrency, "ANALYSIS_PART_CONCURRENCY"))
+	var wg sync.WaitGroup
+	for start := 0; start < len(modified); start += riskBatchSize {
+		batch := modified[start:min(start+riskBatchSize, len(modified))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			if err := assessRiskBatch(ctx, comparison, batch); err != nil && ctx.Err() == nil {
+				utils.LogWarning(fmt.Sprintf("Не удалось оценить изменение риска для пунктов %d–%d: %v", start+1, start+len(batch), err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		utils.LogWarning(fmt.Sprintf("Оценка изменения риска прервана: %v", ctx.Err()))
+	}
+}
+
+func assessRiskBatch(ctx context.Context, comparison *models.Comparison, batch []int) error {
+	input := make([]riskChangeInput, 0, len(batch))
+	byID := make(map[string]int, len(batch))
+	for _, i := range batch {
+		change := comparison.Changes[i]
+		id := fmt.Sprintf("c%d", i+1)
+		byID[id] = i
+		input = append(input, riskChangeInput{
+			ID:       id,
+			Original: truncateRunes(change.Original.Text, maxRiskClauseRunes),
+			Revised:  truncateRunes(change.Revised.Text, maxRiskClauseRunes),
+		})
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга пунктов: %w", err)
+	}
+
+	language := comparison.Language
+	if _, ok := riskChangePrompts[language]; !ok {
+		language = defaultLanguage
+	}
+	prompt := fmt.Sprintf(riskChangePrompts[language], analysisJurisdiction(language),
+		languageInstruction(language), riskChangeSchemas[language], string(data))
+
+	resp, err := queryLLM(withLLMOperation(ctx, llmOpCompare), prompt)
+	if err != nil {
+		return err
+	}
+
+	var out riskChangeOutput
+	if err := unmarshalModelJSON(resp.Content, &out); err != nil {
+		return err
+	}
+
+	for _, assessed := range out.Changes {
+		i, ok := byID[strings.TrimSpace(assessed.ID)]
+		if !ok {
+			continue
+		}
+		change := models.RiskChange(strings.ToLower(strings.TrimSpace(string(assessed.RiskChange))))
+		if !change.IsValid() {
+			continue
+		}
+		risk := &models.ClauseRisk{
+			Change:      change,
+			Explanation: strings.TrimSpace(assessed.Explanation),
+			Model:       resp.Model,
+		}
+		if change != models.RiskUnchanged {
+			risk.Severity = normalizeSeverity(assessed.Severity)
+		}
+		comparison.Changes[i].Risk = risk
+	}
+	return nil
+}
+
+// summarizeComparison подсчитывает изменения; итоговое изменение риска — знак
+// суммы существенности выросших и снизившихся рисков
+func summarizeComparison(comparison *models.Comparison) {
+	s := &comparison.Summary
+	balance := 0
+	for _, change := range comparison.Changes {
+		switch change.Type {
+		case models.ClauseAdded:
+			s.Added++
+		case models.ClauseRemoved:
+			s.Removed++
+		case models.ClauseModified:
+			s.Modified++
+			switch {
+			case change.Risk == nil:
+				s.Unassessed++
+			case change.Risk.Change == models.RiskIncreased:
+				s.RiskIncreased++
+				balance += severityRank[change.Risk.Severity]
+			case change.Risk.Change == models.RiskDecreased:
+				s.RiskDecreased++
+				balance -= severityRank[change.Risk.Severity]
+			}
+		}
+	}
+
+	switch {
+	case balance > 0:
+		s.RiskChange = models.RiskIncreased
+	case balance < 0:
+		s.RiskChange = models.RiskDecreased
+	default:
+		s.RiskChange = models.RiskUnchanged
+	}
+}
+
+// GetComparison возвращает сравнение пользователя по ID
+func GetComparison(userID, id string) (*models.Comparison, error) {
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("неверный ID пользователя")
+	}
+	cid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrComparisonNotFound
+	}
+
+	comparison, err := repositories.GetComparison(cid, uid)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrComparisonNotFound
+	}
+	return comparison, err
+}
+
+// GetUserComparisons возвращает историю сравнений пользователя (только сводки)
+func GetUserComparisons(userID string) ([]models.Comparison, error) {
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("неверный ID пользователя")
+	}
+	return repositories.GetUserComparisons(uid)
+}
@@ -0,0 +1,399 @@
+// contract_terms.go
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"legally/models"
+	"legally/utils"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// termsEdgeClauses — сколько элементов с начала и конца документа всегда
+	// попадает в выдержку: там стороны, дата, реквизиты и подписи
+	termsEdgeClauses = 4
+	minQuoteRunes    = 3
+)
+
+const contractTermsSchema = `{
+  "parties": [
+    {
+      "name": "наименование или ФИО стороны",
+      "role": "роль в договоре: поставщик, покупатель, арендодатель и т. п.",
+      "bin": "БИН, 12 цифр",
+      "iin": "ИИН, 12 цифр",
+      "signatory": "ФИО и должность подписанта",
+      "authority_basis": "основание полномочий: устав, доверенность № ... и т. п.",
+      "quote": "точная цитата из текста, где названа сторона"
+    }
+  ],
+  "effective_date": {"date": "YYYY-MM-DD", "quote": "цитата"},
+  "term": {"value": "срок действия", "end_date": "YYYY-MM-DD", "auto_renewal": false, "renewal": "условия продления", "quote": "цитата"},
+  "price": {"amount": 0, "currency": "KZT", "value": "цена и что в неё входит", "quote": "цитата"},
+  "payment_terms": {"value": "порядок и срок оплаты", "days": 0, "quote": "цитата"},
+  "penalties": [{"value": "неустойка, пеня или штраф и за что", "quote": "цитата"}],
+  "termination_grounds": [{"value": "основание расторжения", "quote": "цитата"}],
+  "governing_law": {"value": "применимое право", "quote": "цитата"},
+  "dispute_forum": {"value": "порядок разрешения споров и суд или арбитраж", "quote": "цитата"}
+}`
+
+// termsKeywords — признаки пунктов с коммерческими условиями; такие пункты
+// попадают в выдержку для извлечения в первую очередь
+var termsKeywords = []string{
+	"цена", "стоимост", "сумм", "оплат", "платеж", "платёж", "срок", "вступает в силу", "действует до",
+	"пролонг", "продлева", "неустойк", "пен", "штраф", "расторж", "отказ от", "применим", "законодательств",
+	"спор", "суд", "арбитраж", "реквизит", "подпис", "бин", "иин", "в лице", "на основании",
+	"баға", "төлем", "мерзім", "айыппұл", "тұрақсыздық", "бұзу", "дау", "сот", "деректемел",
+	"price", "payment", "term", "renewal", "penalt", "terminat", "governing law", "dispute", "court", "arbitration",
+}
+
+var (
+	currencyCodes = map[string]string{
+		"₸": "KZT", "тенге": "KZT", "теңге": "KZT", "тг": "KZT",
+		"$": "USD", "доллар": "USD", "долл": "USD",
+		"€": "EUR", "евро": "EUR",
+		"руб": "RUB", "рубл": "RUB", "₽": "RUB",
+	}
+	isoCurrencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+type rawTerm struct {
+	Value string `json:"value"`
+	Quote string `json:"quote"`
+}
+
+type rawContractTerms struct {
+	Parties []struct {
+		Name           string `json:"name"`
+		Role           string `json:"role"`
+		BIN            string `json:"bin"`
+		IIN            string `json:"iin"`
+		Signatory      string `json:"signatory"`
+		AuthorityBasis string `json:"authority_basis"`
+		Quote          string `json:"quote"`
+	} `json:"parties"`
+	EffectiveDate *struct {
+		Date  string `json:"date"`
+		Quote string `json:"quote"`
+	} `json:"effective_date"`
+	Term *struct {
+		rawTerm
+		EndDate     string `json:"end_date"`
+		AutoRenewal bool   `json:"auto_renewal"`
+		Renewal     string `json:"renewal"`
+	} `json:"term"`
+	Price *struct {
+		rawTerm
+		Amount   float64 `json:"amount"`
+		Currency string  `json:"currency"`
+	} `json:"price"`
+	PaymentTerms *struct {
+		rawTerm
+		Days int `json:"days"`
+	} `json:"payment_terms"`
+	Penalties          []rawTerm `json:"penalties"`
+	TerminationGrounds []rawTerm `json:"termination_grounds"`
+	GoverningLaw       *rawTerm  `json:"governing_law"`
+	DisputeForum       *rawTerm  `json:"dispute_forum"`
+}
+
+// extractContractTerms извлекает из документа стороны, даты, цену и другие
+// коммерческие условия. Модели передаётся выдержка из пунктов, где эти условия
+// обычно стоят, а смещения полей ищутся по цитатам в полном тексте.
+func extractContractTerms(ctx context.Context, text, language string) (*models.ContractTerms, error) {
+	utils.LogAction("Извлечение ключевых условий договора")
+
+	prompt := fmt.Sprintf(`Извлеки из юридического документа его ключевые условия.
+Заполняй только то, что прямо указано в тексте; отсутствующие поля пропусти, ничего не додумывай.
+Для каждого поля приведи в quote точную цитату из текста без изменений и сокращений, не длиннее одного предложения.
+Даты — в формате YYYY-MM-DD, сумму — числом без пробелов, валюту — кодом ISO 4217, срок оплаты — в днях.
+%s Значения date, end_date, amount, currency, bin, iin и quote не переводи.
+
+Ответ верни строго в формате JSON по схеме ниже, без markdown и пояснений вне JSON:
+%s
+
+Текст документа (фрагменты, пропуски обозначены «…»):
+%s`, languageInstruction(language), contractTermsSchema, termsExcerpt(text, partSplitOptions().MaxTokens))
+
+	resp, err := queryLLM(withLLMOperation(ctx, llmOpTerms), prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw rawContractTerms
+	if err := unmarshalModelJSON(resp.Content, &raw); err != nil {
+		return nil, err
+	}
+
+	terms := buildContractTerms(text, &raw)
+	terms.Model = resp.Model
+	utils.LogSuccess(fmt.Sprintf("Извлечены условия договора (%s): сторон %d", resp.Model, len(terms.Parties)))
+	return terms, nil
+}
+
+// termsExcerpt собирает выдержку не больше budget токенов: начало и конец
+// документа, затем пункты с ключевыми словами в порядке следования
+func termsExcerpt(text string, budget int) string {
+	if llm.CountTokens(text) <= budget {
+		return text
+	}
+
+	clauses := utils.SplitClauses(text)
+	selected := make([]bool, len(clauses))
+	used := 0
+	take := func(i int) {
+		if selected[i] {
+			return
+		}
+		if tokens := llm.CountTokens(clauses[i].Text); used+tokens <= budget {
+			selected[i] = true
+			used += tokens
+		}
+	}
+
+	for i := 0; i < min(termsEdgeClauses, len(clauses)); i++ {
+		take(i)
+		take(len(clauses) - 1 - i)
+	}
+	for i, clause := range clauses {
+		lower := strings.ToLower(clause.Text)
+		for _, keyword := range termsKeywords {
+			if strings.Contains(lower, keyword) {
+				take(i)
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, clause := range clauses {
+		if !selected[i] {
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "…\n") {
+				b.WriteString("…\n")
+			}
+			continue
+		}
+		b.WriteString(clause.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func buildContractTerms(text string, raw *rawContractTerms) *models.ContractTerms {
+	terms := &models.ContractTerms{}
+
+	for _, p := range raw.Parties {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			continue
+		}
+		terms.Parties = append(terms.Parties, models.ContractParty{
+			Name:           name,
+			Role:           strings.TrimSpace(p.Role),
+			BIN:            taxpayerNumber(p.BIN),
+			IIN:            taxpayerNumber(p.IIN),
+			Signatory:      strings.TrimSpace(p.Signatory),
+			AuthorityBasis: strings.TrimSpace(p.AuthorityBasis),
+			Source:         locateQuote(text, p.Quote),
+		})
+	}
+
+	if d := raw.EffectiveDate; d != nil {
+		if date, ok := parseTermDate(d.Date); ok {
+			terms.EffectiveDate = &models.DateTerm{Date: date, Source: locateQuote(text, d.Quote)}
+		}
+	}
+
+	if t := raw.Term; t != nil && strings.TrimSpace(t.Value) != "" {
+		terms.Term = &models.ContractTerm{
+			Value:       strings.TrimSpace(t.Value),
+			AutoRenewal: t.AutoRenewal,
+			Renewal:     strings.TrimSpace(t.Renewal),
+			Source:      locateQuote(text, t.Quote),
+		}
+		if date, ok := parseTermDate(t.EndDate); ok {
+			terms.Term.EndDate = &date
+		}
+	}
+
+	if p := raw.Price; p != nil && (p.Amount > 0 || strings.TrimSpace(p.Value) != "") {
+		terms.Price = &models.PriceTerm{
+			Amount:   max(p.Amount, 0),
+			Currency: currencyCode(p.Currency),
+			Value:    strings.TrimSpace(p.Value),
+			Source:   locateQuote(text, p.Quote),
+		}
+	}
+
+	if p := raw.PaymentTerms; p != nil && strings.TrimSpace(p.Value) != "" {
+		terms.PaymentTerms = &models.PaymentTerm{
+			Value:  strings.TrimSpace(p.Value),
+			Days:   max(p.Days, 0),
+			Source: locateQuote(text, p.Quote),
+		}
+	}
+
+	terms.Penalties = textTerms(text, raw.Penalties)
+	terms.TerminationGrounds = textTerms(text, raw.TerminationGrounds)
+	terms.GoverningLaw = textTerm(text, raw.GoverningLaw)
+	terms.DisputeForum = textTerm(text, raw.DisputeForum)
+	return terms
+}
+
+func textTerm(text string, raw *rawTerm) *models.TextTerm {
+	if raw == nil || strings.TrimSpace(raw.Value) == "" {
+		return nil
+	}
+	return &models.TextTerm{Value: strings.TrimSpace(raw.Value), Source: locateQuote(text, raw.Quote)}
+}
+
+func textTerms(text string, raw []rawTerm) []models.TextTerm {
+	var out []models.TextTerm
+	for i := range raw {
+		if term := textTerm(text, &raw[i]); term != nil {
+			out = append(out, *term)
+		}
+	}
+	return out
+}
+
+// relocateContractTerms возвращает копию условий, фрагменты которых заново
+// найдены в text. Нужна для условий из кэша: ключ кэша не учитывает пробелы,
+// и смещения сохранённых фрагментов относятся к тексту другой загрузки.
+func relocateContractTerms(text string, terms *models.ContractTerms) *models.ContractTerms {
+	if terms == nil {
+		return nil
+	}
+	relocate := func(span *models.SourceSpan) *models.SourceSpan {
+		if span == nil {
+			return nil
+		}
+		return locateQuote(text, span.Quote)
+	}
+
+	out := *terms
+	out.Parties = append([]models.ContractParty(nil), terms.Parties...)
+	for i := range out.Parties {
+		out.Parties[i].Source = relocate(out.Parties[i].Source)
+	}
+	if terms.EffectiveDate != nil {
+		d := *terms.EffectiveDate
+		d.Source = relocate(d.Source)
+		out.EffectiveDate = &d
+	}
+	if terms.Term != nil {
+		t := *terms.Term
+		t.Source = relocate(t.Source)
+		out.Term = &t
+	}
+	if terms.Price != nil {
+		p := *terms.Price
+		p.Source = relocate(p.Source)
+		out.Price = &p
+	}
+	if terms.PaymentTerms != nil {
+		p := *terms.PaymentTerms
+		p.Source = relocate(p.Source)
+		out.PaymentTerms = &p
+	}
+	relocateText := func(term *models.TextTerm) *models.TextTerm {
+		if term == nil {
+			return nil
+		}
+		t := *term
+		t.Source = relocate(t.Source)
+		return &t
+	}
+	out.Penalties = nil
+	for i := range terms.Penalties {
+		out.Penalties = append(out.Penalties, *relocateText(&terms.Penalties[i]))
+	}
+	out.TerminationGrounds = nil
+	for i := range terms.TerminationGrounds {
+		out.TerminationGrounds = append(out.TerminationGrounds, *relocateText(&terms.TerminationGrounds[i]))
+	}
+	out.GoverningLaw = relocateText(terms.GoverningLaw)
+	out.DisputeForum = relocateText(terms.DisputeForum)
+	return &out
+}
+
+// locateQuote находит цитату модели в тексте документа без учёта регистра и
+// пробелов. Если цитаты в тексте нет (модель её пересказала), возвращает nil.
+func locateQuote(text, quote string) *models.SourceSpan {
+	// Многоточием модель обозначает сокращение цитаты
+	quote = strings.TrimSpace(strings.Trim(strings.TrimSpace(quote), "…."))
+	if utf8.RuneCountInString(quote) < minQuoteRunes {
+		return nil
+	}
+
+	start, end := -1, -1
+	if i := strings.Index(text, quote); i >= 0 {
+		start, end = i, i+len(quote)
+	} else {
+		fields := strings.Fields(quote)
+		for i, f := range fields {
+			fields[i] = regexp.QuoteMeta(f)
+		}
+		re, err := regexp.Compile(`(?i)` + strings.Join(fields, `\s+`))
+		if err != nil {
+			return nil
+		}
+		loc := re.FindStringIndex(text)
+		if loc == nil {
+			return nil
+		}
+		start, end = loc[0], loc[1]
+	}
+
+	runeStart := utf8.RuneCountInString(text[:start])
+	return &models.SourceSpan{
+		Start: runeStart,
+		End:   runeStart + utf8.RuneCountInString(text[start:end]),
+		Quote: text[start:end],
+	}
+}
+
+// taxpayerNumber оставляет только цифры БИН/ИИН; номер не из 12 цифр отбрасывается
+func taxpayerNumber(value string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, value)
+	if len(digits) != 12 {
+		return ""
+	}
+	return digits
+}
+
+func parseTermDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02", "02.01.2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// currencyCode приводит валюту к коду ISO 4217; неизвестное обозначение отбрасывается
+func currencyCode(value string) string {
+	value = strings.TrimSpace(value)
+	if upper := strings.ToUpper(value); isoCurrencyRe.MatchString(upper) {
+		return upper
+	}
+	lower := strings.ToLower(value)
+	for sign, code := range currencyCodes {
+		if strings.HasPrefix(lower, sign) {
+			return code
+		}
+	}
+	return ""
+}
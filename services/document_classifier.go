@@ -0,0 +1,624 @@
+// document_classifier.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	unknownDocumentCode = "unknown"
+	unknownDocumentType = "Неизвестно"
+
+	taxonomyTTL = 5 * time.Minute
+
+	// Вес совпадения с шаблоном заголовка: в первой строке и в шапке документа
+	firstLineWeight = 6.0
+	headerWeight    = 4.0
+	headerLines     = 8
+	headerChars     = 600
+	// childShare — какая доля оценки подтипа поддерживает основной тип
+	childShare = 0.5
+	// confidenceScale — при такой оценке уверенность достигает ~63%
+	confidenceScale = 5.0
+	minSubtypeScore = 2.0
+	// subtypeShare — подтип включается, если его оценка не ниже этой доли лучшего
+	subtypeShare = 0.4
+
+	// CLASSIFIER_LLM: off (по умолчанию), fallback — спрашивать модель при низкой
+	// уверенности эвристики, always — всегда
+	classifierLLMOff      = "off"
+	classifierLLMFallback = "fallback"
+	classifierLLMAlways   = "always"
+	llmFallbackConfidence = 0.6
+	classifierPromptChars = 3000
+)
+
+var (
+	ErrDocumentTypeNotFound = errors.New("тип документа не найден")
+	ErrDocumentTypeInUse    = errors.New("у типа документа есть подтипы")
+	ErrInvalidDocumentType  = errors.New("некорректный тип документа")
+
+	documentCodeRe = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+	taxonomyCache    []models.DocumentType
+	taxonomyLoadedAt time.Time
+	taxonomyMutex    sync.Mutex
+)
+
+// defaultDocumentTypes — начальная таксономия; записывается в document_types,
+// если коллекция пуста, дальше ею управляет администратор
+func defaultDocumentTypes() []models.DocumentType {
+	t := func(code, name, parent string, patterns []string, keywords ...models.WeightedTerm) models.DocumentType {
+		return models.DocumentType{Code: code, Name: name, Parent: parent, TitlePatterns: patterns, Keywords: keywords, Active: true}
+	}
+	w := func(term string, weight float64) models.WeightedTerm {
+		return models.WeightedTerm{Term: term, Weight: weight}
+	}
+
+	types := []models.DocumentType{
+		t("contract", "Договор", "", []string{"договор", "контракт", "соглашение", "шарт"},
+			w("стороны", 1), w("предмет договора", 2), w("обязуется", 1), w("реквизиты сторон", 2),
+			w("срок действия договора", 1.5), w("ответственность сторон", 1.5), w("тараптар", 1)),
+		t("employment_contract", "Трудовой договор", "contract", []string{"трудовой договор", "еңбек шарты"},
+			w("работник", 2), w("работодатель", 2), w("заработная плата", 2), w("трудовые обязанности", 2),
+			w("рабочее время", 1.5), w("отпуск", 1), w("трудового кодекса", 1.5), w("испытательный срок", 1.5)),
+		t("lease_contract", "Договор аренды", "contract", []string{"договор аренды", "договор найма", "договор субаренды"},
+			w("арендатор", 2), w("арендодатель", 2), w("арендная плата", 2), w("имущество в аренду", 1.5),
+			w("наниматель", 1.5), w("наймодатель", 1.5)),
+		t("nda", "Соглашение о конфиденциальности (NDA)", "contract",
+			[]string{"соглашение о конфиденциальности", "о неразглашении", "non-disclosure", "nda"},
+			w("конфиденциальная информация", 3), w("неразглашени", 2), w("раскрывающая сторона", 2),
+			w("получающая сторона", 2), w("коммерческая тайна", 1.5)),
+		t("supply_contract", "Договор поставки", "contract", []string{"договор поставки"},
+			w("поставщик", 2), w("покупатель", 1), w("поставка товара", 2), w("спецификаци", 1.5), w("отгрузк", 1)),
+		t("services_contract", "Договор оказания услуг", "contract",
+			[]string{"договор оказания услуг", "договор возмездного оказания услуг", "договор на оказание услуг"},
+			w("исполнитель", 2), w("заказчик", 1.5), w("оказание услуг", 2), w("акт оказанных услуг", 2)),
+		t("work_contract", "Договор подряда", "contract", []string{"договор подряда", "договор строительного подряда"},
+			w("подрядчик", 2), w("заказчик", 1), w("выполнение работ", 2), w("акт выполненных работ", 2), w("смета", 1.5)),
+		t("sale_contract", "Договор купли-продажи", "contract", []string{"договор купли-продажи", "договор купли продажи"},
+			w("продавец", 2), w("покупатель", 2), w("право собственности", 1.5), w("передать в собственность", 2)),
+		t("loan_contract", "Договор займа", "contract", []string{"договор займа", "договор кредита", "кредитный договор"},
+			w("заемщик", 2), w("займодатель", 2), w("сумма займа", 2), w("вознаграждение", 1), w("кредитор", 1)),
+		t("agency_contract", "Агентский договор", "contract", []string{"агентский договор"},
+			w("агент", 2), w("принципал", 2), w("агентское вознаграждение", 2)),
+		t("power_of_attorney", "Доверенность", "", []string{"доверенность", "сенімхат"},
+			w("доверяю", 2), w("уполномочивает", 2), w("поверенный", 2), w("доверитель", 2),
+			w("без права передоверия", 2), w("с правом передоверия", 2)),
+		t("charter", "Устав", "", []string{"устав", "жарғы"},
+			w("учредител", 2), w("уставный капитал", 2.5), w("органы управления", 2), w("общее собрание участников", 2),
+			w("юридическое лицо", 1), w("реорганизаци", 1)),
+		t("order", "Приказ", "", []string{"приказ", "бұйрық"},
+			w("приказываю", 3), w("контроль за исполнением", 2), w("ознакомить", 1)),
+		t("resolution", "Постановление", "", []string{"постановление", "қаулы"},
+			w("постановляет", 3), w("постановляю", 3), w("акимат", 1.5), w("правительство", 1)),
+		t("law", "Закон", "", []string{"закон республики казахстан", "кодекс республики казахстан", "заңы"},
+			w("настоящий закон", 3), w("статья 1.", 1.5), w("глава 1", 1), w("вводится в действие", 2)),
+		t("court_decision", "Судебный акт", "", []string{"решение суда", "именем республики казахстан", "определение суда"},
+			w("суд установил", 3), w("истец", 2), w("ответчик", 2), w("решил", 1.5), w("может быть обжаловано", 2)),
+		t("decision", "Решение", "", []string{"решение"},
+			w("решили", 2), w("единственный участник", 2), w("собрание", 1), w("повестка дня", 2)),
+		t("claim", "Претензия", "", []string{"претензия"},
+			w("требуем", 2), w("в досудебном порядке", 2), w("задолженность", 1.5), w("в случае неудовлетворения", 2)),
+		t("lawsuit", "Исковое заявление", "", []string{"исковое заявление", "иск"},
+			w("истец", 2), w("ответчик", 2), w("прошу суд", 3), w("государственная пошлина", 1.5)),
+		t("act", "Акт", "", []string{"акт приема-передачи", "акт выполненных работ", "акт оказанных услуг", "акт сверки"},
+			w("сдал", 1.5), w("принял", 1.5), w("претензий не имеет", 2), w("претензий по", 1)),
+	}
+
+	// Казахские и английские заголовки и слова: двуязычные договоры и документы
+	// иностранных контрагентов
+	type terms struct {
+		patterns []string
+		keywords []models.WeightedTerm
+	}
+	localized := map[string][]terms{
+		"contract": {
+			{[]string{"келісім"}, []models.WeightedTerm{w("шарттың мәні", 2), w("міндеттенеді", 1), w("тараптардың жауапкершілігі", 1.5), w("тараптардың деректемелері", 2)}},
+			{[]string{"agreement", "contract"}, []models.WeightedTerm{w("the parties", 1), w("subject matter", 1.5), w("term of the agreement", 1.5), w("liability of the parties", 1.5), w("governing law", 1.5)}},
+		},
+		"employment_contract": {
+			{nil, []models.WeightedTerm{w("жұмыскер", 2), w("жұмыс беруші", 2), w("жалақы", 2), w("еңбек демалысы", 1), w("сынақ мерзімі", 1.5)}},
+			{[]string{"employment contract", "employment agreement"}, []models.WeightedTerm{w("employee", 2), w("employer", 2), w("salary", 2), w("probation period", 1.5), w("annual leave", 1)}},
+		},
+		"lease_contract": {
+			{[]string{"мүліктік жалдау шарты", "жалдау шарты"}, []models.WeightedTerm{w("жалға алушы", 2), w("жалға беруші", 2), w("жалдау ақысы", 2)}},
+			{[]string{"lease agreement", "tenancy agreement"}, []models.WeightedTerm{w("lessee", 2), w("lessor", 2), w("tenant", 2), w("landlord", 2), w("rent", 1.5)}},
+		},
+		"nda": {
+			{[]string{"құпиялылық туралы келісім"}, []models.WeightedTerm{w("құпия ақпарат", 3)}},
+			{[]string{"confidentiality agreement"}, []models.WeightedTerm{w("confidential information", 3), w("disclosing party", 2), w("receiving party", 2)}},
+		},
+		"supply_contract": {
+			{[]string{"жеткізу шарты"}, []models.WeightedTerm{w("жеткізуші", 2)}},
+			{[]string{"supply agreement"}, []models.WeightedTerm{w("supplier", 2), w("delivery", 1), w("goods", 1)}},
+		},
+		"services_contract": {
+			{[]string{"қызмет көрсету шарты"}, []models.WeightedTerm{w("орындаушы", 2), w("тапсырыс беруші", 1.5)}},
+			{[]string{"services agreement", "service agreement"}, []models.WeightedTerm{w("service provider", 2), w("scope of services", 2), w("client", 1)}},
+		},
+		"work_contract": {
+			{[]string{"мердігерлік шарт"}, []models.WeightedTerm{w("мердігер", 2)}},
+			{[]string{"construction contract", "work contract"}, []models.WeightedTerm{w("contractor", 2), w("works", 1)}},
+		},
+		"sale_contract": {
+			{[]string{"сатып алу-сату шарты"}, []models.WeightedTerm{w("сатушы", 2), w("сатып алушы", 2)}},
+			{[]string{"sale and purchase agreement", "purchase agreement"}, []models.WeightedTerm{w("seller", 2), w("buyer", 2), w("title to", 1)}},
+		},
+		"loan_contract": {
+			{[]string{"қарыз шарты", "несие шарты"}, []models.WeightedTerm{w("қарыз алушы", 2), w("қарыз беруші", 2)}},
+			{[]string{"loan agreement", "credit agreement", "facility agreement"}, []models.WeightedTerm{w("borrower", 2), w("lender", 2), w("principal amount", 2), w("interest", 1)}},
+		},
+		"agency_contract": {
+			{[]string{"агенттік шарт"}, nil},
+			{[]string{"agency agreement"}, []models.WeightedTerm{w("agent", 2), w("principal", 2), w("commission", 1)}},
+		},
+		"power_of_attorney": {
+			{nil, []models.WeightedTerm{w("сенім білдіремін", 2), w("сенім білдірілген өкіл", 2)}},
+			{[]string{"power of attorney"}, []models.WeightedTerm{w("attorney-in-fact", 2), w("hereby authorize", 2), w("on my behalf", 1.5)}},
+		},
+		"charter": {
+			{nil, []models.WeightedTerm{w("жарғылық капитал", 2.5), w("құрылтайшы", 2)}},
+			{[]string{"charter", "articles of association"}, []models.WeightedTerm{w("founder", 2), w("charter capital", 2.5), w("general meeting", 2)}},
+		},
+		"order": {
+			{nil, []models.WeightedTerm{w("бұйырамын", 3)}},
+			{[]string{"order"}, []models.WeightedTerm{w("i hereby order", 3)}},
+		},
+		"resolution": {
+			{nil, []models.WeightedTerm{w("қаулы етеді", 3), w("әкімдік", 1.5)}},
+			{[]string{"resolution"}, []models.WeightedTerm{w("hereby resolves", 3), w("resolves", 2)}},
+		},
+		"law": {
+			{nil, []models.WeightedTerm{w("осы заң", 3)}},
+			{[]string{"law of the republic of kazakhstan", "code of the republic of kazakhstan"}, []models.WeightedTerm{w("this law", 3)}},
+		},
+		"court_decision": {
+			{[]string{"сот шешімі", "қазақстан республикасының атынан"}, []models.WeightedTerm{w("талапкер", 2), w("жауапкер", 2)}},
+			{[]string{"judgment", "court decision"}, []models.WeightedTerm{w("plaintiff", 2), w("defendant", 2), w("the court found", 3)}},
+		},
+		"decision": {
+			{[]string{"шешім"}, nil},
+			{[]string{"decision"}, []models.WeightedTerm{w("sole participant", 2), w("agenda", 2)}},
+		},
+		"claim": {
+			{[]string{"кінәрат-талап"}, []models.WeightedTerm{w("сотқа дейінгі тәртіппен", 2), w("берешек", 1.5)}},
+			{[]string{"letter of claim", "claim letter", "pre-trial claim"}, []models.WeightedTerm{w("we demand", 2), w("outstanding debt", 1.5)}},
+		},
+		"lawsuit": {
+			{[]string{"талап арыз", "талап-арыз"}, []models.WeightedTerm{w("талапкер", 2), w("жауапкер", 2), w("соттан сұраймын", 3)}},
+			{[]string{"statement of claim"}, []models.WeightedTerm{w("plaintiff", 2), w("defendant", 2), w("request the court", 3)}},
+		},
+		"act": {
+			{[]string{"қабылдау-тапсыру актісі", "орындалған жұмыстар актісі"}, nil},
+			{[]string{"acceptance certificate", "certificate of completion", "reconciliation statement"}, []models.WeightedTerm{w("no claims", 2), w("accepted", 1)}},
+		},
+	}
+	for i := range types {
+		for _, l := range localized[types[i].Code] {
+			types[i].TitlePatterns = append(types[i].TitlePatterns, l.patterns...)
+			types[i].Keywords = append(types[i].Keywords, l.keywords...)
+		}
+	}
+	return types
+}
+
+// loadTaxonomy возвращает активные типы документов, при пустой коллекции
+// записывает начальную таксономию. Если база недоступна, используется встроенная.
+func loadTaxonomy() []models.DocumentType {
+	taxonomyMutex.Lock()
+	defer taxonomyMutex.Unlock()
+
+	if taxonomyCache != nil && time.Since(taxonomyLoadedAt) < taxonomyTTL {
+		return taxonomyCache
+	}
+
+	types, err := repositories.GetDocumentTypes()
+	if err == nil && len(types) == 0 {
+		types = defaultDocumentTypes()
+		if err = repositories.InsertDocumentTypes(types); err == nil {
+			utils.LogSuccess(fmt.Sprintf("Записана начальная таксономия документов: %d типов", len(types)))
+		}
+	}
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Используется встроенная таксономия документов: %v", err))
+		types = defaultDocumentTypes()
+	}
+
+	active := types[:0]
+	for _, t := range types {
+		if t.Active {
+			active = append(active, t)
+		}
+	}
+
+	taxonomyCache = active
+	taxonomyLoadedAt = time.Now()
+	return active
+}
+
+func invalidateTaxonomy() {
+	taxonomyMutex.Lock()
+	taxonomyCache = nil
+	taxonomyMutex.Unlock()
+}
+
+// ClassifyDocument определяет основной тип документа, подтипы и язык. Эвристика
+// учитывает заголовок документа и взвешенные характерные слова; по CLASSIFIER_LLM
+// результат уточняется моделью.
+func ClassifyDocument(ctx context.Context, text string) *models.DocumentClassification {
+	taxonomy := loadTaxonomy()
+	result := classifyHeuristic(text, taxonomy)
+	languages := detectLanguages(text)
+	result.Language, result.Languages = languages[0], languages
+
+	mode := strings.ToLower(os.Getenv("CLASSIFIER_LLM"))
+	if mode == classifierLLMAlways || (mode == classifierLLMFallback && result.Confidence < llmFallbackConfidence) {
+		if refined, err := classifyWithLLM(ctx, text, taxonomy); err == nil {
+			refined.Language, refined.Languages = result.Language, result.Languages
+			result = refined
+		} else {
+			utils.LogWarning(fmt.Sprintf("Классификация через AI не удалась, используется эвристика: %v", err))
+		}
+	}
+
+	utils.LogInfo(fmt.Sprintf("Тип документа: %s (%s, уверенность %.2f, язык %s, подтипов %d)",
+		result.Type, result.Method, result.Confidence, result.Language, len(result.Subtypes)))
+	return result
+}
+
+func classifyHeuristic(text string, taxonomy []models.DocumentType) *models.DocumentClassification {
+	lower := strings.ToLower(text)
+	firstLine, header := documentHeader(lower)
+
+	scores := make(map[string]float64, len(taxonomy))
+	for _, t := range taxonomy {
+		var score float64
+		for _, p := range t.TitlePatterns {
+			p = strings.ToLower(strings.TrimSpace(p))
+			switch {
+			case p == "":
+			case containsWord(firstLine, p):
+				score += firstLineWeight
+			case containsWord(header, p):
+				score += headerWeight
+			}
+		}
+		for _, k := range t.Keywords {
+			if n := strings.Count(lower, strings.ToLower(k.Term)); n > 0 {
+				score += k.Weight * math.Log2(1+float64(n))
+			}
+		}
+		scores[t.Code] = score
+	}
+
+	children := make(map[string][]models.DocumentType)
+	var primaries []models.DocumentType
+	for _, t := range taxonomy {
+		if t.Parent == "" {
+			primaries = append(primaries, t)
+		} else {
+			children[t.Parent] = append(children[t.Parent], t)
+		}
+	}
+
+	// Подтип поддерживает основной тип: договор аренды — это прежде всего договор
+	primaryScores := make(map[string]float64, len(primaries))
+	for _, p := range primaries {
+		best := 0.0
+		for _, c := range children[p.Code] {
+			best = math.Max(best, scores[c.Code])
+		}
+		primaryScores[p.Code] = scores[p.Code] + childShare*best
+	}
+
+	sort.SliceStable(primaries, func(i, j int) bool {
+		return primaryScores[primaries[i].Code] > primaryScores[primaries[j].Code]
+	})
+
+	result := &models.DocumentClassification{Code: unknownDocumentCode, Type: unknownDocumentType, Method: "heuristic"}
+	if len(primaries) == 0 || primaryScores[primaries[0].Code] == 0 {
+		return result
+	}
+
+	best := primaries[0]
+	second := 0.0
+	if len(primaries) > 1 {
+		second = primaryScores[primaries[1].Code]
+	}
+	result.Code = best.Code
+	result.Type = best.Name
+	result.Confidence = labelConfidence(primaryScores[best.Code], primaryScores[best.Code]+second)
+
+	subtypes := children[best.Code]
+	sort.SliceStable(subtypes, func(i, j int) bool { return scores[subtypes[i].Code] > scores[subtypes[j].Code] })
+	var total float64
+	for _, s := range subtypes {
+		total += scores[s.Code]
+	}
+	for _, s := range subtypes {
+		score := scores[s.Code]
+		if score < minSubtypeScore || score < subtypeShare*scores[subtypes[0].Code] {
+			break
+		}
+		result.Subtypes = append(result.Subtypes, models.DocumentLabel{
+			Code:       s.Code,
+			Name:       s.Name,
+			Confidence: labelConfidence(score, total),
+		})
+	}
+
+	return result
+}
+
+// labelConfidence сочетает отрыв от конкурентов и абсолютную силу признаков
+func labelConfidence(score, total float64) float64 {
+	if score <= 0 || total <= 0 {
+		return 0
+	}
+	c := score / total * (1 - math.Exp(-score/confidenceScale))
+	return math.Round(c*100) / 100
+}
+
+// containsWord ищет фразу целыми словами: короткие заголовки вроде «иск» или
+// «nda» не должны находиться внутри «риск» и «standard». Границы слов
+// проверяются по буквам любого алфавита — \b в RE2 понимает только латиницу.
+func containsWord(text, phrase string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], phrase)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// documentHeader возвращает первую непустую строку и шапку документа. У текста
+// без переносов шапкой считаются первые headerChars символов.
+func documentHeader(lower string) (string, string) {
+	var lines []string
+	for _, line := range strings.Split(lower, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+			if len(lines) == headerLines {
+				break
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "", ""
+	}
+
+	header := strings.Join(lines, "\n")
+	if utf8.RuneCountInString(header) > headerChars {
+		header = string([]rune(header)[:headerChars])
+	}
+	firstLine := lines[0]
+	if utf8.RuneCountInString(firstLine) > headerChars/4 {
+		firstLine = string([]rune(firstLine)[:headerChars/4])
+	}
+	return firstLine, header
+}
+
+func classifyWithLLM(ctx context.Context, text string, taxonomy []models.DocumentType) (*models.DocumentClassification, error) {
+	byCode := make(map[string]models.DocumentType, len(taxonomy))
+	var list strings.Builder
+	for _, t := range taxonomy {
+		byCode[t.Code] = t
+		if t.Parent == "" {
+			fmt.Fprintf(&list, "- %s: %s\n", t.Code, t.Name)
+		} else {
+			fmt.Fprintf(&list, "  - %s: %s (подтип %s)\n", t.Code, t.Name, t.Parent)
+		}
+	}
+
+	prompt := fmt.Sprintf(`Определи тип юридического документа по таксономии ниже.
+Выбери один основной тип и все подходящие подтипы этого типа (их может не быть).
+
+Таксономия:
+%s
+Ответ верни строго в формате JSON, без пояснений:
+{"type": "код основного типа", "subtypes": ["код подтипа"], "confidence": 0.0-1.0}
+
+Начало документа:
+%s`, list.String(), truncateRunes(text, classifierPromptChars))
+
+	resp, err := queryLLM(withLLMOperation(ctx, llmOpClassification), prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var out struct {
+		Type       string   `json:"type"`
+		Subtypes   []string `json:"subtypes"`
+		Confidence float64  `json:"confidence"`
+	}
+	if err := unmarshalModelJSON(resp.Content, &out); err != nil {
+		return nil, err
+	}
+
+	primary, ok := byCode[strings.TrimSpace(out.Type)]
+	if !ok || primary.Parent != "" {
+		return nil, fmt.Errorf("модель вернула неизвестный тип %q", out.Type)
+	}
+
+	confidence := math.Max(0, math.Min(out.Confidence, 1))
+	result := &models.DocumentClassification{
+		Code:       primary.Code,
+		Type:       primary.Name,
+		Confidence: math.Round(confidence*100) / 100,
+		Method:     "llm",
+	}
+	for _, code := range out.Subtypes {
+		if sub, ok := byCode[strings.TrimSpace(code)]; ok && sub.Parent == primary.Code {
+			result.Subtypes = append(result.Subtypes, models.DocumentLabel{Code: sub.Code, Name: sub.Name, Confidence: result.Confidence})
+		}
+	}
+	return result, nil
+}
+
+// ListDocumentTypes возвращает всю таксономию, включая отключённые типы
+func ListDocumentTypes() ([]models.DocumentType, error) {
+	loadTaxonomy() // записывает начальную таксономию при первом обращении
+	return repositories.GetDocumentTypes()
+}
+
+func CreateDocumentType(docType models.DocumentType) (*models.DocumentType, error) {
+	if err := validateDocumentType(&docType, primitive.NilObjectID); err != nil {
+		return nil, err
+	}
+	if err := repositories.CreateDocumentType(&docType); err != nil {
+		return nil, err
+	}
+	invalidateTaxonomy()
+	return &docType, nil
+}
+
+// UpdateDocumentType заменяет тип целиком; код менять нельзя — на него ссылаются
+// подтипы и сохранённые анализы
+func UpdateDocumentType(id string, docType models.DocumentType) (*models.DocumentType, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrDocumentTypeNotFound
+	}
+	existing, err := repositories.GetDocumentType(objID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrDocumentTypeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	docType.Code = existing.Code
+	if err := validateDocumentType(&docType, objID); err != nil {
+		return nil, err
+	}
+
+	err = repositories.UpdateDocumentType(objID, bson.M{
+		"name":           docType.Name,
+		"parent":         docType.Parent,
+		"title_patterns": docType.TitlePatterns,
+		"keywords":       docType.Keywords,
+		"active":         docType.Active,
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateTaxonomy()
+
+	updated, err := repositories.GetDocumentType(objID)
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func DeleteDocumentType(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrDocumentTypeNotFound
+	}
+	existing, err := repositories.GetDocumentType(objID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrDocumentTypeNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	types, err := repositories.GetDocumentTypes()
+	if err != nil {
+		return err
+	}
+	for _, t := range types {
+		if t.Parent == existing.Code {
+			return ErrDocumentTypeInUse
+		}
+	}
+
+	if err := repositories.DeleteDocumentType(objID); err != nil {
+		return err
+	}
+	invalidateTaxonomy()
+	return nil
+}
+
+// validateDocumentType проверяет код, родителя и веса; self — ID изменяемого типа
+func validateDocumentType(docType *models.DocumentType, self primitive.ObjectID) error {
+	docType.Name = strings.TrimSpace(docType.Name)
+	docType.Code = strings.TrimSpace(docType.Code)
+	docType.Parent = strings.TrimSpace(docType.Parent)
+
+	if !documentCodeRe.MatchString(docType.Code) {
+		return invalidDocumentType("код типа должен состоять из латинских букв, цифр и _: %q", docType.Code)
+	}
+	if docType.Name == "" {
+		return invalidDocumentType("не указано название типа")
+	}
+	if len(docType.TitlePatterns) == 0 && len(docType.Keywords) == 0 {
+		return invalidDocumentType("нужен хотя бы один шаблон заголовка или ключевое слово")
+	}
+	for _, k := range docType.Keywords {
+		if strings.TrimSpace(k.Term) == "" || k.Weight <= 0 {
+			return invalidDocumentType("у ключевого слова должен быть текст и положительный вес")
+		}
+	}
+
+	if docType.Parent == "" {
+		return nil
+	}
+	if docType.Parent == docType.Code {
+		return invalidDocumentType("тип не может быть подтипом самого себя")
+	}
+
+	types, err := repositories.GetDocumentTypes()
+	if err != nil {
+		return err
+	}
+	for _, t := range types {
+		if t.Code == docType.Parent {
+			if t.Parent != "" {
+				return invalidDocumentType("родителем может быть только основной тип")
+			}
+			return nil
+		}
+		if t.Parent == docType.Code && t.ID != self {
+			return invalidDocumentType("у типа есть подтипы, он не может стать подтипом")
+		}
+	}
+	return invalidDocumentType("родительский тип %q не найден", docType.Parent)
+}
+
+func invalidDocumentType(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidDocumentType, fmt.Sprintf(format, args...))
+}
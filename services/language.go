@@ -0,0 +1,285 @@
+// language.go
+
+package services
+
+import (
+	"fmt"
+	"legally/models"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	LanguageRussian = "ru"
+	LanguageKazakh  = "kk"
+	LanguageEnglish = "en"
+
+	defaultLanguage = LanguageRussian
+
+	// kazakhLetterShare — доля специфичных казахских букв среди кириллицы,
+	// начиная с которой текст считается казахским
+	kazakhLetterShare = 0.02
+	// secondaryLanguageShare — с какой доли букв язык считается вторым языком
+	// двуязычного документа
+	secondaryLanguageShare = 0.15
+)
+
+var ErrUnsupportedLanguage = fmt.Errorf("поддерживаются языки: %s, %s, %s", LanguageRussian, LanguageKazakh, LanguageEnglish)
+
+// IsSupportedLanguage сообщает, что отчёт можно получить на этом языке
+func IsSupportedLanguage(lang string) bool {
+	return lang == LanguageRussian || lang == LanguageKazakh || lang == LanguageEnglish
+}
+
+// countLetters определяет язык текста по алфавиту и возвращает также число букв:
+// латиница — английский, кириллица с буквами ә, ғ, қ, ң, ө, ұ, ү, һ, і —
+// казахский, иначе русский
+func countLetters(text string) (string, int) {
+	var cyrillic, kazakh, latin int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			switch unicode.ToLower(r) {
+			case 'ә', 'ғ', 'қ', 'ң', 'ө', 'ұ', 'ү', 'һ', 'і':
+				kazakh++
+			}
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			latin++
+		}
+	}
+
+	switch {
+	case latin > cyrillic:
+		return LanguageEnglish, latin + cyrillic
+	case cyrillic > 0 && float64(kazakh)/float64(cyrillic) >= kazakhLetterShare:
+		return LanguageKazakh, latin + cyrillic
+	default:
+		return LanguageRussian, latin + cyrillic
+	}
+}
+
+// detectLanguages определяет языки документа построчно: двуязычные договоры
+// обычно идут параллельными колонками или абзацами. Первым идёт основной язык,
+// остальные — если на них приходится заметная доля текста.
+func detectLanguages(text string) []string {
+	letters := make(map[string]int)
+	var total int
+	for _, line := range strings.Split(text, "\n") {
+		lang, n := countLetters(line)
+		if n == 0 {
+			continue
+		}
+		letters[lang] += n
+		total += n
+	}
+	if total == 0 {
+		return []string{defaultLanguage}
+	}
+
+	languages := make([]string, 0, len(letters))
+	for lang := range letters {
+		languages = append(languages, lang)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if letters[languages[i]] != letters[languages[j]] {
+			return letters[languages[i]] > letters[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+
+	out := languages[:1]
+	for _, lang := range languages[1:] {
+		if float64(letters[lang])/float64(total) >= secondaryLanguageShare {
+			out = append(out, lang)
+		}
+	}
+	return out
+}
+
+// preferredLanguage выбирает поддерживаемый язык из заголовка Accept-Language
+// с учётом весов q; пустая строка — ни один не подходит
+func preferredLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, item := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(item), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if i := strings.IndexAny(tag, "-_"); i > 0 {
+			tag = tag[:i]
+		}
+		if !IsSupportedLanguage(tag) {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+// languageInstructions требуют от модели писать отчёт на одном языке, даже если
+// документ двуязычный. Добавляются к системному сообщению любого шаблона.
+var languageInstructions = map[string]string{
+	LanguageRussian: "Пиши все текстовые поля ответа только на русском языке, даже если документ полностью или частично написан на другом языке. Цитаты из документа можно приводить на языке оригинала. Значения kind, severity, likelihood и overall_risk не переводи.",
+	LanguageKazakh:  "Жауаптың барлық мәтіндік өрістерін тек қазақ тілінде жаз, тіпті құжат толығымен немесе ішінара басқа тілде жазылған болса да. Құжаттан дәйексөздерді түпнұсқа тілінде келтіруге болады. kind, severity, likelihood және overall_risk мәндерін аударма.",
+	LanguageEnglish: "Write all text fields of the response in English only, even if the document is fully or partly written in another language. Quotes from the document may stay in the original language. Do not translate the values of kind, severity, likelihood and overall_risk.",
+}
+
+func languageInstruction(lang string) string {
+	if s, ok := languageInstructions[lang]; ok {
+		return s
+	}
+	return languageInstructions[defaultLanguage]
+}
+
+// answerLanguageInstructions — то же для свободных ответов на вопросы, где нет
+// полей JSON
+var answerLanguageInstructions = map[string]string{
+	LanguageRussian: "Отвечай только на русском языке, даже если документ написан на другом языке. Цитаты из документа можно приводить на языке оригинала.",
+	LanguageKazakh:  "Тек қазақ тілінде жауап бер, тіпті құжат басқа тілде жазылған болса да. Құжаттан дәйексөздерді түпнұсқа тілінде келтіруге болады.",
+	LanguageEnglish: "Answer in English only, even if the document is written in another language. Quotes from the document may stay in the original language.",
+}
+
+func answerLanguageInstruction(lang string) string {
+	if s, ok := answerLanguageInstructions[lang]; ok {
+		return s
+	}
+	return answerLanguageInstructions[defaultLanguage]
+}
+
+// reportText — подписи markdown-отчёта, проверки ссылок и проверки по правилам
+// на языке отчёта
+type reportText struct {
+	kinds          map[models.FindingKind]string
+	severities     map[models.Severity]string
+	description    string
+	legalBasis     string
+	sources        string
+	referenceCheck string
+	riskLevel      string
+	recommendation string
+	parts          string
+	// fragment и ruleCheck — для выводов детерминированных правил
+	fragment  string
+	ruleCheck string
+	// recommendations и далее — заголовки разделов и итоговые строки
+	recommendations string
+	needsReview     string
+	conclusion      string
+	overallRisk     string
+
+	referenceVerified   string
+	referenceMismatched string
+	referenceNotFound   string
+	noteActMissing      string
+	noteParagraph       string
+	noteMismatch        string
+	noteArticleMissing  string
+	noteNotCompared     string
+
+	// ruleCheckFailed и ruleNoAnswer — пояснения к правилам, которые не удалось проверить
+	ruleCheckFailed string
+	ruleNoAnswer    string
+}
+
+var reportTexts = map[string]reportText{
+	LanguageRussian: {
+		kinds:               map[models.FindingKind]string{models.FindingRisk: "Правовые риски", models.FindingAmbiguity: "Неясные формулировки", models.FindingViolation: "Возможные нарушения"},
+		severities:          map[models.Severity]string{models.SeverityHigh: "высокий", models.SeverityMedium: "средний", models.SeverityLow: "низкий"},
+		description:         "Описание",
+		legalBasis:          "Нормативный акт",
+		sources:             "Источники",
+		referenceCheck:      "Проверка ссылки «%s»",
+		riskLevel:           "Уровень риска",
+		recommendation:      "Рекомендация",
+		parts:               "Части документа",
+		fragment:            "Фрагмент",
+		ruleCheck:           "Найдено автоматической проверкой без AI",
+		recommendations:     "Рекомендации",
+		needsReview:         "Требуют проверки юристом ссылки на нормы: не найдено — %d, не соответствует выводу — %d.",
+		conclusion:          "Заключение",
+		overallRisk:         "Общий уровень риска",
+		referenceVerified:   "подтверждена",
+		referenceMismatched: "статья не соответствует выводу",
+		referenceNotFound:   "не найдена",
+		noteActMissing:      "кодекс отсутствует в базе законов",
+		noteParagraph:       "в статье %s нет пункта %s",
+		noteMismatch:        "текст статьи не относится к содержанию вывода",
+		noteArticleMissing:  "статья %s не найдена в «%s»",
+		noteNotCompared:     "статья найдена; соответствие выводу не проверялось",
+		ruleCheckFailed:     "правило не проверено: запрос к модели завершился ошибкой",
+		ruleNoAnswer:        "правило не проверено: модель не вернула по нему результат",
+	},
+	LanguageKazakh: {
+		kinds:               map[models.FindingKind]string{models.FindingRisk: "Құқықтық тәуекелдер", models.FindingAmbiguity: "Түсініксіз тұжырымдар", models.FindingViolation: "Ықтимал бұзушылықтар"},
+		severities:          map[models.Severity]string{models.SeverityHigh: "жоғары", models.SeverityMedium: "орташа", models.SeverityLow: "төмен"},
+		description:         "Сипаттамасы",
+		legalBasis:          "Нормативтік акт",
+		sources:             "Дереккөздер",
+		referenceCheck:      "«%s» сілтемесін тексеру",
+		riskLevel:           "Тәуекел деңгейі",
+		recommendation:      "Ұсыныс",
+		parts:               "Құжат бөліктері",
+		fragment:            "Үзінді",
+		ruleCheck:           "AI-сыз автоматты тексеру арқылы табылды",
+		recommendations:     "Ұсыныстар",
+		needsReview:         "Нормаларға сілтемелерді заңгер тексеруі қажет: табылмады — %d, қорытындыға сәйкес келмейді — %d.",
+		conclusion:          "Қорытынды",
+		overallRisk:         "Жалпы тәуекел деңгейі",
+		referenceVerified:   "расталды",
+		referenceMismatched: "бап қорытындыға сәйкес келмейді",
+		referenceNotFound:   "табылмады",
+		noteActMissing:      "кодекс заңдар базасында жоқ",
+		noteParagraph:       "%s-бапта %s-тармақ жоқ",
+		noteMismatch:        "бап мәтіні қорытынды мазмұнына қатысты емес",
+		noteArticleMissing:  "%s-бап «%s» ішінде табылмады",
+		noteNotCompared:     "бап табылды; қорытындыға сәйкестігі тексерілмеді",
+		ruleCheckFailed:     "ереже тексерілмеді: модельге сұрау қатемен аяқталды",
+		ruleNoAnswer:        "ереже тексерілмеді: модель ол бойынша нәтиже қайтармады",
+	},
+	LanguageEnglish: {
+		kinds:               map[models.FindingKind]string{models.FindingRisk: "Legal risks", models.FindingAmbiguity: "Ambiguous wording", models.FindingViolation: "Potential violations"},
+		severities:          map[models.Severity]string{models.SeverityHigh: "high", models.SeverityMedium: "medium", models.SeverityLow: "low"},
+		description:         "Description",
+		legalBasis:          "Legal basis",
+		sources:             "Sources",
+		referenceCheck:      "Reference check “%s”",
+		riskLevel:           "Risk level",
+		recommendation:      "Recommendation",
+		parts:               "Document parts",
+		fragment:            "Excerpt",
+		ruleCheck:           "Found by an automated check without AI",
+		recommendations:     "Recommendations",
+		needsReview:         "Legal references need review by a lawyer: not found — %d, not matching the finding — %d.",
+		conclusion:          "Conclusion",
+		overallRisk:         "Overall risk level",
+		referenceVerified:   "verified",
+		referenceMismatched: "article does not match the finding",
+		referenceNotFound:   "not found",
+		noteActMissing:      "the code is not in the law database",
+		noteParagraph:       "article %s has no paragraph %s",
+		noteMismatch:        "the article text is unrelated to the finding",
+		noteArticleMissing:  "article %s not found in “%s”",
+		noteNotCompared:     "article found; its relevance to the finding was not checked",
+		ruleCheckFailed:     "rule not checked: the request to the model failed",
+		ruleNoAnswer:        "rule not checked: the model returned no result for it",
+	},
+}
+
+func reportTextFor(lang string) reportText {
+	if t, ok := reportTexts[lang]; ok {
+		return t
+	}
+	return reportTexts[defaultLanguage]
+}
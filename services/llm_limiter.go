@@ -0,0 +1,112 @@
+// llm_limiter.go
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"legally/utils"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLLMConcurrency       = 4
+	defaultLLMRequestsPerMinute = 20
+	mockRequestsPerMinute       = 60000
+)
+
+// providerLimiter ограничивает число одновременных запросов к провайдеру и их частоту.
+// Лимит общий для всех задач процесса, поэтому одновременные анализы разных
+// пользователей не превышают квоту провайдера.
+type providerLimiter struct {
+	name     string
+	sem      chan struct{}
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+var (
+	limiters      = make(map[string]*providerLimiter)
+	limitersMutex sync.Mutex
+)
+
+// getProviderLimiter возвращает лимитер провайдера. Настройки берутся из
+// <PROVIDER>_MAX_CONCURRENT и <PROVIDER>_REQUESTS_PER_MINUTE, затем из
+// LLM_MAX_CONCURRENT и LLM_REQUESTS_PER_MINUTE.
+func getProviderLimiter(provider string) *providerLimiter {
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+
+	if l, exists := limiters[provider]; exists {
+		return l
+	}
+
+	prefix := strings.ToUpper(provider)
+	concurrency := envInt(defaultLLMConcurrency, prefix+"_MAX_CONCURRENT", "LLM_MAX_CONCURRENT")
+	rpm := envInt(defaultLLMRequestsPerMinute, prefix+"_REQUESTS_PER_MINUTE", "LLM_REQUESTS_PER_MINUTE")
+	if provider == mockProvider {
+		// Mock не обращается к сети, ограничивать его незачем
+		concurrency = envInt(defaultLLMConcurrency, prefix+"_MAX_CONCURRENT")
+		rpm = envInt(mockRequestsPerMinute, prefix+"_REQUESTS_PER_MINUTE")
+	}
+
+	l := &providerLimiter{
+		name:     provider,
+		sem:      make(chan struct{}, concurrency),
+		interval: time.Minute / time.Duration(rpm),
+	}
+	limiters[provider] = l
+
+	utils.LogInfo(fmt.Sprintf("Лимиты LLM-провайдера %s: %d одновременных запросов, %d в минуту", provider, concurrency, rpm))
+	return l
+}
+
+// Acquire ждёт свободный слот и очередное окно по частоте запросов.
+// Возвращённую функцию нужно вызвать после завершения запроса.
+func (l *providerLimiter) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-l.sem }
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait := time.Until(at); wait > 0 {
+		utils.LogInfo(fmt.Sprintf("Ожидание лимита запросов к %s: %v", l.name, wait.Round(time.Millisecond)))
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
+
+// envInt возвращает первое положительное целое из перечисленных переменных окружения
+func envInt(fallback int, names ...string) int {
+	for _, name := range names {
+		if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
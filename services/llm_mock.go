@@ -0,0 +1,102 @@
+// llm_mock.go
+
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"legally/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const mockProvider = "mock"
+
+// mockDefaultResponse возвращается, когда подходящей фикстуры нет. Это валидный ответ
+// и для анализа части, и для сведения отчёта, поэтому весь конвейер проходит без сети.
+const mockDefaultResponse = `{
+  "findings": [
+    {
+      "kind": "risk",
+      "title": "Тестовый вывод",
+      "description": "Ответ сгенерирован mock-провайдером без обращения к языковой модели.",
+      "legal_basis": "Гражданский кодекс РК",
+      "severity": "low",
+      "recommendation": "Настройте реальный LLM-провайдер для полноценного анализа."
+    }
+  ],
+  "recommendations": [],
+  "conclusion": {"summary": "Анализ выполнен mock-провайдером.", "overall_risk": "low"}
+}`
+
+// mockLLMProvider отвечает детерминированно из файлов-фикстур. Фикстура ищется по
+// первым 16 символам SHA-256 от последнего сообщения пользователя: <dir>/<key>.txt,
+// затем <dir>/default.txt, затем используется встроенный ответ.
+type mockLLMProvider struct {
+	dir   string
+	model string
+}
+
+func NewMockProvider(fixturesDir, model string) LLMProvider {
+	return &mockLLMProvider{dir: fixturesDir, model: model}
+}
+
+func (p *mockLLMProvider) Name() string  { return mockProvider }
+func (p *mockLLMProvider) Model() string { return p.model }
+
+func (p *mockLLMProvider) CountTokens(text string) int {
+	return estimateTokens(text)
+}
+
+func (p *mockLLMProvider) Complete(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &ChatResponse{Content: p.lookup(req), Model: p.requestModel(req)}, nil
+}
+
+func (p *mockLLMProvider) Stream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	content := p.lookup(req)
+	if onDelta != nil {
+		for _, chunk := range strings.SplitAfter(content, " ") {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			onDelta(chunk)
+		}
+	}
+	return &ChatResponse{Content: content, Model: p.requestModel(req)}, nil
+}
+
+func (p *mockLLMProvider) requestModel(req ChatRequest) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return p.model
+}
+
+func (p *mockLLMProvider) lookup(req ChatRequest) string {
+	var prompt string
+	for _, m := range req.Messages {
+		if m.Role == "user" {
+			prompt = m.Content
+		}
+	}
+
+	sum := sha256.Sum256([]byte(prompt))
+	key := hex.EncodeToString(sum[:])[:16]
+
+	if p.dir != "" {
+		for _, name := range []string{key + ".txt", "default.txt"} {
+			if data, err := os.ReadFile(filepath.Join(p.dir, name)); err == nil {
+				return string(data)
+			}
+		}
+	}
+
+	utils.LogInfo(fmt.Sprintf("Mock LLM: фикстура %s не найдена, используется ответ по умолчанию", key))
+	return mockDefaultResponse
+}
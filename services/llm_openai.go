@@ -0,0 +1,329 @@
+// llm_openai.go
+
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"legally/models"
+	"legally/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	openRouterProvider = "openrouter"
+	openAIProvider     = "openai"
+	openRouterBaseURL  = "https://openrouter.ai/api/v1"
+)
+
+// openAICompatibleProvider работает с любым API в формате OpenAI /chat/completions:
+// OpenRouter, vLLM, Ollama и т.п.
+type openAICompatibleProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	model   string
+	headers map[string]string
+}
+
+func NewOpenAICompatibleProvider(name, baseURL, apiKey, model string) LLMProvider {
+	return &openAICompatibleProvider{
+		name:    name,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		headers: map[string]string{},
+	}
+}
+
+func NewOpenRouterProvider(apiKey, model string) LLMProvider {
+	return &openAICompatibleProvider{
+		name:    openRouterProvider,
+		baseURL: openRouterBaseURL,
+		apiKey:  apiKey,
+		model:   model,
+		headers: map[string]string{
+			"HTTP-Referer": "https://legally.kz",
+			"X-Title":      "Legally AI Risk Analyzer",
+		},
+	}
+}
+
+func (p *openAICompatibleProvider) Name() string  { return p.name }
+func (p *openAICompatibleProvider) Model() string { return p.model }
+
+func (p *openAICompatibleProvider) CountTokens(text string) int {
+	return estimateTokens(text)
+}
+
+func (p *openAICompatibleProvider) requestModel(chat ChatRequest) string {
+	if chat.Model != "" {
+		return chat.Model
+	}
+	return p.model
+}
+
+func (p *openAICompatibleProvider) newRequest(ctx context.Context, chat ChatRequest, stream bool) (*http.Request, error) {
+	payload := map[string]interface{}{
+		"model":       p.requestModel(chat),
+		"messages":    chat.Messages,
+		"temperature": chat.Temperature,
+		"max_tokens":  chat.MaxTokens,
+	}
+	if stream {
+		payload["stream"] = true
+		// Без этого OpenAI-совместимые серверы не присылают расход в потоке
+		payload["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	if p.name == openRouterProvider {
+		// OpenRouter добавляет в usage стоимость запроса
+		payload["usage"] = map[string]interface{}{"include": true}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка маршалинга payload: %w", err)
+	}
+
+	endpoint := p.baseURL + "/chat/completions"
+	utils.LogRequest("out", endpoint, len(body))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return req, nil
+}
+
+func (p *openAICompatibleProvider) Complete(ctx context.Context, chat ChatRequest) (*ChatResponse, error) {
+	req, err := p.newRequest(ctx, chat, false)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 120 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса к %s: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	resBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа %s: %w", p.name, err)
+	}
+
+	utils.LogRequest("in", fmt.Sprintf("%s (статус: %d)", p.name, resp.StatusCode), len(resBody))
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newLLMError(p.name, p.requestModel(chat), resp, resBody)
+	}
+
+	var res struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage *openAIUsage `json:"usage"`
+	}
+
+	if err := json.Unmarshal(resBody, &res); err != nil {
+		return nil, fmt.Errorf("не удалось распарсить ответ AI: %w", err)
+	}
+
+	if len(res.Choices) == 0 || res.Choices[0].Message.Content == "" {
+		return nil, fmt.Errorf("пустой ответ от %s", p.name)
+	}
+
+	return &ChatResponse{Content: res.Choices[0].Message.Content, Model: p.responseModel(chat, res.Model), Usage: res.Usage.tokenUsage()}, nil
+}
+
+// Stream запрашивает ответ с stream: true и разбирает SSE-поток провайдера
+func (p *openAICompatibleProvider) Stream(ctx context.Context, chat ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	req, err := p.newRequest(ctx, chat, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Длинные ответы стримятся дольше обычного таймаута; прервать запрос можно через ctx
+	client := &http.Client{Timeout: 10 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса к %s: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		resBody, _ := io.ReadAll(resp.Body)
+		utils.LogRequest("in", fmt.Sprintf("%s (статус: %d)", p.name, resp.StatusCode), len(resBody))
+		return nil, newLLMError(p.name, p.requestModel(chat), resp, resBody)
+	}
+
+	var full strings.Builder
+	var model string
+	var usage *openAIUsage
+	// finished — провайдер сообщил о завершении ответа ([DONE] или finish_reason);
+	// без этого конец потока означает обрыв соединения, а не готовый ответ
+	finished := false
+	// partial — полученная часть ответа для учёта расхода при обрыве потока;
+	// nil, если провайдер ещё ничего не вернул
+	partial := func() *ChatResponse {
+		if full.Len() == 0 && usage == nil {
+			return nil
+		}
+		return &ChatResponse{Content: full.String(), Model: p.responseModel(chat, model), Usage: usage.tokenUsage()}
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Пустые строки разделяют события, строки с ":" — комментарии-keepalive
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			finished = true
+			break
+		}
+
+		var chunk struct {
+			Model   string `json:"model"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Error *streamError `json:"error"`
+			// Usage приходит в последнем фрагменте, обычно без choices
+			Usage *openAIUsage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			utils.LogWarning(fmt.Sprintf("Пропущен нераспознанный фрагмент потока: %v", err))
+			continue
+		}
+
+		if chunk.Error != nil {
+			return partial(), &LLMError{Provider: p.name, Model: p.requestModel(chat), StatusCode: chunk.Error.status(), Body: data}
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != nil && *chunk.Choices[0].FinishReason != "" {
+			finished = true
+		}
+
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		full.WriteString(delta)
+		if onDelta != nil {
+			onDelta(delta)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return partial(), fmt.Errorf("ошибка чтения потока %s: %w", p.name, err)
+	}
+
+	utils.LogRequest("in", fmt.Sprintf("%s (поток)", p.name), full.Len())
+
+	if full.Len() == 0 {
+		return partial(), fmt.Errorf("пустой ответ от %s", p.name)
+	}
+	if !finished {
+		return partial(), &LLMError{Provider: p.name, Model: p.requestModel(chat),
+			Body: fmt.Sprintf("поток оборвался до завершения ответа (получено %d байт)", full.Len())}
+	}
+
+	return &ChatResponse{Content: full.String(), Model: p.responseModel(chat, model), Usage: usage.tokenUsage()}, nil
+}
+
+// streamError — ошибка, которую провайдер прислал внутри потока после статуса
+// 200. OpenRouter передаёт в code HTTP-статус, OpenAI — строковый код и тип.
+type streamError struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// status переводит ошибку потока в HTTP-статус, по которому решается, повторять
+// ли запрос; неизвестная ошибка считается сбоем провайдера
+func (e *streamError) status() int {
+	var code int
+	if json.Unmarshal(e.Code, &code) == nil && code >= 400 {
+		return code
+	}
+	var name string
+	_ = json.Unmarshal(e.Code, &name)
+	for _, kind := range []string{name, e.Type} {
+		switch kind {
+		case "rate_limit_exceeded", "rate_limit_error":
+			return http.StatusTooManyRequests
+		case "insufficient_quota":
+			return http.StatusPaymentRequired
+		case "invalid_api_key", "authentication_error":
+			return http.StatusUnauthorized
+		case "permission_error":
+			return http.StatusForbidden
+		case "invalid_request_error", "context_length_exceeded":
+			return http.StatusBadRequest
+		case "server_error", "api_error":
+			return http.StatusInternalServerError
+		case "overloaded_error":
+			return http.StatusServiceUnavailable
+		}
+	}
+	return http.StatusBadGateway
+}
+
+// openAIUsage — блок usage ответа; cost есть только у OpenRouter
+type openAIUsage struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (u *openAIUsage) tokenUsage() models.TokenUsage {
+	if u == nil {
+		return models.TokenUsage{}
+	}
+	return models.TokenUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, Cost: u.Cost}
+}
+
+// responseModel — модель, указанная провайдером в ответе; OpenRouter может
+// направить запрос на другую версию модели
+func (p *openAICompatibleProvider) responseModel(chat ChatRequest, reported string) string {
+	if reported != "" {
+		return reported
+	}
+	return p.requestModel(chat)
+}
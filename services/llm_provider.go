@@ -0,0 +1,141 @@
+// llm_provider.go
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"legally/models"
+	"legally/utils"
+	"os"
+	"strconv"
+	"unicode"
+)
+
+const (
+	defaultLLMModel       = "deepseek/deepseek-r1-0528:free"
+	defaultLLMTemperature = 0.3
+	defaultLLMMaxTokens   = 4000
+
+	systemPrompt = "Ты — юридический эксперт по законодательству Казахстана. Анализируй документы и давай развернутые ответы с конкретными ссылками на законы."
+)
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	// Model переопределяет модель провайдера по умолчанию
+	Model       string
+	Messages    []ChatMessage
+	Temperature float64
+	MaxTokens   int
+}
+
+type ChatResponse struct {
+	Content string
+	// Model — модель, которая фактически сгенерировала ответ
+	Model string
+	// Usage — расход по данным провайдера; UsageEstimated — провайдер его не
+	// сообщил и токены оценены по тексту (см. recordLLMUsage)
+	Usage          models.TokenUsage
+	UsageEstimated bool
+}
+
+// LLMProvider — бэкенд для чат-запросов к языковой модели
+type LLMProvider interface {
+	Name() string
+	Model() string
+	Complete(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	// Stream передаёт фрагменты ответа в onDelta и возвращает полный ответ. Если
+	// поток оборвался, вместе с ошибкой может вернуться полученная часть ответа:
+	// по ней учитывается расход.
+	Stream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error)
+	CountTokens(text string) int
+}
+
+var llm LLMProvider
+
+// InitLLM выбирает провайдера по LLM_PROVIDER: openrouter (по умолчанию), openai
+// (любой OpenAI-совместимый сервер, например vLLM или Ollama) или mock.
+// Модель задаётся LLM_MODEL.
+func InitLLM() error {
+	model := os.Getenv("LLM_MODEL")
+	if model == "" {
+		model = defaultLLMModel
+	}
+
+	provider, err := newLLMProvider(os.Getenv("LLM_PROVIDER"), model)
+	if err != nil {
+		return err
+	}
+
+	llm = provider
+	utils.LogSuccess(fmt.Sprintf("LLM-провайдер: %s, модель: %s", provider.Name(), provider.Model()))
+	return nil
+}
+
+func newLLMProvider(name, model string) (LLMProvider, error) {
+	switch name {
+	case "", openRouterProvider:
+		apiKey := os.Getenv("OPENROUTER_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("OPENROUTER_API_KEY не установлен")
+		}
+		return NewOpenRouterProvider(apiKey, model), nil
+	case openAIProvider:
+		baseURL := os.Getenv("LLM_BASE_URL")
+		if baseURL == "" {
+			return nil, fmt.Errorf("LLM_BASE_URL не установлен")
+		}
+		return NewOpenAICompatibleProvider(openAIProvider, baseURL, os.Getenv("LLM_API_KEY"), model), nil
+	case mockProvider:
+		return NewMockProvider(os.Getenv("LLM_MOCK_FIXTURES"), model), nil
+	default:
+		return nil, fmt.Errorf("неизвестный LLM-провайдер: %s", name)
+	}
+}
+
+func newChatRequest(system, prompt string) ChatRequest {
+	temperature := defaultLLMTemperature
+	if t, err := strconv.ParseFloat(os.Getenv("LLM_TEMPERATURE"), 64); err == nil {
+		temperature = t
+	}
+
+	return ChatRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+		Temperature: temperature,
+		MaxTokens:   envInt(defaultLLMMaxTokens, "LLM_MAX_TOKENS"),
+	}
+}
+
+// queryLLM отправляет промпт настроенному провайдеру с учётом его лимитов,
+// повторов и запасных моделей
+func queryLLM(ctx context.Context, prompt string) (*ChatResponse, error) {
+	return callLLM(ctx, newChatRequest(systemPrompt, prompt), nil)
+}
+
+// queryLLMStream — потоковый вариант queryLLM с собственным системным сообщением
+func queryLLMStream(ctx context.Context, system, prompt string, stream *streamCallbacks) (*ChatResponse, error) {
+	return callLLM(ctx, newChatRequest(system, prompt), stream)
+}
+
+// estimateTokens грубо оценивает число токенов: латиница и цифры занимают около
+// четырёх символов на токен, кириллица и прочие алфавиты — около двух
+func estimateTokens(text string) int {
+	var latin, other int
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+		case r < unicode.MaxASCII:
+			latin++
+		default:
+			other++
+		}
+	}
+	return latin/4 + other/2 + 1
+}
@@ -0,0 +1,269 @@
+// llm_retry.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"legally/utils"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLLMMaxRetries = 3
+	retryBaseDelay       = time.Second
+	retryMaxDelay        = 30 * time.Second
+	maxRetryAfter        = 2 * time.Minute
+	maxErrorBody         = 2048
+)
+
+// LLMError — ошибка ответа провайдера с сохранённым телом ответа
+type LLMError struct {
+	Provider   string
+	Model      string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *LLMError) Error() string {
+	message := e.Body
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(e.Body), &parsed) == nil && parsed.Error.Message != "" {
+		message = parsed.Error.Message
+	}
+
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("ошибка от %s (%s): %s", e.Provider, e.Model, message)
+	}
+	return fmt.Sprintf("ошибка от %s (%s): статус %d: %s", e.Provider, e.Model, e.StatusCode, message)
+}
+
+// Retryable сообщает, что запрос к той же модели имеет смысл повторить
+func (e *LLMError) Retryable() bool {
+	switch e.StatusCode {
+	case 0, // поток оборвался, не дойдя до конца ответа
+		http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Fatal сообщает, что другие модели того же провайдера тоже не помогут:
+// неверный ключ, нет доступа или закончились средства
+func (e *LLMError) Fatal() bool {
+	return e.StatusCode == http.StatusUnauthorized ||
+		e.StatusCode == http.StatusPaymentRequired ||
+		e.StatusCode == http.StatusForbidden
+}
+
+func newLLMError(provider, model string, resp *http.Response, body []byte) *LLMError {
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	return &LLMError{
+		Provider:   provider,
+		Model:      model,
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter понимает оба формата заголовка: секунды и HTTP-дату
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// streamCallbacks получает фрагменты потокового ответа. onReset вызывается перед
+// повтором оборвавшегося потока: полученные до этого фрагменты нужно отбросить.
+type streamCallbacks struct {
+	onDelta func(string)
+	onReset func()
+}
+
+// llmModelChain — основная модель провайдера и запасные из LLM_FALLBACK_MODELS
+func llmModelChain() []string {
+	chain := []string{llm.Model()}
+	for _, m := range strings.Split(os.Getenv("LLM_FALLBACK_MODELS"), ",") {
+		if m = strings.TrimSpace(m); m != "" && m != llm.Model() {
+			chain = append(chain, m)
+		}
+	}
+	return chain
+}
+
+// callLLM выполняет запрос к LLM. Персональные данные в сообщениях заменяются
+// метками (см. piiVault), а в ответе, в том числе потоковом, метки заменяются
+// обратно на исходные значения.
+func callLLM(ctx context.Context, req ChatRequest, stream *streamCallbacks) (*ChatResponse, error) {
+	vault := piiVaultFrom(ctx)
+	if vault == nil {
+		return callLLMChain(ctx, req, stream)
+	}
+	req, redacted := redactChatRequest(vault, req)
+	if !redacted {
+		return callLLMChain(ctx, req, stream)
+	}
+
+	var restorer *piiStreamRestorer
+	if stream != nil && stream.onDelta != nil {
+		restorer = &piiStreamRestorer{vault: vault, out: stream.onDelta}
+		onReset := stream.onReset
+		stream = &streamCallbacks{
+			onDelta: restorer.write,
+			onReset: func() {
+				restorer.reset()
+				if onReset != nil {
+					onReset()
+				}
+			},
+		}
+	}
+
+	resp, err := callLLMChain(ctx, req, stream)
+	if restorer != nil && err == nil {
+		restorer.flush()
+	}
+	if resp != nil {
+		resp.Content = vault.restore(resp.Content)
+	}
+	return resp, err
+}
+
+// callLLMChain выполняет запрос с повторами и переключением на запасные модели.
+// Повторяемые ошибки (429, 5xx, сетевые) повторяются с экспоненциальной задержкой
+// и джиттером, учитывая Retry-After; когда попытки исчерпаны или модель недоступна,
+// запрос уходит следующей модели цепочки. Ошибки авторизации и оплаты прерывают цепочку.
+func callLLMChain(ctx context.Context, req ChatRequest, stream *streamCallbacks) (*ChatResponse, error) {
+	maxRetries := envInt(defaultLLMMaxRetries, "LLM_MAX_RETRIES")
+	chain := llmModelChain()
+
+	var lastErr error
+	for i, model := range chain {
+		req.Model = model
+
+		for attempt := 0; attempt <= maxRetries; attempt++ {
+			if attempt > 0 {
+				delay := retryDelay(attempt, lastErr)
+				utils.LogWarning(fmt.Sprintf("Повтор запроса к %s через %v (попытка %d/%d): %v",
+					model, delay.Round(time.Millisecond), attempt+1, maxRetries+1, lastErr))
+				if err := sleepContext(ctx, delay); err != nil {
+					return nil, err
+				}
+			}
+
+			resp, err := callLLMOnce(ctx, req, stream)
+			if err == nil {
+				return resp, nil
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+
+			var llmErr *LLMError
+			if errors.As(err, &llmErr) {
+				if llmErr.Fatal() {
+					return nil, err
+				}
+				if !llmErr.Retryable() {
+					break
+				}
+			}
+		}
+
+		if i < len(chain)-1 {
+			utils.LogWarning(fmt.Sprintf("Модель %s недоступна, переключаемся на %s: %v", model, chain[i+1], lastErr))
+		}
+	}
+
+	return nil, fmt.Errorf("все модели недоступны: %w", lastErr)
+}
+
+func callLLMOnce(ctx context.Context, req ChatRequest, stream *streamCallbacks) (*ChatResponse, error) {
+	release, err := getProviderLimiter(llm.Name()).Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if stream == nil || stream.onDelta == nil {
+		resp, err := llm.Complete(ctx, req)
+		recordLLMUsage(ctx, req, resp, err)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	emitted := false
+	resp, err := llm.Stream(ctx, req, func(delta string) {
+		emitted = true
+		stream.onDelta(delta)
+	})
+	recordLLMUsage(ctx, req, resp, err)
+	if err != nil {
+		if emitted && stream.onReset != nil {
+			stream.onReset()
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// retryDelay — экспоненциальная задержка со случайным разбросом; Retry-After провайдера важнее
+func retryDelay(attempt int, err error) time.Duration {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) && llmErr.RetryAfter > 0 {
+		if llmErr.RetryAfter > maxRetryAfter {
+			return maxRetryAfter
+		}
+		return llmErr.RetryAfter
+	}
+
+	backoff := retryBaseDelay << (attempt - 1)
+	if backoff > retryMaxDelay || backoff <= 0 {
+		backoff = retryMaxDelay
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,140 @@
+// llm_usage.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Операции, по которым учитываются запросы к LLM
+const (
+	llmOpClassification = "classification"
+	llmOpAnalysis       = "analysis"
+	llmOpMerge          = "merge"
+	llmOpTerms          = "terms"
+	llmOpPlaybook       = "playbook"
+	llmOpChat           = "chat"
+	llmOpCompare        = "compare"
+	llmOpRedline        = "redline"
+)
+
+// usageScope — на кого записывается расход запросов к LLM в рамках ctx
+type usageScope struct {
+	userID         primitive.ObjectID
+	organizationID primitive.ObjectID
+	jobID          *primitive.ObjectID
+	analysisID     *primitive.ObjectID
+}
+
+type usageScopeKey struct{}
+type llmOperationKey struct{}
+
+// withUsageScope задаёт владельца расхода для всех запросов к LLM в рамках ctx
+func withUsageScope(ctx context.Context, scope usageScope) context.Context {
+	return context.WithValue(ctx, usageScopeKey{}, scope)
+}
+
+// withLLMOperation помечает запросы к LLM в рамках ctx операцией для отчёта о расходе
+func withLLMOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, llmOperationKey{}, operation)
+}
+
+// userUsageScope — расход запросов пользователя вне анализа документа
+func userUsageScope(userID primitive.ObjectID) usageScope {
+	scope := usageScope{userID: userID}
+	if organization := userOrganization(userID); organization != nil {
+		scope.organizationID = organization.ID
+	}
+	return scope
+}
+
+// analysisUsageScope — расход вопросов и правок по готовому анализу
+func analysisUsageScope(analysis *models.Analysis) usageScope {
+	return usageScope{userID: analysis.UserID, organizationID: analysis.OrganizationID, analysisID: &analysis.ID}
+}
+
+// recordLLMUsage сохраняет расход каждой попытки запроса, в том числе неудачной:
+// оборванный поток и повторы провайдер тоже считает. resp неудачной попытки —
+// полученная часть ответа или nil. Если провайдер расход не сообщил, токены
+// оцениваются по тексту запроса и ответа; попытка без ответа записывается без
+// токенов. Ошибка записи не мешает запросу: она только логируется.
+func recordLLMUsage(ctx context.Context, req ChatRequest, resp *ChatResponse, callErr error) {
+	model := req.Model
+	if model == "" {
+		model = llm.Model()
+	}
+
+	var usage models.TokenUsage
+	estimated := false
+	if resp != nil {
+		usage = resp.Usage
+		if usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+			for _, m := range req.Messages {
+				usage.PromptTokens += int64(llm.CountTokens(m.Content))
+			}
+			usage.CompletionTokens = int64(llm.CountTokens(resp.Content))
+			estimated = true
+		}
+		if resp.Model != "" {
+			model = resp.Model
+		}
+	}
+	usage.Calls = 1
+	if resp != nil {
+		resp.Usage = usage
+		resp.UsageEstimated = estimated
+	}
+
+	scope, _ := ctx.Value(usageScopeKey{}).(usageScope)
+	operation, _ := ctx.Value(llmOperationKey{}).(string)
+	call := &models.LLMCall{
+		UserID:         scope.userID,
+		OrganizationID: scope.organizationID,
+		JobID:          scope.jobID,
+		AnalysisID:     scope.analysisID,
+		Operation:      operation,
+		Provider:       llm.Name(),
+		Model:          model,
+		Status:         models.LLMCallSucceeded,
+		Estimated:      estimated,
+		TokenUsage:     usage,
+	}
+	if callErr != nil {
+		call.Status = models.LLMCallFailed
+		var llmErr *LLMError
+		if errors.As(callErr, &llmErr) {
+			call.StatusCode = llmErr.StatusCode
+		}
+	}
+	if err := repositories.SaveLLMCall(call); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось записать расход запроса к %s: %v", model, err))
+	}
+}
+
+// jobUsage — суммарный расход запросов задачи анализа; nil, если его не удалось посчитать
+func jobUsage(jobID primitive.ObjectID) *models.TokenUsage {
+	usage, err := repositories.SumLLMUsage(bson.M{"job_id": jobID})
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось посчитать расход задачи %s: %v", jobID.Hex(), err))
+		return nil
+	}
+	return &usage
+}
+
+// InitUsage создаёт индексы, по которым считаются расход и лимиты
+func InitUsage() {
+	if err := repositories.EnsureLLMUsageIndexes(); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось создать индексы журнала запросов к LLM: %v", err))
+	}
+	if err := repositories.EnsureAnalysisJobIndexes(); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось создать индексы задач анализа: %v", err))
+	}
+}
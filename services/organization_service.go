@@ -0,0 +1,150 @@
+// organization_service.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("организация не найдена")
+	ErrInvalidOrganization  = errors.New("некорректные данные организации")
+)
+
+func ListOrganizations() ([]models.Organization, error) {
+	return repositories.GetOrganizations()
+}
+
+func GetOrganization(id string) (*models.Organization, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	organization, err := repositories.GetOrganization(objID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrOrganizationNotFound
+	}
+	return organization, err
+}
+
+func CreateOrganization(organization models.Organization) (*models.Organization, error) {
+	if err := validateOrganization(&organization); err != nil {
+		return nil, err
+	}
+	if err := repositories.CreateOrganization(&organization); err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// UpdateOrganization заменяет название и настройки организации. Если настройки оценки
+// риска изменились, оценки уже сохранённых анализов организации пересчитываются;
+// возвращается число пересчитанных анализов.
+func UpdateOrganization(id string, organization models.Organization) (*models.Organization, int, error) {
+	existing, err := GetOrganization(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := validateOrganization(&organization); err != nil {
+		return nil, 0, err
+	}
+
+	err = repositories.UpdateOrganization(existing.ID, bson.M{
+		"name":           organization.Name,
+		"risk_policy":    organization.RiskPolicy,
+		"privacy_policy": organization.PrivacyPolicy,
+		"plan":           organization.Plan,
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, 0, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rescored := 0
+	if !reflect.DeepEqual(existing.RiskPolicy, organization.RiskPolicy) {
+		if rescored, err = RescoreOrganization(existing.ID); err != nil {
+			return nil, rescored, fmt.Errorf("настройки сохранены, но оценки не пересчитаны: %w", err)
+		}
+	}
+
+	updated, err := repositories.GetOrganization(existing.ID)
+	if err != nil {
+		return nil, rescored, err
+	}
+	return updated, rescored, nil
+}
+
+// RescoreOrganizationAnalyses пересчитывает оценки риска анализов организации
+func RescoreOrganizationAnalyses(id string) (int, error) {
+	organization, err := GetOrganization(id)
+	if err != nil {
+		return 0, err
+	}
+	return RescoreOrganization(organization.ID)
+}
+
+// AssignUserOrganization привязывает пользователя к организации. Оценки уже
+// сделанных анализов не меняются: они считались по настройкам прежней организации.
+func AssignUserOrganization(userID string, req models.AssignOrganizationRequest) error {
+	user, err := ValidateUser(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	organizationID := primitive.NilObjectID
+	if req.OrganizationID != "" {
+		organization, err := GetOrganization(req.OrganizationID)
+		if err != nil {
+			return err
+		}
+		organizationID = organization.ID
+	}
+
+	if err := repositories.SetUserOrganization(user.ID, organizationID); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	utils.LogSuccess(fmt.Sprintf("Пользователь %s привязан к организации %q", user.Email, req.OrganizationID))
+	return nil
+}
+
+// userOrganization — организация пользователя; nil, если её нет или она не загрузилась
+func userOrganization(userID primitive.ObjectID) *models.Organization {
+	user, err := ValidateUser(userID.Hex())
+	if err != nil || user.OrganizationID.IsZero() {
+		return nil
+	}
+	organization, err := repositories.GetOrganization(user.OrganizationID)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			utils.LogWarning(fmt.Sprintf("Не удалось загрузить организацию %s: %v", user.OrganizationID.Hex(), err))
+		}
+		return nil
+	}
+	return organization
+}
+
+func validateOrganization(organization *models.Organization) error {
+	organization.Name = strings.TrimSpace(organization.Name)
+	if organization.Name == "" {
+		return fmt.Errorf("%w: название не может быть пустым", ErrInvalidOrganization)
+	}
+	if err := validateUsagePlan(organization.Plan); err != nil {
+		return err
+	}
+	return validateRiskPolicy(organization.RiskPolicy)
+}
@@ -0,0 +1,208 @@
+// pii_redaction.go
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// piiPlaceholderRe — метки, которыми персональные данные заменяются в запросах к LLM
+var piiPlaceholderRe = regexp.MustCompile(`\[(IIN|BIN|IBAN|ID|EMAIL|PHONE|PERSON)_(\d+)\]`)
+
+// maxPIIPlaceholderLen — длина самой длинной метки с запасом: столько байт
+// потокового ответа придерживается, пока метка не закроется
+const maxPIIPlaceholderLen = 24
+
+const piiSystemNote = "\n\nВ тексте персональные данные заменены метками вида [PERSON_1], [IIN_1]. " +
+	"Оставляй такие метки в ответе без изменений, в том числе в цитатах, и не пытайся угадать скрытые значения."
+
+// piiVault хранит соответствие меток и исходных значений. Одно значение всегда
+// получает одну и ту же метку, поэтому части документа и сводка, отправленные
+// разными запросами, ссылаются на одни и те же метки.
+type piiVault struct {
+	mu            sync.Mutex
+	byValue       map[string]string
+	byPlaceholder map[string]string
+	counters      map[utils.PIIKind]int
+}
+
+func newPIIVault() *piiVault {
+	return &piiVault{
+		byValue:       make(map[string]string),
+		byPlaceholder: make(map[string]string),
+		counters:      make(map[utils.PIIKind]int),
+	}
+}
+
+// redact заменяет найденные персональные данные метками; false — заменять нечего
+func (v *piiVault) redact(text string) (string, bool) {
+	matches := utils.DetectPII(text)
+	if len(matches) == 0 {
+		return text, false
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var b strings.Builder
+	b.Grow(len(text))
+	pos := 0
+	for _, m := range matches {
+		b.WriteString(text[pos:m.Start])
+		b.WriteString(v.placeholder(m.Kind, m.Value))
+		pos = m.End
+	}
+	b.WriteString(text[pos:])
+	return b.String(), true
+}
+
+// placeholder возвращает метку значения, заводя новую при первой встрече; вызывается под v.mu
+func (v *piiVault) placeholder(kind utils.PIIKind, value string) string {
+	key := string(kind) + "\x00" + value
+	if p, ok := v.byValue[key]; ok {
+		return p
+	}
+	v.counters[kind]++
+	p := "[" + string(kind) + "_" + strconv.Itoa(v.counters[kind]) + "]"
+	v.byValue[key] = p
+	v.byPlaceholder[p] = value
+	return p
+}
+
+// restore подставляет исходные значения вместо меток; незнакомые метки остаются как есть
+func (v *piiVault) restore(text string) string {
+	if !strings.Contains(text, "[") {
+		return text
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return piiPlaceholderRe.ReplaceAllStringFunc(text, func(p string) string {
+		if value, ok := v.byPlaceholder[p]; ok {
+			return value
+		}
+		return p
+	})
+}
+
+type piiVaultKey struct{}
+
+// withPIIVault задаёт хранилище меток для всех запросов к LLM в рамках ctx
+func withPIIVault(ctx context.Context, v *piiVault) context.Context {
+	return context.WithValue(ctx, piiVaultKey{}, v)
+}
+
+// piiVaultFrom возвращает хранилище меток из ctx, а если его нет — новое на один
+// запрос. nil означает, что маскирование выключено (PII_REDACTION=false).
+func piiVaultFrom(ctx context.Context) *piiVault {
+	if enabled, err := strconv.ParseBool(os.Getenv("PII_REDACTION")); err == nil && !enabled {
+		return nil
+	}
+	if v, ok := ctx.Value(piiVaultKey{}).(*piiVault); ok && v != nil {
+		return v
+	}
+	return newPIIVault()
+}
+
+// redactPII маскирует текст, который уходит внешнему сервису, но ответ которого
+// не содержит текста (например, запрос эмбеддингов)
+func redactPII(ctx context.Context, text string) string {
+	v := piiVaultFrom(ctx)
+	if v == nil {
+		return text
+	}
+	redacted, _ := v.redact(text)
+	return redacted
+}
+
+// redactChatRequest маскирует копии сообщений запроса и добавляет к системному
+// сообщению указание сохранять метки; false — персональных данных не найдено
+func redactChatRequest(v *piiVault, req ChatRequest) (ChatRequest, bool) {
+	messages := make([]ChatMessage, len(req.Messages))
+	redacted := false
+	for i, msg := range req.Messages {
+		content, ok := v.redact(msg.Content)
+		messages[i] = ChatMessage{Role: msg.Role, Content: content}
+		redacted = redacted || ok
+	}
+	if !redacted {
+		return req, false
+	}
+
+	if len(messages) > 0 && messages[0].Role == "system" {
+		messages[0].Content += piiSystemNote
+	} else {
+		messages = append([]ChatMessage{{Role: "system", Content: strings.TrimSpace(piiSystemNote)}}, messages...)
+	}
+	req.Messages = messages
+	return req, true
+}
+
+// piiStreamRestorer подставляет исходные значения в потоковый ответ. Метка может
+// прийти разрезанной между фрагментами, поэтому незакрытый хвост вида «[PERS»
+// придерживается до следующего фрагмента.
+type piiStreamRestorer struct {
+	vault   *piiVault
+	out     func(string)
+	pending string
+}
+
+func (r *piiStreamRestorer) write(delta string) {
+	text := r.pending + delta
+	r.pending = ""
+	if i := strings.LastIndexByte(text, '['); i >= 0 && !strings.ContainsRune(text[i:], ']') && len(text)-i < maxPIIPlaceholderLen {
+		r.pending = text[i:]
+		text = text[:i]
+	}
+	if text != "" {
+		r.out(r.vault.restore(text))
+	}
+}
+
+// flush отдаёт придержанный хвост в конце ответа
+func (r *piiStreamRestorer) flush() {
+	if r.pending != "" {
+		r.out(r.vault.restore(r.pending))
+		r.pending = ""
+	}
+}
+
+func (r *piiStreamRestorer) reset() {
+	r.pending = ""
+}
+
+// storeRedactedText сообщает, хранить ли текст документа со скрытыми
+// персональными данными: так решает организация, а без её настроек — PII_STORE_REDACTED
+func storeRedactedText(organization *models.Organization) bool {
+	if organization != nil && organization.PrivacyPolicy != nil {
+		return organization.PrivacyPolicy.StoreRedactedText
+	}
+	store, _ := strconv.ParseBool(os.Getenv("PII_STORE_REDACTED"))
+	return store
+}
+
+// maskStoredJobText скрывает персональные данные в тексте завершённой задачи,
+// если так требуют настройки организации: после завершения текст задачи не нужен
+func maskStoredJobText(job *models.AnalysisJob) {
+	if job.Text == "" || !storeRedactedText(userOrganization(job.UserID)) {
+		return
+	}
+	masked := utils.MaskPII(job.Text)
+	if masked == job.Text {
+		return
+	}
+	if err := repositories.UpdateAnalysisJob(job.ID, map[string]interface{}{"text": masked}); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось скрыть персональные данные в задаче %s: %v", job.ID.Hex(), err))
+		return
+	}
+	job.Text = masked
+}
@@ -0,0 +1,270 @@
+// playbook_check.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"strings"
+	"sync"
+)
+
+const (
+	// playbookRulesPerCall — сколько правил проверяется одним запросом: каждой
+	// группе подбирается своя выдержка из документа
+	playbookRulesPerCall = 8
+	// playbookPromptTokens — запас на инструкцию, правила и ответ
+	playbookPromptTokens = 2500
+)
+
+const playbookCheckSchema = `{
+  "rules": [
+    {
+      "id": "id правила из списка",
+      "status": "met | not_met | not_addressed",
+      "evidence": ["точная цитата из текста документа"],
+      "explanation": "почему условие документа соответствует или не соответствует правилу"
+    }
+  ]
+}`
+
+type rawPlaybookCheck struct {
+	Rules []struct {
+		ID          string   `json:"id"`
+		Status      string   `json:"status"`
+		Evidence    []string `json:"evidence"`
+		Explanation string   `json:"explanation"`
+	} `json:"rules"`
+}
+
+// playbookRuleRef — правило вместе с набором, из которого оно взято
+type playbookRuleRef struct {
+	playbook *models.Playbook
+	rule     models.PlaybookRule
+}
+
+// key уникален среди правил всех наборов, проверяемых одним запросом
+func (r playbookRuleRef) key() string {
+	return r.playbook.ID.Hex() + ":" + r.rule.ID
+}
+
+// applicablePlaybookRules отбирает из действующих наборов организации правила,
+// которые относятся к типу документа
+func applicablePlaybookRules(playbooks []models.Playbook, classification *models.DocumentClassification) []playbookRuleRef {
+	var refs []playbookRuleRef
+	for i := range playbooks {
+		playbook := &playbooks[i]
+		if !documentTypeMatches(playbook.DocumentTypes, classification) {
+			continue
+		}
+		for _, rule := range playbook.Rules {
+			if documentTypeMatches(rule.DocumentTypes, classification) {
+				refs = append(refs, playbookRuleRef{playbook: playbook, rule: rule})
+			}
+		}
+	}
+	return refs
+}
+
+// documentTypeMatches сравнивает список типов с кодом и названием типа
+// документа без учёта регистра; пустой список подходит любому документу
+func documentTypeMatches(types []string, classification *models.DocumentClassification) bool {
+	if len(types) == 0 {
+		return true
+	}
+	if classification == nil {
+		return false
+	}
+	for _, t := range types {
+		if strings.EqualFold(t, classification.Code) || strings.EqualFold(t, classification.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPlaybooks проверяет документ по действующим наборам правил организации.
+// Правила проверяются группами параллельно; ошибка одной группы не мешает
+// остальным, её правила попадают в результат со статусом «не проверено».
+func checkPlaybooks(ctx context.Context, organization *models.Organization, text string, classification *models.DocumentClassification, language string) []models.PlaybookCheck {
+	if organization == nil {
+		return nil
+	}
+	playbooks, err := repositories.GetOrganizationPlaybooks(organization.ID, true)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось загрузить наборы правил организации %s: %v", organization.Name, err))
+		return nil
+	}
+	refs := applicablePlaybookRules(playbooks, classification)
+	if len(refs) == 0 {
+		return nil
+	}
+	utils.LogAction(fmt.Sprintf("Проверка по правилам организации %s: %d правил", organization.Name, len(refs)))
+
+	var groups [][]playbookRuleRef
+	for start := 0; start < len(refs); start += playbookRulesPerCall {
+		groups = append(groups, refs[start:min(start+playbookRulesPerCall, len(refs))])
+	}
+
+	results := make([][]models.PlaybookCheck, len(groups))
+	sem := make(chan struct{}, envInt(defaultPartConcurrency, "ANALYSIS_PART_CONCURRENCY"))
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			checks, err := checkPlaybookRules(ctx, text, group, language)
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("Не удалось проверить группу правил %d/%d: %v", i+1, len(groups), err))
+				checks = make([]models.PlaybookCheck, len(group))
+				for j, ref := range group {
+					checks[j] = notCheckedPlaybookRule(ref, reportTextFor(language).ruleCheckFailed, err.Error())
+				}
+			}
+			results[i] = checks
+		}()
+	}
+	wg.Wait()
+
+	var checks []models.PlaybookCheck
+	checked := 0
+	for _, group := range results {
+		for _, check := range group {
+			if check.Status != models.RuleNotChecked {
+				checked++
+			}
+		}
+		checks = append(checks, group...)
+	}
+	utils.LogSuccess(fmt.Sprintf("Проверено правил организации: %d из %d", checked, len(refs)))
+	return checks
+}
+
+// checkPlaybookRules проверяет группу правил по выдержке из документа,
+// подобранной по словам этих правил
+func checkPlaybookRules(ctx context.Context, text string, refs []playbookRuleRef, language string) ([]models.PlaybookCheck, error) {
+	type promptRule struct {
+		ID       string `json:"id"`
+		Rule     string `json:"rule"`
+		Expected string `json:"expected,omitempty"`
+	}
+	rules := make([]promptRule, len(refs))
+	var query strings.Builder
+	for i, ref := range refs {
+		rules[i] = promptRule{ID: ref.key(), Rule: ref.rule.Rule, Expected: ref.rule.Expected}
+		fmt.Fprintf(&query, "%s %s %s\n", ref.rule.Title, ref.rule.Rule, ref.rule.Expected)
+	}
+	rulesJSON, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	budget := max(envInt(defaultContextTokens, "LLM_CONTEXT_TOKENS")-envInt(defaultLLMMaxTokens, "LLM_MAX_TOKENS")-playbookPromptTokens, minPartTokens)
+	prompt := fmt.Sprintf(`Проверь юридический документ на соответствие внутренним правилам организации.
+Для каждого правила из списка определи status:
+- met — условие документа соответствует правилу и ожидаемой позиции (expected);
+- not_met — условие есть, но противоречит правилу или отличается от ожидаемой позиции;
+- not_addressed — в документе нет условия, к которому относится правило.
+В evidence приведи точные цитаты из текста без изменений и сокращений, не длиннее одного предложения каждая; для not_addressed evidence оставь пустым.
+Верни результат для каждого правила из списка, id копируй без изменений.
+%s Значения id, status и evidence не переводи.
+
+Ответ верни строго в формате JSON по схеме ниже, без markdown и пояснений вне JSON:
+%s
+
+Правила:
+%s
+
+Текст документа (фрагменты, пропуски обозначены «…»):
+%s`, languageInstruction(language), playbookCheckSchema, rulesJSON, questionExcerpt(text, query.String(), budget))
+
+	resp, err := queryLLM(withLLMOperation(ctx, llmOpPlaybook), prompt)
+	if err != nil {
+		return nil, err
+	}
+	var raw rawPlaybookCheck
+	if err := unmarshalModelJSON(resp.Content, &raw); err != nil {
+		return nil, err
+	}
+	return buildPlaybookChecks(text, refs, &raw, language), nil
+}
+
+// buildPlaybookChecks сопоставляет ответ модели с правилами. Правила, которых
+// нет в ответе или у которых неизвестный статус, помечаются «не проверено»:
+// это не то же самое, что «не урегулировано».
+func buildPlaybookChecks(text string, refs []playbookRuleRef, raw *rawPlaybookCheck, language string) []models.PlaybookCheck {
+	byKey := make(map[string]int, len(raw.Rules))
+	for i, r := range raw.Rules {
+		byKey[strings.TrimSpace(r.ID)] = i
+	}
+
+	checks := make([]models.PlaybookCheck, 0, len(refs))
+	for _, ref := range refs {
+		i, ok := byKey[ref.key()]
+		if !ok {
+			checks = append(checks, notCheckedPlaybookRule(ref, reportTextFor(language).ruleNoAnswer, "правило отсутствует в ответе модели"))
+			continue
+		}
+		answer := raw.Rules[i]
+		status, ok := normalizeRuleStatus(answer.Status)
+		if !ok {
+			checks = append(checks, notCheckedPlaybookRule(ref, reportTextFor(language).ruleNoAnswer,
+				fmt.Sprintf("неизвестный статус %q в ответе модели", answer.Status)))
+			continue
+		}
+
+		check := newPlaybookCheck(ref, status)
+		check.Explanation = strings.TrimSpace(answer.Explanation)
+		if status != models.RuleNotAddressed {
+			for _, quote := range answer.Evidence {
+				if span := locateQuote(text, quote); span != nil {
+					check.Evidence = append(check.Evidence, *span)
+				}
+			}
+		}
+		if status != models.RuleMet {
+			check.Fallback = ref.rule.Fallback
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func newPlaybookCheck(ref playbookRuleRef, status models.RuleStatus) models.PlaybookCheck {
+	return models.PlaybookCheck{
+		PlaybookID: ref.playbook.ID,
+		Playbook:   ref.playbook.Name,
+		RuleID:     ref.rule.ID,
+		Title:      ref.rule.Title,
+		Severity:   ref.rule.Severity,
+		Status:     status,
+	}
+}
+
+// notCheckedPlaybookRule — результат по правилу, которое не удалось проверить:
+// explanation — пояснение на языке отчёта, reason — техническая причина
+func notCheckedPlaybookRule(ref playbookRuleRef, explanation, reason string) models.PlaybookCheck {
+	check := newPlaybookCheck(ref, models.RuleNotChecked)
+	check.Explanation = explanation
+	check.Error = reason
+	return check
+}
+
+func normalizeRuleStatus(status string) (models.RuleStatus, bool) {
+	switch strings.ToLower(strings.Join(strings.FieldsFunc(status, func(r rune) bool { return r == ' ' || r == '_' || r == '-' }), "_")) {
+	case "met", "compliant", "соблюдено":
+		return models.RuleMet, true
+	case "not_met", "non_compliant", "violated", "не_соблюдено":
+		return models.RuleNotMet, true
+	case "not_addressed", "absent", "missing", "не_урегулировано":
+		return models.RuleNotAddressed, true
+	}
+	return "", false
+}
@@ -0,0 +1,206 @@
+// playbook_service.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxPlaybookRules     = 50
+	maxPlaybookRuleRunes = 2000
+)
+
+var (
+	ErrPlaybookNotFound = errors.New("набор правил не найден")
+	ErrInvalidPlaybook  = errors.New("некорректный набор правил")
+)
+
+// ListOrganizationPlaybooks возвращает все наборы правил организации, включая отключённые
+func ListOrganizationPlaybooks(organizationID string) ([]models.Playbook, error) {
+	organization, err := GetOrganization(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return repositories.GetOrganizationPlaybooks(organization.ID, false)
+}
+
+// ListUserPlaybooks возвращает действующие наборы правил организации пользователя
+func ListUserPlaybooks(userID string) ([]models.Playbook, error) {
+	user, err := ValidateUser(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.OrganizationID.IsZero() {
+		return []models.Playbook{}, nil
+	}
+	return repositories.GetOrganizationPlaybooks(user.OrganizationID, true)
+}
+
+func GetPlaybook(id string) (*models.Playbook, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPlaybookNotFound
+	}
+	playbook, err := repositories.GetPlaybook(objID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrPlaybookNotFound
+	}
+	return playbook, err
+}
+
+func CreatePlaybook(organizationID string, playbook models.Playbook) (*models.Playbook, error) {
+	organization, err := GetOrganization(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePlaybook(&playbook, nil); err != nil {
+		return nil, err
+	}
+
+	playbook.ID = primitive.NilObjectID
+	playbook.OrganizationID = organization.ID
+	if err := repositories.CreatePlaybook(&playbook); err != nil {
+		return nil, err
+	}
+	utils.LogSuccess(fmt.Sprintf("Организация %s: добавлен набор правил %q (%d правил)", organization.Name, playbook.Name, len(playbook.Rules)))
+	return &playbook, nil
+}
+
+// UpdatePlaybook заменяет набор правил целиком. Уже сохранённые анализы не
+// перепроверяются: новые правила применяются к следующим анализам.
+func UpdatePlaybook(id string, playbook models.Playbook) (*models.Playbook, error) {
+	existing, err := GetPlaybook(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePlaybook(&playbook, existing.Rules); err != nil {
+		return nil, err
+	}
+
+	err = repositories.UpdatePlaybook(existing.ID, bson.M{
+		"name":           playbook.Name,
+		"description":    playbook.Description,
+		"document_types": playbook.DocumentTypes,
+		"disabled":       playbook.Disabled,
+		"rules":          playbook.Rules,
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrPlaybookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return repositories.GetPlaybook(existing.ID)
+}
+
+func DeletePlaybook(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrPlaybookNotFound
+	}
+	if err := repositories.DeletePlaybook(objID); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrPlaybookNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// validatePlaybook нормализует набор: обрезает пробелы, назначает ID правилам
+// без него и важность medium правилам без важности. ID назначается один раз:
+// правило без ID, текст которого совпадает с сохранённым правилом из previous,
+// получает его ID, иначе — новый, не зависящий от позиции в наборе.
+func validatePlaybook(playbook *models.Playbook, previous []models.PlaybookRule) error {
+	playbook.Name = strings.TrimSpace(playbook.Name)
+	playbook.Description = strings.TrimSpace(playbook.Description)
+	if playbook.Name == "" {
+		return fmt.Errorf("%w: название не может быть пустым", ErrInvalidPlaybook)
+	}
+	if len(playbook.Rules) == 0 {
+		return fmt.Errorf("%w: нужно хотя бы одно правило", ErrInvalidPlaybook)
+	}
+	if len(playbook.Rules) > maxPlaybookRules {
+		return fmt.Errorf("%w: не больше %d правил в наборе", ErrInvalidPlaybook, maxPlaybookRules)
+	}
+	playbook.DocumentTypes = normalizeDocumentTypes(playbook.DocumentTypes)
+
+	// ID, заданные в запросе, не переходят к другим правилам
+	explicit := make(map[string]bool, len(playbook.Rules))
+	for _, rule := range playbook.Rules {
+		explicit[strings.TrimSpace(rule.ID)] = true
+	}
+	previousIDs := make(map[string]string, len(previous))
+	for _, rule := range previous {
+		if !explicit[rule.ID] {
+			previousIDs[strings.TrimSpace(rule.Rule)] = rule.ID
+		}
+	}
+
+	ids := make(map[string]bool, len(playbook.Rules))
+	for i := range playbook.Rules {
+		rule := &playbook.Rules[i]
+		rule.ID = strings.TrimSpace(rule.ID)
+		rule.Title = strings.TrimSpace(rule.Title)
+		rule.Rule = strings.TrimSpace(rule.Rule)
+		rule.Expected = strings.TrimSpace(rule.Expected)
+		rule.Fallback = strings.TrimSpace(rule.Fallback)
+		rule.DocumentTypes = normalizeDocumentTypes(rule.DocumentTypes)
+
+		if rule.Rule == "" {
+			return fmt.Errorf("%w: правило %d без текста", ErrInvalidPlaybook, i+1)
+		}
+		if len([]rune(rule.Rule))+len([]rune(rule.Expected))+len([]rune(rule.Fallback)) > maxPlaybookRuleRunes {
+			return fmt.Errorf("%w: правило %d длиннее %d символов", ErrInvalidPlaybook, i+1, maxPlaybookRuleRunes)
+		}
+		if rule.Title == "" {
+			rule.Title = truncateRunes(rule.Rule, chatThreadTitleRunes)
+		}
+		switch {
+		case rule.Severity == "":
+			rule.Severity = models.SeverityMedium
+		case !rule.Severity.IsValid():
+			return fmt.Errorf("%w: неизвестная важность %q в правиле %d", ErrInvalidPlaybook, rule.Severity, i+1)
+		}
+		if rule.ID == "" {
+			if id, ok := previousIDs[rule.Rule]; ok {
+				rule.ID = id
+				delete(previousIDs, rule.Rule)
+			} else {
+				rule.ID = primitive.NewObjectID().Hex()
+			}
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("%w: повторяется ID правила %q", ErrInvalidPlaybook, rule.ID)
+		}
+		ids[rule.ID] = true
+	}
+	return nil
+}
+
+func normalizeDocumentTypes(types []string) []string {
+	var out []string
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// InitPlaybooks создаёт индексы наборов правил
+func InitPlaybooks() {
+	if err := repositories.EnsurePlaybookIndexes(); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось создать индексы наборов правил: %v", err))
+	}
+}
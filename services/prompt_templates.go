@@ -0,0 +1,603 @@
+// prompt_templates.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	promptTemplatesTTL = time.Minute
+	// promptTextMarker — текст документа при проверке шаблона: шаблон без
+	// {{.Text}} отправил бы модели промпт без документа
+	promptTextMarker = "<<ТЕКСТ ДОКУМЕНТА>>"
+)
+
+var (
+	ErrPromptTemplateNotFound = errors.New("шаблон промпта не найден")
+	ErrInvalidPromptTemplate  = errors.New("некорректный шаблон промпта")
+	ErrPromptTemplateActive   = errors.New("активную версию шаблона нельзя удалить")
+	ErrNoPreviousPrompt       = errors.New("нет предыдущей версии шаблона для отката")
+
+	promptTemplateCache    []models.PromptTemplate
+	promptTemplateLoadedAt time.Time
+	promptTemplateMutex    sync.Mutex
+
+	promptFuncs = template.FuncMap{"join": strings.Join}
+
+	// defaultJurisdictions подставляются в промпт после слова «законодательству»
+	// и его аналогов, поэтому русская и казахская формы — в родительном падеже
+	defaultJurisdictions = map[string]string{
+		LanguageRussian: "Республики Казахстан",
+		LanguageKazakh:  "Қазақстан Республикасының",
+		LanguageEnglish: "the Republic of Kazakhstan",
+	}
+)
+
+// builtinPromptTemplates используются, пока администратор не активировал ни одного
+// подходящего шаблона; ключ — язык отчёта
+var builtinPromptTemplates = map[string]*models.PromptTemplate{
+	LanguageRussian: {
+		Language: LanguageRussian,
+		Active:   true,
+		System:   `Ты — юридический эксперт по законодательству {{.Jurisdiction}}. Анализируй документы и давай развернутые ответы с конкретными ссылками на законы.`,
+		Body: `Проанализируй следующий юридический документ на соответствие законодательству {{.Jurisdiction}}.
+{{if .DocumentType}}Тип документа: {{.DocumentType}}{{if .Subtypes}} ({{join .Subtypes ", "}}){{end}}.
+{{end}}
+Найди:
+- правовые риски (kind "risk");
+- неясные формулировки (kind "ambiguity") — в description укажи, в чём неясность, в recommendation — как переформулировать;
+- возможные нарушения (kind "violation") — в description укажи также возможные санкции.
+
+Для каждого пункта укажи нормативный акт (закон/статья), уровень риска и рекомендацию по исправлению.
+Отдельно перечисли конкретные рекомендации по исправлению документа и дай общее заключение.
+
+Ответ верни строго в формате JSON по схеме ниже, без markdown и пояснений вне JSON:
+{{.Schema}}
+{{if .LawContext}}
+Ниже — фрагменты законодательства из базы, подобранные к этой части документа.
+Опирайся в первую очередь на них: в legal_basis называй акт и статью, а в citations
+перечисли идентификаторы фрагментов (в квадратных скобках), которые подтверждают вывод.
+Не придумывай идентификаторы; если вывод опирается на норму, которой нет в списке,
+оставь citations пустым.
+
+{{.LawContext}}
+{{end}}{{if .PreviousContext}}
+Конец предыдущей части приведён только для связности — не анализируй его отдельно:
+"""
+{{.PreviousContext}}
+"""
+{{end}}
+Документ:
+{{.Text}}`,
+	},
+	LanguageKazakh: {
+		Language: LanguageKazakh,
+		Active:   true,
+		System:   `Сен — Қазақстан заңнамасы жөніндегі заң сарапшысысың. Құжаттарды талдап, заңдарға нақты сілтемелермен толық жауап бер. Құқықтық бағалау {{.Jurisdiction}} заңнамасына сәйкес жүргізіледі.`,
+		Body: `Төмендегі заңдық құжатты {{.Jurisdiction}} заңнамасына сәйкестігі тұрғысынан талда.
+{{if .DocumentType}}Құжат түрі: {{.DocumentType}}{{if .Subtypes}} ({{join .Subtypes ", "}}){{end}}.
+{{end}}
+Мыналарды тап:
+- құқықтық тәуекелдер (kind "risk");
+- түсініксіз тұжырымдар (kind "ambiguity") — description өрісінде неге түсініксіз екенін, recommendation өрісінде қалай қайта тұжырымдау керегін көрсет;
+- ықтимал бұзушылықтар (kind "violation") — description өрісінде ықтимал санкцияларды да көрсет.
+
+Әр тармақ үшін нормативтік актіні (заң/бап), тәуекел деңгейін және түзету жөніндегі ұсынысты көрсет.
+Құжатты түзету жөніндегі нақты ұсыныстарды бөлек тізіп, жалпы қорытынды бер.
+
+Жауапты markdown-сыз және JSON-нан тыс түсініктемесіз, төмендегі схема бойынша қатаң JSON форматында қайтар:
+{{.Schema}}
+{{if .LawContext}}
+Төменде — құжаттың осы бөлігіне іріктелген, базадағы заңнама үзінділері.
+Ең алдымен соларға сүйен: legal_basis өрісінде акт пен бапты атап, citations өрісінде
+қорытындыны растайтын үзінділердің идентификаторларын (төртбұрышты жақшадағы) тізіп шық.
+Идентификаторларды ойдан шығарма; егер қорытынды тізімде жоқ нормаға сүйенсе,
+citations өрісін бос қалдыр.
+
+{{.LawContext}}
+{{end}}{{if .PreviousContext}}
+Алдыңғы бөліктің соңы тек байланыс үшін берілген — оны бөлек талдама:
+"""
+{{.PreviousContext}}
+"""
+{{end}}
+Құжат:
+{{.Text}}`,
+	},
+	LanguageEnglish: {
+		Language: LanguageEnglish,
+		Active:   true,
+		System:   `You are a legal expert in the law of Kazakhstan. Analyse documents and give detailed answers with specific references to legislation. The legal assessment follows the law of {{.Jurisdiction}}.`,
+		Body: `Analyse the following legal document for compliance with the law of {{.Jurisdiction}}.
+{{if .DocumentType}}Document type: {{.DocumentType}}{{if .Subtypes}} ({{join .Subtypes ", "}}){{end}}.
+{{end}}
+Find:
+- legal risks (kind "risk");
+- ambiguous wording (kind "ambiguity") — explain the ambiguity in description and how to rephrase it in recommendation;
+- potential violations (kind "violation") — also state the possible sanctions in description.
+
+For each item give the legal act (law/article), the risk level and a recommended fix.
+Separately list specific recommendations for fixing the document and give an overall conclusion.
+
+Return the answer strictly as JSON following the schema below, without markdown or explanations outside the JSON:
+{{.Schema}}
+{{if .LawContext}}
+Below are fragments of legislation from the database selected for this part of the document.
+Rely on them first: name the act and article in legal_basis, and list in citations the
+identifiers of the fragments (in square brackets) that support the finding.
+Do not invent identifiers; if a finding relies on a provision that is not in the list,
+leave citations empty.
+
+{{.LawContext}}
+{{end}}{{if .PreviousContext}}
+The end of the previous part is given only for continuity — do not analyse it separately:
+"""
+{{.PreviousContext}}
+"""
+{{end}}
+Document:
+{{.Text}}`,
+	},
+}
+
+// builtinReducePromptTemplates — встроенные шаблоны сведения результатов частей
+// в единый отчёт; в {{.Text}} подставляются результаты частей в JSON
+var builtinReducePromptTemplates = map[string]*models.PromptTemplate{
+	LanguageRussian: {
+		Purpose:  models.PromptPurposeReduce,
+		Language: LanguageRussian,
+		Active:   true,
+		System:   `Ты — юридический эксперт по законодательству {{.Jurisdiction}}. Сводишь результаты анализа частей документа в единый отчёт.`,
+		Body: `Ниже — результаты независимого анализа частей одного юридического документа.
+Каждая часть анализировалась без знания остальных, поэтому выводы могут дублироваться или противоречить друг другу.
+
+Сведи их в единый отчёт по документу:
+- объедини повторяющиеся и пересекающиеся выводы в один;
+- устрани противоречия между частями;
+- учти термины из defined_terms: термин, определённый в одной части, может использоваться в другой;
+  сними выводы о неясности, если определение есть в другой части;
+- сформируй одно заключение по всему документу и общий уровень риска.
+
+Для каждого итогового вывода перечисли в sources идентификаторы исходных выводов (поле id).
+
+Ответ верни строго в формате JSON по схеме ниже, без markdown и пояснений вне JSON:
+{{.Schema}}
+
+Результаты частей:
+{{.Text}}`,
+	},
+	LanguageKazakh: {
+		Purpose:  models.PromptPurposeReduce,
+		Language: LanguageKazakh,
+		Active:   true,
+		System:   `Сен — Қазақстан заңнамасы жөніндегі заң сарапшысысың. Құжат бөліктерін талдау нәтижелерін бірыңғай есепке біріктіресің. Құқықтық бағалау {{.Jurisdiction}} заңнамасына сәйкес жүргізіледі.`,
+		Body: `Төменде — бір заңдық құжаттың бөліктерін бір-біріне тәуелсіз талдау нәтижелері.
+Әр бөлік қалғандарын білмей талданған, сондықтан қорытындылар қайталануы немесе бір-біріне қайшы келуі мүмкін.
+
+Оларды құжат бойынша бірыңғай есепке біріктір:
+- қайталанатын және қиылысатын қорытындыларды біреуге біріктір;
+- бөліктер арасындағы қайшылықтарды жой;
+- defined_terms өрісіндегі терминдерді ескер: бір бөлікте анықталған термин басқа бөлікте қолданылуы мүмкін;
+  анықтамасы басқа бөлікте болса, түсініксіздік туралы қорытындыларды алып таста;
+- бүкіл құжат бойынша бір қорытынды және жалпы тәуекел деңгейін бер.
+
+Әр қорытынды үшін sources өрісінде бастапқы қорытындылардың идентификаторларын (id өрісі) тізіп шық.
+
+Жауапты markdown-сыз және JSON-нан тыс түсініктемесіз, төмендегі схема бойынша қатаң JSON форматында қайтар:
+{{.Schema}}
+
+Бөліктердің нәтижелері:
+{{.Text}}`,
+	},
+	LanguageEnglish: {
+		Purpose:  models.PromptPurposeReduce,
+		Language: LanguageEnglish,
+		Active:   true,
+		System:   `You are a legal expert in the law of Kazakhstan. You combine the results of analysing parts of a document into a single report. The legal assessment follows the law of {{.Jurisdiction}}.`,
+		Body: `Below are the results of independent analysis of the parts of a single legal document.
+Each part was analysed without knowledge of the others, so findings may be duplicated or contradict each other.
+
+Combine them into a single report on the document:
+- merge repeated and overlapping findings into one;
+- resolve contradictions between the parts;
+- take defined_terms into account: a term defined in one part may be used in another;
+  drop ambiguity findings if the definition is given in another part;
+- give one conclusion for the whole document and the overall risk level.
+
+For each resulting finding list in sources the identifiers of the original findings (the id field).
+
+Return the answer strictly as JSON following the schema below, without markdown or explanations outside the JSON:
+{{.Schema}}
+
+Results of the parts:
+{{.Text}}`,
+	},
+}
+
+// builtinPromptTemplate — встроенный шаблон для назначения и языка отчёта
+func builtinPromptTemplate(purpose, language string) *models.PromptTemplate {
+	builtin := builtinPromptTemplates
+	if purpose == models.PromptPurposeReduce {
+		builtin = builtinReducePromptTemplates
+	}
+	if tmpl, ok := builtin[language]; ok {
+		return tmpl
+	}
+	return builtin[defaultLanguage]
+}
+
+// PromptData — переменные, доступные в шаблоне промпта
+type PromptData struct {
+	// Text — текст части документа, а в шаблоне сведения — результаты частей в JSON
+	Text string
+	// PreviousContext — конец предыдущей части (перекрытие)
+	PreviousContext string
+	// LawContext — подобранные фрагменты законов с идентификаторами для citations
+	LawContext   string
+	Jurisdiction string
+	// DocumentType и Subtypes — названия типа и подтипов, DocumentCode — код типа
+	DocumentType string
+	DocumentCode string
+	Subtypes     []string
+	// Language — язык отчёта, SourceLanguages — языки самого документа
+	Language        string
+	SourceLanguages []string
+	// Schema — JSON-схема ответа
+	Schema string
+}
+
+// analysisPrompt — шаблон и общие переменные промпта задачи
+type analysisPrompt struct {
+	template *models.PromptTemplate
+	data     PromptData
+}
+
+// analysisJurisdiction — юрисдикция анализа в форме языка промпта. Переопределяется
+// ANALYSIS_JURISDICTION_KK, ANALYSIS_JURISDICTION_EN и ANALYSIS_JURISDICTION (русский)
+func analysisJurisdiction(language string) string {
+	if !IsSupportedLanguage(language) {
+		language = defaultLanguage
+	}
+	names := []string{"ANALYSIS_JURISDICTION_" + strings.ToUpper(language)}
+	if language == LanguageRussian {
+		names = append(names, "ANALYSIS_JURISDICTION")
+	}
+	for _, name := range names {
+		if j := strings.TrimSpace(os.Getenv(name)); j != "" {
+			return j
+		}
+	}
+	return defaultJurisdictions[language]
+}
+
+// newPromptData заполняет переменные шаблона, общие для всех частей документа
+func newPromptData(classification *models.DocumentClassification, language string) PromptData {
+	data := PromptData{
+		Jurisdiction: analysisJurisdiction(language),
+		Language:     language,
+		Schema:       analysisSchemaFor(language),
+	}
+	if classification != nil {
+		data.SourceLanguages = classification.Languages
+	}
+	if classification != nil && classification.Code != unknownDocumentCode {
+		data.DocumentType = classification.Type
+		data.DocumentCode = classification.Code
+		for _, s := range classification.Subtypes {
+			data.Subtypes = append(data.Subtypes, s.Name)
+		}
+	}
+	return data
+}
+
+// renderPrompt строит системное сообщение и промпт по шаблону. Требование писать
+// на языке отчёта добавляется к любому шаблону, чтобы отчёт по двуязычному
+// документу не смешивал языки.
+func renderPrompt(tmpl *models.PromptTemplate, data PromptData) (string, string, error) {
+	system, err := executePromptTemplate("system", tmpl.System, data)
+	if err != nil {
+		return "", "", err
+	}
+	system += "\n\n" + languageInstruction(data.Language)
+	body, err := executePromptTemplate("body", tmpl.Body, data)
+	if err != nil {
+		return "", "", err
+	}
+	return system, body, nil
+}
+
+func executePromptTemplate(name, text string, data PromptData) (string, error) {
+	t, err := template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// InitPromptTemplates создаёт индексы шаблонов промптов
+func InitPromptTemplates() {
+	if err := repositories.EnsurePromptTemplateIndexes(); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось создать индексы шаблонов промптов: %v", err))
+	}
+}
+
+func loadActivePromptTemplates() []models.PromptTemplate {
+	promptTemplateMutex.Lock()
+	defer promptTemplateMutex.Unlock()
+
+	if promptTemplateCache != nil && time.Since(promptTemplateLoadedAt) < promptTemplatesTTL {
+		return promptTemplateCache
+	}
+
+	templates, err := repositories.GetPromptTemplates(bson.M{"active": true})
+	if err != nil {
+		// Без базы остаётся встроенный шаблон; кэш не обновляем, чтобы повторить запрос
+		utils.LogWarning(fmt.Sprintf("Не удалось загрузить шаблоны промптов: %v", err))
+		return nil
+	}
+	if templates == nil {
+		templates = []models.PromptTemplate{}
+	}
+
+	promptTemplateCache = templates
+	promptTemplateLoadedAt = time.Now()
+	return templates
+}
+
+func invalidatePromptTemplates() {
+	promptTemplateMutex.Lock()
+	promptTemplateCache = nil
+	promptTemplateMutex.Unlock()
+}
+
+// selectPromptTemplate выбирает активный шаблон назначения purpose: сначала по
+// подтипам, затем по основному типу и общий; для каждого типа шаблон на языке
+// отчёта важнее шаблона для любого языка
+func selectPromptTemplate(purpose string, classification *models.DocumentClassification, language string) *models.PromptTemplate {
+	active := loadActivePromptTemplates()
+
+	var codes []string
+	if classification != nil {
+		for _, s := range classification.Subtypes {
+			codes = append(codes, s.Code)
+		}
+		codes = append(codes, classification.Code)
+	}
+	codes = append(codes, "")
+
+	for _, code := range codes {
+		for _, lang := range []string{language, ""} {
+			for i := range active {
+				if active[i].Purpose == purpose && active[i].DocumentType == code && active[i].Language == lang {
+					return &active[i]
+				}
+			}
+		}
+	}
+	return builtinPromptTemplate(purpose, language)
+}
+
+// jobPromptTemplate возвращает шаблон задачи. Выбранная версия запоминается в задаче,
+// чтобы после перезапуска все части строились одним и тем же промптом.
+func jobPromptTemplate(job *models.AnalysisJob) (*models.PromptTemplate, error) {
+	if job.Prompt != nil {
+		if job.Prompt.ID.IsZero() {
+			return builtinPromptTemplate("", job.Language), nil
+		}
+		tmpl, err := repositories.GetPromptTemplate(job.Prompt.ID)
+		if err == nil {
+			return tmpl, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		utils.LogWarning(fmt.Sprintf("Шаблон промпта задачи %s удалён, выбирается заново", job.ID.Hex()))
+	}
+
+	tmpl := selectPromptTemplate("", job.Classification, job.Language)
+	job.Prompt = tmpl.Ref()
+	if err := repositories.UpdateAnalysisJob(job.ID, map[string]interface{}{"prompt_template": job.Prompt}); err != nil {
+		return nil, err
+	}
+	utils.LogInfo(fmt.Sprintf("Шаблон промпта задачи %s: %q/%q v%d",
+		job.ID.Hex(), tmpl.DocumentType, tmpl.Language, tmpl.Version))
+	return tmpl, nil
+}
+
+// ListPromptTemplates возвращает все версии шаблонов; purpose, documentType и
+// language сужают выборку, если заданы
+func ListPromptTemplates(purpose, documentType, language *string) ([]models.PromptTemplate, error) {
+	filter := bson.M{}
+	if purpose != nil {
+		// Назначение шаблонов анализа не хранится
+		if p := promptPurpose(*purpose); p != "" {
+			filter["purpose"] = p
+		} else {
+			filter["purpose"] = nil
+		}
+	}
+	if documentType != nil {
+		filter["document_type"] = *documentType
+	}
+	if language != nil {
+		filter["language"] = *language
+	}
+	return repositories.GetPromptTemplates(filter)
+}
+
+func GetPromptTemplate(id string) (*models.PromptTemplate, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPromptTemplateNotFound
+	}
+	tmpl, err := repositories.GetPromptTemplate(objID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrPromptTemplateNotFound
+	}
+	return tmpl, err
+}
+
+// CreatePromptTemplate проверяет шаблон пробной подстановкой и сохраняет его новой версией
+func CreatePromptTemplate(req models.PromptTemplateRequest, userID string) (*models.PromptTemplate, error) {
+	tmpl := &models.PromptTemplate{
+		Purpose:      promptPurpose(req.Purpose),
+		DocumentType: strings.TrimSpace(req.DocumentType),
+		Language:     strings.TrimSpace(req.Language),
+		System:       req.System,
+		Body:         req.Body,
+		Comment:      strings.TrimSpace(req.Comment),
+	}
+	if id, err := primitive.ObjectIDFromHex(userID); err == nil {
+		tmpl.CreatedBy = id
+	}
+
+	if err := validatePromptTemplate(tmpl); err != nil {
+		return nil, err
+	}
+	if err := repositories.CreatePromptTemplate(tmpl); err != nil {
+		return nil, err
+	}
+	if req.Activate {
+		if err := repositories.ActivatePromptTemplate(tmpl); err != nil {
+			return nil, err
+		}
+		invalidatePromptTemplates()
+	}
+
+	utils.LogSuccess(fmt.Sprintf("Создан шаблон промпта %s%q/%q v%d", promptPurposeLabel(tmpl.Purpose), tmpl.DocumentType, tmpl.Language, tmpl.Version))
+	return tmpl, nil
+}
+
+func ActivatePromptTemplate(id string) (*models.PromptTemplate, error) {
+	tmpl, err := GetPromptTemplate(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := repositories.ActivatePromptTemplate(tmpl); err != nil {
+		return nil, err
+	}
+	invalidatePromptTemplates()
+	return tmpl, nil
+}
+
+// RollbackPromptTemplate активирует версию, предшествующую активной
+func RollbackPromptTemplate(key models.PromptTemplateKey) (*models.PromptTemplate, error) {
+	purpose := promptPurpose(key.Purpose)
+	documentType := strings.TrimSpace(key.DocumentType)
+	language := strings.TrimSpace(key.Language)
+	versions, err := ListPromptTemplates(&purpose, &documentType, &language)
+	if err != nil {
+		return nil, err
+	}
+
+	// Версии отсортированы по убыванию: нужна первая после активной
+	for i, v := range versions {
+		if !v.Active {
+			continue
+		}
+		if i+1 == len(versions) {
+			return nil, ErrNoPreviousPrompt
+		}
+		previous := versions[i+1]
+		if err := repositories.ActivatePromptTemplate(&previous); err != nil {
+			return nil, err
+		}
+		invalidatePromptTemplates()
+		utils.LogSuccess(fmt.Sprintf("Шаблон промпта %s%q/%q откатан с v%d на v%d", promptPurposeLabel(purpose), documentType, language, v.Version, previous.Version))
+		return &previous, nil
+	}
+	return nil, ErrNoPreviousPrompt
+}
+
+// DeletePromptTemplate удаляет неактивную версию. Анализы хранят ссылку с номером
+// версии, поэтому запись о том, каким шаблоном они построены, остаётся.
+func DeletePromptTemplate(id string) error {
+	tmpl, err := GetPromptTemplate(id)
+	if err != nil {
+		return err
+	}
+	if tmpl.Active {
+		return ErrPromptTemplateActive
+	}
+	return repositories.DeletePromptTemplate(tmpl.ID)
+}
+
+// promptPurpose приводит назначение из запроса к хранимому виду: шаблоны анализа
+// («analysis» или пусто) хранятся без назначения
+func promptPurpose(purpose string) string {
+	purpose = strings.ToLower(strings.TrimSpace(purpose))
+	if purpose == "analysis" {
+		return ""
+	}
+	return purpose
+}
+
+func promptPurposeLabel(purpose string) string {
+	if purpose == "" {
+		return ""
+	}
+	return purpose + " "
+}
+
+func validatePromptTemplate(tmpl *models.PromptTemplate) error {
+	if tmpl.Purpose != "" && tmpl.Purpose != models.PromptPurposeReduce {
+		return fmt.Errorf("%w: неизвестное назначение шаблона %q", ErrInvalidPromptTemplate, tmpl.Purpose)
+	}
+	if tmpl.Language != "" && !IsSupportedLanguage(tmpl.Language) {
+		return fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, ErrUnsupportedLanguage)
+	}
+	if tmpl.DocumentType != "" {
+		exists, err := repositories.DocumentTypeCodeExists(tmpl.DocumentType)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: неизвестный тип документа %q", ErrInvalidPromptTemplate, tmpl.DocumentType)
+		}
+	}
+
+	sample := PromptData{
+		Text:            promptTextMarker,
+		PreviousContext: "…",
+		LawContext:      "[L-000000-1] Закон",
+		Jurisdiction:    analysisJurisdiction(tmpl.Language),
+		DocumentType:    "Договор",
+		DocumentCode:    "contract",
+		Subtypes:        []string{"Договор аренды"},
+		Language:        tmpl.Language,
+		SourceLanguages: []string{LanguageRussian, LanguageKazakh},
+		Schema:          analysisSchemaFor(tmpl.Language),
+	}
+	if tmpl.Purpose == models.PromptPurposeReduce {
+		sample.Schema = reduceSchemaFor(tmpl.Language)
+	}
+	system, body, err := renderPrompt(tmpl, sample)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	if strings.TrimSpace(system) == "" {
+		return fmt.Errorf("%w: пустое системное сообщение", ErrInvalidPromptTemplate)
+	}
+	if !strings.Contains(body, promptTextMarker) {
+		return fmt.Errorf("%w: в шаблоне нет {{.Text}}", ErrInvalidPromptTemplate)
+	}
+	return nil
+}
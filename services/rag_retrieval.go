@@ -0,0 +1,339 @@
+// rag_retrieval.go
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultLawTopK = 6
+	// lawIndexTTL — как часто перечитывать корпус, если его не сбросили явно
+	lawIndexTTL = 5 * time.Minute
+	// lawChunkPromptChars — сколько символов фрагмента закона попадает в промпт
+	lawChunkPromptChars = 1500
+	// lawContextTokens — запас в промпте части под фрагменты законов
+	lawContextTokens = 3000
+	// lawQueryChars — сколько текста части отправлять на эмбеддинг запроса
+	lawQueryChars = 6000
+	// lawEmbeddingWeight — доля косинусной близости в итоговой оценке фрагмента,
+	// остаток приходится на BM25
+	lawEmbeddingWeight = 0.5
+
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// stemLength — грубая основа слова: русские окончания редко длиннее
+	stemLength = 6
+)
+
+// lawChunk — фрагмент закона из RAG-базы, подготовленный для поиска
+type lawChunk struct {
+	citation   models.Citation
+	content    string
+	embeddings []float64
+	length     int
+}
+
+// lawIndex — инвертированный индекс фрагментов для BM25
+type lawIndex struct {
+	chunks   []lawChunk
+	postings map[string][]lawPosting
+	avgLen   float64
+	builtAt  time.Time
+}
+
+type lawPosting struct {
+	chunk int
+	tf    int
+}
+
+var (
+	lawIndexCache *lawIndex
+	lawIndexMutex sync.Mutex
+
+	stopWords = map[string]bool{
+		"настоя": true, "которы": true, "являет": true, "случае": true, "соотве": true,
+		"либо": true, "также": true, "если": true, "может": true, "должен": true,
+		"быть": true, "этого": true, "иных": true, "иные": true, "иной": true,
+		"для": true, "при": true, "или": true, "что": true, "его": true, "она": true,
+		"они": true, "the": true, "and": true,
+	}
+)
+
+// lawCitationID строит стабильный идентификатор фрагмента: конец ID документа
+// и номер фрагмента. Модели проще воспроизвести его, чем ObjectID.
+func lawCitationID(doc models.RAGDocument, i int) string {
+	hex := doc.ID.Hex()
+	return fmt.Sprintf("L-%s-%d", hex[len(hex)-6:], i+1)
+}
+
+// InvalidateLawIndex сбрасывает индексы после загрузки или удаления RAG-документа
+func InvalidateLawIndex() {
+	lawIndexMutex.Lock()
+	lawIndexCache = nil
+	lawIndexMutex.Unlock()
+	invalidateStatutes()
+}
+
+func getLawIndex() (*lawIndex, error) {
+	lawIndexMutex.Lock()
+	defer lawIndexMutex.Unlock()
+
+	if lawIndexCache != nil && time.Since(lawIndexCache.builtAt) < lawIndexTTL {
+		return lawIndexCache, nil
+	}
+
+	docs, err := repositories.GetProcessedRAGChunks()
+	if err != nil {
+		return nil, err
+	}
+
+	index := &lawIndex{postings: make(map[string][]lawPosting), builtAt: time.Now()}
+	var totalLen int
+	for _, doc := range docs {
+		for i, chunk := range doc.Chunks {
+			if strings.TrimSpace(chunk.Content) == "" {
+				continue
+			}
+
+			terms := lawTerms(doc.Title + " " + chunk.Content)
+			n := len(index.chunks)
+			for term, tf := range terms.counts {
+				index.postings[term] = append(index.postings[term], lawPosting{chunk: n, tf: tf})
+			}
+			totalLen += terms.total
+
+			index.chunks = append(index.chunks, lawChunk{
+				content: chunk.Content,
+				citation: models.Citation{
+					ID:         lawCitationID(doc, i),
+					DocumentID: doc.ID,
+					ChunkID:    chunk.ID,
+					Title:      doc.Title,
+					Source:     doc.Source,
+				},
+				embeddings: chunk.Embeddings,
+				length:     terms.total,
+			})
+		}
+	}
+	if len(index.chunks) > 0 {
+		index.avgLen = float64(totalLen) / float64(len(index.chunks))
+	}
+
+	utils.LogInfo(fmt.Sprintf("Индекс законов построен: %d документов, %d фрагментов", len(docs), len(index.chunks)))
+	lawIndexCache = index
+	return index, nil
+}
+
+type termCounts struct {
+	counts map[string]int
+	total  int
+}
+
+// lawTerms разбивает текст на основы слов без стоп-слов
+func lawTerms(text string) termCounts {
+	out := termCounts{counts: make(map[string]int)}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if utf8.RuneCountInString(w) < 3 {
+			continue
+		}
+		if utf8.RuneCountInString(w) > stemLength {
+			w = string([]rune(w)[:stemLength])
+		}
+		if stopWords[w] {
+			continue
+		}
+		out.counts[w]++
+		out.total++
+	}
+	return out
+}
+
+// retrieveLawChunks подбирает фрагменты законов, относящиеся к тексту части.
+// Основной сигнал — BM25 по основам слов; если настроены эмбеддинги OpenAI,
+// к нему добавляется косинусная близость. Пустой корпус — не ошибка.
+func retrieveLawChunks(ctx context.Context, text string) ([]lawChunk, error) {
+	index, err := getLawIndex()
+	if err != nil {
+		return nil, err
+	}
+	if len(index.chunks) == 0 {
+		return nil, nil
+	}
+
+	scores := index.bm25(lawTerms(text))
+
+	if os.Getenv("OPENAI_API_KEY") != "" && ctx.Err() == nil {
+		query := text
+		if utf8.RuneCountInString(query) > lawQueryChars {
+			query = string([]rune(query)[:lawQueryChars])
+		}
+		// Запрос эмбеддингов тоже уходит внешнему сервису
+		query = redactPII(ctx, query)
+		if embedding, err := NewRAGService().generateEmbeddings(query); err == nil {
+			blendEmbeddingScores(scores, index.chunks, embedding)
+		} else {
+			utils.LogWarning(fmt.Sprintf("Поиск законов без эмбеддингов: %v", err))
+		}
+	}
+
+	order := make([]int, 0, len(scores))
+	for i, score := range scores {
+		if score > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	topK := envInt(defaultLawTopK, "RAG_TOP_K")
+	if len(order) > topK {
+		order = order[:topK]
+	}
+
+	chunks := make([]lawChunk, len(order))
+	for i, n := range order {
+		chunks[i] = index.chunks[n]
+	}
+	return chunks, nil
+}
+
+// blendEmbeddingScores смешивает оценки BM25 с косинусной близостью к запросу.
+// Близость нормируется к [0, 1] так же, как BM25, и смешивается с одними и теми
+// же весами для всех фрагментов: фрагмент без эмбеддинга или с отрицательной
+// близостью получает за неё ноль, а не сохраняет полную оценку BM25.
+func blendEmbeddingScores(scores []float64, chunks []lawChunk, embedding []float64) {
+	sims := make([]float64, len(chunks))
+	var top float64
+	for i, chunk := range chunks {
+		sims[i] = max(cosineSimilarity(embedding, chunk.embeddings), 0)
+		top = max(top, sims[i])
+	}
+	if top == 0 {
+		return
+	}
+	for i := range scores {
+		scores[i] = (1-lawEmbeddingWeight)*scores[i] + lawEmbeddingWeight*sims[i]/top
+	}
+}
+
+// bm25 возвращает оценки всех фрагментов, нормированные к [0, 1]
+func (idx *lawIndex) bm25(query termCounts) []float64 {
+	scores := make([]float64, len(idx.chunks))
+	n := float64(len(idx.chunks))
+
+	for term := range query.counts {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+		for _, p := range postings {
+			tf := float64(p.tf)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(idx.chunks[p.chunk].length)/idx.avgLen)
+			scores[p.chunk] += idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+
+	var max float64
+	for _, s := range scores {
+		max = math.Max(max, s)
+	}
+	if max > 0 {
+		for i := range scores {
+			scores[i] /= max
+		}
+	}
+	return scores
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// formatLawContext оформляет фрагменты законов для промпта с их идентификаторами
+func formatLawContext(chunks []lawChunk) string {
+	var b strings.Builder
+	for _, c := range chunks {
+		content := strings.TrimSpace(c.content)
+		if utf8.RuneCountInString(content) > lawChunkPromptChars {
+			content = string([]rune(content)[:lawChunkPromptChars]) + "…"
+		}
+		fmt.Fprintf(&b, "[%s] %s", c.citation.ID, c.citation.Title)
+		if c.citation.Source != "" {
+			fmt.Fprintf(&b, " (%s)", c.citation.Source)
+		}
+		fmt.Fprintf(&b, "\n%s\n\n", content)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// resolveCitations заменяет идентификаторы из ответа модели ссылками на фрагменты.
+// Идентификаторы, которых не было в промпте, отбрасываются.
+func resolveCitations(result *models.AnalysisResult, chunks []lawChunk) {
+	known := make(map[string]models.Citation, len(chunks))
+	for _, c := range chunks {
+		known[c.citation.ID] = c.citation
+	}
+
+	for i := range result.Findings {
+		f := &result.Findings[i]
+		var resolved []models.Citation
+		seen := make(map[string]bool)
+		for _, c := range f.Citations {
+			id := strings.Trim(strings.TrimSpace(c.ID), "[]")
+			citation, ok := known[id]
+			if !ok {
+				if id != "" {
+					utils.LogWarning(fmt.Sprintf("Модель сослалась на неизвестный фрагмент %s", id))
+				}
+				continue
+			}
+			if !seen[id] {
+				seen[id] = true
+				resolved = append(resolved, citation)
+			}
+		}
+		f.Citations = resolved
+	}
+}
+
+// mergeCitations объединяет ссылки без повторов
+func mergeCitations(a, b []models.Citation) []models.Citation {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []models.Citation
+	for _, c := range append(append([]models.Citation{}, a...), b...) {
+		if !seen[c.ID] {
+			seen[c.ID] = true
+			out = append(out, c)
+		}
+	}
+	return out
+}
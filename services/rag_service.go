@@ -22,6 +22,7 @@ import (
 const (
 	embeddingModel = "text-embedding-ada-002"
 	embeddingAPI   = "https://api.openai.com/v1/embeddings"
+	ragChunkTokens = 600
 )
 
 type RAGService struct{}
@@ -106,6 +107,8 @@ func (s *RAGService) ProcessDocumentAsync(docID primitive.ObjectID) {
 		utils.LogError(fmt.Sprintf("Ошибка обновления статуса: %v", err))
 		return
 	}
+	// Пока документ обрабатывается, его нет среди обработанных: индексы перечитываются без него
+	InvalidateLawIndex()
 
 	// Generate embeddings for the document
 	embeddings, err := s.generateEmbeddings(doc.Content)
@@ -141,6 +144,7 @@ func (s *RAGService) ProcessDocumentAsync(docID primitive.ObjectID) {
 		return
 	}
 
+	InvalidateLawIndex()
 	utils.LogSuccess(fmt.Sprintf("Документ успешно обработан: %s", doc.Title))
 }
 
@@ -223,27 +227,22 @@ func (s *RAGService) generateSimpleEmbeddings(text string) []float64 {
 func (s *RAGService) chunkDocument(content string) []models.DocumentChunk {
 	utils.LogAction("Разделение документа на чанки")
 
-	// Simple chunking strategy - split by paragraphs
-	paragraphs := strings.Split(content, "\n\n")
-	var chunks []models.DocumentChunk
-
-	startIndex := 0
-	for _, paragraph := range paragraphs {
-		paragraph = strings.TrimSpace(paragraph)
-		if len(paragraph) == 0 {
-			continue
-		}
+	// Split by articles and clauses so that each chunk can be cited on its own
+	parts := utils.SplitDocument(content, utils.SplitOptions{
+		MaxTokens:   ragChunkTokens,
+		CountTokens: estimateTokens,
+	})
 
-		// Create chunk
+	var chunks []models.DocumentChunk
+	for _, part := range parts {
 		chunk := models.DocumentChunk{
 			ID:         primitive.NewObjectID(),
-			Content:    paragraph,
-			StartIndex: startIndex,
-			EndIndex:   startIndex + len(paragraph),
+			Content:    part.Text,
+			StartIndex: part.Start,
+			EndIndex:   part.End,
 		}
 
 		chunks = append(chunks, chunk)
-		startIndex += len(paragraph) + 2 // +2 for "\n\n"
 	}
 
 	utils.LogInfo(fmt.Sprintf("Документ разделен на %d чанков", len(chunks)))
@@ -305,6 +304,7 @@ func (s *RAGService) DeleteRAGDocument(docID string) error {
 	if err != nil {
 		return fmt.Errorf("ошибка удаления документа: %w", err)
 	}
+	InvalidateLawIndex()
 
 	utils.LogSuccess("RAG документ успешно удален")
 	return nil
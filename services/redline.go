@@ -0,0 +1,325 @@
+// redline.go
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// redlineAuthor — автор исправлений и комментариев в DOCX
+const redlineAuthor = "Legally"
+
+var (
+	ErrAnalysisNotFound = errors.New("анализ не найден")
+	// ErrRedlineUnavailable — у старых анализов нет структурированных выводов или текста
+	ErrRedlineUnavailable = errors.New("для этого анализа нельзя построить документ с правками: нет структурированных выводов или текста документа")
+)
+
+const redlineSchema = `{
+  "edits": [
+    {
+      "finding": "f1",
+      "quote": "точная цитата из фрагмента, которую нужно изменить",
+      "replacement": "новая редакция этой цитаты целиком; пустая строка — удалить цитату",
+      "comment": "обоснование правки для юриста"
+    }
+  ]
+}`
+
+type redlineInputFinding struct {
+	ID             string `json:"id"`
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	LegalBasis     string `json:"legal_basis,omitempty"`
+	Recommendation string `json:"recommendation,omitempty"`
+}
+
+type redlineOutput struct {
+	Edits []struct {
+		Finding     string `json:"finding"`
+		Quote       string `json:"quote"`
+		Replacement string `json:"replacement"`
+		Comment     string `json:"comment"`
+	} `json:"edits"`
+}
+
+// GetRedlineDocx строит DOCX анализа с предложенными правками в режиме исправлений.
+// Правки запрашиваются у модели при первой выгрузке и сохраняются в анализе;
+// refresh запрашивает их заново. Возвращает содержимое и имя файла.
+func GetRedlineDocx(ctx context.Context, userID, analysisID string, refresh bool) ([]byte, string, error) {
+	analysis, err := getUserAnalysis(userID, analysisID)
+	if err != nil {
+		return nil, "", err
+	}
+	if analysis.Result == nil || strings.TrimSpace(analysis.Text) == "" {
+		return nil, "", ErrRedlineUnavailable
+	}
+
+	if analysis.Redline == nil || refresh {
+		if err := CheckUsageQuota(userID, 0); err != nil {
+			return nil, "", err
+		}
+		analysis.Redline = proposeRedline(withUsageScope(ctx, analysisUsageScope(analysis)), analysis)
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		if err := repositories.SetAnalysisRedline(analysis.ID, analysis.Redline); err != nil {
+			utils.LogWarning(fmt.Sprintf("Не удалось сохранить правки анализа %s: %v", analysisID, err))
+		}
+	}
+
+	data, err := utils.BuildRedlineDocx(analysis.Text, redlineRevisions(analysis), redlineAuthor, analysis.Redline.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// У документов из архива в имени есть путь внутри архива
+	base := filepath.Base(analysis.Filename)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	if name == "" {
+		name = "document"
+	}
+	utils.LogSuccess(fmt.Sprintf("Построен DOCX с правками для анализа %s: правок %d", analysisID, len(analysis.Redline.Edits)))
+	return data, name + "_redline.docx", nil
+}
+
+func getUserAnalysis(userID, analysisID string) (*models.Analysis, error) {
+	id, err := primitive.ObjectIDFromHex(analysisID)
+	if err != nil {
+		return nil, ErrAnalysisNotFound
+	}
+	analysis, err := repositories.GetAnalysis(id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAnalysisNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if analysis.UserID.Hex() != userID {
+		return nil, ErrAnalysisNotFound
+	}
+	return analysis, nil
+}
+
+// proposeRedline просит модель превратить рекомендации выводов в конкретные
+// замены текста. Выводы группируются по частям документа: модель получает текст
+// части, а не весь документ. Часть, которую не удалось обработать, остаётся
+// без правок — её выводы попадут в DOCX комментариями.
+func proposeRedline(ctx context.Context, analysis *models.Analysis) *models.Redline {
+	redline := &models.Redline{Edits: []models.RedlineEdit{}, CreatedAt: time.Now()}
+	text := []rune(analysis.Text)
+
+	groups := make(map[int][]int)
+	for i, f := range analysis.Result.Findings {
+		part := 0
+		if len(f.Parts) > 0 {
+			part = f.Parts[0]
+		}
+		groups[part] = append(groups[part], i)
+	}
+	parts := make([]int, 0, len(groups))
+	for part := range groups {
+		parts = append(parts, part)
+	}
+	sort.Ints(parts)
+
+	for _, part := range parts {
+		start, end := partRange(analysis, part, text)
+		edits, model, err := proposePartEdits(ctx, analysis, string(text[start:end]), groups[part])
+		if ctx.Err() != nil {
+			return redline
+		}
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("Не удалось подготовить правки для части %d: %v", part, err))
+			continue
+		}
+		for _, edit := range edits {
+			if edit.Source != nil {
+				edit.Source.Start += start
+				edit.Source.End += start
+			}
+			redline.Edits = append(redline.Edits, edit)
+		}
+		redline.Model = model
+	}
+	return redline
+}
+
+// partRange — границы части (номер с 1) в символах текста; для выводов без
+// номера части — начало документа в пределах бюджета части
+func partRange(analysis *models.Analysis, part int, text []rune) (int, int) {
+	for _, p := range analysis.Parts {
+		if p.Index+1 == part && p.Start < p.End && p.End <= len(text) {
+			return p.Start, p.End
+		}
+	}
+	budget := partSplitOptions().MaxTokens
+	end := len(text)
+	for end > 0 && llm.CountTokens(string(text[:end])) > budget {
+		end = end * 3 / 4
+	}
+	return 0, end
+}
+
+func proposePartEdits(ctx context.Context, analysis *models.Analysis, fragment string, findings []int) ([]models.RedlineEdit, string, error) {
+	input := make([]redlineInputFinding, 0, len(findings))
+	for _, i := range findings {
+		f := analysis.Result.Findings[i]
+		input = append(input, redlineInputFinding{
+			ID:             fmt.Sprintf("f%d", i+1),
+			Title:          f.Title,
+			Description:    f.Description,
+			LegalBasis:     f.LegalBasis,
+			Recommendation: f.Recommendation,
+		})
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка маршалинга выводов: %w", err)
+	}
+
+	prompt := fmt.Sprintf(`Ниже — фрагмент юридического документа и выводы его анализа с рекомендациями.
+Для каждого вывода, который можно исправить правкой текста, предложи конкретную правку фрагмента:
+- в quote приведи точную цитату из фрагмента без изменений — как можно короче, но не меньше одного слова;
+- в replacement — новую редакцию этой цитаты целиком;
+- чтобы добавить новый текст, процитируй предложение, после которого его нужно вставить, и повтори его в replacement вместе с добавленным текстом;
+- в comment кратко объясни, зачем нужна правка.
+Если вывод нельзя исправить правкой этого фрагмента, не включай его в ответ.
+%s Цитату quote не переводи, replacement пиши на языке фрагмента.
+
+Ответ верни строго в формате JSON по схеме ниже, без markdown и пояснений вне JSON:
+%s
+
+Выводы:
+%s
+
+Фрагмент документа:
+%s`, languageInstruction(analysis.Language), redlineSchema, string(data), fragment)
+
+	resp, err := queryLLM(withLLMOperation(ctx, llmOpRedline), prompt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var out redlineOutput
+	if err := unmarshalModelJSON(resp.Content, &out); err != nil {
+		return nil, resp.Model, err
+	}
+
+	known := make(map[string]int, len(findings))
+	for _, i := range findings {
+		known[fmt.Sprintf("f%d", i+1)] = i
+	}
+
+	var edits []models.RedlineEdit
+	for _, e := range out.Edits {
+		i, ok := known[strings.TrimSpace(e.Finding)]
+		if !ok {
+			continue
+		}
+		source := locateQuote(fragment, e.Quote)
+		if source == nil {
+			utils.LogWarning(fmt.Sprintf("Цитата правки для вывода %d не найдена в тексте", i+1))
+			continue
+		}
+		edits = append(edits, models.RedlineEdit{
+			Finding:     i,
+			Original:    source.Quote,
+			Replacement: strings.TrimSpace(e.Replacement),
+			Comment:     strings.TrimSpace(e.Comment),
+			Source:      source,
+		})
+	}
+	return edits, resp.Model, nil
+}
+
+// redlineRevisions превращает правки в исправления DOCX. Выводы без правок
+// становятся комментариями к своему фрагменту, если он известен (выводы
+// правил), иначе — к первой строке своей части.
+func redlineRevisions(analysis *models.Analysis) []utils.Revision {
+	t := reportTextFor(analysis.Language)
+	text := []rune(analysis.Text)
+	findings := analysis.Result.Findings
+
+	var revisions []utils.Revision
+	edited := make(map[int]bool)
+	for _, edit := range analysis.Redline.Edits {
+		if edit.Source == nil || edit.Finding < 0 || edit.Finding >= len(findings) || edit.Source.End > len(text) {
+			continue
+		}
+		edited[edit.Finding] = true
+		revisions = append(revisions, utils.Revision{
+			Start:       edit.Source.Start,
+			End:         edit.Source.End,
+			Replace:     true,
+			Replacement: edit.Replacement,
+			Comment:     redlineComment(findings[edit.Finding], edit.Comment, t),
+		})
+	}
+
+	for i, f := range findings {
+		if edited[i] {
+			continue
+		}
+		if f.Span != nil && f.Span.Start < f.Span.End && f.Span.End <= len(text) {
+			revisions = append(revisions, utils.Revision{
+				Start:   f.Span.Start,
+				End:     f.Span.End,
+				Comment: redlineComment(f, f.Recommendation, t),
+			})
+			continue
+		}
+		if f.Span != nil && f.Span.Start < f.Span.End && f.Span.End <= len(text) {
+			revisions = append(revisions, utils.Revision{
+				Start:   f.Span.Start,
+				End:     f.Span.End,
+				Comment: redlineComment(f, f.Recommendation, t),
+			})
+			continue
+		}
+		part := 0
+		if len(f.Parts) > 0 {
+			part = f.Parts[0]
+		}
+		start := 0
+		if part > 0 {
+			start, _ = partRange(analysis, part, text)
+		}
+		end := start
+		for end < len(text) && text[end] != '\n' {
+			end++
+		}
+		revisions = append(revisions, utils.Revision{
+			Start:   start,
+			End:     end,
+			Comment: redlineComment(f, f.Recommendation, t),
+		})
+	}
+	return revisions
+}
+
+func redlineComment(f models.Finding, note string, t reportText) string {
+	lines := []string{fmt.Sprintf("%s (%s: %s)", f.Title, t.riskLevel, t.severities[f.Severity])}
+	if note = strings.TrimSpace(note); note != "" {
+		lines = append(lines, note)
+	} else if f.Description != "" {
+		lines = append(lines, f.Description)
+	}
+	if f.LegalBasis != "" {
+		lines = append(lines, fmt.Sprintf("%s: %s", t.legalBasis, f.LegalBasis))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,305 @@
+// risk_score.go
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// riskHighScore и riskMediumScore — границы уровней риска на шкале 0–100
+	riskHighScore   = 50
+	riskMediumScore = 25
+	// maxRiskWeight ограничивает веса организации, чтобы один вывод не занимал
+	// всю шкалу из-за опечатки в настройках
+	maxRiskWeight = 10
+)
+
+var (
+	ErrInvalidRiskPolicy = errors.New("некорректные настройки оценки риска")
+	// ErrRiskUnavailable — у старых анализов нет структурированных выводов
+	ErrRiskUnavailable = errors.New("для этого анализа нельзя рассчитать оценку риска: нет структурированных выводов")
+)
+
+// riskLevels — уровни по возрастанию: строки и столбцы матрицы риска
+var riskLevels = []models.Severity{models.SeverityLow, models.SeverityMedium, models.SeverityHigh}
+
+// defaultRiskWeights — веса по умолчанию. Один вывод с высокими последствиями и
+// вероятностью и подтверждённой ссылкой даёт оценку 50, то есть высокий уровень.
+// Непроверенные ссылки снижают вклад вывода: он может опираться на
+// несуществующую норму.
+func defaultRiskWeights() models.RiskWeights {
+	return models.RiskWeights{
+		Severity: map[models.Severity]float64{
+			models.SeverityHigh:   1,
+			models.SeverityMedium: 0.5,
+			models.SeverityLow:    0.2,
+		},
+		Likelihood: map[models.Severity]float64{
+			models.SeverityHigh:   1,
+			models.SeverityMedium: 0.7,
+			models.SeverityLow:    0.4,
+		},
+		Kind: map[models.FindingKind]float64{
+			models.FindingViolation: 1.2,
+			models.FindingRisk:      1,
+			models.FindingAmbiguity: 0.7,
+		},
+		Citation: map[models.CitationStatus]float64{
+			models.CitationVerified:   1,
+			models.CitationNone:       0.9,
+			models.CitationUnverified: 0.7,
+		},
+		Saturation: 0.5,
+	}
+}
+
+// riskWeights дополняет веса организации значениями по умолчанию: в настройках
+// можно переопределить только часть весов
+func riskWeights(policy *models.RiskPolicy) models.RiskWeights {
+	weights := defaultRiskWeights()
+	if policy == nil || policy.Weights == nil {
+		return weights
+	}
+	custom := policy.Weights
+	for k, v := range custom.Severity {
+		weights.Severity[k] = v
+	}
+	for k, v := range custom.Likelihood {
+		weights.Likelihood[k] = v
+	}
+	for k, v := range custom.Kind {
+		weights.Kind[k] = v
+	}
+	for k, v := range custom.Citation {
+		weights.Citation[k] = v
+	}
+	if custom.Saturation > 0 {
+		weights.Saturation = custom.Saturation
+	}
+	return weights
+}
+
+// validateRiskPolicy проверяет ключи и диапазоны весов и порог подписания
+func validateRiskPolicy(policy *models.RiskPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.SigningThreshold < 0 || policy.SigningThreshold > 100 {
+		return fmt.Errorf("%w: порог подписания должен быть от 0 до 100", ErrInvalidRiskPolicy)
+	}
+	w := policy.Weights
+	if w == nil {
+		return nil
+	}
+
+	check := func(group, key string, valid bool, value float64) error {
+		if !valid {
+			return fmt.Errorf("%w: неизвестный ключ %s в %s", ErrInvalidRiskPolicy, key, group)
+		}
+		if value < 0 || value > maxRiskWeight || math.IsNaN(value) {
+			return fmt.Errorf("%w: вес %s.%s должен быть от 0 до %d", ErrInvalidRiskPolicy, group, key, maxRiskWeight)
+		}
+		return nil
+	}
+	for k, v := range w.Severity {
+		if err := check("severity", string(k), k.IsValid(), v); err != nil {
+			return err
+		}
+	}
+	for k, v := range w.Likelihood {
+		if err := check("likelihood", string(k), k.IsValid(), v); err != nil {
+			return err
+		}
+	}
+	for k, v := range w.Kind {
+		valid := k == models.FindingRisk || k == models.FindingAmbiguity || k == models.FindingViolation
+		if err := check("kind", string(k), valid, v); err != nil {
+			return err
+		}
+	}
+	for k, v := range w.Citation {
+		valid := k == models.CitationVerified || k == models.CitationUnverified || k == models.CitationNone
+		if err := check("citation", string(k), valid, v); err != nil {
+			return err
+		}
+	}
+	if w.Saturation < 0 || w.Saturation > 1 {
+		return fmt.Errorf("%w: saturation должен быть от 0 до 1", ErrInvalidRiskPolicy)
+	}
+	return nil
+}
+
+// scoreAnalysis оценивает риск документа по выводам. Вклад вывода p = вес
+// последствий × вес вероятности × вес вида × вес статуса ссылок (не больше 1),
+// а оценка — 100 × (1 − Π(1 − p × Saturation)): каждый следующий вывод
+// приближает оценку к 100, но не превышает её.
+func scoreAnalysis(result *models.AnalysisResult, policy *models.RiskPolicy) *models.RiskScore {
+	if result == nil {
+		return nil
+	}
+	weights := riskWeights(policy)
+
+	cells := make(map[[2]models.Severity]*models.RiskMatrixCell, len(riskLevels)*len(riskLevels))
+	matrix := make([]models.RiskMatrixCell, 0, len(riskLevels)*len(riskLevels))
+	for _, likelihood := range riskLevels {
+		for _, impact := range riskLevels {
+			matrix = append(matrix, models.RiskMatrixCell{Likelihood: likelihood, Impact: impact})
+		}
+	}
+	for i := range matrix {
+		cells[[2]models.Severity{matrix[i].Likelihood, matrix[i].Impact}] = &matrix[i]
+	}
+
+	safe := 1.0
+	for i, f := range result.Findings {
+		impact := normalizeSeverity(f.Severity)
+		likelihood := findingLikelihood(f)
+
+		p := weights.Severity[impact] * weights.Likelihood[likelihood] * weights.Kind[f.Kind] * weights.Citation[findingCitationStatus(f)]
+		safe *= 1 - min(max(p, 0), 1)*weights.Saturation
+
+		cell := cells[[2]models.Severity{likelihood, impact}]
+		cell.Count++
+		cell.Findings = append(cell.Findings, i)
+	}
+
+	score := int(math.Round(100 * (1 - safe)))
+	risk := &models.RiskScore{
+		Score:      score,
+		Level:      riskScoreLevel(score),
+		Matrix:     matrix,
+		ComputedAt: time.Now(),
+	}
+	if policy != nil && policy.SigningThreshold > 0 {
+		risk.SigningThreshold = policy.SigningThreshold
+		risk.SigningBlocked = score >= policy.SigningThreshold
+	}
+	return risk
+}
+
+// findingLikelihood — вероятность из ответа модели, а если её нет — по виду
+// вывода: нарушение уже содержится в тексте, остальное лишь может случиться
+func findingLikelihood(f models.Finding) models.Severity {
+	if f.Likelihood.IsValid() {
+		return f.Likelihood
+	}
+	if f.Kind == models.FindingViolation {
+		return models.SeverityHigh
+	}
+	return models.SeverityMedium
+}
+
+// findingCitationStatus — итог проверки ссылок вывода на статьи законов
+func findingCitationStatus(f models.Finding) models.CitationStatus {
+	if len(f.References) == 0 {
+		return models.CitationNone
+	}
+	for _, ref := range f.References {
+		if ref.Status != models.ReferenceVerified {
+			return models.CitationUnverified
+		}
+	}
+	return models.CitationVerified
+}
+
+func riskScoreLevel(score int) models.Severity {
+	switch {
+	case score >= riskHighScore:
+		return models.SeverityHigh
+	case score >= riskMediumScore:
+		return models.SeverityMedium
+	default:
+		return models.SeverityLow
+	}
+}
+
+// organizationRiskPolicy — настройки организации; без них действуют веса по
+// умолчанию и порог подписания из RISK_SIGNING_THRESHOLD
+func organizationRiskPolicy(id primitive.ObjectID) *models.RiskPolicy {
+	organization, err := repositories.GetOrganization(id)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			utils.LogWarning(fmt.Sprintf("Не удалось загрузить организацию %s: %v", id.Hex(), err))
+		}
+		return defaultRiskPolicy()
+	}
+	return riskPolicyOf(organization)
+}
+
+// riskPolicyOf — настройки оценки риска организации или настройки по умолчанию
+func riskPolicyOf(organization *models.Organization) *models.RiskPolicy {
+	if organization == nil || organization.RiskPolicy == nil {
+		return defaultRiskPolicy()
+	}
+	return organization.RiskPolicy
+}
+
+func defaultRiskPolicy() *models.RiskPolicy {
+	return &models.RiskPolicy{SigningThreshold: envInt(0, "RISK_SIGNING_THRESHOLD")}
+}
+
+// GetAnalysisRisk возвращает оценку риска анализа. Анализам, сделанным до
+// появления оценки, она считается при первом запросе и сохраняется.
+func GetAnalysisRisk(userID, analysisID string) (*models.RiskScore, error) {
+	analysis, err := getUserAnalysis(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.Risk != nil {
+		return analysis.Risk, nil
+	}
+	if analysis.Result == nil {
+		return nil, ErrRiskUnavailable
+	}
+
+	policy := defaultRiskPolicy()
+	if !analysis.OrganizationID.IsZero() {
+		policy = organizationRiskPolicy(analysis.OrganizationID)
+	}
+	analysis.Risk = scoreAnalysis(analysis.Result, policy)
+	if err := repositories.SetAnalysisRisk(analysis.ID, analysis.Risk); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось сохранить оценку риска анализа %s: %v", analysisID, err))
+	}
+	return analysis.Risk, nil
+}
+
+// RescoreOrganization пересчитывает оценки риска всех анализов организации по
+// её текущим настройкам и возвращает число пересчитанных анализов
+func RescoreOrganization(id primitive.ObjectID) (int, error) {
+	policy := organizationRiskPolicy(id)
+
+	count := 0
+	err := repositories.ForEachOrganizationAnalysis(id, func(analysis *models.Analysis) error {
+		if analysis.Result == nil {
+			return nil
+		}
+		if err := repositories.SetAnalysisRisk(analysis.ID, scoreAnalysis(analysis.Result, policy)); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Ошибка пересчёта оценок риска организации %s: %v", id.Hex(), err))
+		return count, err
+	}
+	utils.LogSuccess(fmt.Sprintf("Пересчитаны оценки риска организации %s: %d анализов", id.Hex(), count))
+	return count, nil
+}
+
+// InitAnalysisIndexes создаёт индексы истории анализов, в том числе по оценке риска
+func InitAnalysisIndexes() {
+	if err := repositories.EnsureAnalysisIndexes(); err != nil {
+		utils.LogWarning(fmt.Sprintf("Не удалось создать индексы анализов: %v", err))
+	}
+}
@@ -0,0 +1,49 @@
+// rule_checks.go
+
+package services
+
+import (
+	"fmt"
+	"legally/models"
+	"legally/rules"
+	"legally/utils"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ruleEngine возвращает движок детерминированных проверок с учётом настроек:
+// RULE_ENGINE=false выключает его целиком, RULE_ENGINE_DISABLED перечисляет
+// через запятую ID отключённых правил. nil — проверки выключены.
+func ruleEngine() *rules.Engine {
+	if enabled, err := strconv.ParseBool(os.Getenv("RULE_ENGINE")); err == nil && !enabled {
+		return nil
+	}
+	engine := rules.Default()
+	if disabled := strings.TrimSpace(os.Getenv("RULE_ENGINE_DISABLED")); disabled != "" {
+		engine = engine.Without(strings.Split(disabled, ",")...)
+	}
+	return engine
+}
+
+// withRuleFindings возвращает копию результата, дополненную выводами
+// детерминированных правил. Сам результат модели не меняется: он хранится в
+// кэше, а правила применяются к каждому анализу заново.
+func withRuleFindings(result *models.AnalysisResult, text, language string) *models.AnalysisResult {
+	engine := ruleEngine()
+	if engine == nil || result == nil {
+		return result
+	}
+	findings := engine.Run(rules.Parse(text, language))
+	if len(findings) == 0 {
+		return result
+	}
+	utils.LogInfo(fmt.Sprintf("Автоматические проверки: выводов %d", len(findings)))
+
+	merged := *result
+	merged.Findings = append(append(make([]models.Finding, 0, len(result.Findings)+len(findings)), result.Findings...), findings...)
+	if risk := overallRisk(findings); severityRank[risk] > severityRank[merged.Conclusion.OverallRisk] {
+		merged.Conclusion.OverallRisk = risk
+	}
+	return &merged
+}
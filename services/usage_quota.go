@@ -0,0 +1,448 @@
+// usage_quota.go
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"legally/db"
+	"legally/models"
+	"legally/repositories"
+	"legally/utils"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	quotaAnalyses = "analyses"
+	quotaTokens   = "tokens"
+	quotaCost     = "cost"
+
+	usageMonthLayout = "2006-01"
+)
+
+var (
+	ErrQuotaExceeded      = errors.New("месячный лимит исчерпан")
+	ErrInvalidUsagePeriod = errors.New("месяц задаётся в формате ГГГГ-ММ")
+)
+
+// QuotaError — исчерпан месячный лимит пользователя или его организации
+type QuotaError struct {
+	// Organization — исчерпан общий лимит организации, а не личный
+	Organization bool
+	Limit        string
+	Used         float64
+	Allowed      float64
+	ResetsAt     time.Time
+}
+
+func (e *QuotaError) Error() string {
+	owner := "пользователя"
+	if e.Organization {
+		owner = "организации"
+	}
+	reset := e.ResetsAt.Format("02.01.2006")
+	switch e.Limit {
+	case quotaAnalyses:
+		return fmt.Sprintf("исчерпан месячный лимит анализов %s: %.0f из %.0f, лимит обновится %s", owner, e.Used, e.Allowed, reset)
+	case quotaTokens:
+		return fmt.Sprintf("исчерпан месячный лимит токенов %s: %.0f из %.0f, лимит обновится %s", owner, e.Used, e.Allowed, reset)
+	}
+	return fmt.Sprintf("исчерпан месячный бюджет %s: $%.2f из $%.2f, лимит обновится %s", owner, e.Used, e.Allowed, reset)
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuotaExceeded }
+
+// Status — 429 для числа анализов: запрос можно повторить после сброса лимита;
+// 402 для токенов и бюджета: продолжить можно только с планом побольше
+func (e *QuotaError) Status() int {
+	if e.Limit == quotaAnalyses {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusPaymentRequired
+}
+
+// quotaHttpError переводит ошибку проверки лимитов в ответ сервиса
+func quotaHttpError(err error) *HttpError {
+	var quotaErr *QuotaError
+	if errors.As(err, &quotaErr) {
+		return &HttpError{Status: quotaErr.Status(), Message: quotaErr.Error(), Code: "QUOTA_EXCEEDED"}
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		return &HttpError{Status: http.StatusNotFound, Message: err.Error()}
+	}
+	return &HttpError{Status: http.StatusInternalServerError, Message: err.Error()}
+}
+
+// quotaUser загружает пользователя для проверки лимитов. В отличие от
+// ValidateUser, ошибка базы не выдаётся за отсутствие пользователя.
+func quotaUser(userID string) (*models.User, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	var user models.User
+	err = db.GetCollection("users").FindOne(context.Background(), bson.M{"_id": objID}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	return &user, nil
+}
+
+// CheckUsageQuota проверяет перед запуском, что у пользователя и его организации
+// остались лимиты на analyses новых анализов в этом месяце; analyses = 0
+// проверяет только токены и бюджет (вопросы, сравнения, правки). Если расход
+// не удалось посчитать, запрос пропускается: учёт не должен останавливать работу.
+func CheckUsageQuota(userID string, analyses int) error {
+	user, err := quotaUser(userID)
+	if err != nil {
+		return err
+	}
+	return checkUsageQuota(user, userOrganization(user.ID), analyses)
+}
+
+// ReserveUsageQuota — CheckUsageQuota для запуска анализов. Число анализов
+// считается по созданным задачам, поэтому проверка и постановка задач идут под
+// блокировкой пользователя и его организации (в пределах процесса): иначе
+// параллельные запросы увидят один и тот же остаток и вместе превысят лимит.
+// Вызывающий ставит задачи и затем вызывает release; при ошибке блокировка
+// уже снята.
+func ReserveUsageQuota(userID string, analyses int) (release func(), err error) {
+	user, err := quotaUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	organization := userOrganization(user.ID)
+
+	// Порядок захвата всегда один — пользователь, затем организация
+	unlockUser := lockQuotaOwner(user.ID.Hex())
+	unlockOrganization := func() {}
+	if organization != nil {
+		unlockOrganization = lockQuotaOwner(organization.ID.Hex())
+	}
+	release = func() {
+		unlockOrganization()
+		unlockUser()
+	}
+
+	if err := checkUsageQuota(user, organization, analyses); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+func checkUsageQuota(user *models.User, organization *models.Organization, analyses int) error {
+	period := usagePeriod(time.Now())
+
+	if quota := userQuota(user, organization); !quota.IsZero() {
+		summary, err := usageSummary(bson.M{"user_id": user.ID}, period)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("Лимиты пользователя %s не проверены: %v", user.ID.Hex(), err))
+		} else if err := checkQuota(summary, quota, analyses, period, false); err != nil {
+			return err
+		}
+	}
+
+	if organization != nil && organization.Plan != nil && !organization.Plan.Quota.IsZero() {
+		summary, err := usageSummary(bson.M{"organization_id": organization.ID}, period)
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("Лимиты организации %s не проверены: %v", organization.Name, err))
+		} else if err := checkQuota(summary, organization.Plan.Quota, analyses, period, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quotaLock — блокировка лимитов одного владельца; refs — сколько запросов её
+// держат или ждут, чтобы удалить запись, когда она больше не нужна
+type quotaLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var (
+	quotaLocks      = make(map[string]*quotaLock)
+	quotaLocksMutex sync.Mutex
+)
+
+// lockQuotaOwner захватывает блокировку лимитов пользователя или организации
+// и возвращает функцию её освобождения
+func lockQuotaOwner(owner string) func() {
+	quotaLocksMutex.Lock()
+	lock, ok := quotaLocks[owner]
+	if !ok {
+		lock = &quotaLock{}
+		quotaLocks[owner] = lock
+	}
+	lock.refs++
+	quotaLocksMutex.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		quotaLocksMutex.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(quotaLocks, owner)
+		}
+		quotaLocksMutex.Unlock()
+	}
+}
+
+func checkQuota(summary *models.UsageSummary, quota models.UsageQuota, analyses int, period models.UsagePeriod, organization bool) error {
+	exceeded := func(limit string, used, allowed float64) error {
+		utils.LogWarning(fmt.Sprintf("Лимит %s исчерпан: %.2f из %.2f", limit, used, allowed))
+		return &QuotaError{Organization: organization, Limit: limit, Used: used, Allowed: allowed, ResetsAt: period.To}
+	}
+	if quota.Analyses > 0 && analyses > 0 && summary.Analyses+int64(analyses) > quota.Analyses {
+		return exceeded(quotaAnalyses, float64(summary.Analyses), float64(quota.Analyses))
+	}
+	if quota.Tokens > 0 && summary.Usage.TotalTokens() >= quota.Tokens {
+		return exceeded(quotaTokens, float64(summary.Usage.TotalTokens()), float64(quota.Tokens))
+	}
+	if quota.Cost > 0 && summary.Usage.Cost >= quota.Cost {
+		return exceeded(quotaCost, summary.Usage.Cost, quota.Cost)
+	}
+	return nil
+}
+
+// userQuota — лимиты пользователя: из плана организации, если в нём заданы
+// лимиты участников, иначе по роли
+func userQuota(user *models.User, organization *models.Organization) models.UsageQuota {
+	if organization != nil && organization.Plan != nil && organization.Plan.MemberQuota != nil {
+		return *organization.Plan.MemberQuota
+	}
+	return roleQuota(user.Role)
+}
+
+// roleQuota читает лимиты роли из USAGE_QUOTA_<РОЛЬ>, например
+// USAGE_QUOTA_USER="analyses=100,tokens=5000000,cost=20"; без переменной лимитов нет
+func roleQuota(role models.UserRole) models.UsageQuota {
+	name := "USAGE_QUOTA_" + strings.ToUpper(string(role))
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return models.UsageQuota{}
+	}
+	quota, err := parseUsageQuota(value)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("%s: %v", name, err))
+	}
+	return quota
+}
+
+// parseUsageQuota разбирает лимиты вида «analyses=100,tokens=5000000,cost=20»;
+// неверные поля пропускаются
+func parseUsageQuota(value string) (models.UsageQuota, error) {
+	var quota models.UsageQuota
+	var bad []string
+	for _, field := range strings.Split(value, ",") {
+		key, raw, _ := strings.Cut(strings.TrimSpace(field), "=")
+		raw = strings.TrimSpace(raw)
+		var err error
+		switch strings.TrimSpace(key) {
+		case quotaAnalyses:
+			quota.Analyses, err = strconv.ParseInt(raw, 10, 64)
+		case quotaTokens:
+			quota.Tokens, err = strconv.ParseInt(raw, 10, 64)
+		case quotaCost:
+			quota.Cost, err = strconv.ParseFloat(raw, 64)
+		default:
+			err = errors.New("неизвестный лимит")
+		}
+		if err != nil {
+			bad = append(bad, field)
+		}
+	}
+	if len(bad) > 0 {
+		return quota, fmt.Errorf("пропущены неверные лимиты: %s", strings.Join(bad, ", "))
+	}
+	return quota, nil
+}
+
+func validateUsagePlan(plan *models.UsagePlan) error {
+	if plan == nil {
+		return nil
+	}
+	plan.Name = strings.TrimSpace(plan.Name)
+	for _, quota := range []*models.UsageQuota{&plan.Quota, plan.MemberQuota} {
+		if quota != nil && (quota.Analyses < 0 || quota.Tokens < 0 || quota.Cost < 0) {
+			return fmt.Errorf("%w: лимиты плана не могут быть отрицательными", ErrInvalidOrganization)
+		}
+	}
+	return nil
+}
+
+// usagePeriod — календарный месяц (UTC), в который попадает t
+func usagePeriod(t time.Time) models.UsagePeriod {
+	t = t.UTC()
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return models.UsagePeriod{From: from, To: from.AddDate(0, 1, 0)}
+}
+
+// parseUsagePeriod разбирает месяц вида 2024-03; пустая строка — текущий месяц
+func parseUsagePeriod(month string) (models.UsagePeriod, error) {
+	if month = strings.TrimSpace(month); month == "" {
+		return usagePeriod(time.Now()), nil
+	}
+	t, err := time.Parse(usageMonthLayout, month)
+	if err != nil {
+		return models.UsagePeriod{}, ErrInvalidUsagePeriod
+	}
+	return usagePeriod(t), nil
+}
+
+// usageQuery отбирает запросы к LLM владельца за период
+func usageQuery(owner bson.M, period models.UsagePeriod) bson.M {
+	query := bson.M{"created_at": bson.M{"$gte": period.From, "$lt": period.To}}
+	for k, v := range owner {
+		query[k] = v
+	}
+	return query
+}
+
+// usageSummary считает анализы и расход владельца (пользователя или организации) за период
+func usageSummary(owner bson.M, period models.UsagePeriod) (*models.UsageSummary, error) {
+	analyses, err := repositories.CountAnalysisJobsBetween(owner, period.From, period.To)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := repositories.SumLLMUsage(usageQuery(owner, period))
+	if err != nil {
+		return nil, err
+	}
+	return &models.UsageSummary{Analyses: analyses, Usage: usage}, nil
+}
+
+// withQuota добавляет к сводке лимиты и остаток по ним
+func withQuota(summary *models.UsageSummary, quota models.UsageQuota) *models.UsageSummary {
+	if quota.IsZero() {
+		return summary
+	}
+	summary.Quota = &quota
+	remaining := models.UsageQuota{}
+	if quota.Analyses > 0 {
+		remaining.Analyses = max(quota.Analyses-summary.Analyses, 0)
+	}
+	if quota.Tokens > 0 {
+		remaining.Tokens = max(quota.Tokens-summary.Usage.TotalTokens(), 0)
+	}
+	if quota.Cost > 0 {
+		remaining.Cost = max(quota.Cost-summary.Usage.Cost, 0)
+	}
+	summary.Remaining = &remaining
+	return summary
+}
+
+// GetUserUsage возвращает расход пользователя и его организации за месяц
+// (ГГГГ-ММ, пусто — текущий) с лимитами и остатком
+func GetUserUsage(userID, month string) (*models.UsageReport, error) {
+	user, err := quotaUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	period, err := parseUsagePeriod(month)
+	if err != nil {
+		return nil, err
+	}
+	organization := userOrganization(user.ID)
+
+	owner := bson.M{"user_id": user.ID}
+	summary, err := usageSummary(owner, period)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.UsageReport{Period: period, User: *withQuota(summary, userQuota(user, organization))}
+
+	if organization != nil {
+		summary, err := usageSummary(bson.M{"organization_id": organization.ID}, period)
+		if err != nil {
+			return nil, err
+		}
+		var quota models.UsageQuota
+		if organization.Plan != nil {
+			quota = organization.Plan.Quota
+		}
+		report.Organization = withQuota(summary, quota)
+	}
+
+	if report.ByOperation, err = repositories.GroupLLMUsage(usageQuery(owner, period), "operation"); err != nil {
+		return nil, err
+	}
+	if report.ByModel, err = repositories.GroupLLMUsage(usageQuery(owner, period), "model"); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetOrganizationUsage возвращает расход организации за месяц с разбивкой по пользователям
+func GetOrganizationUsage(organizationID, month string) (*models.OrganizationUsageReport, error) {
+	organization, err := GetOrganization(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	period, err := parseUsagePeriod(month)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := bson.M{"organization_id": organization.ID}
+	summary, err := usageSummary(owner, period)
+	if err != nil {
+		return nil, err
+	}
+	var quota models.UsageQuota
+	if organization.Plan != nil {
+		quota = organization.Plan.Quota
+	}
+	report := &models.OrganizationUsageReport{Period: period, Organization: *withQuota(summary, quota)}
+
+	query := usageQuery(owner, period)
+	if report.ByUser, err = repositories.GroupLLMUsage(query, "user_id"); err != nil {
+		return nil, err
+	}
+	if report.ByOperation, err = repositories.GroupLLMUsage(query, "operation"); err != nil {
+		return nil, err
+	}
+	if report.ByModel, err = repositories.GroupLLMUsage(query, "model"); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetUsageOverview возвращает расход всего сервиса за месяц
+func GetUsageOverview(month string) (*models.AdminUsageReport, error) {
+	period, err := parseUsagePeriod(month)
+	if err != nil {
+		return nil, err
+	}
+	query := usageQuery(bson.M{}, period)
+	report := &models.AdminUsageReport{Period: period}
+	if report.Total, err = repositories.SumLLMUsage(query); err != nil {
+		return nil, err
+	}
+	for field, groups := range map[string]*[]models.UsageGroup{
+		"user_id":         &report.ByUser,
+		"organization_id": &report.ByOrganization,
+		"model":           &report.ByModel,
+		"operation":       &report.ByOperation,
+	} {
+		if *groups, err = repositories.GroupLLMUsage(query, field); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,210 @@
+// batch_upload.go
+
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// MaxBatchUploadSize — предел запроса пакетной загрузки
+	MaxBatchUploadSize = 100 << 20
+	// MaxBatchDocuments — сколько документов можно отправить одним пакетом
+	MaxBatchDocuments = 50
+
+	// maxArchiveEntries ограничивает число записей архива вместе с папками:
+	// центральный каталог читается целиком ещё до проверки содержимого
+	maxArchiveEntries = 500
+	// maxArchiveUnpacked — предел суммарного объёма распакованных файлов архива
+	maxArchiveUnpacked = 200 << 20
+	// maxCompressionRatio — во сколько раз файл может сжиматься; больше бывает
+	// только у специально собранных архивов-бомб, а не у PDF
+	maxCompressionRatio = 100
+)
+
+// UploadedDocument — документ из пакета с извлечённым текстом. Error заполнен,
+// если файл отклонён: остальные документы пакета обрабатываются независимо.
+type UploadedDocument struct {
+	Filename string
+	Text     string
+	Error    string
+}
+
+// ProcessBatchUpload извлекает тексты PDF из полей documents (несколько файлов)
+// и document формы. ZIP-архивы распаковываются в памяти по одному файлу: имена
+// записей не используются как пути, записи с «..» или абсолютным путём
+// отклоняются, а размеры проверяются и по заголовкам, и по фактически
+// прочитанным байтам, поэтому архив-бомба не распакуется сверх пределов.
+// Ошибка возвращается, только если запрос нельзя разобрать целиком.
+func ProcessBatchUpload(c *gin.Context) ([]UploadedDocument, error) {
+	LogAction("Начало обработки пакетной загрузки")
+
+	if err := ParseUploadForm(c, MaxBatchUploadSize); err != nil {
+		return nil, err
+	}
+	if c.Request.MultipartForm == nil {
+		return nil, fmt.Errorf("файлы не получены")
+	}
+
+	var documents []UploadedDocument
+	for _, field := range []string{"documents", "document"} {
+		for _, header := range c.Request.MultipartForm.File[field] {
+			ext := strings.ToLower(filepath.Ext(header.Filename))
+			if ext != ".pdf" && ext != ".zip" {
+				documents = append(documents, UploadedDocument{
+					Filename: header.Filename,
+					Error:    "поддерживаются только PDF файлы и ZIP-архивы",
+				})
+				continue
+			}
+
+			file, err := header.Open()
+			if err != nil {
+				documents = append(documents, UploadedDocument{Filename: header.Filename, Error: "файл не получен"})
+				continue
+			}
+			if ext == ".zip" {
+				documents = append(documents, extractArchive(c, file, header.Size, header.Filename)...)
+			} else {
+				documents = append(documents, extractBatchPDF(c, file, header.Size, header.Filename))
+			}
+			file.Close()
+
+			if len(documents) > MaxBatchDocuments {
+				return nil, fmt.Errorf("в пакете не больше %d документов", MaxBatchDocuments)
+			}
+		}
+	}
+
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("файлы не получены")
+	}
+	return documents, nil
+}
+
+func extractBatchPDF(c *gin.Context, r io.Reader, size int64, filename string) UploadedDocument {
+	doc := UploadedDocument{Filename: filename}
+	if size > maxFileSize {
+		doc.Error = "размер файла не должен превышать 10MB"
+		return doc
+	}
+
+	text, err := extractUploadedPDF(c.Request.Context(), r, filename)
+	if err != nil {
+		doc.Error = err.Error()
+		return doc
+	}
+	doc.Text = text
+	return doc
+}
+
+// extractArchive извлекает PDF из ZIP-архива. Папки, служебные файлы macOS и
+// скрытые файлы пропускаются, вложенные архивы не распаковываются.
+func extractArchive(c *gin.Context, r io.ReaderAt, size int64, archiveName string) []UploadedDocument {
+	LogAction(fmt.Sprintf("Распаковка архива: %s", archiveName))
+
+	fail := func(message string) []UploadedDocument {
+		LogWarning(fmt.Sprintf("Архив %s отклонён: %s", archiveName, message))
+		return []UploadedDocument{{Filename: archiveName, Error: message}}
+	}
+
+	// ErrInsecurePath не мешает читать архив: такие записи отклоняются ниже по одной
+	archive, err := zip.NewReader(r, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return fail("не удалось открыть ZIP-архив")
+	}
+	if len(archive.File) > maxArchiveEntries {
+		return fail(fmt.Sprintf("в архиве больше %d файлов", maxArchiveEntries))
+	}
+
+	var (
+		documents []UploadedDocument
+		unpacked  uint64
+	)
+	for _, entry := range archive.File {
+		name := entry.Name
+		if entry.FileInfo().IsDir() || skipArchiveEntry(name) {
+			continue
+		}
+
+		// Имя в отчёте — путь внутри архива, чтобы одноимённые файлы из
+		// разных папок различались
+		doc := UploadedDocument{Filename: archiveName + "/" + name}
+		if !filepath.IsLocal(name) || strings.Contains(name, `\`) {
+			doc.Error = "недопустимый путь в архиве"
+			documents = append(documents, doc)
+			continue
+		}
+		if strings.ToLower(path.Ext(name)) != ".pdf" {
+			doc.Error = "поддерживаются только PDF файлы"
+			documents = append(documents, doc)
+			continue
+		}
+		if entry.UncompressedSize64 > maxFileSize {
+			doc.Error = "размер файла не должен превышать 10MB"
+			documents = append(documents, doc)
+			continue
+		}
+		if entry.CompressedSize64 > 0 && entry.UncompressedSize64/entry.CompressedSize64 > maxCompressionRatio {
+			return fail("подозрительно высокая степень сжатия")
+		}
+		if unpacked += entry.UncompressedSize64; unpacked > maxArchiveUnpacked {
+			return fail(fmt.Sprintf("распакованный архив больше %dMB", maxArchiveUnpacked>>20))
+		}
+
+		doc = extractArchiveEntry(c, entry, doc)
+		documents = append(documents, doc)
+		if len(documents) > MaxBatchDocuments {
+			break
+		}
+	}
+
+	if len(documents) == 0 {
+		return fail("в архиве нет PDF файлов")
+	}
+	return documents
+}
+
+func extractArchiveEntry(c *gin.Context, entry *zip.File, doc UploadedDocument) UploadedDocument {
+	rc, err := entry.Open()
+	if err != nil {
+		doc.Error = "не удалось распаковать файл"
+		return doc
+	}
+	defer rc.Close()
+
+	// Заголовок записи может врать о размере: читается не больше предела
+	// плюс один байт, чтобы заметить превышение
+	data, err := io.ReadAll(io.LimitReader(rc, maxFileSize+1))
+	if err != nil {
+		doc.Error = "не удалось распаковать файл"
+		return doc
+	}
+	if len(data) > maxFileSize {
+		doc.Error = "размер файла не должен превышать 10MB"
+		return doc
+	}
+
+	text, err := extractUploadedPDF(c.Request.Context(), bytes.NewReader(data), path.Base(doc.Filename))
+	if err != nil {
+		doc.Error = err.Error()
+		return doc
+	}
+	doc.Text = text
+	return doc
+}
+
+// skipArchiveEntry — служебные записи, которые архиваторы добавляют сами
+func skipArchiveEntry(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, ".") || base == "Thumbs.db"
+}
@@ -0,0 +1,343 @@
+// docx_writer.go
+
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Revision — правка текста для DOCX с режимом исправлений. Смещения в символах
+// текста; Replace означает замену [Start, End) на Replacement (пустая строка —
+// удаление), иначе к фрагменту только добавляется комментарий.
+type Revision struct {
+	Start       int
+	End         int
+	Replace     bool
+	Replacement string
+	Comment     string
+}
+
+const (
+	wordNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+	docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/comments.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.comments+xml"/>
+<Override PartName="/word/settings.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"/>
+</Types>`
+
+	docxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`
+
+	docxDocumentRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="comments.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings" Target="settings.xml"/>
+</Relationships>`
+
+	// trackRevisions оставляет режим исправлений включённым для дальнейших правок
+	docxSettings = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:settings xmlns:w="` + wordNamespace + `"><w:trackRevisions/></w:settings>`
+)
+
+// BuildRedlineDocx строит DOCX из текста документа: каждая строка — абзац,
+// правки оформлены как исправления Word (w:ins/w:del), комментарии привязаны к
+// исправленному фрагменту. Правка, выходящая за пределы абзаца, становится
+// комментарием к его концу; из пересекающихся замен остаётся первая, а
+// комментарии могут пересекаться с любыми правками и друг с другом.
+func BuildRedlineDocx(text string, revisions []Revision, author string, date time.Time) ([]byte, error) {
+	w := &redlineWriter{author: author, date: date.UTC().Format(time.RFC3339)}
+
+	sorted := append([]Revision(nil), revisions...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	w.body.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	w.body.WriteString(`<w:document xmlns:w="` + wordNamespace + `"><w:body>`)
+
+	offset, next := 0, 0
+	for _, line := range strings.Split(text, "\n") {
+		lineRunes := []rune(line)
+		lineEnd := offset + len(lineRunes)
+
+		var local []Revision
+		lastReplace := -1
+		for next < len(sorted) && sorted[next].Start <= lineEnd {
+			r := sorted[next]
+			next++
+			if r.Start < offset {
+				continue
+			}
+			if r.End > lineEnd {
+				r.End = lineEnd
+				r.Replace = false
+			}
+			r.Start -= offset
+			r.End -= offset
+			if r.Replace {
+				if r.Start < lastReplace {
+					continue
+				}
+				lastReplace = r.End
+			}
+			local = append(local, r)
+		}
+
+		w.paragraph(lineRunes, local)
+		offset = lineEnd + 1 // перенос строки
+	}
+
+	w.body.WriteString(`<w:sectPr/></w:body></w:document>`)
+
+	var comments bytes.Buffer
+	comments.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	comments.WriteString(`<w:comments xmlns:w="` + wordNamespace + `">`)
+	comments.Write(w.comments.Bytes())
+	comments.WriteString(`</w:comments>`)
+
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(docxContentTypes)},
+		{"_rels/.rels", []byte(docxRels)},
+		{"word/_rels/document.xml.rels", []byte(docxDocumentRels)},
+		{"word/settings.xml", []byte(docxSettings)},
+		{"word/document.xml", w.body.Bytes()},
+		{"word/comments.xml", comments.Bytes()},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка создания %s: %w", f.name, err)
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			return nil, fmt.Errorf("ошибка записи %s: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("ошибка упаковки DOCX: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+type redlineWriter struct {
+	author   string
+	date     string
+	body     bytes.Buffer
+	comments bytes.Buffer
+	// annotationID — общий счётчик исправлений и комментариев: Word требует,
+	// чтобы их идентификаторы не повторялись
+	annotationID int
+}
+
+// lineComment — комментарий к фрагменту абзаца [start, end)
+type lineComment struct {
+	start, end int
+	text       string
+	id         int
+}
+
+// paragraph пишет абзац с правками; смещения правок — в символах строки.
+// Замены не пересекаются друг с другом, а границы комментариев, попавшие
+// внутрь замены, сдвигаются к её краям: исправление не разрывается.
+func (w *redlineWriter) paragraph(line []rune, revisions []Revision) {
+	var replaces []Revision
+	var comments []*lineComment
+	for _, r := range revisions {
+		if r.Replace {
+			replaces = append(replaces, r)
+		}
+	}
+	for _, r := range revisions {
+		if strings.TrimSpace(r.Comment) == "" {
+			continue
+		}
+		start, end := r.Start, r.End
+		for _, e := range replaces {
+			if start > e.Start && start < e.End {
+				start = e.Start
+			}
+			if end > e.Start && end < e.End {
+				end = e.End
+			}
+		}
+		// Комментарии к одному и тому же фрагменту объединяются в один
+		merged := false
+		for _, c := range comments {
+			if c.start == start && c.end == end {
+				c.text += "\n\n" + strings.TrimSpace(r.Comment)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			comments = append(comments, &lineComment{start: start, end: end, text: strings.TrimSpace(r.Comment)})
+		}
+	}
+
+	bounds := map[int]bool{0: true, len(line): true}
+	for _, r := range replaces {
+		bounds[r.Start], bounds[r.End] = true, true
+	}
+	for _, c := range comments {
+		bounds[c.start], bounds[c.end] = true, true
+	}
+	points := make([]int, 0, len(bounds))
+	for p := range bounds {
+		points = append(points, p)
+	}
+	sort.Ints(points)
+
+	w.body.WriteString(`<w:p>`)
+	replace := 0
+	for i, pos := range points {
+		// Сначала закрываются комментарии, которые здесь заканчиваются, затем
+		// открываются новые; у пустого фрагмента начало идёт перед концом
+		for _, c := range comments {
+			if c.end == pos && c.start < pos {
+				w.commentEnd(c.id)
+			}
+		}
+		for _, c := range comments {
+			if c.start == pos {
+				c.id = w.comment(c.text)
+				fmt.Fprintf(&w.body, `<w:commentRangeStart w:id="%d"/>`, c.id)
+				if c.end == pos {
+					w.commentEnd(c.id)
+				}
+			}
+		}
+		// Вставка без удаления — замена пустого фрагмента
+		for replace < len(replaces) && replaces[replace].Start == pos && replaces[replace].End == pos {
+			w.change("", replaces[replace].Replacement)
+			replace++
+		}
+		if i == len(points)-1 {
+			break
+		}
+
+		segment := string(line[pos:points[i+1]])
+		if replace < len(replaces) && replaces[replace].Start == pos && replaces[replace].End == points[i+1] {
+			w.change(segment, replaces[replace].Replacement)
+			replace++
+		} else {
+			w.run(segment)
+		}
+	}
+	w.body.WriteString(`</w:p>`)
+}
+
+func (w *redlineWriter) commentEnd(id int) {
+	fmt.Fprintf(&w.body, `<w:commentRangeEnd w:id="%d"/><w:r><w:commentReference w:id="%d"/></w:r>`, id, id)
+}
+
+// change оформляет замену как пословные удаления и вставки, чтобы в Word были
+// видны только действительно изменённые слова
+func (w *redlineWriter) change(original, replacement string) {
+	lead := original[:len(original)-len(strings.TrimLeft(original, " "))]
+	trail := original[len(strings.TrimRight(original, " ")):]
+	w.run(lead)
+
+	// Пробел перед фрагментом нужен, только если в той же редакции перед ним уже
+	// есть слова: иначе после удаления первого слова абзац начнётся с пробела
+	var hasOld, hasNew bool
+	for _, segment := range WordDiff(original, replacement) {
+		switch segment.Op {
+		case DiffEqual:
+			switch {
+			case hasOld && hasNew:
+				w.run(" ")
+			case hasOld:
+				w.deleted(" ")
+			case hasNew:
+				w.inserted(" ")
+			}
+			w.run(segment.Text)
+			hasOld, hasNew = true, true
+		case DiffDelete:
+			if hasOld {
+				w.deleted(" " + segment.Text)
+			} else {
+				w.deleted(segment.Text)
+			}
+			hasOld = true
+		case DiffInsert:
+			if hasNew {
+				w.inserted(" " + segment.Text)
+			} else {
+				w.inserted(segment.Text)
+			}
+			hasNew = true
+		}
+	}
+	w.run(trail)
+}
+
+func (w *redlineWriter) deleted(text string) {
+	w.annotationID++
+	fmt.Fprintf(&w.body, `<w:del w:id="%d" w:author="%s" w:date="%s"><w:r><w:delText xml:space="preserve">%s</w:delText></w:r></w:del>`,
+		w.annotationID, xmlEscape(w.author), w.date, xmlEscape(text))
+}
+
+func (w *redlineWriter) inserted(text string) {
+	w.annotationID++
+	fmt.Fprintf(&w.body, `<w:ins w:id="%d" w:author="%s" w:date="%s"><w:r><w:t xml:space="preserve">%s</w:t></w:r></w:ins>`,
+		w.annotationID, xmlEscape(w.author), w.date, xmlEscape(text))
+}
+
+func (w *redlineWriter) run(text string) {
+	if text == "" {
+		return
+	}
+	fmt.Fprintf(&w.body, `<w:r><w:t xml:space="preserve">%s</w:t></w:r>`, xmlEscape(text))
+}
+
+// comment добавляет комментарий в comments.xml; абзацы комментария — по строкам текста
+func (w *redlineWriter) comment(text string) int {
+	w.annotationID++
+	id := w.annotationID
+	fmt.Fprintf(&w.comments, `<w:comment w:id="%d" w:author="%s" w:date="%s" w:initials="%s">`,
+		id, xmlEscape(w.author), w.date, xmlEscape(initials(w.author)))
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		fmt.Fprintf(&w.comments, `<w:p><w:r><w:t xml:space="preserve">%s</w:t></w:r></w:p>`, xmlEscape(line))
+	}
+	w.comments.WriteString(`</w:comment>`)
+	return id
+}
+
+func initials(author string) string {
+	r, _ := utf8.DecodeRuneInString(author)
+	if r == utf8.RuneError {
+		return ""
+	}
+	return string(r)
+}
+
+// xmlEscape экранирует текст и убирает символы, недопустимые в XML: текст из
+// PDF иногда содержит управляющие символы
+func xmlEscape(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || r >= 0x20 && r != 0xFFFE && r != 0xFFFF {
+			return r
+		}
+		return -1
+	}, text)
+	var b bytes.Buffer
+	_ = xml.EscapeText(io.Writer(&b), []byte(text))
+	return b.String()
+}
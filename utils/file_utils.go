@@ -4,6 +4,8 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/ledongthuc/pdf"
@@ -21,23 +23,35 @@ const (
 	pdfTimeout     = 30 * time.Second
 )
 
+// MaxCompareUploadSize — предел запроса с двумя редакциями документа
+const MaxCompareUploadSize = 2 * maxFileSize
+
 func ProcessUploadedFile(c *gin.Context) (string, string, error) {
-	LogAction("Начало обработки загруженного файла")
+	return ProcessUploadedField(c, "document", maxFileSize)
+}
+
+// ProcessUploadedField извлекает текст PDF из поля field формы. maxBody — предел
+// размера всего запроса, если в нём несколько файлов; каждый файл не больше 10MB.
+func ProcessUploadedField(c *gin.Context, field string, maxBody int64) (string, string, error) {
+	LogAction(fmt.Sprintf("Начало обработки загруженного файла (%s)", field))
 
 	// Ensure we don't process files that are too large
-	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize)
-	if err := c.Request.ParseMultipartForm(maxFileSize); err != nil {
-		LogError(fmt.Sprintf("Превышен максимальный размер файла (10MB): %v", err))
-		return "", "", fmt.Errorf("размер файла не должен превышать 10MB")
+	if err := ParseUploadForm(c, maxBody); err != nil {
+		return "", "", err
 	}
 
-	file, header, err := c.Request.FormFile("document")
+	file, header, err := c.Request.FormFile(field)
 	if err != nil {
 		LogError(fmt.Sprintf("Ошибка получения файла: %v", err))
 		return "", "", fmt.Errorf("файл не получен")
 	}
 	defer file.Close()
 
+	if header.Size > maxFileSize {
+		LogError(fmt.Sprintf("Превышен максимальный размер файла (10MB): %s", header.Filename))
+		return "", "", fmt.Errorf("размер файла не должен превышать 10MB")
+	}
+
 	// Validate file extension
 	ext := strings.ToLower(filepath.Ext(header.Filename))
 	if ext != ".pdf" {
@@ -45,50 +59,76 @@ func ProcessUploadedFile(c *gin.Context) (string, string, error) {
 		return "", "", fmt.Errorf("поддерживаются только PDF файлы")
 	}
 
+	text, err := extractUploadedPDF(c.Request.Context(), file, header.Filename)
+	if err != nil {
+		return "", "", err
+	}
+
+	LogSuccess(fmt.Sprintf("Успешно обработан файл: %s (символов: %d)", header.Filename, len(text)))
+	return text, header.Filename, nil
+}
+
+// extractUploadedPDF сохраняет PDF во временный файл и извлекает из него текст,
+// прерываясь, если клиент отключился
+func extractUploadedPDF(ctx context.Context, r io.Reader, filename string) (string, error) {
 	// Create temp file
-	tempPath := filepath.Join("./temp", tempFilePrefix+fmt.Sprintf("%d_%s", time.Now().Unix(), header.Filename))
+	tempPath := filepath.Join("./temp", tempFilePrefix+fmt.Sprintf("%d_%s", time.Now().UnixNano(), filepath.Base(filename)))
 	LogInfo(fmt.Sprintf("Создание временного файла: %s", tempPath))
 
 	tempFile, err := os.Create(tempPath)
 	if err != nil {
 		LogError(fmt.Sprintf("Ошибка создания временного файла: %v", err))
-		return "", "", fmt.Errorf("ошибка создания временного файла")
+		return "", fmt.Errorf("ошибка создания временного файла")
 	}
 
 	// Copy file contents
-	if _, err := io.Copy(tempFile, file); err != nil {
+	if _, err := io.Copy(tempFile, r); err != nil {
 		tempFile.Close()
+		os.Remove(tempPath)
 		LogError(fmt.Sprintf("Ошибка сохранения файла: %v", err))
-		return "", "", fmt.Errorf("ошибка сохранения файла")
+		return "", fmt.Errorf("ошибка сохранения файла")
 	}
 	tempFile.Close()
 
-	// Extract text from PDF
-	text, err := SafeExtractTextFromPDF(tempPath, pdfTimeout)
+	// Clean up temp file
+	defer func() {
+		if err := os.Remove(tempPath); err != nil {
+			LogWarning(fmt.Sprintf("Не удалось удалить временный файл: %v", err))
+		}
+	}()
+
+	text, err := SafeExtractTextFromPDF(ctx, tempPath, pdfTimeout)
 	if err != nil {
-		os.Remove(tempPath)
 		LogError(fmt.Sprintf("Ошибка извлечения текста: %v", err))
-		return "", "", fmt.Errorf("ошибка извлечения текста: %v", err)
+		return "", fmt.Errorf("ошибка извлечения текста: %v", err)
 	}
 
 	if len(text) == 0 {
-		os.Remove(tempPath)
 		LogWarning("Документ не содержит текста")
-		return "", "", fmt.Errorf("документ не содержит текста")
+		return "", fmt.Errorf("документ не содержит текста")
 	}
+	return text, nil
+}
 
-	// Clean up temp file
-	if err := os.Remove(tempPath); err != nil {
-		LogWarning(fmt.Sprintf("Не удалось удалить временный файл: %v", err))
+// ParseUploadForm разбирает multipart-форму один раз, ограничивая размер запроса
+// maxBody. Запрос без multipart не считается ошибкой: поля читаются как обычно.
+func ParseUploadForm(c *gin.Context, maxBody int64) error {
+	if c.Request.MultipartForm != nil {
+		return nil
 	}
-
-	LogSuccess(fmt.Sprintf("Успешно обработан файл: %s (символов: %d)", header.Filename, len(text)))
-	return text, header.Filename, nil
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+	err := c.Request.ParseMultipartForm(maxBody)
+	if err == nil || errors.Is(err, http.ErrNotMultipart) {
+		return nil
+	}
+	LogError(fmt.Sprintf("Превышен максимальный размер файла (%dMB): %v", maxBody>>20, err))
+	return fmt.Errorf("размер файла не должен превышать %dMB", maxBody>>20)
 }
 
-func SafeExtractTextFromPDF(path string, timeout time.Duration) (string, error) {
-	result := make(chan string)
-	errChan := make(chan error)
+func SafeExtractTextFromPDF(ctx context.Context, path string, timeout time.Duration) (string, error) {
+	// Buffered so the extraction goroutine can exit even if nobody is waiting anymore
+	result := make(chan string, 1)
+	errChan := make(chan error, 1)
 
 	go func() {
 		text, err := ExtractTextFromPDF(path)
@@ -106,6 +146,8 @@ func SafeExtractTextFromPDF(path string, timeout time.Duration) (string, error)
 		return "", err
 	case <-time.After(timeout):
 		return "", fmt.Errorf("таймаут извлечения текста (%v)", timeout)
+	case <-ctx.Done():
+		return "", fmt.Errorf("извлечение текста прервано: %w", ctx.Err())
 	}
 }
 
@@ -133,25 +175,19 @@ func ExtractTextFromPDF(path string) (string, error) {
 		return "", fmt.Errorf("файл пуст")
 	}
 
-	text = strings.Join(strings.Fields(text), " ")
+	text = normalizeWhitespace(text)
 	LogInfo(fmt.Sprintf("Извлечено %d символов из PDF", len(text)))
 	return text, nil
 }
 
-func SplitText(text string, maxChars int) []string {
-	LogAction(fmt.Sprintf("Разделение текста (макс. %d символов на часть)", maxChars))
-
-	runes := []rune(text)
-	var parts []string
-
-	for start := 0; start < len(runes); start += maxChars {
-		end := start + maxChars
-		if end > len(runes) {
-			end = len(runes)
+// normalizeWhitespace схлопывает пробелы внутри строк и пустые строки, сохраняя
+// переносы: по ним SplitDocument находит заголовки разделов и пунктов
+func normalizeWhitespace(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
 		}
-		parts = append(parts, string(runes[start:end]))
 	}
-
-	LogInfo(fmt.Sprintf("Текст разделен на %d частей", len(parts)))
-	return parts
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,232 @@
+// pii.go
+
+package utils
+
+import (
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// PIIKind — вид персональных данных
+type PIIKind string
+
+const (
+	PIIIIN    PIIKind = "IIN"
+	PIIBIN    PIIKind = "BIN"
+	PIIIBAN   PIIKind = "IBAN"
+	PIIIDCard PIIKind = "ID"
+	PIIEmail  PIIKind = "EMAIL"
+	PIIPhone  PIIKind = "PHONE"
+	PIIPerson PIIKind = "PERSON"
+)
+
+// PIIMatch — найденное значение; Start и End — смещения в байтах текста
+type PIIMatch struct {
+	Kind  PIIKind
+	Start int
+	End   int
+	Value string
+}
+
+const (
+	cyrUpper = `А-ЯЁӘҒҚҢӨҰҮҺІ`
+	cyrLower = `а-яёәғқңөұүһі`
+	// cyrWord — слово с заглавной буквы: фамилия или имя
+	cyrWord = `[` + cyrUpper + `][` + cyrLower + `]+`
+	// cyrPatronymic — отчество в любом падеже или казахское «-ұлы/-қызы»
+	cyrPatronymic = `[` + cyrUpper + `][` + cyrLower + `]+(?:(?:ович|евич|овн|евн|ичн)[` + cyrLower + `]{0,3}|ұлы|улы|қызы|кызы)`
+	cyrInitial    = `[` + cyrUpper + `]\.`
+)
+
+var (
+	// taxpayerNumberRe — ИИН или БИН слитно или группами, как их пишут в
+	// реквизитах: «880101 300123», «8801 0130 0123», «880 101 300 123»
+	taxpayerNumberRe = regexp.MustCompile(`\b(?:\d{12}|\d{6}[ \x{00A0}]\d{6}|\d{4}(?:[ \x{00A0}]\d{4}){2}|\d{3}(?:[ \x{00A0}]\d{3}){3})\b`)
+	kzIBANRe         = regexp.MustCompile(`\bKZ\d{2}(?:\s?[0-9A-Z]{4}){4}\b`)
+	emailRe          = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phoneRe          = regexp.MustCompile(`(?:\+7|\b8)[\s\-]?\(?\d{3}\)?[\s\-]?\d{3}[\s\-]?\d{2}[\s\-]?\d{2}\b`)
+	// idCardRe — номер удостоверения личности (9 цифр) или паспорта (N и 8 цифр)
+	// после названия документа: девять цифр без контекста слишком похожи на суммы
+	idCardRe   = regexp.MustCompile(`(?i)(?:удостоверени|уд\.\s*л|паспорт|жеке\s+куәлі|identity\s+card|id\s+card|passport)[^\n\d]{0,40}?(\d{9}|N\d{8})\b`)
+	passportRe = regexp.MustCompile(`\bN\d{8}\b`)
+	// personRes — ФИО с отчеством в любом порядке, фамилия с инициалами и
+	// английские имена с обращением
+	personRes = []*regexp.Regexp{
+		regexp.MustCompile(cyrWord + `\s+` + cyrWord + `\s+` + cyrPatronymic),
+		regexp.MustCompile(cyrWord + `\s+` + cyrPatronymic + `\s+` + cyrWord),
+		regexp.MustCompile(cyrWord + `\s+` + cyrInitial + `\s?` + cyrInitial),
+		regexp.MustCompile(cyrInitial + `\s?` + cyrInitial + `\s?` + cyrWord),
+		regexp.MustCompile(`\b(?:Mr|Mrs|Ms|Dr)\.?\s+[A-Z][a-z]+(?:\s+[A-Z][a-z]+)?`),
+	}
+)
+
+// DetectPII находит персональные данные: ИИН и БИН, в том числе записанные
+// группами цифр (с проверкой контрольного разряда), казахстанские IBAN (с
+// проверкой по модулю 97), номера удостоверений личности и паспортов, email,
+// телефоны и ФИО. Пересекающиеся совпадения
+// не возвращаются: остаётся то, что начинается раньше, а при равном начале — более длинное.
+func DetectPII(text string) []PIIMatch {
+	var matches []PIIMatch
+	add := func(kind PIIKind, start, end int) {
+		matches = append(matches, PIIMatch{Kind: kind, Start: start, End: end, Value: text[start:end]})
+	}
+
+	for _, loc := range taxpayerNumberRe.FindAllStringIndex(text, -1) {
+		number := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\u00a0' {
+				return -1
+			}
+			return r
+		}, text[loc[0]:loc[1]])
+		// Группы цифр внутри более длинного числа вроде «1 000 000 000 000» — не номер
+		if len(number) < loc[1]-loc[0] && digitGroupAround(text, loc[0], loc[1]) {
+			continue
+		}
+		if !ValidTaxpayerNumber(number) {
+			continue
+		}
+		// У БИН пятая цифра — тип организации (4–6), у ИИН это первая цифра дня рождения (0–3)
+		if number[4] >= '4' && number[4] <= '6' {
+			add(PIIBIN, loc[0], loc[1])
+		} else {
+			add(PIIIIN, loc[0], loc[1])
+		}
+	}
+	for _, loc := range kzIBANRe.FindAllStringIndex(text, -1) {
+		if validIBAN(text[loc[0]:loc[1]]) {
+			add(PIIIBAN, loc[0], loc[1])
+		}
+	}
+	for _, loc := range emailRe.FindAllStringIndex(text, -1) {
+		add(PIIEmail, loc[0], loc[1])
+	}
+	for _, loc := range phoneRe.FindAllStringIndex(text, -1) {
+		add(PIIPhone, loc[0], loc[1])
+	}
+	for _, loc := range idCardRe.FindAllStringSubmatchIndex(text, -1) {
+		add(PIIIDCard, loc[2], loc[3])
+	}
+	for _, loc := range passportRe.FindAllStringIndex(text, -1) {
+		add(PIIIDCard, loc[0], loc[1])
+	}
+	for _, re := range personRes {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			add(PIIPerson, loc[0], loc[1])
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+	out := matches[:0]
+	end := 0
+	for _, m := range matches {
+		if m.Start < end {
+			continue
+		}
+		out = append(out, m)
+		end = m.End
+	}
+	return out
+}
+
+// digitGroupAround сообщает, что перед start или после end через пробел стоит
+// ещё одна группа цифр
+func digitGroupAround(text string, start, end int) bool {
+	before := strings.TrimRight(text[:start], " \u00a0")
+	if len(before) < start && before != "" && isASCIIDigit(before[len(before)-1]) {
+		return true
+	}
+	after := strings.TrimLeft(text[end:], " \u00a0")
+	return len(after) < len(text)-end && after != "" && isASCIIDigit(after[0])
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// ValidTaxpayerNumber проверяет контрольный разряд ИИН или БИН: взвешенная сумма
+// первых 11 цифр по модулю 11 с весами 1–11, а если получилось 10 — с весами
+// 3–11, 1, 2; повторное 10 означает, что номер не выдаётся
+func ValidTaxpayerNumber(number string) bool {
+	if len(number) != 12 {
+		return false
+	}
+	digits := make([]int, 12)
+	for i, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+		digits[i] = int(r - '0')
+	}
+
+	control := func(shift int) int {
+		sum := 0
+		for i := 0; i < 11; i++ {
+			sum += digits[i] * ((i+shift)%11 + 1)
+		}
+		return sum % 11
+	}
+	c := control(0)
+	if c == 10 {
+		c = control(2)
+		if c == 10 {
+			return false
+		}
+	}
+	return c == digits[11]
+}
+
+// validIBAN проверяет контрольные цифры IBAN по модулю 97 (ISO 13616)
+func validIBAN(iban string) bool {
+	iban = strings.ReplaceAll(iban, " ", "")
+	if len(iban) < 5 {
+		return false
+	}
+	var b strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			b.WriteString(big.NewInt(int64(r - 'A' + 10)).String())
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(b.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// MaskPII заменяет буквы и цифры найденных персональных данных звёздочками.
+// Длина текста в символах не меняется, поэтому смещения частей и цитат,
+// посчитанные по исходному тексту, остаются верными.
+func MaskPII(text string) string {
+	matches := DetectPII(text)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	b.Grow(len(text))
+	pos := 0
+	for _, m := range matches {
+		b.WriteString(text[pos:m.Start])
+		for _, r := range m.Value {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				b.WriteRune('*')
+			} else {
+				b.WriteRune(r)
+			}
+		}
+		pos = m.End
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
@@ -0,0 +1,327 @@
+// text_diff.go
+
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// minNumberedSimilarity — сходство, начиная с которого пункты с одинаковым
+	// номером считаются одним пунктом в разных редакциях
+	minNumberedSimilarity = 0.3
+	// minClauseSimilarity — то же для пунктов, у которых номер изменился или его нет
+	minClauseSimilarity = 0.5
+	// maxDiffCells ограничивает таблицу LCS пословного сравнения; для больших
+	// фрагментов отличающаяся середина помечается целиком
+	maxDiffCells = 4_000_000
+)
+
+var clauseNumberRe = regexp.MustCompile(`(?i)^(?:(статья|пункт|раздел|глава|приложение|тармақ|бөлім|тарау|қосымша)\s*№?\s*)?(\d{1,3}(?:\.\d{1,3})*)`)
+
+// Clause — структурный элемент документа (раздел, статья, пункт) для сравнения
+// редакций. Смещения указаны в символах исходного текста.
+type Clause struct {
+	Index int `json:"index"`
+	// Number — номер элемента вида «5.2» или «статья 7»; пусто для преамбулы
+	Number  string `json:"number,omitempty"`
+	Heading string `json:"heading,omitempty"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Text    string `json:"text"`
+}
+
+// SplitClauses делит текст на элементы по тем же заголовкам, что и SplitDocument,
+// но без объединения их в части
+func SplitClauses(text string) []Clause {
+	var clauses []Clause
+	for _, block := range structureBlocks(text) {
+		start, end := trimRange(text, block.start, block.end)
+		if start >= end {
+			continue
+		}
+		clause := Clause{
+			Index:   len(clauses),
+			Number:  clauseNumber(block.heading),
+			Heading: block.heading,
+			Start:   utf8.RuneCountInString(text[:start]),
+			Text:    text[start:end],
+		}
+		clause.End = clause.Start + utf8.RuneCountInString(clause.Text)
+		clauses = append(clauses, clause)
+	}
+	return clauses
+}
+
+func clauseNumber(heading string) string {
+	m := clauseNumberRe.FindStringSubmatch(heading)
+	if m == nil {
+		return ""
+	}
+	if m[1] != "" {
+		return strings.ToLower(m[1]) + " " + m[2]
+	}
+	return m[2]
+}
+
+// ClausePair — сопоставление элемента старой редакции элементу новой.
+// Old или New равен -1, если элемент удалён или добавлен.
+type ClausePair struct {
+	Old        int
+	New        int
+	Similarity float64
+}
+
+// AlignClauses сопоставляет элементы двух редакций: сначала совпадающие по тексту
+// (в том числе перенесённые), затем с тем же номером, затем самые похожие по
+// словам. Текст из PDF приходит без исходной разметки, поэтому построчное
+// сравнение не годится. Пары возвращаются в порядке новой редакции, удалённые
+// элементы — после предшествовавшего им элемента старой редакции.
+func AlignClauses(original, revised []Clause) []ClausePair {
+	oldMatch := make([]int, len(original))
+	newMatch := make([]int, len(revised))
+	for i := range oldMatch {
+		oldMatch[i] = -1
+	}
+	for j := range newMatch {
+		newMatch[j] = -1
+	}
+	similarity := make(map[[2]int]float64)
+	match := func(i, j int, sim float64) {
+		oldMatch[i], newMatch[j] = j, i
+		similarity[[2]int{i, j}] = sim
+	}
+
+	oldWords := make([]map[string]int, len(original))
+	newWords := make([]map[string]int, len(revised))
+	byText := make(map[string][]int)
+	for i, c := range original {
+		oldWords[i] = wordCounts(clauseBody(c))
+		key := normalizedText(clauseBody(c))
+		byText[key] = append(byText[key], i)
+	}
+	for j, c := range revised {
+		newWords[j] = wordCounts(clauseBody(c))
+		key := normalizedText(clauseBody(c))
+		if candidates := byText[key]; len(candidates) > 0 {
+			match(candidates[0], j, 1)
+			byText[key] = candidates[1:]
+		}
+	}
+
+	byNumber := make(map[string]int)
+	for i, c := range original {
+		if c.Number != "" && oldMatch[i] < 0 {
+			byNumber[c.Number] = i
+		}
+	}
+	for j, c := range revised {
+		if c.Number == "" || newMatch[j] >= 0 {
+			continue
+		}
+		if i, ok := byNumber[c.Number]; ok && oldMatch[i] < 0 {
+			if sim := diceSimilarity(oldWords[i], newWords[j]); sim >= minNumberedSimilarity {
+				match(i, j, sim)
+			}
+		}
+	}
+
+	type candidate struct {
+		i, j int
+		sim  float64
+	}
+	var candidates []candidate
+	for i := range original {
+		if oldMatch[i] >= 0 {
+			continue
+		}
+		for j := range revised {
+			if newMatch[j] >= 0 {
+				continue
+			}
+			if sim := diceSimilarity(oldWords[i], newWords[j]); sim >= minClauseSimilarity {
+				candidates = append(candidates, candidate{i, j, sim})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].sim > candidates[b].sim })
+	for _, c := range candidates {
+		if oldMatch[c.i] < 0 && newMatch[c.j] < 0 {
+			match(c.i, c.j, c.sim)
+		}
+	}
+
+	var pairs []ClausePair
+	emitted := 0
+	emitRemoved := func(upTo int) {
+		for ; emitted < upTo; emitted++ {
+			if oldMatch[emitted] < 0 {
+				pairs = append(pairs, ClausePair{Old: emitted, New: -1})
+			}
+		}
+	}
+	for j := range revised {
+		i := newMatch[j]
+		if i < 0 {
+			pairs = append(pairs, ClausePair{Old: -1, New: j})
+			continue
+		}
+		emitRemoved(i)
+		pairs = append(pairs, ClausePair{Old: i, New: j, Similarity: similarity[[2]int{i, j}]})
+	}
+	emitRemoved(len(original))
+	return pairs
+}
+
+// clauseBody — текст элемента без номера: перенумерация не должна мешать сопоставлению
+func clauseBody(c Clause) string {
+	if m := clauseNumberRe.FindStringIndex(c.Text); m != nil {
+		return c.Text[m[1]:]
+	}
+	return c.Text
+}
+
+// normalizedText приводит текст к виду, в котором различия только в пробелах,
+// регистре и знаках препинания не считаются изменением
+func normalizedText(text string) string {
+	return strings.Join(words(text), " ")
+}
+
+// SameText сообщает, что тексты совпадают с точностью до пробелов, регистра и пунктуации
+func SameText(a, b string) bool {
+	return normalizedText(a) == normalizedText(b)
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func wordCounts(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, w := range words(text) {
+		counts[w]++
+	}
+	return counts
+}
+
+// diceSimilarity — коэффициент Сёренсена — Дайса по мультимножествам слов
+func diceSimilarity(a, b map[string]int) float64 {
+	var total, common int
+	for w, n := range a {
+		total += n
+		common += min(n, b[w])
+	}
+	for _, n := range b {
+		total += n
+	}
+	if total == 0 {
+		return 1
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+// TextSimilarity оценивает сходство текстов по словам от 0 до 1
+func TextSimilarity(a, b string) float64 {
+	return diceSimilarity(wordCounts(a), wordCounts(b))
+}
+
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"
+	DiffInsert DiffOp = "insert"
+	DiffDelete DiffOp = "delete"
+)
+
+// DiffSegment — фрагмент пословного сравнения
+type DiffSegment struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// WordDiff сравнивает тексты по словам (наибольшая общая подпоследовательность).
+// Слова сравниваются без учёта регистра, в результат попадают слова новой
+// редакции для equal и insert и старой — для delete.
+func WordDiff(a, b string) []DiffSegment {
+	oldWords, newWords := strings.Fields(a), strings.Fields(b)
+	equal := func(i, j int) bool {
+		return strings.EqualFold(oldWords[i], newWords[j])
+	}
+
+	var segments []DiffSegment
+	add := func(op DiffOp, w string) {
+		if n := len(segments); n > 0 && segments[n-1].Op == op {
+			segments[n-1].Text += " " + w
+			return
+		}
+		segments = append(segments, DiffSegment{Op: op, Text: w})
+	}
+
+	// Общие начало и конец не участвуют в LCS
+	prefix := 0
+	for prefix < len(oldWords) && prefix < len(newWords) && equal(prefix, prefix) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldWords)-prefix && suffix < len(newWords)-prefix &&
+		equal(len(oldWords)-1-suffix, len(newWords)-1-suffix) {
+		suffix++
+	}
+
+	for _, w := range newWords[:prefix] {
+		add(DiffEqual, w)
+	}
+
+	oldMid := oldWords[prefix : len(oldWords)-suffix]
+	newMid := newWords[prefix : len(newWords)-suffix]
+	if len(oldMid)*len(newMid) > maxDiffCells {
+		for _, w := range oldMid {
+			add(DiffDelete, w)
+		}
+		for _, w := range newMid {
+			add(DiffInsert, w)
+		}
+	} else {
+		n, m := len(oldMid), len(newMid)
+		// lcs[i][j] — длина LCS суффиксов oldMid[i:] и newMid[j:]
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if equal(prefix+i, prefix+j) {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && equal(prefix+i, prefix+j):
+				add(DiffEqual, newMid[j])
+				i++
+				j++
+			case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+				add(DiffDelete, oldMid[i])
+				i++
+			default:
+				add(DiffInsert, newMid[j])
+				j++
+			}
+		}
+	}
+
+	for _, w := range newWords[len(newWords)-suffix:] {
+		add(DiffEqual, w)
+	}
+	return segments
+}
@@ -0,0 +1,401 @@
+// text_splitter.go
+
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Уровни структурных границ: чем меньше, тем крупнее элемент документа
+const (
+	levelPreamble = 0
+	levelSection  = 1 // раздел, глава, приложение
+	levelArticle  = 2 // статья
+	levelClause   = 3 // пункт, нумерованный пункт 1., 1.2., 1.2.3.
+	levelText     = 9 // продолжение слишком длинного элемента
+)
+
+var (
+	annexRe   = regexp.MustCompile(`(?i)^(приложение|қосымша)(\s|№|$)`)
+	sectionRe = regexp.MustCompile(`(?i)^(раздел|глава|бөлім|тарау)(\s|$)`)
+	articleRe = regexp.MustCompile(`(?i)^(статья\s*\d|\d+[-‑–]?\s*бап)`)
+	punktRe   = regexp.MustCompile(`(?i)^(пункт|тармақ)\s*\d`)
+	// Только «1.» и «1.2.»: «1)» — подпункт перечня внутри пункта, а не новый пункт
+	clauseRe = regexp.MustCompile(`^(\d{1,3}(?:\.\d{1,3})*)\.\s`)
+)
+
+// TextChunk — часть документа для отдельного запроса к модели.
+// Смещения указаны в символах исходного текста.
+type TextChunk struct {
+	Index int `json:"index"`
+	// Heading — ближайший структурный заголовок, с которого начинается часть
+	Heading string `json:"heading,omitempty"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Tokens  int    `json:"tokens"`
+	Text    string `json:"-"`
+	// Context — конец предыдущей части для связности; анализируется только Text
+	Context      string `json:"-"`
+	ContextStart int    `json:"context_start,omitempty"`
+}
+
+// SplitOptions задаёт бюджет части в токенах модели и размер перекрытия
+type SplitOptions struct {
+	MaxTokens     int
+	OverlapTokens int
+	// CountTokens оценивает число токенов; по умолчанию — два символа на токен
+	CountTokens func(string) int
+}
+
+type textBlock struct {
+	start, end int // байтовые смещения
+	level      int
+	heading    string
+}
+
+// SplitDocument делит текст на части по границам разделов, статей, пунктов и
+// приложений так, чтобы каждая часть укладывалась в MaxTokens. Элемент, который
+// сам не помещается в бюджет, делится по абзацам, затем по предложениям.
+// Новый раздел или приложение по возможности начинает новую часть.
+func SplitDocument(text string, opts SplitOptions) []TextChunk {
+	count := opts.CountTokens
+	if count == nil {
+		count = func(s string) int { return utf8.RuneCountInString(s)/2 + 1 }
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = 4000
+	}
+
+	LogAction(fmt.Sprintf("Разделение текста (до %d токенов на часть, перекрытие %d)", opts.MaxTokens, opts.OverlapTokens))
+
+	var pieces []textBlock
+	for _, block := range structureBlocks(text) {
+		if count(text[block.start:block.end]) <= opts.MaxTokens {
+			pieces = append(pieces, block)
+			continue
+		}
+		for i, r := range splitOversized(text, block.start, block.end, opts.MaxTokens, count, 0) {
+			piece := textBlock{start: r[0], end: r[1], level: levelText, heading: block.heading}
+			if i == 0 {
+				piece.level = block.level
+			}
+			pieces = append(pieces, piece)
+		}
+	}
+
+	var ranges []textBlock
+	var current *textBlock
+	tokens := 0
+	for _, piece := range pieces {
+		pieceTokens := count(text[piece.start:piece.end])
+		if current != nil {
+			full := tokens+pieceTokens > opts.MaxTokens
+			newSection := piece.level == levelSection && tokens >= opts.MaxTokens/2
+			if full || newSection {
+				ranges = append(ranges, *current)
+				current = nil
+			}
+		}
+		if current == nil {
+			p := piece
+			current = &p
+			tokens = 0
+		}
+		current.end = piece.end
+		tokens += pieceTokens
+	}
+	if current != nil {
+		ranges = append(ranges, *current)
+	}
+
+	chunks := make([]TextChunk, 0, len(ranges))
+	prevStart, prevEnd := -1, -1
+	for _, r := range ranges {
+		start, end := trimRange(text, r.start, r.end)
+		if start >= end {
+			continue
+		}
+		chunk := TextChunk{
+			Index:   len(chunks),
+			Heading: r.heading,
+			Start:   utf8.RuneCountInString(text[:start]),
+			Text:    text[start:end],
+		}
+		chunk.End = chunk.Start + utf8.RuneCountInString(chunk.Text)
+		chunk.Tokens = count(chunk.Text)
+
+		if prevStart >= 0 && opts.OverlapTokens > 0 {
+			from := tailStart(text, prevStart, prevEnd, opts.OverlapTokens, count)
+			if from < prevEnd {
+				chunk.Context = text[from:prevEnd]
+				chunk.ContextStart = utf8.RuneCountInString(text[:from])
+			}
+		}
+
+		chunks = append(chunks, chunk)
+		prevStart, prevEnd = start, end
+	}
+
+	LogInfo(fmt.Sprintf("Текст разделен на %d частей", len(chunks)))
+	return chunks
+}
+
+// structureBlocks разбивает текст на блоки, каждый из которых начинается со
+// структурного заголовка (кроме, возможно, преамбулы). Заголовок ищется в начале
+// строки, а также после конца предложения: текст из PDF часто приходит без переносов.
+func structureBlocks(text string) []textBlock {
+	var blocks []textBlock
+	current := textBlock{start: 0, level: levelPreamble}
+
+	candidates := boundaryCandidates(text)
+	for k, at := range candidates {
+		lineEnd := len(text)
+		if i := strings.IndexByte(text[at.pos:], '\n'); i >= 0 {
+			lineEnd = at.pos + i
+		}
+		// Заголовок внутри строки заканчивается вместе с предложением
+		if at.inline && k+1 < len(candidates) && candidates[k+1].pos < lineEnd {
+			lineEnd = candidates[k+1].pos
+		}
+		line := strings.TrimSpace(text[at.pos:lineEnd])
+
+		level, ok := headingLevel(line, at.inline)
+		if !ok {
+			continue
+		}
+		if at.pos > current.start {
+			current.end = at.pos
+			blocks = append(blocks, current)
+			current = textBlock{start: at.pos}
+		}
+		current.level = level
+		current.heading = shortHeading(line)
+	}
+
+	current.end = len(text)
+	if current.end > current.start {
+		blocks = append(blocks, current)
+	}
+	return blocks
+}
+
+type boundary struct {
+	pos    int
+	inline bool
+}
+
+// boundaryCandidates возвращает по возрастанию начала строк и позиции после
+// конца предложения, где может начинаться заголовок
+func boundaryCandidates(text string) []boundary {
+	var out []boundary
+	lineStart := true
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\n':
+			lineStart = true
+		case c == ' ' || c == '\t' || c == '\r':
+		case lineStart:
+			out = append(out, boundary{pos: i})
+			lineStart = false
+		case strings.IndexByte(".;:!?", c) >= 0:
+			if c == '.' && endsWithNumber(text[:i]) {
+				// «2.1. Цена» — точка после номера пункта не завершает предложение
+				continue
+			}
+			j := i + 1
+			for j < len(text) && (text[j] == ' ' || text[j] == '\t') {
+				j++
+			}
+			if j > i+1 && j < len(text) && text[j] != '\n' && text[j] != '\r' {
+				out = append(out, boundary{pos: j, inline: true})
+				i = j - 1
+			}
+		}
+	}
+	return out
+}
+
+// endsWithNumber сообщает, что текст заканчивается номером вида «2» или «2.1»
+func endsWithNumber(text string) bool {
+	i := len(text)
+	for i > 0 && (text[i-1] >= '0' && text[i-1] <= '9' || text[i-1] == '.') {
+		i--
+	}
+	if i == len(text) || text[i] == '.' {
+		return false
+	}
+	return i == 0 || text[i-1] == ' ' || text[i-1] == '\n' || text[i-1] == '\t'
+}
+
+// headingLevel определяет уровень заголовка в начале строки. Внутри строки
+// «Приложение» и «Раздел» считаются заголовком только с заглавной буквы,
+// одиночный номер «1.» — пунктом, а не разделом, а за номером пункта должна
+// идти заглавная буква.
+func headingLevel(line string, inline bool) (int, bool) {
+	switch {
+	case line == "":
+		return 0, false
+	case inline && !startsUpper(line):
+		return 0, false
+	case annexRe.MatchString(line), sectionRe.MatchString(line):
+		return levelSection, true
+	case articleRe.MatchString(line):
+		return levelArticle, true
+	case punktRe.MatchString(line):
+		return levelClause, true
+	}
+	if m := clauseRe.FindStringSubmatch(line); m != nil {
+		// Внутри строки «п. 1.2. настоящего договора» — ссылка, а не новый пункт
+		if inline && !startsUpper(strings.TrimSpace(line[len(m[0]):])) {
+			return 0, false
+		}
+		// «1.» — обычно раздел договора, «1.2.» и глубже — пункты
+		if !inline && !strings.Contains(m[1], ".") {
+			return levelSection, true
+		}
+		return levelClause, true
+	}
+	return 0, false
+}
+
+func startsUpper(line string) bool {
+	r, _ := utf8.DecodeRuneInString(line)
+	return unicode.IsUpper(r) || unicode.IsDigit(r)
+}
+
+func shortHeading(line string) string {
+	const maxHeading = 80
+	if utf8.RuneCountInString(line) <= maxHeading {
+		return line
+	}
+	return string([]rune(line)[:maxHeading]) + "…"
+}
+
+// Разделители для слишком длинных блоков, от крупных к мелким
+var oversizedSeparators = []string{"\n\n", "\n", ". ", "; ", ", "}
+
+// splitOversized делит диапазон [start, end) на куски не больше maxTokens,
+// сначала по абзацам, затем по строкам и предложениям, в крайнем случае — по символам
+func splitOversized(text string, start, end, maxTokens int, count func(string) int, sep int) [][2]int {
+	if count(text[start:end]) <= maxTokens {
+		return [][2]int{{start, end}}
+	}
+	if sep >= len(oversizedSeparators) {
+		return splitByRunes(text, start, end, maxTokens, count)
+	}
+
+	separator := oversizedSeparators[sep]
+	var segments [][2]int
+	for from := start; from < end; {
+		i := strings.Index(text[from:end], separator)
+		to := end
+		if i >= 0 {
+			to = from + i + len(separator)
+		}
+		segments = append(segments, [2]int{from, to})
+		from = to
+	}
+
+	var out [][2]int
+	curStart, curEnd := -1, -1
+	for _, s := range segments {
+		if curStart >= 0 && count(text[curStart:s[1]]) <= maxTokens {
+			curEnd = s[1]
+			continue
+		}
+		if curStart >= 0 {
+			out = append(out, [2]int{curStart, curEnd})
+			curStart = -1
+		}
+		if count(text[s[0]:s[1]]) > maxTokens {
+			out = append(out, splitOversized(text, s[0], s[1], maxTokens, count, sep+1)...)
+			continue
+		}
+		curStart, curEnd = s[0], s[1]
+	}
+	if curStart >= 0 {
+		out = append(out, [2]int{curStart, curEnd})
+	}
+	return out
+}
+
+func splitByRunes(text string, start, end, maxTokens int, count func(string) int) [][2]int {
+	var out [][2]int
+	for start < end {
+		// Бинарный поиск самого длинного префикса, укладывающегося в бюджет
+		lo, hi := start, end
+		for lo < hi {
+			mid := lo + (hi-lo+1)/2
+			for mid < hi && !utf8.RuneStart(text[mid]) {
+				mid++
+			}
+			if count(text[start:mid]) <= maxTokens {
+				lo = mid
+			} else {
+				hi = mid - 1
+				for hi > start && !utf8.RuneStart(text[hi]) {
+					hi--
+				}
+			}
+		}
+		if lo == start {
+			_, size := utf8.DecodeRuneInString(text[start:end])
+			lo = start + size
+		}
+		out = append(out, [2]int{start, lo})
+		start = lo
+	}
+	return out
+}
+
+// tailStart возвращает начало хвоста [start, end), укладывающегося в budget токенов.
+// Хвост по возможности начинается с новой строки или предложения.
+func tailStart(text string, start, end, budget int, count func(string) int) int {
+	if count(text[start:end]) <= budget {
+		return start
+	}
+
+	var boundaries, runes []int
+	for i := start + 1; i < end; i++ {
+		if !utf8.RuneStart(text[i]) {
+			continue
+		}
+		runes = append(runes, i)
+		prev := text[i-1]
+		if prev == '\n' || (prev == ' ' && i-2 >= start && strings.IndexByte(".;!?", text[i-2]) >= 0) {
+			boundaries = append(boundaries, i)
+		}
+	}
+
+	fits := func(positions []int) int {
+		// Хвост тем короче, чем дальше его начало, поэтому первое подходящее ищется бинарно
+		i := sort.Search(len(positions), func(k int) bool {
+			return count(text[positions[k]:end]) <= budget
+		})
+		if i < len(positions) {
+			return positions[i]
+		}
+		return end
+	}
+
+	if from := fits(boundaries); from < end {
+		return from
+	}
+	return fits(runes)
+}
+
+func trimRange(text string, start, end int) (int, int) {
+	for start < end && strings.ContainsRune(" \t\r\n", rune(text[start])) {
+		start++
+	}
+	for end > start && strings.ContainsRune(" \t\r\n", rune(text[end-1])) {
+		end--
+	}
+	return start, end
+}